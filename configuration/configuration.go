@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	multierror "github.com/hashicorp/go-multierror"
 	log "github.com/macarrie/flemzerd/logging"
-	"github.com/macarrie/flemzerd/vidocq"
+	"github.com/macarrie/flemzerd/naming"
+	. "github.com/macarrie/flemzerd/objects"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"golang.org/x/sys/unix"
@@ -90,6 +93,7 @@ type Configuration struct {
 		UseSSL       bool   `mapstructure:"use_ssl"`
 		SSLCert      string `mapstructure:"ssl_cert"`
 		SSLServerKey string `mapstructure:"ssl_server_key"`
+		WebhookToken string `mapstructure:"webhook_token"`
 	}
 	Providers     map[string]map[string]string
 	Indexers      map[string][]map[string]interface{}
@@ -106,25 +110,56 @@ type Configuration struct {
 		NotifyFailure          bool `mapstructure:"notify_failure"`
 	}
 	System struct {
-		CheckInterval                int    `mapstructure:"check_interval"`
-		HealthcheckInterval          int    `mapstructure:"healthcheck_interval"`
-		TorrentDownloadAttemptsLimit int    `mapstructure:"torrent_download_attempts_limit"`
-		TrackShows                   bool   `mapstructure:"track_shows"`
-		TrackMovies                  bool   `mapstructure:"track_movies"`
-		AutomaticShowDownload        bool   `mapstructure:"automatic_show_download"`
-		AutomaticMovieDownload       bool   `mapstructure:"automatic_movie_download"`
-		ShowDownloadDelay            int    `mapstructure:"show_download_delay"`
-		MovieDownloadDelay           int    `mapstructure:"movie_download_delay"`
-		PreferredMediaQuality        string `mapstructure:"preferred_media_quality"`
-		ExcludedReleaseTypes         string `mapstructure:"excluded_release_types"`
-		StrictTorrentCheck           bool   `mapstructure:"strict_torrent_check"`
+		CheckInterval                int     `mapstructure:"check_interval"`
+		HealthcheckInterval          int     `mapstructure:"healthcheck_interval"`
+		TorrentDownloadAttemptsLimit int     `mapstructure:"torrent_download_attempts_limit"`
+		TrackShows                   bool    `mapstructure:"track_shows"`
+		TrackMovies                  bool    `mapstructure:"track_movies"`
+		AutomaticShowDownload        bool    `mapstructure:"automatic_show_download"`
+		AutomaticMovieDownload       bool    `mapstructure:"automatic_movie_download"`
+		ShowDownloadDelay            int     `mapstructure:"show_download_delay"`
+		MovieDownloadDelay           int     `mapstructure:"movie_download_delay"`
+		PreferredMediaQuality        string  `mapstructure:"preferred_media_quality"`
+		ExcludedReleaseTypes         string  `mapstructure:"excluded_release_types"`
+		StrictTorrentCheck           bool    `mapstructure:"strict_torrent_check"`
+		PreferredReleaseGroups       string  `mapstructure:"preferred_release_groups"`
+		AllowedReleaseGroups         string  `mapstructure:"allowed_release_groups"`
+		BlockedReleaseGroups         string  `mapstructure:"blocked_release_groups"`
+		RequiredWords                string  `mapstructure:"required_words"`
+		IgnoredWords                 string  `mapstructure:"ignored_words"`
+		AutomaticUpgrades            bool    `mapstructure:"automatic_upgrades"`
+		UpgradeCheckInterval         int     `mapstructure:"upgrade_check_interval"`
+		DownloadSeasonPacks          bool    `mapstructure:"download_season_packs"`
+		BackfillLimit                int     `mapstructure:"backfill_limit"`
+		DownloadCheckInterval        int     `mapstructure:"download_check_interval"`
+		MaxActiveDownloads           int     `mapstructure:"max_active_downloads"`
+		StallNoProgressTimeout       int     `mapstructure:"stall_no_progress_timeout"`
+		StallNoPeersTimeout          int     `mapstructure:"stall_no_peers_timeout"`
+		StallMaxEta                  int     `mapstructure:"stall_max_eta"`
+		SeedRatio                    float64 `mapstructure:"seed_ratio"`
+		SeedTime                     int     `mapstructure:"seed_time"`
 	}
 	Library struct {
-		ShowPath      string `mapstructure:"show_path"`
-		MoviePath     string `mapstructure:"movie_path"`
-		CustomTmpPath string `mapstructure:"custom_tmp_path"`
-	}
-	Version string
+		ShowPath       string `mapstructure:"show_path"`
+		MoviePath      string `mapstructure:"movie_path"`
+		CustomTmpPath  string `mapstructure:"custom_tmp_path"`
+		ImportMode     string `mapstructure:"import_mode"`
+		ExtraFilesPath string `mapstructure:"extra_files_path"`
+		VerifyMedia    bool   `mapstructure:"verify_media"`
+		// Interval between library scans, in hours. 0 disables scheduled scans
+		ScanInterval int `mapstructure:"scan_interval"`
+	}
+	// Templates used to name library folders and files
+	Naming naming.Patterns `mapstructure:"naming"`
+	// Torrent size limits for each quality, in MB per minute of runtime
+	SizeLimits map[string]SizeLimit `mapstructure:"size_limits"`
+	Version    string
+}
+
+// SizeLimit defines minimum and maximum torrent size, in MB per minute of media runtime. 0 means no limit
+type SizeLimit struct {
+	Min float64 `mapstructure:"min"`
+	Max float64 `mapstructure:"max"`
 }
 
 func setDefaultValues() {
@@ -146,6 +181,17 @@ func setDefaultValues() {
 	viper.SetDefault("library.show_path", "/var/lib/flemzerd/library/shows")
 	viper.SetDefault("library.movie_path", "/var/lib/flemzerd/library/movies")
 	viper.SetDefault("library.custom_tmp_path", "/var/lib/flemzerd/tmp")
+	viper.SetDefault("library.import_mode", IMPORT_MOVE)
+	viper.SetDefault("library.extra_files_path", "")
+	viper.SetDefault("library.verify_media", false)
+	viper.SetDefault("library.scan_interval", 12)
+
+	viper.SetDefault("naming.movie_folder", naming.DefaultPatterns.MovieFolder)
+	viper.SetDefault("naming.movie_file", naming.DefaultPatterns.MovieFile)
+	viper.SetDefault("naming.show_folder", naming.DefaultPatterns.ShowFolder)
+	viper.SetDefault("naming.season_folder", naming.DefaultPatterns.SeasonFolder)
+	viper.SetDefault("naming.episode_folder", naming.DefaultPatterns.EpisodeFolder)
+	viper.SetDefault("naming.episode_file", naming.DefaultPatterns.EpisodeFile)
 
 	viper.SetDefault("system.check_interval", 15)
 	viper.SetDefault("system.healthcheck_interval", 5)
@@ -159,6 +205,33 @@ func setDefaultValues() {
 	viper.SetDefault("system.preferred_media_quality", "720p")
 	viper.SetDefault("system.excluded_release_types", "cam,screener,telesync,telecine")
 	viper.SetDefault("system.strict_torrent_check", true)
+	viper.SetDefault("system.preferred_release_groups", "")
+	viper.SetDefault("system.allowed_release_groups", "")
+	viper.SetDefault("system.blocked_release_groups", "")
+	viper.SetDefault("system.required_words", "")
+	viper.SetDefault("system.ignored_words", "")
+	viper.SetDefault("size_limits.480p.min", 1)
+	viper.SetDefault("size_limits.480p.max", 25)
+	viper.SetDefault("size_limits.576p.min", 1)
+	viper.SetDefault("size_limits.576p.max", 30)
+	viper.SetDefault("size_limits.720p.min", 2)
+	viper.SetDefault("size_limits.720p.max", 60)
+	viper.SetDefault("size_limits.1080p.min", 4)
+	viper.SetDefault("size_limits.1080p.max", 130)
+	viper.SetDefault("size_limits.2160p.min", 10)
+	viper.SetDefault("size_limits.2160p.max", 400)
+
+	viper.SetDefault("system.automatic_upgrades", false)
+	viper.SetDefault("system.upgrade_check_interval", 24)
+	viper.SetDefault("system.download_season_packs", true)
+	viper.SetDefault("system.backfill_limit", 5)
+	viper.SetDefault("system.download_check_interval", 20)
+	viper.SetDefault("system.max_active_downloads", 0)
+	viper.SetDefault("system.stall_no_progress_timeout", 120)
+	viper.SetDefault("system.stall_no_peers_timeout", 60)
+	viper.SetDefault("system.stall_max_eta", 0)
+	viper.SetDefault("system.seed_ratio", 0)
+	viper.SetDefault("system.seed_time", 0)
 }
 
 func UseFile(filePath string) {
@@ -234,6 +307,66 @@ func Check() error {
 		}
 	}
 
+	for name, downloader := range Config.Downloaders {
+		for _, key := range []string{"priority", "min_size", "max_size", "max_active_downloads"} {
+			value, ok := downloader[key]
+			if !ok {
+				continue
+			}
+			if _, err := strconv.Atoi(value); err != nil {
+				configError = ConfigurationError{
+					Status:  WARNING,
+					Message: "Downloader setting must be an integer. Value will be ignored",
+					Key:     fmt.Sprintf("downloaders.%s.%s", name, key),
+					Value:   value,
+				}
+				log.WithFields(log.Fields{
+					"error": configError,
+				}).Warning("Configuration warning")
+				errorList = multierror.Append(errorList, configError)
+			}
+		}
+
+		for _, mediaType := range strings.Split(downloader["media_types"], ",") {
+			mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+			if mediaType != "" && mediaType != "movie" && mediaType != "episode" {
+				configError = ConfigurationError{
+					Status:  WARNING,
+					Message: "Unknown downloader media type. Supported media types are movie and episode",
+					Key:     fmt.Sprintf("downloaders.%s.media_types", name),
+					Value:   downloader["media_types"],
+				}
+				log.WithFields(log.Fields{
+					"error": configError,
+				}).Warning("Configuration warning")
+				errorList = multierror.Append(errorList, configError)
+			}
+		}
+	}
+
+	for indexerType, indexerList := range Config.Indexers {
+		for _, indexer := range indexerList {
+			for _, key := range []string{"seed_ratio", "seed_time"} {
+				value, ok := indexer[key]
+				if !ok {
+					continue
+				}
+				if _, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64); err != nil {
+					configError = ConfigurationError{
+						Status:  WARNING,
+						Message: "Indexer seeding goal must be a number. System seeding goal will be used",
+						Key:     fmt.Sprintf("indexers.%s.%v.%s", indexerType, indexer["name"], key),
+						Value:   fmt.Sprintf("%v", value),
+					}
+					log.WithFields(log.Fields{
+						"error": configError,
+					}).Warning("Configuration warning")
+					errorList = multierror.Append(errorList, configError)
+				}
+			}
+		}
+	}
+
 	if len(Config.Notifiers) == 0 && Config.Notifications.Enabled {
 		configError = ConfigurationError{
 			Status:  WARNING,
@@ -335,6 +468,40 @@ func Check() error {
 		errorList = multierror.Append(errorList, configError)
 	}
 
+	switch Config.Library.ImportMode {
+	case IMPORT_MOVE, IMPORT_COPY, IMPORT_HARDLINK, IMPORT_SYMLINK:
+	default:
+		configError = ConfigurationError{
+			Status:  WARNING,
+			Message: "Unknown import mode. Supported import modes are move, copy, hardlink and symlink. Downloaded files will be moved into library",
+			Key:     "library.import_mode",
+			Value:   Config.Library.ImportMode,
+		}
+		log.WithFields(log.Fields{
+			"error": configError,
+		}).Warning("Configuration warning")
+		errorList = multierror.Append(errorList, configError)
+	}
+
+	namingProblems := Config.Naming.Validate()
+	var namingKeys []string
+	for key := range namingProblems {
+		namingKeys = append(namingKeys, key)
+	}
+	sort.Strings(namingKeys)
+	for _, key := range namingKeys {
+		configError = ConfigurationError{
+			Status:  WARNING,
+			Message: fmt.Sprintf("Invalid naming template (%s). Default template will be used", namingProblems[key]),
+			Key:     fmt.Sprintf("naming.%s", key),
+			Value:   viper.GetString(fmt.Sprintf("naming.%s", key)),
+		}
+		log.WithFields(log.Fields{
+			"error": configError,
+		}).Warning("Configuration warning")
+		errorList = multierror.Append(errorList, configError)
+	}
+
 	_, kodi := Config.MediaCenters["kodi"]
 	if kodi {
 		_, kodiAddress := Config.MediaCenters["kodi"]["address"]
@@ -513,16 +680,40 @@ func Check() error {
 		}
 	}
 
-	vidocq.CheckVidocq()
-	if !vidocq.LocalVidocqAvailable {
-		configError = ConfigurationError{
-			Status:  CRITICAL,
-			Message: "Vidocq has not been found on the server or could not be correctly executed. Torrent search will encounter issues, especially if strict torrent checking is enabled",
+	for quality, limit := range Config.SizeLimits {
+		if limit.Min > 0 && limit.Max > 0 && limit.Min > limit.Max {
+			configError = ConfigurationError{
+				Status:  WARNING,
+				Message: "Minimum size is greater than maximum size. No size filter will be done for this quality",
+				Key:     fmt.Sprintf("size_limits.%s", quality),
+				Value:   fmt.Sprintf("min=%v, max=%v", limit.Min, limit.Max),
+			}
+			log.WithFields(log.Fields{
+				"error": configError,
+			}).Warning("Configuration warning")
+			errorList = multierror.Append(errorList, configError)
+
+			delete(Config.SizeLimits, quality)
+		}
+	}
+
+	wordLists := map[string]string{
+		"system.required_words": Config.System.RequiredWords,
+		"system.ignored_words":  Config.System.IgnoredWords,
+	}
+	for key, words := range wordLists {
+		for _, problem := range (ReleaseFilters{RequiredWords: words}).Check() {
+			configError = ConfigurationError{
+				Status:  WARNING,
+				Message: problem + ". Expression will be ignored when filtering torrents",
+				Key:     key,
+				Value:   words,
+			}
+			log.WithFields(log.Fields{
+				"error": configError,
+			}).Warning("Configuration warning")
+			errorList = multierror.Append(errorList, configError)
 		}
-		log.WithFields(log.Fields{
-			"error": configError,
-		}).Error("Configuration error")
-		errorList = multierror.Append(errorList, configError)
 	}
 
 	if Config.Interface.Enabled && Config.Interface.Auth.Username == "admin" && Config.Interface.Auth.Password == "flemzerd" {
@@ -35,7 +35,7 @@ func TestCheck(t *testing.T) {
 		FilePath       string
 	}{
 		{
-			6,
+			5,
 			"../testdata/test_config.toml",
 		},
 		{
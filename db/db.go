@@ -29,7 +29,7 @@ var DbFatal func(code int) = os.Exit
 
 // InitDb initializes and migrates database tables
 func InitDb() {
-	Client.AutoMigrate(&SessionData{}, &TvShow{}, &TvSeason{}, &Episode{}, &Movie{}, &MediaIds{}, &Torrent{}, &DownloadingItem{}, &Notification{})
+	Client.AutoMigrate(&SessionData{}, &TvShow{}, &TvSeason{}, &Episode{}, &Movie{}, &MediaIds{}, &Torrent{}, &DownloadingItem{}, &Notification{}, &QualityProfile{}, &ReplacedRelease{}, &TorrentScore{}, &SeedingTorrent{}, &ScannedFile{})
 }
 
 // Reset DB tables to an empty state. Mainly used in test suite.
@@ -42,6 +42,11 @@ func ResetDb() {
 	Client.DropTable(&Torrent{})
 	Client.DropTable(&DownloadingItem{})
 	Client.DropTable(&Notification{})
+	Client.DropTable(&QualityProfile{})
+	Client.DropTable(&ReplacedRelease{})
+	Client.DropTable(&TorrentScore{})
+	Client.DropTable(&SeedingTorrent{})
+	Client.DropTable(&ScannedFile{})
 	InitDb()
 }
 
@@ -237,6 +242,16 @@ func GetUnreadNotifications() ([]Notification, error) {
 	return notifs, nil
 }
 
+// Gets quality profiles from database, ordered by name
+func GetQualityProfiles() ([]QualityProfile, error) {
+	var profiles []QualityProfile
+	if err := Client.Order("name").Find(&profiles).Error; err != nil {
+		return []QualityProfile{}, err
+	}
+
+	return profiles, nil
+}
+
 // Saves given token as Trakt token in database
 func SaveTraktToken(token string) {
 	Session.TraktToken = token
@@ -249,6 +264,12 @@ func SaveTelegramChatID(chat_id int64) {
 	Client.Save(&Session)
 }
 
+// Saves paused state of download queue in database
+func SaveQueuePaused(paused bool) {
+	Session.QueuePaused = paused
+	Client.Save(&Session)
+}
+
 func SaveDownloadable(d *downloadable.Downloadable) {
 	switch (*d).(type) {
 	case *Movie:
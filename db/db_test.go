@@ -295,3 +295,37 @@ func TestSaveDownloadable(t *testing.T) {
 		t.Error("Expected episode downloading item to be saved during SaveDownloadable")
 	}
 }
+
+func TestTorrentScoreBreakdown(t *testing.T) {
+	ResetDb()
+	movie := Movie{
+		Title: "movie_with_scored_torrents",
+		DownloadingItem: DownloadingItem{
+			TorrentList: []Torrent{
+				Torrent{
+					Name:  "torrent",
+					Score: 30,
+					ScoreBreakdown: []TorrentScore{
+						TorrentScore{Factor: "seeders", Score: 10},
+						TorrentScore{Factor: "quality", Score: 20},
+					},
+				},
+			},
+		},
+	}
+	Client.Create(&movie)
+
+	var movieFromDb Movie
+	Client.Find(&movieFromDb, movie.ID)
+	torrent := movieFromDb.DownloadingItem.CurrentTorrent()
+	if len(torrent.ScoreBreakdown) != 2 {
+		t.Errorf("Expected torrent score breakdown to have 2 factors, got %d instead", len(torrent.ScoreBreakdown))
+	}
+
+	Client.Unscoped().Delete(&torrent)
+	var count int
+	Client.Model(&TorrentScore{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected score breakdown to be deleted with torrent, got %d scores instead", count)
+	}
+}
@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/rar"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+// ExtractionError is returned when archives of a downloaded release cannot be extracted, or do not contain any video file
+type ExtractionError struct {
+	Archive string
+	Err     error
+}
+
+func (e *ExtractionError) Error() string {
+	return fmt.Sprintf("archive extraction failed (%s): %s", e.Archive, e.Err)
+}
+
+// getReleaseArchives returns first volumes of RAR archives found in release downloaded in path
+func getReleaseArchives(path string) ([]string, error) {
+	var archives []string
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && rar.IsFirstVolume(info.Name()) {
+			archives = append(archives, filePath)
+		}
+		return nil
+	})
+
+	return archives, err
+}
+
+// extractArchives extracts RAR archives of release t next to their volumes, so that extracted files are imported with the release.
+// Extracted release must contain a video file. Archive volumes are removed after extraction, unless downloaded data is kept by import mode (seeding, copies or links)
+func extractArchives(t Torrent) error {
+	archives, err := getReleaseArchives(t.DownloadDir)
+	if err != nil {
+		return errors.Wrap(err, "could not search archives in release")
+	}
+	if len(archives) == 0 {
+		return nil
+	}
+
+	for _, archive := range archives {
+		files, err := rar.Extract(archive, filepath.Dir(archive))
+		if err != nil {
+			return &ExtractionError{
+				Archive: filepath.Base(archive),
+				Err:     err,
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"archive": filepath.Base(archive),
+			"files":   len(files),
+		}).Info("Release archive extracted")
+
+		if getImportMode(hasSeedingGoals(t)) != IMPORT_MOVE {
+			continue
+		}
+		for _, volume := range rar.Volumes(archive) {
+			if err := os.Remove(volume); err != nil {
+				log.WithFields(log.Fields{
+					"volume": volume,
+					"error":  err,
+				}).Warning("Could not remove extracted archive volume")
+			}
+		}
+	}
+
+	files, err := listReleaseFiles(t.DownloadDir)
+	if err != nil {
+		return errors.Wrap(err, "could not list extracted files")
+	}
+	if len(files.videos) == 0 {
+		return &ExtractionError{
+			Archive: t.Name,
+			Err:     errors.New("no video file found in extracted files"),
+		}
+	}
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +15,7 @@ import (
 	"github.com/macarrie/flemzerd/notifiers"
 	. "github.com/macarrie/flemzerd/objects"
 	"github.com/macarrie/flemzerd/stats"
+	"github.com/macarrie/flemzerd/vidocq"
 
 	"github.com/macarrie/flemzerd/downloadable"
 
@@ -70,37 +70,138 @@ func Reset() {
 	downloadersCollection = []Downloader{}
 }
 
-func AddTorrent(t Torrent) (string, error) {
+// getDownloader returns the first registered downloader handling the protocol of torrent t
+func getDownloader(t Torrent) (Downloader, error) {
+	for _, d := range downloadersCollection {
+		if getDownloaderProtocol(d) == t.GetProtocol() {
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No downloader configured for %s protocol", t.GetProtocol())
+}
+
+// SupportsProtocol returns true if at least one registered downloader can download releases using protocol
+func SupportsProtocol(protocol string) bool {
+	for _, d := range downloadersCollection {
+		if getDownloaderProtocol(d) == protocol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getTorrentDownloader returns the downloader holding a torrent. Items added before downloaders were recorded fall back to the first downloader handling the torrent protocol
+func getTorrentDownloader(downloaderName string, t Torrent) (Downloader, error) {
+	if downloaderName == "" {
+		return getDownloader(t)
+	}
+
+	return GetDownloader(downloaderName)
+}
+
+// AddTorrent sends torrent t downloaded for media d to the downloader with highest priority among downloaders matching routing rules.
+// If the downloader cannot add the torrent, the next healthy downloader is used instead.
+// Name of the downloader that accepted the torrent is returned along with the torrent ID in this downloader
+func AddTorrent(d downloadable.Downloadable, t Torrent) (string, string, error) {
 	if len(downloadersCollection) == 0 {
-		return "", errors.New("Cannot add torrents, no downloaders are configured")
+		return "", "", errors.New("Cannot add torrents, no downloaders are configured")
 	}
 
-	id, err := downloadersCollection[0].AddTorrent(t)
-	if err != nil {
-		return "", errors.Wrap(err, "cannot add torrent in downloader")
+	candidates := getDownloaders(d, t)
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("No downloader configured for %s protocol matches routing rules", t.GetProtocol())
+	}
+
+	var queueKey string
+	if d != nil {
+		queueKey = getQueueKey(d)
 	}
 
-	return id, nil
+	var errorList *multierror.Error
+	for index, dl := range candidates {
+		queue.Lock()
+		freeSlot := queue.hasFreeSlot(dl.GetName(), queueKey)
+		queue.Unlock()
+		if !freeSlot {
+			log.WithFields(log.Fields{
+				"downloader": dl.GetName(),
+			}).Debug("Maximum number of active downloads reached in downloader, skipping this downloader")
+			errorList = multierror.Append(errorList, fmt.Errorf("maximum number of active downloads reached in %s", dl.GetName()))
+			continue
+		}
+
+		if index > 0 {
+			if _, err := dl.Status(); err != nil {
+				log.WithFields(log.Fields{
+					"downloader": dl.GetName(),
+					"error":      err,
+				}).Debug("Downloader is not alive, skipping failover to this downloader")
+				errorList = multierror.Append(errorList, err)
+				continue
+			}
+		}
+
+		id, err := dl.AddTorrent(t)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"downloader": dl.GetName(),
+				"torrent":    t.Name,
+				"error":      err,
+			}).Warning("Cannot add torrent in downloader, trying next downloader")
+			errorList = multierror.Append(errorList, errors.Wrapf(err, "cannot add torrent in %s", dl.GetName()))
+			continue
+		}
+
+		if d != nil {
+			queue.setDownloader(d, dl.GetName())
+		}
+
+		return dl.GetName(), id, nil
+	}
+
+	return "", "", errors.Wrap(errorList.ErrorOrNil(), "cannot add torrent in downloader")
 }
 
-func AddTorrentMapping(flemzerId string, downloaderId string) {
-	downloadersCollection[0].AddTorrentMapping(flemzerId, downloaderId)
+func AddTorrentMapping(downloaderName string, t Torrent, downloaderId string) {
+	d, err := getTorrentDownloader(downloaderName, t)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"torrent": t.Name,
+			"error":   err,
+		}).Warning("Cannot recover torrent mapping")
+		return
+	}
+
+	d.AddTorrentMapping(t.TorrentId, downloaderId)
 }
 
 func StartTorrent(t Torrent) error {
 	return nil
 }
 
-func RemoveTorrent(t Torrent) error {
+func RemoveTorrent(downloaderName string, t Torrent) error {
 	if len(downloadersCollection) == 0 {
 		return errors.New("Cannot remove torrents, no downloaders are configured")
 	}
 
-	return downloadersCollection[0].RemoveTorrent(t)
+	d, err := getTorrentDownloader(downloaderName, t)
+	if err != nil {
+		return err
+	}
+
+	return d.RemoveTorrent(t)
 }
 
-func GetTorrentStatus(t *Torrent) error {
-	return downloadersCollection[0].GetTorrentStatus(t)
+func GetTorrentStatus(downloaderName string, t *Torrent) error {
+	d, err := getTorrentDownloader(downloaderName, *t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	return d.GetTorrentStatus(t)
 }
 
 func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable, torrent *Torrent) (err error, aborted bool, torrentSkipped bool) {
@@ -109,10 +210,10 @@ func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable
 
 	// If current downloader ID is set, we are recovering a download process and not adding torrent (it already has been added in download client)
 	if downloadingItem.CurrentDownloaderId == "" {
-		torrentId, err := AddTorrent(*torrent)
+		downloaderName, torrentId, err := AddTorrent(*d, *torrent)
 		if err != nil {
-			RemoveTorrent(*torrent)
 			torrent.Failed = true
+			torrent.FailureReason = err.Error()
 			db.Client.Save(torrent)
 			(*d).SetDownloadingItem(downloadingItem)
 			db.SaveDownloadable(d)
@@ -121,6 +222,12 @@ func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable
 		}
 
 		downloadingItem.CurrentDownloaderId = torrentId
+		downloadingItem.CurrentDownloader = downloaderName
+
+		(*d).GetLog().WithFields(log.Fields{
+			"downloader": downloaderName,
+			"torrent":    torrent.Name,
+		}).Debug("Torrent added in downloader")
 	}
 
 	(*d).SetDownloadingItem(downloadingItem)
@@ -128,12 +235,14 @@ func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable
 
 	_ = StartTorrent(*torrent)
 
-	downloadErr, downloadAborted, torrentSkipped := WaitForDownload(ctxStore, torrent)
+	downloadErr, downloadAborted, torrentSkipped := WaitForDownload(ctxStore, downloadingItem.CurrentDownloader, torrent)
 	if downloadAborted || torrentSkipped {
-		return nil, downloadAborted, torrentSkipped
+		// Error describes why torrent was skipped when torrent was not skipped manually
+		return downloadErr, downloadAborted, torrentSkipped
 	}
 	if downloadErr != nil {
-		if err := RemoveTorrent(*torrent); err != nil {
+		torrent.FailureReason = downloadErr.Error()
+		if err := RemoveTorrent(downloadingItem.CurrentDownloader, *torrent); err != nil {
 			log.WithFields(log.Fields{
 				"torrent": torrent.Name,
 				"error":   err,
@@ -154,37 +263,70 @@ func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable
 
 	// If function has not returned yet, download ended with no errors !
 	(*d).GetLog().Info("Item successfully downloaded")
-	notifier.NotifyDownloadedItem(*d)
 
 	downloadingItem.Pending = false
 	downloadingItem.Downloading = false
 	downloadingItem.Downloaded = true
-
-	// Delete all torrents but downloaded one to avoid crowding the db
-	currentTorrent := downloadingItem.CurrentTorrent()
-	for _, torrent := range downloadingItem.TorrentList {
-		if torrent.ID == currentTorrent.ID {
-			continue
-		}
-		db.Client.Unscoped().Delete(&torrent)
-	}
-	downloadingItem.TorrentList = []Torrent{currentTorrent}
+	downloadingItem.ImportFailed = false
+	downloadingItem.ImportFailureReason = ""
 
 	(*d).SetDownloadingItem(downloadingItem)
 	db.SaveDownloadable(d)
 
+	temporaryPath := torrent.DownloadDir
 	err = MoveItemToLibrary(*d)
+
+	// Releases rejected by media verification are removed, and next torrent is downloaded
+	var verificationErr *VerificationError
+	if errors.As(err, &verificationErr) {
+		return rejectDownloadedRelease(d, downloadingItem.CurrentDownloader, torrent, temporaryPath, verificationErr), false, false
+	}
+	// Episodes of the season pack have been imported, but the episode it was downloaded for is downloaded with next torrent
+	if errors.Is(err, errEpisodeNotInSeasonPack) {
+		return rejectSeasonPack(d, downloadingItem.CurrentDownloader, torrent, temporaryPath), false, false
+	}
+
+	notifier.NotifyDownloadedItem(*d)
 	if err != nil {
 		(*d).GetLog().WithFields(log.Fields{
 			"temporary_path": downloadingItem.CurrentTorrent().DownloadDir,
 			"library_path":   configuration.Config.Library.MoviePath,
 			"error":          err,
 		}).Error("Could not move item from temporary download path to library folder")
+
+		// Release already in library is kept if upgraded release could not be moved into library
+		if downloadingItem.Upgrading {
+			RestoreUpgradedRelease(*d)
+		} else {
+			setImportFailed(*d, err)
+		}
+		// Downloaded data is kept when seeding ends, as it is the only copy of the item
+		temporaryPath = ""
 	} else {
 		mediacenter.RefreshLibrary()
 	}
+	// Symlinked library files point to temporary download folder
+	if getImportMode(true) == IMPORT_SYMLINK {
+		temporaryPath = ""
+	}
 
-	if err := RemoveTorrent(*torrent); err != nil {
+	// Delete all torrents but downloaded one to avoid crowding the db
+	downloadingItem = (*d).GetDownloadingItem()
+	currentTorrent := downloadingItem.CurrentTorrent()
+	for _, torrent := range downloadingItem.TorrentList {
+		if torrent.ID == currentTorrent.ID {
+			continue
+		}
+		db.Client.Unscoped().Delete(&torrent)
+	}
+	downloadingItem.TorrentList = []Torrent{currentTorrent}
+
+	(*d).SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(d)
+
+	if hasSeedingGoals(*torrent) {
+		startSeeding(downloadingItem.CurrentDownloader, downloadingItem.CurrentDownloaderId, *torrent, temporaryPath)
+	} else if err := RemoveTorrent(downloadingItem.CurrentDownloader, *torrent); err != nil {
 		log.WithFields(log.Fields{
 			"torrent": torrent.Name,
 			"error":   err,
@@ -202,35 +344,46 @@ func HandleTorrentDownload(ctxStore ContextStorage, d *downloadable.Downloadable
 	return nil, false, false
 }
 
-func WaitForDownload(ctxStore ContextStorage, t *Torrent) (err error, aborted bool, torrentSkipped bool) {
-	downloadLoopTicker := time.NewTicker(20 * time.Second)
-	for {
-		log.WithFields(log.Fields{
-			"torrent": t.Name,
-		}).Debug("Checking torrent download progress")
-
-		err := GetTorrentStatus(t)
-		if err != nil {
-			return errors.Wrap(err, "error when getting download status"), false, false
-		}
-		db.Client.Save(t)
-		status := t.Status
-
-		switch status {
-		case TORRENT_STOPPED:
-			return errors.New("Torrent stopped in download client"), false, false
-		case TORRENT_SEEDING:
-			// Download complete ! Return with no error
-			return nil, false, false
-		}
+// WaitForDownload waits for torrent t to be downloaded. Torrent status is retrieved by the download monitor
+func WaitForDownload(ctxStore ContextStorage, downloaderName string, t *Torrent) (err error, aborted bool, torrentSkipped bool) {
+	watcher, err := monitor.watch(downloaderName, *t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.Wrap(err, "error when getting download status"), false, false
+	}
+	defer monitor.unwatch(watcher)
 
+	stall := newStallTracker(time.Now())
+	for {
 		select {
 		case <-ctxStore.SkipTorrent:
+			t.FailureReason = "Torrent skipped manually"
 			return nil, false, true
-		case <-downloadLoopTicker.C:
-			continue
 		case <-ctxStore.Context.Done():
 			return nil, true, false
+		case update := <-watcher.updates:
+			*t = update.Torrent
+			if update.Err != nil {
+				return errors.Wrap(update.Err, "error when getting download status"), false, false
+			}
+
+			switch t.Status {
+			case TORRENT_STOPPED:
+				db.Client.Save(t)
+				return errors.New("Torrent stopped in download client"), false, false
+			case TORRENT_SEEDING:
+				db.Client.Save(t)
+				// Download complete ! Return with no error
+				return nil, false, false
+			}
+
+			// Stalled torrents are skipped like manually skipped torrents
+			if reason := stall.check(*t, time.Now()); reason != "" {
+				t.FailureReason = reason
+				db.Client.Save(t)
+				return errors.New(reason), false, true
+			}
+			db.Client.Save(t)
 		}
 	}
 }
@@ -289,7 +442,7 @@ func Download(d downloadable.Downloadable) error {
 	downloadRoutinesMutex.Unlock()
 
 	if recovery {
-		AddTorrentMapping(downloadingItem.CurrentTorrent().TorrentId, downloadingItem.CurrentDownloaderId)
+		AddTorrentMapping(downloadingItem.CurrentDownloader, downloadingItem.CurrentTorrent(), downloadingItem.CurrentDownloaderId)
 	}
 
 	for index, _ := range downloadingItem.TorrentList {
@@ -308,11 +461,21 @@ func Download(d downloadable.Downloadable) error {
 		var torrentSkipped bool
 
 		torrentDownloadError, downloadAborted, torrentSkipped = HandleTorrentDownload(ctxStore, &d, torrent)
+		// Downloader holding the torrent is recorded by HandleTorrentDownload
+		downloaderName := d.GetDownloadingItem().CurrentDownloader
 		if torrentSkipped {
-			d.GetLog().Info("Torrent download skipped. Cleaning up current download artifacts.")
+			if torrentDownloadError != nil {
+				d.GetLog().WithFields(log.Fields{
+					"torrent": torrent.Name,
+					"reason":  torrent.FailureReason,
+				}).Warning("Torrent download stalled. Cleaning up current download artifacts and switching to next torrent.")
+				notifier.NotifyStalledDownload(d, *torrent)
+			} else {
+				d.GetLog().Info("Torrent download skipped. Cleaning up current download artifacts.")
+			}
 
 			currentDownloadPath := downloadingItem.CurrentTorrent().DownloadDir
-			if err := RemoveTorrent(downloadingItem.CurrentTorrent()); err != nil {
+			if err := RemoveTorrent(downloaderName, downloadingItem.CurrentTorrent()); err != nil {
 				log.WithFields(log.Fields{
 					"torrent": downloadingItem.CurrentTorrent().Name,
 					"error":   err,
@@ -330,16 +493,43 @@ func Download(d downloadable.Downloadable) error {
 
 			// Reset current downloader id to avoid entering in recovery mode when trying to download next torrent
 			downloadingItem.CurrentDownloaderId = ""
+			downloadingItem.CurrentDownloader = ""
 			d.SetDownloadingItem(downloadingItem)
 			db.SaveDownloadable(&d)
 
+			if torrentDownloadError != nil && (len(downloadingItem.FailedTorrents()) > configuration.Config.System.TorrentDownloadAttemptsLimit || len(downloadingItem.FailedTorrents()) >= len(downloadingItem.TorrentList)) {
+				MarkDownloadAsFailed(d)
+				return errors.New("Download failed, no torrents could be downloaded")
+			}
+
 			continue
 		}
 		if downloadAborted {
 			d.GetLog().Info("Download manually aborted. Cleaning up current download artifacts.")
 
+			if downloadingItem.Upgrading {
+				currentDownloadPath := downloadingItem.CurrentTorrent().DownloadDir
+				if err := RemoveTorrent(downloaderName, downloadingItem.CurrentTorrent()); err != nil {
+					log.WithFields(log.Fields{
+						"torrent": downloadingItem.CurrentTorrent().Name,
+						"error":   err,
+					}).Error("Could not remove torrent from downloader when aborting upgrade")
+				}
+				if err := os.Remove(currentDownloadPath); err != nil {
+					log.WithFields(log.Fields{
+						"path":  currentDownloadPath,
+						"error": err,
+					}).Error("Could not remove downloaded data for media when aborting upgrade")
+				}
+
+				d.SetDownloadingItem(downloadingItem)
+				RestoreUpgradedRelease(d)
+
+				return nil
+			}
+
 			currentDownloadPath := downloadingItem.CurrentTorrent().DownloadDir
-			if err := RemoveTorrent(downloadingItem.CurrentTorrent()); err != nil {
+			if err := RemoveTorrent(downloaderName, downloadingItem.CurrentTorrent()); err != nil {
 				log.WithFields(log.Fields{
 					"torrent": downloadingItem.CurrentTorrent().Name,
 					"error":   err,
@@ -366,6 +556,7 @@ func Download(d downloadable.Downloadable) error {
 			}).Warning("Couldn't download torrent. Skipping to next torrent in list")
 
 			downloadingItem.CurrentDownloaderId = ""
+			downloadingItem.CurrentDownloader = ""
 			d.SetDownloadingItem(downloadingItem)
 			db.SaveDownloadable(&d)
 		} else {
@@ -386,6 +577,7 @@ func Download(d downloadable.Downloadable) error {
 
 func AbortDownload(d downloadable.Downloadable) {
 	d.GetLog().Info("Aborting item download")
+	queue.remove(d)
 	downloadingItem := d.GetDownloadingItem()
 	downloadRoutinesStruct := getDownloadRoutinesStruct(d)
 
@@ -400,6 +592,12 @@ func AbortDownload(d downloadable.Downloadable) {
 		downloadRoutinesMutex.Unlock()
 	}
 
+	// Aborted upgrades go back to the release already in library instead of resetting download state
+	if downloadingItem.Upgrading {
+		RestoreUpgradedRelease(d)
+		return
+	}
+
 	downloadingItem.Pending = false
 	downloadingItem.Downloading = false
 	downloadingItem.Downloaded = false
@@ -417,6 +615,7 @@ func AbortDownload(d downloadable.Downloadable) {
 
 func SkipTorrent(d downloadable.Downloadable) {
 	d.GetLog().Info("Skipping current torrent download")
+	queue.remove(d)
 	downloadingItem := d.GetDownloadingItem()
 	downloadRoutinesStruct := getDownloadRoutinesStruct(d)
 
@@ -447,11 +646,17 @@ func SkipTorrent(d downloadable.Downloadable) {
 }
 
 func MarkDownloadAsFailed(d downloadable.Downloadable) {
+	downloadingItem := d.GetDownloadingItem()
+	if downloadingItem.Upgrading {
+		d.GetLog().Warning("Upgrade failed, no better release could be downloaded. Keeping release already in library")
+		RestoreUpgradedRelease(d)
+		return
+	}
+
 	d.GetLog().Error("Download failed, no torrents could be downloaded")
 
 	notifier.NotifyFailedDownload(d)
 
-	downloadingItem := d.GetDownloadingItem()
 	downloadingItem.DownloadFailed = true
 	downloadingItem.Downloading = false
 
@@ -465,25 +670,49 @@ func MarkDownloadAsFailed(d downloadable.Downloadable) {
 	db.SaveDownloadable(&d)
 }
 
-func sanitizeStringForFilename(src string) string {
-	reg, _ := regexp.Compile("[^a-z0-9]+")
-	return reg.ReplaceAllString(strings.ToLower(src), "_")
-}
-
 func MoveItemToLibrary(d downloadable.Downloadable) error {
 	downloadingItem := d.GetDownloadingItem()
 
 	var libraryPath string
 	var destinationPath string
+	var fileName string
+	// Numbers of episodes contained in multi-episode releases
+	var episodeNumbers []int
+
+	// Archived releases are extracted in temporary folder before import
+	if err := extractArchives(downloadingItem.CurrentTorrent()); err != nil {
+		return err
+	}
+
+	if episode, ok := d.(*Episode); ok && downloadingItem.CurrentTorrent().SeasonPack {
+		episode.GetLog().WithFields(log.Fields{
+			"temporary_path": downloadingItem.CurrentTorrent().DownloadDir,
+			"library_path":   configuration.Config.Library.ShowPath,
+		}).Debug("Moving season pack episodes to library")
+
+		if err := moveSeasonPackToLibrary(episode, &downloadingItem); err != nil {
+			return err
+		}
+
+		d.SetDownloadingItem(downloadingItem)
+		db.SaveDownloadable(&d)
+		return nil
+	}
 
 	switch d.(type) {
 	case *Movie:
 		libraryPath = configuration.Config.Library.MoviePath
-		destinationPath = fmt.Sprintf("%s/%s", configuration.Config.Library.MoviePath, sanitizeStringForFilename(d.GetTitle()))
+		destinationPath, fileName = getMovieLibraryPath(*d.(*Movie), downloadingItem.CurrentTorrent())
 	case *Episode:
 		libraryPath = configuration.Config.Library.ShowPath
 		episode := *d.(*Episode)
-		destinationPath = fmt.Sprintf("%s/%s/season_%d/s%02de%02d", configuration.Config.Library.ShowPath, sanitizeStringForFilename(episode.TvShow.GetTitle()), episode.Season, episode.Season, episode.Number)
+		mediaInfo := vidocq.Parse(downloadingItem.CurrentTorrent().Name)
+		if mediaInfo.Season == episode.Season && mediaInfo.IsMultiEpisode() && mediaInfo.CoversEpisode(episode.Number) {
+			episodeNumbers = mediaInfo.EpisodeNumbers()
+			destinationPath, fileName = getEpisodeLibraryPath(episode, downloadingItem.CurrentTorrent(), mediaInfo.Episode, mediaInfo.LastEpisode)
+		} else {
+			destinationPath, fileName = getEpisodeLibraryPath(episode, downloadingItem.CurrentTorrent(), episode.Number, 0)
+		}
 	}
 
 	d.GetLog().WithFields(log.Fields{
@@ -491,29 +720,132 @@ func MoveItemToLibrary(d downloadable.Downloadable) error {
 		"library_path":   libraryPath,
 	}).Debug("Moving item to library")
 
+	// Downloaded release is verified before import, so that a rejected release never replaces the release already in library
+	if configuration.Config.Library.VerifyMedia {
+		episodes := 1
+		if len(episodeNumbers) > 0 {
+			episodes = len(episodeNumbers)
+		}
+
+		mediaProbe, err := verifyReleaseMedia(d, downloadingItem.CurrentTorrent(), episodes)
+		if err != nil {
+			return err
+		}
+		downloadingItem.Probe = mediaProbe
+	}
+
 	err := os.MkdirAll(destinationPath, 0755)
 	if err != nil {
 		return errors.Wrap(err, "Could not create library folder for item")
 	}
 
-	target := fmt.Sprintf("%s/%s", destinationPath, downloadingItem.CurrentTorrent().Name)
-	err = os.Rename(downloadingItem.CurrentTorrent().DownloadDir, target)
+	target, err := importRelease(downloadingItem.CurrentTorrent(), destinationPath, fileName)
 	if err != nil {
 		return errors.Wrap(err, "Could not move item to library")
 	}
 
 	currentTorrent := downloadingItem.CurrentTorrent()
 	currentTorrent.DownloadDir = destinationPath
+	currentTorrent.LibraryPath = target
 	db.Client.Save(&currentTorrent)
+	for i := range downloadingItem.TorrentList {
+		if downloadingItem.TorrentList[i].ID == currentTorrent.ID {
+			downloadingItem.TorrentList[i].DownloadDir = currentTorrent.DownloadDir
+			downloadingItem.TorrentList[i].LibraryPath = currentTorrent.LibraryPath
+		}
+	}
+
+	if downloadingItem.Upgrading {
+		replaceUpgradedRelease(d, &downloadingItem, libraryPath, target)
+	}
+
+	d.SetDownloadingItem(downloadingItem)
 	db.SaveDownloadable(&d)
 
+	if len(episodeNumbers) > 0 {
+		markCoveredEpisodesDownloaded(*d.(*Episode), currentTorrent, episodeNumbers)
+	}
+
 	return nil
 }
 
+// replaceUpgradedRelease removes the release replaced by an upgrade from library and keeps track of it in replaced releases history
+func replaceUpgradedRelease(d downloadable.Downloadable, downloadingItem *DownloadingItem, libraryPath string, newReleasePath string) {
+	var oldTorrent Torrent
+	if !db.Client.Unscoped().Find(&oldTorrent, downloadingItem.UpgradedTorrentID).RecordNotFound() {
+		oldPath := oldTorrent.LibraryPath
+		// Releases imported before library path was tracked are located in download dir, under release name
+		if oldPath == "" && oldTorrent.Name != "" {
+			oldPath = fmt.Sprintf("%s/%s", oldTorrent.DownloadDir, oldTorrent.Name)
+		}
+
+		// Only remove files located in library, to avoid removing unexpected data if replaced release path is incomplete
+		if oldPath != "" && strings.HasPrefix(oldPath, libraryPath) && oldPath != newReleasePath {
+			if err := removeLibraryRelease(oldPath, newReleasePath); err != nil {
+				d.GetLog().WithFields(log.Fields{
+					"path":  oldPath,
+					"error": err,
+				}).Error("Could not remove replaced release from library")
+			}
+		}
+
+		d.GetLog().WithFields(log.Fields{
+			"replaced_release": oldTorrent.Name,
+			"new_release":      downloadingItem.CurrentTorrent().Name,
+		}).Info("Release replaced by upgrade")
+
+		downloadingItem.ReplacedReleases = append(downloadingItem.ReplacedReleases, ReplacedRelease{
+			Name:       oldTorrent.Name,
+			Quality:    oldTorrent.MediaInfo.Quality,
+			Path:       oldPath,
+			ReplacedBy: downloadingItem.CurrentTorrent().Name,
+		})
+		db.Client.Unscoped().Delete(&oldTorrent)
+	}
+
+	downloadingItem.Upgrading = false
+	downloadingItem.UpgradedTorrentID = 0
+}
+
+// RestoreUpgradedRelease cancels an upgrade in progress: torrents retrieved for upgrade are deleted, and the release already in library becomes the current release of the item again
+func RestoreUpgradedRelease(d downloadable.Downloadable) {
+	downloadingItem := d.GetDownloadingItem()
+	if !downloadingItem.Upgrading {
+		return
+	}
+
+	var oldTorrent Torrent
+	found := !db.Client.Find(&oldTorrent, downloadingItem.UpgradedTorrentID).RecordNotFound()
+
+	for _, torrent := range downloadingItem.TorrentList {
+		if found && torrent.ID == oldTorrent.ID {
+			continue
+		}
+		db.Client.Unscoped().Delete(&torrent)
+	}
+
+	downloadingItem.TorrentList = []Torrent{}
+	if found {
+		downloadingItem.TorrentList = []Torrent{oldTorrent}
+	}
+
+	downloadingItem.Pending = false
+	downloadingItem.Downloading = false
+	downloadingItem.Downloaded = true
+	downloadingItem.DownloadFailed = false
+	downloadingItem.CurrentDownloaderId = ""
+	downloadingItem.CurrentDownloader = ""
+	downloadingItem.Upgrading = false
+	downloadingItem.UpgradedTorrentID = 0
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+}
+
 func FillTorrentList(list []Torrent) []Torrent {
 	var torrentList []Torrent
 	for _, torrent := range list {
-		if !torrent.Failed {
+		// Releases that no configured downloader can handle are skipped to avoid wasting download attempts
+		if !torrent.Failed && SupportsProtocol(torrent.GetProtocol()) {
 			torrentList = append(torrentList, torrent)
 		}
 	}
@@ -528,8 +860,7 @@ func FillTorrentList(list []Torrent) []Torrent {
 // GetDownloader returns the registered downloader with name "name". An non-nil error is returned if no registered downloader are found with the required name
 func GetDownloader(name string) (Downloader, error) {
 	for _, d := range downloadersCollection {
-		mod, _ := d.Status()
-		if mod.Name == name {
+		if d.GetName() == name {
 			return d, nil
 		}
 	}
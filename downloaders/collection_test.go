@@ -3,8 +3,10 @@ package downloader
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"syscall"
 	"testing"
 	"time"
 
@@ -14,7 +16,10 @@ import (
 	"github.com/macarrie/flemzerd/downloadable"
 	log "github.com/macarrie/flemzerd/logging"
 	"github.com/macarrie/flemzerd/mocks"
+	"github.com/macarrie/flemzerd/naming"
 	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
 )
 
 func init() {
@@ -71,7 +76,7 @@ func TestAddTorrentWhenNoDownloadersAdded(t *testing.T) {
 	torrent := Torrent{
 		Link: "test",
 	}
-	_, err := AddTorrent(torrent)
+	_, _, err := AddTorrent(nil, torrent)
 	if err == nil {
 		t.Error("Got no downloaders configured, expected to have error when adding torrent")
 	}
@@ -85,25 +90,58 @@ func TestAddTorrentWhenDownloadersAdded(t *testing.T) {
 	AddDownloader(m)
 
 	count := m.GetTorrentCount()
-	AddTorrent(torrent)
+	AddTorrent(nil, torrent)
 
 	if m.GetTorrentCount() != count+1 {
 		t.Error("Expected ", count+1, " torrents, got ", m.GetTorrentCount())
 	}
 
 	downloadersCollection = []Downloader{mock.ErrorDownloader{}}
-	_, err := AddTorrent(torrent)
+	_, _, err := AddTorrent(nil, torrent)
 	if err == nil {
 		t.Error("Expected to have error when adding torrent to mock.ErrrDownloader")
 	}
 }
 
+func TestAddTorrentProtocolRouting(t *testing.T) {
+	downloadersCollection = []Downloader{mock.Downloader{}}
+	configuration.Config.System.TorrentDownloadAttemptsLimit = 5
+
+	usenetRelease := Torrent{
+		Link:     "test.nzb",
+		Protocol: PROTOCOL_USENET,
+	}
+	if _, _, err := AddTorrent(nil, usenetRelease); err == nil {
+		t.Error("Expected to have error when adding usenet release without usenet downloader")
+	}
+	if SupportsProtocol(PROTOCOL_USENET) {
+		t.Error("Expected usenet protocol not to be supported without usenet downloader")
+	}
+	if list := FillTorrentList([]Torrent{usenetRelease, Torrent{Link: "test"}}); len(list) != 1 || list[0].GetProtocol() != PROTOCOL_TORRENT {
+		t.Error("Expected releases without available downloader to be removed from torrent list")
+	}
+
+	AddDownloader(mock.UsenetDownloader{})
+	_, id, err := AddTorrent(nil, usenetRelease)
+	if err != nil {
+		t.Error("Expected usenet release to be added to usenet downloader, got error: ", err)
+	}
+	if id != "usenet_id" {
+		t.Errorf("Expected usenet release to be added to usenet downloader, got downloader id '%s'", id)
+	}
+
+	_, id, _ = AddTorrent(nil, Torrent{Link: "test"})
+	if id != "id" {
+		t.Errorf("Expected torrent to be added to torrent downloader, got downloader id '%s'", id)
+	}
+}
+
 func TestRemoveTorrentWhenNoDownloadersAdded(t *testing.T) {
 	downloadersCollection = []Downloader{}
 	torrent := Torrent{
 		Link: "test",
 	}
-	err := RemoveTorrent(torrent)
+	err := RemoveTorrent("", torrent)
 	if err == nil {
 		t.Error("Got no downloaders configured, expected to have error when removing torrent")
 	}
@@ -116,9 +154,9 @@ func TestRemoveTorrentWhenDownloadersAdded(t *testing.T) {
 	}
 	AddDownloader(m)
 
-	AddTorrent(torrent)
+	AddTorrent(nil, torrent)
 	count := m.GetTorrentCount()
-	RemoveTorrent(torrent)
+	RemoveTorrent("", torrent)
 
 	if m.GetTorrentCount() != count-1 {
 		t.Error("Expected ", count-1, " torrents, got ", m.GetTorrentCount())
@@ -130,7 +168,7 @@ func TestAddTorrentMapping(t *testing.T) {
 
 	downloadersCollection = []Downloader{mock.Downloader{}}
 
-	AddTorrentMapping("test", "test")
+	AddTorrentMapping("", Torrent{TorrentId: "test"}, "test")
 }
 
 func TestGetTorrentStatus(t *testing.T) {
@@ -138,7 +176,7 @@ func TestGetTorrentStatus(t *testing.T) {
 		TorrentId: strconv.Itoa(TORRENT_DOWNLOADING),
 	}
 
-	_ = GetTorrentStatus(&testTorrent)
+	_ = GetTorrentStatus("", &testTorrent)
 	status := testTorrent.Status
 
 	if status != TORRENT_SEEDING {
@@ -160,7 +198,7 @@ func TestWaitForDownload(t *testing.T) {
 		Cancel:      cancel,
 		SkipTorrent: make(chan bool),
 	}
-	err, _, _ := WaitForDownload(ctxStore, &testTorrent)
+	err, _, _ := WaitForDownload(ctxStore, "", &testTorrent)
 	if err == nil {
 		t.Error("Expected to get an error when download is stopped, got none instead")
 	}
@@ -173,7 +211,7 @@ func TestWaitForDownload(t *testing.T) {
 		Cancel:      cancel2,
 		SkipTorrent: make(chan bool),
 	}
-	err, _, _ = WaitForDownload(ctxStore2, &testTorrent)
+	err, _, _ = WaitForDownload(ctxStore2, "", &testTorrent)
 	if err != nil {
 		t.Error("Expected nil error to return when download is complete, got \"", err, "\" instead")
 	}
@@ -359,6 +397,758 @@ func TestMoveMovieToLibrary(t *testing.T) {
 	}
 }
 
+func TestMoveItemToLibraryWithNamingTemplates(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Naming.MovieFolder = "{{ .Title }} ({{ .Year }})"
+	configuration.Config.Naming.MovieFile = "{{ .Title }} ({{ .Year }}) - {{ .Quality }}"
+	defer func() {
+		configuration.Config.Naming = naming.DefaultPatterns
+	}()
+
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	downloadDir := fmt.Sprintf("%s/download", configuration.Config.Library.MoviePath)
+	os.MkdirAll(fmt.Sprintf("%s/release", downloadDir), 0755)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	f, _ := os.Create(fmt.Sprintf("%s/release/movie.release.1080p.MKV", downloadDir))
+	f.WriteString("movie")
+	f.Close()
+	os.Create(fmt.Sprintf("%s/release/sample.mkv", downloadDir))
+	os.Create(fmt.Sprintf("%s/release/movie.release.nfo", downloadDir))
+
+	testMovie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		Date:          time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC),
+		DownloadingItem: DownloadingItem{
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "movie.release.1080p",
+					DownloadDir: downloadDir,
+					MediaInfo: MediaInfo{
+						Quality: "1080p",
+					},
+				},
+			},
+		},
+	}
+	db.Client.Save(&testMovie)
+
+	if err := MoveItemToLibrary(&testMovie); err != nil {
+		t.Fatal("Movie could not be moved to library: ", err)
+	}
+
+	expectedPath := fmt.Sprintf("%s/test movie (2019)/test movie (2019) - 1080p.mkv", configuration.Config.Library.MoviePath)
+	if info, err := os.Stat(expectedPath); err != nil || info.Size() != int64(len("movie")) {
+		t.Errorf("Expected movie video file to be imported as %s", expectedPath)
+	}
+	currentTorrent := testMovie.DownloadingItem.CurrentTorrent()
+	if currentTorrent.LibraryPath != expectedPath {
+		t.Errorf("Expected torrent to track imported file path, got %s", currentTorrent.LibraryPath)
+	}
+	if currentTorrent.Name != "movie.release.1080p" {
+		t.Errorf("Expected torrent to keep its release name, got %s", currentTorrent.Name)
+	}
+	if _, err := os.Stat(downloadDir); !os.IsNotExist(err) {
+		t.Error("Expected remaining release files to be removed from temporary folder")
+	}
+}
+
+func TestMoveItemToLibraryExtraFiles(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.ExtraFilesPath = "/tmp/flemzerd_test_tmp_extras"
+	defer func() {
+		configuration.Config.Library.ExtraFilesPath = ""
+		configuration.Config.Naming = naming.DefaultPatterns
+	}()
+
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	os.RemoveAll(configuration.Config.Library.ExtraFilesPath)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+	defer os.RemoveAll(configuration.Config.Library.ExtraFilesPath)
+
+	downloadDir := fmt.Sprintf("%s/download", configuration.Config.Library.MoviePath)
+	os.MkdirAll(fmt.Sprintf("%s/release/Subs", downloadDir), 0755)
+	os.MkdirAll(fmt.Sprintf("%s/release/Sample", downloadDir), 0755)
+
+	files := map[string]string{
+		"release/movie.release.1080p.mkv":    "movie content",
+		"release/movie.release.1080p.en.srt": "subtitle",
+		"release/Subs/French.srt":            "subtitle",
+		"release/Sample/movie.sample.mkv":    "movie",
+		"release/featurette.mkv":             "bonus",
+		"release/movie.release.nfo":          "nfo",
+	}
+	for name, content := range files {
+		f, _ := os.Create(fmt.Sprintf("%s/%s", downloadDir, name))
+		f.WriteString(content)
+		f.Close()
+	}
+
+	testMovie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		DownloadingItem: DownloadingItem{
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "movie.release.1080p",
+					DownloadDir: downloadDir,
+				},
+			},
+		},
+	}
+	db.Client.Save(&testMovie)
+
+	if err := MoveItemToLibrary(&testMovie); err != nil {
+		t.Fatal("Movie could not be moved to library: ", err)
+	}
+
+	libraryDir := fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath)
+	for _, name := range []string{"movie.release.1080p.mkv", "movie.release.1080p.en.srt", "movie.release.1080p.French.srt"} {
+		if _, err := os.Stat(fmt.Sprintf("%s/%s", libraryDir, name)); err != nil {
+			t.Errorf("Expected %s to be imported into library", name)
+		}
+	}
+	if entries, _ := ioutil.ReadDir(libraryDir); len(entries) != 3 {
+		t.Errorf("Expected only movie file and subtitles to be imported into library, got %d files", len(entries))
+	}
+	for _, name := range []string{"release/Sample/movie.sample.mkv", "release/featurette.mkv", "release/movie.release.nfo"} {
+		if _, err := os.Stat(fmt.Sprintf("%s/movie.release.1080p/%s", configuration.Config.Library.ExtraFilesPath, name)); err != nil {
+			t.Errorf("Expected %s to be archived in extra files folder", name)
+		}
+	}
+	if _, err := os.Stat(downloadDir); !os.IsNotExist(err) {
+		t.Error("Expected release temporary folder to be removed")
+	}
+
+	// Multi-part releases
+	configuration.Config.Library.ExtraFilesPath = ""
+	configuration.Config.Naming.MovieFile = "{{ .Title }}"
+	os.MkdirAll(downloadDir, 0755)
+	for _, name := range []string{"movie.cd1.avi", "movie.cd2.avi"} {
+		f, _ := os.Create(fmt.Sprintf("%s/%s", downloadDir, name))
+		f.WriteString("movie part")
+		f.Close()
+	}
+	testMovie.DownloadingItem.TorrentList = []Torrent{
+		Torrent{
+			Name:        "movie.multi.part",
+			DownloadDir: downloadDir,
+		},
+	}
+
+	if err := MoveItemToLibrary(&testMovie); err != nil {
+		t.Fatal("Movie could not be moved to library: ", err)
+	}
+	for _, name := range []string{"test movie - part1.avi", "test movie - part2.avi"} {
+		if _, err := os.Stat(fmt.Sprintf("%s/%s", libraryDir, name)); err != nil {
+			t.Errorf("Expected %s to be imported into library", name)
+		}
+	}
+}
+
+func TestMoveItemToLibraryWithArchives(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	downloadDir := fmt.Sprintf("%s/download", configuration.Config.Library.MoviePath)
+	os.MkdirAll(fmt.Sprintf("%s/release", downloadDir), 0755)
+	for _, volume := range []string{"movie.release.1080p.rar", "movie.release.1080p.r00"} {
+		content, _ := ioutil.ReadFile(fmt.Sprintf("../testdata/archive/%s", volume))
+		ioutil.WriteFile(fmt.Sprintf("%s/release/%s", downloadDir, volume), content, 0644)
+	}
+
+	testMovie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		DownloadingItem: DownloadingItem{
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "movie.release.1080p",
+					DownloadDir: downloadDir,
+				},
+			},
+		},
+	}
+	db.Client.Save(&testMovie)
+
+	if err := MoveItemToLibrary(&testMovie); err != nil {
+		t.Fatal("Archived movie could not be moved to library: ", err)
+	}
+
+	libraryFile := fmt.Sprintf("%s/test_movie/Movie.Release.1080p.mkv", configuration.Config.Library.MoviePath)
+	if info, err := os.Stat(libraryFile); err != nil || info.Size() != 700 {
+		t.Error("Expected movie file extracted from archive to be imported into library")
+	}
+	if entries, _ := ioutil.ReadDir(fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath)); len(entries) != 1 {
+		t.Errorf("Expected archive volumes not to be imported into library, got %d files", len(entries))
+	}
+	if _, err := os.Stat(downloadDir); !os.IsNotExist(err) {
+		t.Error("Expected archives to be removed from temporary folder")
+	}
+
+	// Archives that cannot be extracted
+	os.MkdirAll(downloadDir, 0755)
+	ioutil.WriteFile(fmt.Sprintf("%s/movie.release.rar", downloadDir), []byte("not a rar archive"), 0644)
+	testMovie.DownloadingItem.TorrentList = []Torrent{
+		Torrent{
+			Name:        "movie.release",
+			DownloadDir: downloadDir,
+		},
+	}
+
+	err := MoveItemToLibrary(&testMovie)
+	var extractionErr *ExtractionError
+	if !errors.As(err, &extractionErr) {
+		t.Fatalf("Expected archive extraction error, got %v", err)
+	}
+	setImportFailed(&testMovie, err)
+	if !testMovie.DownloadingItem.ImportFailed || testMovie.DownloadingItem.CurrentTorrent().FailureReason != extractionErr.Error() {
+		t.Error("Expected archive extraction failure to be reported as torrent failure reason")
+	}
+}
+
+func TestMoveItemToLibraryWithMediaVerification(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.VerifyMedia = true
+	defer func() {
+		configuration.Config.Library.VerifyMedia = false
+	}()
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	// Test video is a 90 minutes 1920x800 hevc file, with french and english audio tracks
+	video, _ := ioutil.ReadFile("../testdata/probe/movie.mkv")
+	downloadDir := fmt.Sprintf("%s/download", configuration.Config.Library.MoviePath)
+	libraryFile := fmt.Sprintf("%s/test_movie/movie.mkv", configuration.Config.Library.MoviePath)
+
+	testData := []struct {
+		Quality  string
+		Runtime  int
+		Content  []byte
+		Rejected bool
+	}{
+		{"1080p", 100, video, false},
+		{"", 0, video, false},
+		{"2160p", 100, video, true},
+		{"1080p", 240, video, true},
+		{"1080p", 100, []byte("fake video file"), true},
+	}
+
+	for _, data := range testData {
+		os.MkdirAll(downloadDir, 0755)
+		ioutil.WriteFile(fmt.Sprintf("%s/movie.mkv", downloadDir), data.Content, 0644)
+
+		testMovie := Movie{
+			Title:         "test movie",
+			OriginalTitle: "test movie",
+			Runtime:       data.Runtime,
+			DownloadingItem: DownloadingItem{
+				TorrentList: []Torrent{
+					Torrent{
+						Name:        "movie",
+						DownloadDir: downloadDir,
+						MediaInfo: MediaInfo{
+							Quality: data.Quality,
+						},
+					},
+				},
+			},
+		}
+		db.Client.Save(&testMovie)
+
+		err := MoveItemToLibrary(&testMovie)
+		var verificationErr *VerificationError
+		if data.Rejected {
+			if !errors.As(err, &verificationErr) {
+				t.Errorf("Expected %s release with %d minutes runtime to be rejected by media verification, got %v", data.Quality, data.Runtime, err)
+			}
+			if _, err := os.Stat(libraryFile); !os.IsNotExist(err) {
+				t.Error("Expected rejected release to be removed from library")
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected %s release with %d minutes runtime to pass media verification, got %v", data.Quality, data.Runtime, err)
+			continue
+		}
+		mediaProbe := testMovie.DownloadingItem.Probe
+		if !mediaProbe.Probed || mediaProbe.Width != 1920 || mediaProbe.Duration != 5400 || mediaProbe.VideoCodec != "hevc" || mediaProbe.AudioLanguages != "fre,eng" {
+			t.Errorf("Expected probe result to be stored on item, got %+v", mediaProbe)
+		}
+		os.RemoveAll(libraryFile)
+	}
+
+	// Multi-part releases are verified with the total duration of their parts
+	for _, data := range []struct {
+		Runtime  int
+		Rejected bool
+	}{
+		{240, false},
+		{400, true},
+	} {
+		os.RemoveAll(downloadDir)
+		os.MkdirAll(downloadDir, 0755)
+		for _, name := range []string{"movie.cd1.mkv", "movie.cd2.mkv"} {
+			ioutil.WriteFile(fmt.Sprintf("%s/%s", downloadDir, name), video, 0644)
+		}
+
+		multiPartMovie := Movie{
+			Title:         "test movie",
+			OriginalTitle: "test movie",
+			Runtime:       data.Runtime,
+			DownloadingItem: DownloadingItem{
+				TorrentList: []Torrent{
+					Torrent{
+						Name:        "movie",
+						DownloadDir: downloadDir,
+					},
+				},
+			},
+		}
+		db.Client.Save(&multiPartMovie)
+
+		err := MoveItemToLibrary(&multiPartMovie)
+		var verificationErr *VerificationError
+		if data.Rejected != errors.As(err, &verificationErr) {
+			t.Errorf("Expected multi-part release with %d minutes runtime to be rejected: %t, got %v", data.Runtime, data.Rejected, err)
+		}
+		entries, _ := ioutil.ReadDir(fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath))
+		if data.Rejected && len(entries) != 0 {
+			t.Errorf("Expected no part of rejected multi-part release to be imported into library, got %d files", len(entries))
+		}
+		if !data.Rejected && (len(entries) != 2 || multiPartMovie.DownloadingItem.Probe.Duration != 2*5400) {
+			t.Errorf("Expected multi-part release to be imported with its total duration, got %d files and probe %+v", len(entries), multiPartMovie.DownloadingItem.Probe)
+		}
+		os.RemoveAll(fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath))
+	}
+	os.RemoveAll(downloadDir)
+
+	// Rejected upgrade imported with the same library name does not replace release already in library
+	libraryDir := fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath)
+	os.MkdirAll(libraryDir, 0755)
+	ioutil.WriteFile(libraryFile, []byte("old release"), 0644)
+	librarySubtitle := fmt.Sprintf("%s/movie.en.srt", libraryDir)
+	ioutil.WriteFile(librarySubtitle, []byte("subtitle"), 0644)
+	os.MkdirAll(downloadDir, 0755)
+	ioutil.WriteFile(fmt.Sprintf("%s/movie.mkv", downloadDir), []byte("fake video file"), 0644)
+
+	oldTorrent := Torrent{
+		Name:        "movie",
+		DownloadDir: libraryDir,
+		LibraryPath: libraryFile,
+	}
+	db.Client.Create(&oldTorrent)
+	upgradedMovie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		Runtime:       100,
+		DownloadingItem: DownloadingItem{
+			Downloaded:        true,
+			Upgrading:         true,
+			UpgradedTorrentID: oldTorrent.ID,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "movie",
+					DownloadDir: downloadDir,
+				},
+			},
+		},
+	}
+	db.Client.Save(&upgradedMovie)
+
+	var verificationErr *VerificationError
+	if err := MoveItemToLibrary(&upgradedMovie); !errors.As(err, &verificationErr) {
+		t.Errorf("Expected fake upgraded release to be rejected by media verification, got %v", err)
+	}
+	if content, err := ioutil.ReadFile(libraryFile); err != nil || string(content) != "old release" {
+		t.Error("Expected release in library to be kept when upgraded release with the same name is rejected")
+	}
+	if _, err := os.Stat(librarySubtitle); err != nil {
+		t.Error("Expected subtitles of release in library to be kept when upgraded release is rejected")
+	}
+
+	// Rejected torrent is marked as failed and its downloaded data is removed
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_tmp_download"
+	temporaryPath := fmt.Sprintf("%s/release", configuration.Config.Library.CustomTmpPath)
+	os.MkdirAll(temporaryPath, 0755)
+	defer os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+	downloadersCollection = []Downloader{mock.Downloader{}}
+
+	var d downloadable.Downloadable = &Movie{}
+	torrent := Torrent{
+		Name: "movie",
+	}
+	err := rejectDownloadedRelease(&d, "", &torrent, temporaryPath, &VerificationError{Path: libraryFile, Reason: "fake"})
+	if err == nil {
+		t.Error("Expected rejected release to return an error")
+	}
+	if !torrent.Failed || torrent.FailureReason == "" {
+		t.Error("Expected rejected torrent to be marked as failed with verification failure reason")
+	}
+	if _, err := os.Stat(temporaryPath); !os.IsNotExist(err) {
+		t.Error("Expected downloaded data of rejected torrent to be removed")
+	}
+}
+
+func TestMoveUpgradedItemToLibrary(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_tmp_download"
+
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+	os.MkdirAll(configuration.Config.Library.CustomTmpPath, 0755)
+
+	libraryDir := fmt.Sprintf("%s/test_movie", configuration.Config.Library.MoviePath)
+	os.MkdirAll(libraryDir, 0755)
+	oldReleasePath := fmt.Sprintf("%s/test_movie.480p.mkv", libraryDir)
+	os.Create(oldReleasePath)
+
+	newReleasePath := fmt.Sprintf("%s/new_release", configuration.Config.Library.CustomTmpPath)
+	os.Create(newReleasePath)
+
+	oldTorrent := Torrent{
+		Name:        "Test.Movie.2018.480p",
+		DownloadDir: libraryDir,
+		LibraryPath: oldReleasePath,
+		MediaInfo: MediaInfo{
+			Quality: "480p",
+		},
+	}
+	db.Client.Create(&oldTorrent)
+
+	movie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		DownloadingItem: DownloadingItem{
+			Downloaded:        true,
+			Upgrading:         true,
+			UpgradedTorrentID: oldTorrent.ID,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "Test.Movie.2018.720p",
+					DownloadDir: newReleasePath,
+				},
+			},
+		},
+	}
+	db.Client.Create(&movie)
+
+	if err := MoveItemToLibrary(&movie); err != nil {
+		t.Errorf("Movie could not be moved to library: %s", err.Error())
+	}
+
+	if _, err := os.Stat(oldReleasePath); !os.IsNotExist(err) {
+		t.Error("Expected replaced release to be removed from library")
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/Test.Movie.2018.720p", libraryDir)); err != nil {
+		t.Error("Expected upgraded release to be moved into library")
+	}
+
+	var movieFromDB Movie
+	db.Client.Find(&movieFromDB, movie.ID)
+	if movieFromDB.DownloadingItem.Upgrading {
+		t.Error("Expected upgrade to be finished after moving upgraded release into library")
+	}
+	if len(movieFromDB.DownloadingItem.ReplacedReleases) != 1 {
+		t.Errorf("Expected to have 1 replaced release in history, got %d instead", len(movieFromDB.DownloadingItem.ReplacedReleases))
+		return
+	}
+	if movieFromDB.DownloadingItem.ReplacedReleases[0].Quality != "480p" {
+		t.Errorf("Expected replaced release quality to be 480p, got '%s' instead", movieFromDB.DownloadingItem.ReplacedReleases[0].Quality)
+	}
+	if movieFromDB.DownloadingItem.ReplacedReleases[0].Name != "Test.Movie.2018.480p" || movieFromDB.DownloadingItem.ReplacedReleases[0].Path != oldReleasePath {
+		t.Errorf("Expected replaced release history to keep release name and library path, got %+v", movieFromDB.DownloadingItem.ReplacedReleases[0])
+	}
+	if movieFromDB.DownloadingItem.CurrentTorrent().DownloadDir != libraryDir {
+		t.Errorf("Expected current torrent download dir to be library path, got '%s' instead", movieFromDB.DownloadingItem.CurrentTorrent().DownloadDir)
+	}
+}
+
+func TestMoveSeasonPackToLibrary(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.ShowPath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_tmp_download"
+
+	os.RemoveAll(configuration.Config.Library.ShowPath)
+	os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+
+	packPath := fmt.Sprintf("%s/pack", configuration.Config.Library.CustomTmpPath)
+	packContentPath := fmt.Sprintf("%s/Test.Show.S01.720p", packPath)
+	os.MkdirAll(packContentPath, 0755)
+	for _, file := range []string{"Test.Show.S01E01.720p.mkv", "Test.Show.S01E02.720p.mkv", "Test.Show.S01E03.720p.mkv", "Test.Show.S01E02.720p.sample.mkv", "Test.Show.S01.720p.nfo"} {
+		os.Create(fmt.Sprintf("%s/%s", packContentPath, file))
+	}
+
+	show := TvShow{
+		Title:         "test show",
+		OriginalTitle: "test show",
+	}
+	db.Client.Create(&show)
+
+	lead := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 1,
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "Test.Show.S01.720p",
+					DownloadDir: packPath,
+					SeasonPack:  true,
+				},
+			},
+		},
+	}
+	db.Client.Create(&lead)
+
+	missingEpisode := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 2,
+	}
+	db.Client.Create(&missingEpisode)
+
+	downloadedEpisode := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 3,
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name: "Test.Show.S01E03.1080p",
+				},
+			},
+		},
+	}
+	db.Client.Create(&downloadedEpisode)
+
+	if err := MoveItemToLibrary(&lead); err != nil {
+		t.Errorf("Season pack could not be moved to library: %s", err.Error())
+	}
+
+	for number, file := range map[int]string{1: "Test.Show.S01E01.720p.mkv", 2: "Test.Show.S01E02.720p.mkv"} {
+		path := fmt.Sprintf("%s/test_show/season_1/s01e%02d/%s", configuration.Config.Library.ShowPath, number, file)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected episode %d file to be moved into library at '%s'", number, path)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/test_show/season_1/s01e03", configuration.Config.Library.ShowPath)); !os.IsNotExist(err) {
+		t.Error("Expected season pack file to be skipped for episode already downloaded")
+	}
+	if _, err := os.Stat(packPath); !os.IsNotExist(err) {
+		t.Error("Expected season pack temporary folder to be removed")
+	}
+
+	var leadFromDB Episode
+	db.Client.Find(&leadFromDB, lead.ID)
+	leadTorrent := leadFromDB.DownloadingItem.CurrentTorrent()
+	if leadTorrent.Name != "Test.Show.S01.720p" {
+		t.Errorf("Expected lead episode torrent to keep season pack name, got '%s' instead", leadTorrent.Name)
+	}
+	if expected := fmt.Sprintf("%s/test_show/season_1/s01e01/Test.Show.S01E01.720p.mkv", configuration.Config.Library.ShowPath); leadTorrent.LibraryPath != expected {
+		t.Errorf("Expected lead episode torrent to point to its episode file '%s', got '%s' instead", expected, leadTorrent.LibraryPath)
+	}
+
+	var missingFromDB Episode
+	db.Client.Find(&missingFromDB, missingEpisode.ID)
+	if !missingFromDB.DownloadingItem.Downloaded {
+		t.Error("Expected episode covered by season pack to be marked as downloaded")
+	}
+	expectedDir := fmt.Sprintf("%s/test_show/season_1/s01e02", configuration.Config.Library.ShowPath)
+	if missingFromDB.DownloadingItem.CurrentTorrent().DownloadDir != expectedDir {
+		t.Errorf("Expected covered episode torrent download dir to be '%s', got '%s' instead", expectedDir, missingFromDB.DownloadingItem.CurrentTorrent().DownloadDir)
+	}
+
+	var downloadedFromDB Episode
+	db.Client.Find(&downloadedFromDB, downloadedEpisode.ID)
+	if downloadedFromDB.DownloadingItem.CurrentTorrent().Name != "Test.Show.S01E03.1080p" {
+		t.Error("Expected episode already downloaded to keep its release")
+	}
+}
+
+func TestGetSeasonPackEpisodeFiles(t *testing.T) {
+	packPath := "/tmp/flemzerd_test_tmp_download/Free.Samples.S01.720p"
+	os.RemoveAll(packPath)
+	os.MkdirAll(fmt.Sprintf("%s/Sample", packPath), 0755)
+	defer os.RemoveAll(packPath)
+	for _, file := range []string{"Free.Samples.S01E01.720p.mkv", "Free.Samples.S01E02.720p.sample.mkv", "Sample/Free.Samples.S01E03.720p.mkv", "Free.Samples.S02E04.720p.mkv"} {
+		os.Create(fmt.Sprintf("%s/%s", packPath, file))
+	}
+
+	files, err := getSeasonPackEpisodeFiles(packPath, 1)
+	if err != nil {
+		t.Errorf("Season pack files could not be listed: %s", err.Error())
+	}
+	if len(files) != 1 || files[1] != fmt.Sprintf("%s/Free.Samples.S01E01.720p.mkv", packPath) {
+		t.Errorf("Expected only episode 1 to be found in season pack of a show with 'sample' in its title, got %v", files)
+	}
+}
+
+func TestMoveSeasonPackWithoutLeadEpisode(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.ShowPath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_tmp_download"
+
+	os.RemoveAll(configuration.Config.Library.ShowPath)
+	os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+
+	packPath := fmt.Sprintf("%s/pack", configuration.Config.Library.CustomTmpPath)
+	packContentPath := fmt.Sprintf("%s/Test.Show.S01.720p", packPath)
+	os.MkdirAll(packContentPath, 0755)
+	os.Create(fmt.Sprintf("%s/Test.Show.S01E02.720p.mkv", packContentPath))
+
+	show := TvShow{
+		Title:         "test show",
+		OriginalTitle: "test show",
+	}
+	db.Client.Create(&show)
+
+	lead := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 1,
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "Test.Show.S01.720p",
+					DownloadDir: packPath,
+					SeasonPack:  true,
+				},
+			},
+		},
+	}
+	db.Client.Create(&lead)
+
+	coveredEpisode := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 2,
+	}
+	db.Client.Create(&coveredEpisode)
+
+	if err := MoveItemToLibrary(&lead); !errors.Is(err, errEpisodeNotInSeasonPack) {
+		t.Errorf("Expected an error when season pack does not contain lead episode, got %v", err)
+	}
+
+	var coveredFromDB Episode
+	db.Client.Find(&coveredFromDB, coveredEpisode.ID)
+	if !coveredFromDB.DownloadingItem.Downloaded {
+		t.Error("Expected other episodes of season pack to be imported when lead episode is missing")
+	}
+}
+
+func TestMoveMultiEpisodeToLibrary(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.ShowPath = "/tmp/flemzerd_test_tmp"
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_tmp_download"
+
+	os.RemoveAll(configuration.Config.Library.ShowPath)
+	os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+	os.MkdirAll(configuration.Config.Library.CustomTmpPath, 0755)
+
+	releasePath := fmt.Sprintf("%s/release", configuration.Config.Library.CustomTmpPath)
+	os.Create(releasePath)
+
+	show := TvShow{
+		Title:         "test show",
+		OriginalTitle: "test show",
+	}
+	db.Client.Create(&show)
+
+	episode := Episode{
+		TvShow: show,
+		Season: 2,
+		Number: 6,
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "Test.Show.S02E05-07.720p",
+					DownloadDir: releasePath,
+				},
+			},
+		},
+	}
+	db.Client.Create(&episode)
+
+	coveredEpisodes := []Episode{
+		Episode{TvShow: show, Season: 2, Number: 5},
+		Episode{TvShow: show, Season: 2, Number: 7},
+		Episode{TvShow: show, Season: 2, Number: 8},
+	}
+	for i := range coveredEpisodes {
+		db.Client.Create(&coveredEpisodes[i])
+	}
+
+	if err := MoveItemToLibrary(&episode); err != nil {
+		t.Errorf("Multi-episode release could not be moved to library: %s", err.Error())
+	}
+
+	libraryDir := fmt.Sprintf("%s/test_show/season_2/s02e05-e07", configuration.Config.Library.ShowPath)
+	if _, err := os.Stat(fmt.Sprintf("%s/Test.Show.S02E05-07.720p", libraryDir)); err != nil {
+		t.Errorf("Expected multi-episode release to be moved into '%s'", libraryDir)
+	}
+
+	for _, covered := range coveredEpisodes {
+		var episodeFromDB Episode
+		db.Client.Find(&episodeFromDB, covered.ID)
+
+		expected := covered.Number != 8
+		if episodeFromDB.DownloadingItem.Downloaded != expected {
+			t.Errorf("Expected episode %d downloaded state to be %t, got %t instead", covered.Number, expected, episodeFromDB.DownloadingItem.Downloaded)
+		}
+		if expected && episodeFromDB.DownloadingItem.CurrentTorrent().DownloadDir != libraryDir {
+			t.Errorf("Expected episode %d release to be located in '%s', got '%s' instead", covered.Number, libraryDir, episodeFromDB.DownloadingItem.CurrentTorrent().DownloadDir)
+		}
+	}
+}
+
+func TestRestoreUpgradedRelease(t *testing.T) {
+	db.ResetDb()
+
+	oldTorrent := Torrent{
+		Name: "Test.Movie.2018.480p",
+	}
+	db.Client.Create(&oldTorrent)
+
+	movie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		DownloadingItem: DownloadingItem{
+			Downloading:       true,
+			Upgrading:         true,
+			UpgradedTorrentID: oldTorrent.ID,
+			TorrentList: []Torrent{
+				Torrent{
+					Name: "Test.Movie.2018.720p",
+				},
+			},
+		},
+	}
+	db.Client.Create(&movie)
+
+	MarkDownloadAsFailed(&movie)
+
+	var movieFromDB Movie
+	db.Client.Find(&movieFromDB, movie.ID)
+	if !movieFromDB.DownloadingItem.Downloaded || movieFromDB.DownloadingItem.DownloadFailed || movieFromDB.DownloadingItem.Upgrading {
+		t.Error("Expected movie to be marked as downloaded with previous release when upgrade fails")
+	}
+	if movieFromDB.DownloadingItem.CurrentTorrent().Name != oldTorrent.Name {
+		t.Errorf("Expected current torrent to be previous release, got '%s' instead", movieFromDB.DownloadingItem.CurrentTorrent().Name)
+	}
+	if len(movieFromDB.DownloadingItem.TorrentList) != 1 {
+		t.Errorf("Expected torrents retrieved for upgrade to be removed, got %d torrents instead", len(movieFromDB.DownloadingItem.TorrentList))
+	}
+}
+
 func TestFillDownloadList(t *testing.T) {
 	torrent1 := Torrent{
 		TorrentId: "1",
@@ -597,3 +1387,551 @@ func TestGetDownloader(t *testing.T) {
 		t.Errorf("Got error while retrieving known notifier: %s", err.Error())
 	}
 }
+
+func TestRoutingRules(t *testing.T) {
+	downloadersConf := configuration.Config.Downloaders
+	configuration.Config.Downloaders = map[string]map[string]string{
+		"Downloader": map[string]string{
+			"priority":    "10",
+			"media_types": "Movie",
+			"min_size":    "100",
+			"max_size":    "1000",
+			"tags":        "4k, private_tracker",
+		},
+	}
+	defer func() {
+		configuration.Config.Downloaders = downloadersConf
+	}()
+
+	rules := GetRoutingRules("Downloader")
+	if rules.Priority != 10 || rules.MinSize != 100*1024*1024 || rules.MaxSize != 1000*1024*1024 {
+		t.Errorf("Unexpected routing rules parsed from configuration: %+v", rules)
+	}
+	if unknownRules := GetRoutingRules("Unknown"); !unknownRules.Accepts(&Episode{}, Torrent{TotalSize: 1}) {
+		t.Error("Expected downloaders without routing rules to accept every release")
+	}
+
+	movie := Movie{QualityProfile: QualityProfile{Name: "4K"}}
+	movie.QualityProfile.ID = 1
+	movie.QualityProfileID = 1
+
+	testData := []struct {
+		Media    downloadable.Downloadable
+		Torrent  Torrent
+		Expected bool
+	}{
+		{&movie, Torrent{TotalSize: 500 * 1024 * 1024}, true},
+		{&movie, Torrent{}, true},
+		{&Episode{}, Torrent{TotalSize: 500 * 1024 * 1024, Indexer: "private_tracker"}, false},
+		{&movie, Torrent{TotalSize: 50 * 1024 * 1024}, false},
+		{&movie, Torrent{TotalSize: 5000 * 1024 * 1024}, false},
+		{&Movie{}, Torrent{TotalSize: 500 * 1024 * 1024}, false},
+		{&Movie{}, Torrent{TotalSize: 500 * 1024 * 1024, Indexer: "private_tracker"}, true},
+	}
+	for i, testCase := range testData {
+		if result := rules.Accepts(testCase.Media, testCase.Torrent); result != testCase.Expected {
+			t.Errorf("Test %d: expected routing rules result to be %t, got %t instead", i, testCase.Expected, result)
+		}
+	}
+}
+
+func TestAddTorrentRoutingAndFailover(t *testing.T) {
+	downloadersConf := configuration.Config.Downloaders
+	configuration.Config.Downloaders = map[string]map[string]string{
+		"ErrorDownloader": map[string]string{"priority": "10"},
+		"Downloader":      map[string]string{"media_types": "episode"},
+	}
+	defer func() {
+		configuration.Config.Downloaders = downloadersConf
+	}()
+	downloadersCollection = []Downloader{mock.Downloader{}, mock.ErrorDownloader{}}
+
+	name, _, err := AddTorrent(&Episode{}, Torrent{Link: "test"})
+	if err != nil {
+		t.Error("Expected torrent to be added by failover downloader, got error: ", err)
+	}
+	if name != "Downloader" {
+		t.Errorf("Expected torrent to be added by 'Downloader' after failure of downloader with highest priority, got '%s' instead", name)
+	}
+
+	if _, _, err := AddTorrent(&Movie{}, Torrent{Link: "test"}); err == nil {
+		t.Error("Expected an error when only failing downloaders match routing rules")
+	}
+
+	downloadersCollection = []Downloader{mock.ErrorDownloader{}, mock.Downloader{}}
+	configuration.Config.Downloaders["Downloader"]["priority"] = "20"
+	if name, _, _ := AddTorrent(&Episode{}, Torrent{Link: "test"}); name != "Downloader" {
+		t.Errorf("Expected torrent to be added by downloader with highest priority, got '%s' instead", name)
+	}
+
+	configuration.Config.Downloaders["Downloader"]["priority"] = "10"
+	for _, collection := range [][]Downloader{{mock.Downloader{}, mock.ErrorDownloader{}}, {mock.ErrorDownloader{}, mock.Downloader{}}} {
+		downloadersCollection = collection
+		if downloaders := getDownloaders(&Episode{}, Torrent{Link: "test"}); len(downloaders) != 2 || downloaders[0].GetName() != "Downloader" {
+			t.Error("Expected downloaders with same priority to be sorted by name")
+		}
+	}
+
+	if err := GetTorrentStatus("Unknown", &Torrent{}); err == nil {
+		t.Error("Expected an error when getting torrent status from unknown downloader")
+	}
+}
+
+// batchDownloader counts batched status requests
+type batchDownloader struct {
+	mock.StalledDownloader
+	calls *int
+}
+
+func (d batchDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	*d.calls += 1
+	for _, t := range torrents {
+		t.Status = TORRENT_DOWNLOADING
+	}
+	return make([]error, len(torrents))
+}
+
+func TestDownloadMonitor(t *testing.T) {
+	calls := 0
+	downloadersCollection = []Downloader{batchDownloader{calls: &calls}}
+
+	m := &downloadMonitor{
+		watchers: make(map[string]map[*torrentWatcher]bool),
+		pending:  make(map[string]bool),
+		wake:     make(chan bool, 1),
+	}
+	// Checks are performed manually in this test
+	m.once.Do(func() {})
+
+	first, err := m.watch("", Torrent{TorrentId: "first"})
+	if err != nil {
+		t.Fatal("Expected torrent to be watched, got error: ", err)
+	}
+	second, _ := m.watch("StalledDownloader", Torrent{TorrentId: "second"})
+	if !m.pending["StalledDownloader"] {
+		t.Error("Expected watching a torrent to trigger a downloader check")
+	}
+
+	m.check("StalledDownloader")
+	if calls != 1 {
+		t.Errorf("Expected torrents status to be retrieved in 1 batch, got %d requests instead", calls)
+	}
+	for _, watcher := range []*torrentWatcher{first, second} {
+		select {
+		case update := <-watcher.updates:
+			if update.Err != nil || update.Torrent.Status != TORRENT_DOWNLOADING {
+				t.Errorf("Expected torrent %s to be downloading, got status %d (error: %v)", update.Torrent.TorrentId, update.Torrent.Status, update.Err)
+			}
+		default:
+			t.Errorf("Expected torrent %s to receive a status update", watcher.torrent.TorrentId)
+		}
+	}
+
+	m.unwatch(first)
+	m.unwatch(second)
+	m.check("StalledDownloader")
+	if calls != 1 {
+		t.Error("Expected no status request when no torrents are watched")
+	}
+
+	if _, err := m.watch("Unknown", Torrent{}); err == nil {
+		t.Error("Expected an error when watching a torrent held by an unknown downloader")
+	}
+
+	if err := NotifyDownloader("Unknown"); err == nil {
+		t.Error("Expected an error when notifying an unknown downloader")
+	}
+	if err := NotifyDownloader("StalledDownloader"); err != nil {
+		t.Error("Expected downloader notification to succeed, got error: ", err)
+	}
+}
+
+func TestDownloadQueue(t *testing.T) {
+	db.ResetDb()
+	MovieDownloadRoutines = make(DownloadRoutineStruct)
+	downloadersCollection = []Downloader{mock.StalledDownloader{}}
+	configuration.Config.System.MaxActiveDownloads = 1
+	configuration.Config.System.TorrentDownloadAttemptsLimit = 5
+	defer func() {
+		configuration.Config.System.MaxActiveDownloads = 0
+		ResumeQueue()
+	}()
+
+	var movies []*Movie
+	for i := 0; i < 3; i++ {
+		movie := Movie{
+			Title:         fmt.Sprintf("queued movie %d", i),
+			OriginalTitle: fmt.Sprintf("queued movie %d", i),
+			DownloadingItem: DownloadingItem{
+				TorrentList: []Torrent{
+					Torrent{
+						TorrentId: fmt.Sprintf("queued_%d", i),
+						Name:      fmt.Sprintf("queued.movie.%d.720p", i),
+					},
+				},
+			},
+		}
+		db.Client.Create(&movie)
+		movies = append(movies, &movie)
+	}
+
+	// Last item has a higher priority and must be queued first
+	Enqueue(movies[0])
+	movies[2].DownloadingItem.Priority = 1
+	Enqueue(movies[1])
+	Enqueue(movies[2])
+	Enqueue(movies[1])
+
+	status := GetQueue()
+	if len(status.Active) != 1 || status.Active[0].Id != movies[0].ID {
+		t.Fatalf("Expected first item to be downloading, got %+v", status.Active)
+	}
+	if len(status.Queued) != 2 || status.Queued[0].Id != movies[2].ID || status.Queued[1].Id != movies[1].ID {
+		t.Fatalf("Expected queued items to be ordered by priority, got %+v", status.Queued)
+	}
+
+	var movieFromDB Movie
+	db.Client.Find(&movieFromDB, movies[1].ID)
+	if !movieFromDB.DownloadingItem.Queued || movieFromDB.DownloadingItem.QueuePosition != 2 {
+		t.Error("Expected queue state to be saved in database")
+	}
+
+	if err := MoveQueueItem("movie", movies[1].ID, 1); err != nil {
+		t.Error("Expected item to be moved in queue, got error: ", err)
+	}
+	if err := MoveQueueItem("movie", movies[1].ID, 5); err == nil {
+		t.Error("Expected an error when moving item out of queue bounds")
+	}
+	if err := SetQueueItemPriority("episode", movies[1].ID, 2); err == nil {
+		t.Error("Expected an error when changing priority of an item not in queue")
+	}
+	if status := GetQueue(); status.Queued[0].Id != movies[1].ID {
+		t.Errorf("Expected item to be first in queue after being moved, got %+v", status.Queued)
+	}
+
+	// Download slot is freed, but paused queue does not start new downloads.
+	// Active downloads are aborted with a copy of the item like API handlers do, since the item itself is modified by the download process
+	PauseQueue()
+	var activeMovie Movie
+	db.Client.Find(&activeMovie, movies[0].ID)
+	AbortDownload(&activeMovie)
+	waitForActiveDownloads := func() {
+		for i := 0; i < 20 && len(GetQueue().Active) > 0; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	waitForActiveDownloads()
+	if status := GetQueue(); len(status.Active) != 0 || len(status.Queued) != 2 || !status.Paused {
+		t.Errorf("Expected no download to start when queue is paused, got %+v", status)
+	}
+
+	var session SessionData
+	db.Client.First(&session)
+	if !session.QueuePaused {
+		t.Error("Expected paused state of queue to be saved in database")
+	}
+	queue.paused = false
+	LoadQueueState()
+	if !GetQueue().Paused {
+		t.Error("Expected paused state of queue to be restored from database")
+	}
+
+	ResumeQueue()
+	status = GetQueue()
+	if len(status.Active) != 1 || status.Active[0].Id != movies[1].ID {
+		t.Errorf("Expected first queued item to start when queue is resumed, got %+v", status.Active)
+	}
+
+	AbortDownload(movies[2])
+	if status := GetQueue(); len(status.Queued) != 0 {
+		t.Errorf("Expected aborted item to be removed from queue, got %+v", status.Queued)
+	}
+	activeMovie = Movie{}
+	db.Client.Find(&activeMovie, movies[1].ID)
+	AbortDownload(&activeMovie)
+	waitForActiveDownloads()
+}
+
+func TestStallTracker(t *testing.T) {
+	configuration.Config.System.StallNoProgressTimeout = 120
+	configuration.Config.System.StallNoPeersTimeout = 60
+	configuration.Config.System.StallMaxEta = 24
+	defer func() {
+		configuration.Config.System.StallNoProgressTimeout = 0
+		configuration.Config.System.StallNoPeersTimeout = 0
+		configuration.Config.System.StallMaxEta = 0
+	}()
+
+	start := time.Now()
+	torrent := Torrent{
+		Status:         TORRENT_DOWNLOADING,
+		PercentDone:    0.1,
+		PeersConnected: 3,
+		ETA:            start.Add(time.Hour),
+	}
+
+	tracker := newStallTracker(start)
+	if reason := tracker.check(torrent, start.Add(30*time.Minute)); reason != "" {
+		t.Errorf("Expected download to progress normally, got stall reason '%s'", reason)
+	}
+
+	// Torrent stuck at 99%
+	torrent.PercentDone = 0.99
+	tracker.check(torrent, start.Add(40*time.Minute))
+	if reason := tracker.check(torrent, start.Add(150*time.Minute)); reason != "" {
+		t.Errorf("Expected download not to be stalled before no progress timeout, got '%s'", reason)
+	}
+	if reason := tracker.check(torrent, start.Add(161*time.Minute)); reason == "" {
+		t.Error("Expected download without progress to be stalled")
+	}
+
+	// No peers
+	torrent.PercentDone = 0
+	torrent.PeersConnected = 0
+	tracker = newStallTracker(start)
+	if reason := tracker.check(torrent, start.Add(61*time.Minute)); reason == "" {
+		t.Error("Expected download without peers to be stalled")
+	}
+	torrent.Protocol = PROTOCOL_USENET
+	tracker = newStallTracker(start)
+	if reason := tracker.check(torrent, start.Add(61*time.Minute)); reason != "" {
+		t.Errorf("Expected peers rule not to be applied to usenet downloads, got '%s'", reason)
+	}
+
+	// Torrents waiting in download client queue are not stalled
+	torrent.Protocol = PROTOCOL_TORRENT
+	torrent.Status = TORRENT_DOWNLOAD_PENDING
+	tracker = newStallTracker(start)
+	if reason := tracker.check(torrent, start.Add(200*time.Minute)); reason != "" {
+		t.Errorf("Expected pending download not to be stalled, got '%s'", reason)
+	}
+
+	// ETA rule is only applied after grace period
+	torrent.Status = TORRENT_DOWNLOADING
+	torrent.PeersConnected = 1
+	torrent.ETA = start.Add(48 * time.Hour)
+	tracker = newStallTracker(start)
+	if reason := tracker.check(torrent, start.Add(time.Minute)); reason != "" {
+		t.Errorf("Expected ETA rule not to be applied during grace period, got '%s'", reason)
+	}
+	torrent.PercentDone = 0.01
+	if reason := tracker.check(torrent, start.Add(STALL_ETA_GRACE_PERIOD)); reason == "" {
+		t.Error("Expected download with ETA beyond limit to be stalled")
+	}
+}
+
+func TestSeedingGoals(t *testing.T) {
+	configuration.Config.System.SeedRatio = 1.5
+	configuration.Config.System.SeedTime = 0
+	configuration.Config.Indexers = map[string][]map[string]interface{}{
+		"torznab": []map[string]interface{}{
+			map[string]interface{}{"name": "private", "seed_ratio": 2, "seed_time": int64(72)},
+			map[string]interface{}{"name": "public", "seed_ratio": "0"},
+		},
+	}
+	defer func() {
+		configuration.Config.System.SeedRatio = 0
+		configuration.Config.Indexers = nil
+	}()
+
+	testData := []struct {
+		Torrent  Torrent
+		Ratio    float64
+		SeedTime int
+	}{
+		{Torrent{Indexer: "unknown"}, 1.5, 0},
+		{Torrent{Indexer: "private"}, 2, 72},
+		{Torrent{Indexer: "public"}, 0, 0},
+		{Torrent{Indexer: "private", Protocol: PROTOCOL_USENET}, 0, 0},
+	}
+
+	for _, data := range testData {
+		ratio, seedTime := getSeedingGoals(data.Torrent)
+		if ratio != data.Ratio || seedTime != data.SeedTime {
+			t.Errorf("Expected seeding goals of torrent from %s indexer to be (%f, %d), got (%f, %d) instead", data.Torrent.Indexer, data.Ratio, data.SeedTime, ratio, seedTime)
+		}
+	}
+	if hasSeedingGoals(Torrent{Indexer: "public"}) {
+		t.Error("Expected torrent without seeding goals not to be kept seeding")
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	root := "/tmp/flemzerd_test_import"
+	os.RemoveAll(root)
+	os.MkdirAll(fmt.Sprintf("%s/source/subfolder", root), 0755)
+	defer os.RemoveAll(root)
+	defer func() {
+		configuration.Config.Library.ImportMode = IMPORT_MOVE
+	}()
+
+	sourceFile := fmt.Sprintf("%s/source/subfolder/file.mkv", root)
+	f, _ := os.Create(sourceFile)
+	f.WriteString("media")
+	f.Close()
+
+	configuration.Config.Library.ImportMode = IMPORT_MOVE
+	if err := importPath(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/seeding", root), true); err != nil {
+		t.Fatal("Expected import to succeed, got error: ", err)
+	}
+	sourceInfo, sourceErr := os.Stat(sourceFile)
+	targetInfo, targetErr := os.Stat(fmt.Sprintf("%s/seeding/subfolder/file.mkv", root))
+	if sourceErr != nil || targetErr != nil {
+		t.Fatal("Expected file to be available in source and target folders when source is kept for seeding")
+	}
+	if !os.SameFile(sourceInfo, targetInfo) {
+		t.Error("Expected imported file to be a hardlink of source file")
+	}
+
+	configuration.Config.Library.ImportMode = IMPORT_COPY
+	if err := importPath(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/copy", root), false); err != nil {
+		t.Fatal("Expected import to succeed, got error: ", err)
+	}
+	copyInfo, err := os.Stat(fmt.Sprintf("%s/copy/subfolder/file.mkv", root))
+	if err != nil || os.SameFile(sourceInfo, copyInfo) || copyInfo.Size() != sourceInfo.Size() {
+		t.Error("Expected imported file to be a copy of source file")
+	}
+	if err := verifyCopy(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/copy", root)); err != nil {
+		t.Error("Expected copy to be verified, got error: ", err)
+	}
+	if err := verifyCopy(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/missing", root)); err == nil {
+		t.Error("Expected copy verification to fail when copied files are missing")
+	}
+
+	configuration.Config.Library.ImportMode = IMPORT_SYMLINK
+	if err := importPath(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/symlink", root), false); err != nil {
+		t.Fatal("Expected import to succeed, got error: ", err)
+	}
+	if linkTarget, err := os.Readlink(fmt.Sprintf("%s/symlink", root)); err != nil || linkTarget != fmt.Sprintf("%s/source", root) {
+		t.Error("Expected imported folder to be a symlink to source folder")
+	}
+
+	// Same-name upgrades replace library files without modifying data of replaced release still seeding
+	oldSeedingFile := fmt.Sprintf("%s/old_seeding.mkv", root)
+	newSeedingFile := fmt.Sprintf("%s/new_seeding.mkv", root)
+	libraryFile := fmt.Sprintf("%s/library.mkv", root)
+	ioutil.WriteFile(oldSeedingFile, []byte("old release"), 0644)
+	ioutil.WriteFile(newSeedingFile, []byte("new release"), 0644)
+	os.Link(oldSeedingFile, libraryFile)
+	configuration.Config.Library.ImportMode = IMPORT_HARDLINK
+	if err := importPath(newSeedingFile, libraryFile, true); err != nil {
+		t.Fatal("Expected import over existing library file to succeed, got error: ", err)
+	}
+	if content, _ := ioutil.ReadFile(oldSeedingFile); string(content) != "old release" {
+		t.Error("Expected data of replaced release to be left untouched")
+	}
+	newInfo, _ := os.Stat(newSeedingFile)
+	if libraryInfo, err := os.Stat(libraryFile); err != nil || !os.SameFile(newInfo, libraryInfo) {
+		t.Error("Expected library file to be replaced by a hardlink of new release")
+	}
+
+	configuration.Config.Library.ImportMode = IMPORT_SYMLINK
+	if err := importPath(oldSeedingFile, libraryFile, false); err != nil {
+		t.Fatal("Expected symlink import over existing library file to succeed, got error: ", err)
+	}
+	if linkTarget, err := os.Readlink(libraryFile); err != nil || linkTarget != oldSeedingFile {
+		t.Error("Expected library file to be replaced by a symlink to new release")
+	}
+	if entries, _ := ioutil.ReadDir(root); len(entries) != 7 {
+		t.Errorf("Expected no temporary file to be left next to imported files, got %d files", len(entries))
+	}
+
+	configuration.Config.Library.ImportMode = IMPORT_MOVE
+	if err := importPath(fmt.Sprintf("%s/source", root), fmt.Sprintf("%s/moved", root), false); err != nil {
+		t.Fatal("Expected import to succeed, got error: ", err)
+	}
+	if _, err := os.Stat(sourceFile); !os.IsNotExist(err) {
+		t.Error("Expected source to be moved when it is not kept for seeding")
+	}
+
+	if !isCrossDeviceError(&os.LinkError{Op: "rename", Err: syscall.EXDEV}) {
+		t.Error("Expected EXDEV rename error to be detected as a cross filesystem move")
+	}
+	if isCrossDeviceError(&os.LinkError{Op: "rename", Err: syscall.ENOENT}) {
+		t.Error("Expected ENOENT rename error not to be detected as a cross filesystem move")
+	}
+}
+
+func TestRetryImport(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_tmp"
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	os.MkdirAll(configuration.Config.Library.MoviePath, 0755)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	movie := Movie{
+		Title:         "test movie",
+		OriginalTitle: "test movie",
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name:        "test_torrent",
+					DownloadDir: fmt.Sprintf("%s/test_flemzerd_retry_import", configuration.Config.Library.MoviePath),
+				},
+			},
+		},
+	}
+	db.Client.Save(&movie)
+
+	if err := RetryImport(&movie); err == nil {
+		t.Error("Expected an error when retrying import of an item without failed import")
+	}
+
+	setImportFailed(&movie, errors.New("Import error"))
+	if err := RetryImport(&movie); err == nil {
+		t.Error("Expected import retry to fail when downloaded files are missing")
+	}
+	if !movie.DownloadingItem.ImportFailed {
+		t.Error("Expected item to be marked as failed import after failed retry")
+	}
+
+	os.Create(movie.DownloadingItem.TorrentList[0].DownloadDir)
+	if err := RetryImport(&movie); err != nil {
+		t.Error("Expected import retry to succeed, got error: ", err)
+	}
+	if movie.DownloadingItem.ImportFailed || movie.DownloadingItem.ImportFailureReason != "" {
+		t.Error("Expected failed import flag to be cleared after successful retry")
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/test_movie/test_torrent", configuration.Config.Library.MoviePath)); err != nil {
+		t.Error("Expected item to be imported into library after retry")
+	}
+}
+
+func TestReapSeedingTorrents(t *testing.T) {
+	db.ResetDb()
+	downloadersCollection = []Downloader{mock.Downloader{}, mock.ErrorDownloader{}}
+	defer Reset()
+
+	configuration.Config.Library.CustomTmpPath = "/tmp/flemzerd_test_seeding"
+	os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+	seededPath := fmt.Sprintf("%s/seeded", configuration.Config.Library.CustomTmpPath)
+	os.MkdirAll(seededPath, 0755)
+	defer os.RemoveAll(configuration.Config.Library.CustomTmpPath)
+
+	startSeeding("Downloader", "id", Torrent{Name: "ratio_not_reached", TorrentId: "ratio"}, "")
+	db.Client.Create(&SeedingTorrent{
+		Name:        "seed_time_reached",
+		Downloader:  "Downloader",
+		Path:        seededPath,
+		SeedTime:    1,
+		CompletedAt: time.Now().Add(-2 * time.Hour),
+	})
+	db.Client.Create(&SeedingTorrent{
+		Name:        "status_error",
+		Downloader:  "ErrorDownloader",
+		SeedTime:    1,
+		CompletedAt: time.Now().Add(-2 * time.Hour),
+	})
+	db.Client.Model(&SeedingTorrent{}).Where("name = ?", "ratio_not_reached").Update("seed_ratio", 1)
+
+	ReapSeedingTorrents()
+
+	var seedingTorrents []SeedingTorrent
+	db.Client.Order("id").Find(&seedingTorrents)
+	if len(seedingTorrents) != 2 || seedingTorrents[0].Name != "ratio_not_reached" || seedingTorrents[1].Name != "status_error" {
+		t.Errorf("Expected only torrent that reached its seeding goals to be removed, got %d seeding torrents left", len(seedingTorrents))
+	}
+	if _, err := os.Stat(seededPath); !os.IsNotExist(err) {
+		t.Error("Expected seeded torrent data to be removed with torrent")
+	}
+}
@@ -11,3 +11,17 @@ type Downloader interface {
 	RemoveTorrent(t Torrent) error
 	GetTorrentStatus(t *Torrent) error
 }
+
+// ProtocolDownloader is implemented by downloaders handling releases of another protocol than torrent (usenet for example)
+type ProtocolDownloader interface {
+	GetProtocol() string
+}
+
+// getDownloaderProtocol returns the protocol handled by downloader. Downloaders not implementing ProtocolDownloader handle torrents
+func getDownloaderProtocol(d Downloader) string {
+	if protocolDownloader, ok := d.(ProtocolDownloader); ok {
+		return protocolDownloader.GetProtocol()
+	}
+
+	return PROTOCOL_TORRENT
+}
@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"fmt"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/naming"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/jinzhu/gorm"
+	"github.com/rs/xid"
+)
+
+// getEpisodeLibraryPath returns the library folder of an episode downloaded with release t, and the file name (without extension) of the episode. An empty file name means release name is kept.
+// For multi-episode releases, lastNumber is the number of the last episode of the release (0 for single episode releases)
+func getEpisodeLibraryPath(episode Episode, t Torrent, number int, lastNumber int) (string, string) {
+	vars := naming.Variables{
+		Title:          episode.TvShow.GetTitle(),
+		OriginalTitle:  episode.TvShow.OriginalTitle,
+		Year:           episode.TvShow.FirstAired.Year(),
+		Quality:        t.MediaInfo.Quality,
+		ReleaseName:    t.Name,
+		ImdbId:         episode.TvShow.MediaIds.Imdb,
+		TmdbId:         episode.TvShow.MediaIds.Tmdb,
+		TvdbId:         episode.TvShow.MediaIds.Tvdb,
+		TraktId:        episode.TvShow.MediaIds.Trakt,
+		Show:           episode.TvShow.GetTitle(),
+		Season:         episode.Season,
+		Episode:        number,
+		EpisodeTitle:   episode.Title,
+		AbsoluteNumber: episode.AbsoluteNumber,
+	}
+	if lastNumber > number {
+		vars.LastEpisode = lastNumber
+	}
+
+	folder, file := configuration.Config.Naming.EpisodePath(vars)
+	return fmt.Sprintf("%s/%s", configuration.Config.Library.ShowPath, folder), file
+}
+
+// getCoveredEpisode returns the episode of the same show and season as episode, with given number, if it is waiting to be downloaded.
+// Returns false if episode does not exist in database, or if it is already downloaded (or downloading) separately
+func getCoveredEpisode(episode Episode, number int) (Episode, bool) {
+	var covered Episode
+	req := db.Client.Where("tv_show_id = ? AND season = ? AND number = ?", episode.TvShowID, episode.Season, number).Find(&covered)
+	if req.RecordNotFound() {
+		episode.GetLog().WithFields(log.Fields{
+			"covered_episode": number,
+		}).Warning("No episode found in database for episode contained in release")
+		return Episode{}, false
+	}
+
+	downloadingItem := covered.DownloadingItem
+	if downloadingItem.Downloaded || downloadingItem.Downloading || downloadingItem.Pending {
+		covered.GetLog().Info("Episode already downloaded separately. Skipping")
+		return Episode{}, false
+	}
+
+	return covered, true
+}
+
+// markEpisodeDownloaded marks episode as downloaded with release, when release has been downloaded and moved into library by the download of another episode (season pack or multi-episode release)
+func markEpisodeDownloaded(episode *Episode, release Torrent) {
+	for _, torrent := range episode.DownloadingItem.TorrentList {
+		db.Client.Unscoped().Delete(&torrent)
+	}
+
+	release.Model = gorm.Model{}
+	release.TorrentListID = 0
+	release.TorrentId = xid.New().String()
+	release.Failed = false
+	release.ScoreBreakdown = []TorrentScore{}
+
+	episode.DownloadingItem.TorrentList = []Torrent{release}
+	episode.DownloadingItem.Pending = false
+	episode.DownloadingItem.Downloading = false
+	episode.DownloadingItem.Downloaded = true
+	episode.DownloadingItem.DownloadFailed = false
+	episode.DownloadingItem.TorrentsNotFound = false
+	db.Client.Save(episode)
+
+	episode.GetLog().WithFields(log.Fields{
+		"release": release.Name,
+	}).Info("Episode marked as downloaded")
+}
+
+// markCoveredEpisodesDownloaded marks all other episodes contained in a multi-episode release downloaded for episode as downloaded
+func markCoveredEpisodesDownloaded(episode Episode, release Torrent, numbers []int) {
+	for _, number := range numbers {
+		if number == episode.Number {
+			continue
+		}
+
+		covered, ok := getCoveredEpisode(episode, number)
+		if !ok {
+			continue
+		}
+		markEpisodeDownloaded(&covered, release)
+	}
+}
@@ -0,0 +1,407 @@
+package deluge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/upgear/go-kit/retry"
+)
+
+var module Module
+
+// Since torrents in deluge are identified by their info hash and torrent objects in flemzerd have their own ID, we need to know which deluge torrent correspond to which flemzerd torrent
+// This map stores "flemzerd torrent id" -> "deluge torrent hash" relations
+var torrentsMapping map[string]string
+var torrentsMappingMutex sync.Mutex
+
+var torrentStatusFields = []string{
+	"name",
+	"state",
+	"progress",
+	"total_size",
+	"download_payload_rate",
+	"upload_payload_rate",
+	"eta",
+	"save_path",
+	"num_peers",
+	"num_seeds",
+	"ratio",
+}
+
+type DelugeDownloader struct {
+	Address   string
+	Port      int
+	Password  string
+	client    *http.Client
+	requestId int
+	mutex     sync.Mutex
+}
+
+type DelugeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e DelugeError) Error() string {
+	return fmt.Sprintf("deluge error (code %d): %s", e.Code, e.Message)
+}
+
+type delugeRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Id     int           `json:"id"`
+}
+
+type delugeResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *DelugeError    `json:"error"`
+	Id     int             `json:"id"`
+}
+
+type delugeTorrent struct {
+	Name                string  `json:"name"`
+	State               string  `json:"state"`
+	Progress            float64 `json:"progress"`
+	TotalSize           int64   `json:"total_size"`
+	DownloadPayloadRate float64 `json:"download_payload_rate"`
+	UploadPayloadRate   float64 `json:"upload_payload_rate"`
+	Eta                 float64 `json:"eta"`
+	SavePath            string  `json:"save_path"`
+	NumPeers            int     `json:"num_peers"`
+	NumSeeds            int     `json:"num_seeds"`
+	Ratio               float64 `json:"ratio"`
+}
+
+func New(address string, port int, password string) *DelugeDownloader {
+	module = Module{
+		Name: "deluge",
+		Type: "downloader",
+		Status: ModuleStatus{
+			Alive:   true,
+			Message: "",
+		},
+	}
+
+	return &DelugeDownloader{
+		Address:  address,
+		Port:     port,
+		Password: password,
+	}
+}
+
+func (d *DelugeDownloader) Init() error {
+	if !strings.HasPrefix(d.Address, "http") {
+		d.Address = fmt.Sprintf("http://%s", d.Address)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot create deluge session cookie jar")
+	}
+	d.client = &http.Client{
+		Timeout: time.Duration(HTTP_TIMEOUT * time.Second),
+		Jar:     jar,
+	}
+
+	torrentsMappingMutex.Lock()
+	torrentsMapping = make(map[string]string)
+	torrentsMappingMutex.Unlock()
+
+	return nil
+}
+
+func (d *DelugeDownloader) GetName() string {
+	return "deluge"
+}
+
+// call performs a JSON-RPC call to deluge web UI and decodes its result into result
+func (d *DelugeDownloader) call(method string, result interface{}, params ...interface{}) error {
+	if d.client == nil {
+		return errors.New("deluge client not initialized")
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	d.mutex.Lock()
+	d.requestId++
+	id := d.requestId
+	d.mutex.Unlock()
+
+	body, _ := json.Marshal(delugeRequest{
+		Method: method,
+		Params: params,
+		Id:     id,
+	})
+
+	res, err := d.client.Post(fmt.Sprintf("%s:%d/json", d.Address, d.Port), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "cannot perform HTTP request to deluge")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("deluge returned unexpected status code %d", res.StatusCode)
+	}
+
+	var response delugeResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return errors.Wrap(err, "cannot parse deluge response")
+	}
+	if response.Error != nil {
+		return *response.Error
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return errors.Wrapf(err, "cannot parse deluge %s result", method)
+		}
+	}
+
+	return nil
+}
+
+// authenticatedCall performs a JSON-RPC call, logging in to deluge web UI first if needed
+func (d *DelugeDownloader) authenticatedCall(method string, result interface{}, params ...interface{}) error {
+	err := d.call(method, result, params...)
+	if delugeErr, ok := err.(DelugeError); !ok || delugeErr.Code != 1 {
+		return err
+	}
+
+	// Error code 1 is returned when session is not authenticated
+	if err := d.login(); err != nil {
+		return err
+	}
+
+	return d.call(method, result, params...)
+}
+
+// login authenticates against deluge web UI and connects it to the first known daemon if needed
+func (d *DelugeDownloader) login() error {
+	var authenticated bool
+	if err := d.call("auth.login", &authenticated, d.Password); err != nil {
+		return err
+	}
+	if !authenticated {
+		return errors.New("Credentials refused when attempting to connect to deluge")
+	}
+
+	var connected bool
+	if err := d.call("web.connected", &connected); err != nil {
+		return err
+	}
+	if connected {
+		return nil
+	}
+
+	var hosts [][]interface{}
+	if err := d.call("web.get_hosts", &hosts); err != nil {
+		return err
+	}
+	if len(hosts) == 0 || len(hosts[0]) == 0 {
+		return errors.New("No deluge daemon available in deluge web UI")
+	}
+
+	return d.call("web.connect", nil, hosts[0][0])
+}
+
+func (d *DelugeDownloader) Status() (Module, error) {
+	log.Debug("Checking deluge downloader status")
+
+	if err := d.login(); err != nil {
+		module.Status.Alive = false
+		module.Status.Message = err.Error()
+
+		return module, err
+	}
+
+	module.Status.Alive = true
+	module.Status.Message = ""
+
+	return module, nil
+}
+
+func (d *DelugeDownloader) AddTorrent(t Torrent) (string, error) {
+	method := "core.add_torrent_url"
+	if strings.HasPrefix(t.Link, "magnet:") {
+		method = "core.add_torrent_magnet"
+	}
+
+	var hash string
+	options := map[string]interface{}{
+		"download_location": t.DownloadDir,
+	}
+	if err := d.authenticatedCall(method, &hash, t.Link, options); err != nil {
+		return "", errors.Wrap(err, "cannot add torrent to deluge")
+	}
+	if hash == "" {
+		return "", errors.New("deluge did not return added torrent hash")
+	}
+
+	d.AddTorrentMapping(t.TorrentId, hash)
+
+	return hash, nil
+}
+
+func (d *DelugeDownloader) AddTorrentMapping(flemzerID string, delugeID string) {
+	torrentsMappingMutex.Lock()
+	torrentsMapping[flemzerID] = delugeID
+	torrentsMappingMutex.Unlock()
+}
+
+func getTorrentHash(t Torrent) (string, error) {
+	torrentsMappingMutex.Lock()
+	hash, ok := torrentsMapping[t.TorrentId]
+	torrentsMappingMutex.Unlock()
+
+	if !ok {
+		return "", errors.New("Could not find corresponding deluge torrent")
+	}
+
+	return hash, nil
+}
+
+func (d *DelugeDownloader) RemoveTorrent(t Torrent) error {
+	hash, err := getTorrentHash(t)
+	if err != nil {
+		return err
+	}
+
+	if err := d.authenticatedCall("core.remove_torrent", nil, hash, false); err != nil {
+		return errors.Wrap(err, "cannot remove torrent from deluge")
+	}
+
+	return nil
+}
+
+func convertState(torrent delugeTorrent) int {
+	switch torrent.State {
+	case "Downloading", "Checking", "Allocating", "Moving":
+		return TORRENT_DOWNLOADING
+	case "Seeding":
+		return TORRENT_SEEDING
+	case "Paused":
+		// Completed torrents paused by seeding limits are done downloading
+		if torrent.Progress >= 100 {
+			return TORRENT_SEEDING
+		}
+		return TORRENT_STOPPED
+	case "Queued":
+		return TORRENT_DOWNLOAD_PENDING
+	default:
+		return TORRENT_UNKNOWN_STATUS
+	}
+}
+
+func (d *DelugeDownloader) GetTorrentStatus(t *Torrent) error {
+	hash, err := getTorrentHash(*t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	var torrent delugeTorrent
+	var found bool
+	if err := retry.Double(3).Run(func() error {
+		var result json.RawMessage
+		if err := d.authenticatedCall("core.get_torrent_status", &result, hash, torrentStatusFields); err != nil {
+			return err
+		}
+
+		// Deluge returns an empty object for unknown torrents
+		var fields map[string]interface{}
+		if err := json.Unmarshal(result, &fields); err != nil {
+			return err
+		}
+		found = len(fields) != 0
+
+		return json.Unmarshal(result, &torrent)
+	}); err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.Wrap(err, "cannot get torrent status from deluge")
+	}
+
+	if !found {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.New("Could not find torrent in deluge")
+	}
+
+	convertStatus(t, torrent)
+	return nil
+}
+
+// GetTorrentsStatus retrieves status of several torrents with a single request to deluge
+func (d *DelugeDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	hashes := make([]string, len(torrents))
+	var requestedHashes []string
+	for i, t := range torrents {
+		hash, err := getTorrentHash(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+			continue
+		}
+		hashes[i] = hash
+		requestedHashes = append(requestedHashes, hash)
+	}
+	if len(requestedHashes) == 0 {
+		return statusErrors
+	}
+
+	var delugeTorrents map[string]delugeTorrent
+	if err := retry.Double(3).Run(func() error {
+		return d.authenticatedCall("core.get_torrents_status", &delugeTorrents, map[string]interface{}{"id": requestedHashes}, torrentStatusFields)
+	}); err != nil {
+		for i, t := range torrents {
+			if hashes[i] == "" {
+				continue
+			}
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.Wrap(err, "cannot get torrents status from deluge")
+		}
+		return statusErrors
+	}
+
+	for i, t := range torrents {
+		if hashes[i] == "" {
+			continue
+		}
+
+		torrent, ok := delugeTorrents[hashes[i]]
+		if !ok {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.New("Could not find torrent in deluge")
+			continue
+		}
+		convertStatus(t, torrent)
+	}
+
+	return statusErrors
+}
+
+func convertStatus(t *Torrent, torrent delugeTorrent) {
+	t.ETA = time.Now().Add(time.Duration(torrent.Eta) * time.Second)
+	t.PercentDone = torrent.Progress / 100
+	t.TotalSize = torrent.TotalSize
+	t.RateDownload = int64(torrent.DownloadPayloadRate)
+	t.RateUpload = int64(torrent.UploadPayloadRate)
+	t.PeersConnected = torrent.NumPeers + torrent.NumSeeds
+	// Deluge returns a negative ratio when nothing has been downloaded yet
+	t.UploadRatio = 0
+	if torrent.Ratio > 0 {
+		t.UploadRatio = torrent.Ratio
+	}
+	t.Status = convertState(torrent)
+}
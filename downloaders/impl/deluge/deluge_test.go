@@ -0,0 +1,251 @@
+package deluge
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+const fakeHash = "8c4adbf9ebe66f1d804fb6a4fb9b74966c3ab609"
+
+type fakeDeluge struct {
+	connected bool
+	torrents  map[string]delugeTorrent
+}
+
+func (f *fakeDeluge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		Id     int               `json:"id"`
+	}
+	if r.URL.Path != "/json" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reply := func(result interface{}, err *DelugeError) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": result,
+			"error":  err,
+			"id":     req.Id,
+		})
+	}
+	param := func(i int) string {
+		var s string
+		json.Unmarshal(req.Params[i], &s)
+		return s
+	}
+
+	if req.Method == "auth.login" {
+		if param(0) != "password" {
+			reply(false, nil)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: "session", Path: "/"})
+		reply(true, nil)
+		return
+	}
+
+	if cookie, err := r.Cookie("_session_id"); err != nil || cookie.Value != "session" {
+		reply(nil, &DelugeError{Code: 1, Message: "Not authenticated"})
+		return
+	}
+
+	switch req.Method {
+	case "web.connected":
+		reply(f.connected, nil)
+	case "web.get_hosts":
+		reply([][]interface{}{{"host_id", "127.0.0.1", 58846, "localclient"}}, nil)
+	case "web.connect":
+		f.connected = param(0) == "host_id"
+		reply(nil, nil)
+	case "core.add_torrent_url", "core.add_torrent_magnet":
+		var options map[string]string
+		json.Unmarshal(req.Params[1], &options)
+		f.torrents[fakeHash] = delugeTorrent{
+			Name:      "torrent",
+			State:     "Downloading",
+			Progress:  50,
+			TotalSize: 1000,
+			SavePath:  options["download_location"],
+		}
+		reply(fakeHash, nil)
+	case "core.remove_torrent":
+		delete(f.torrents, param(0))
+		reply(true, nil)
+	case "core.get_torrent_status":
+		if torrent, ok := f.torrents[param(0)]; ok {
+			reply(torrent, nil)
+		} else {
+			reply(map[string]interface{}{}, nil)
+		}
+	case "core.get_torrents_status":
+		var filter map[string][]string
+		json.Unmarshal(req.Params[0], &filter)
+		result := make(map[string]delugeTorrent)
+		for _, hash := range filter["id"] {
+			if torrent, ok := f.torrents[hash]; ok {
+				result[hash] = torrent
+			}
+		}
+		reply(result, nil)
+	default:
+		reply(nil, &DelugeError{Code: 2, Message: "Unknown method"})
+	}
+}
+
+func newTestDownloader(t *testing.T, password string) (*DelugeDownloader, *fakeDeluge, func()) {
+	fake := &fakeDeluge{
+		torrents: make(map[string]delugeTorrent),
+	}
+	server := httptest.NewServer(fake)
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	d := New(host, port, password)
+	if err := d.Init(); err != nil {
+		t.Fatal("Expected deluge downloader init to succeed, got error: ", err)
+	}
+
+	return d, fake, server.Close
+}
+
+func TestStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "password")
+	defer closeServer()
+
+	mod, err := d.Status()
+	if err != nil {
+		t.Error("Expected status check to succeed, got error: ", err)
+	}
+	if !mod.Status.Alive {
+		t.Error("Expected module to be alive")
+	}
+	if !fake.connected {
+		t.Error("Expected deluge web UI to be connected to daemon after status check")
+	}
+
+	refused, _, closeRefused := newTestDownloader(t, "wrong")
+	defer closeRefused()
+
+	mod, err = refused.Status()
+	if err == nil {
+		t.Error("Expected status check to fail with refused credentials")
+	}
+	if mod.Status.Alive {
+		t.Error("Expected module not to be alive with refused credentials")
+	}
+}
+
+func TestTorrentLifecycle(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "password")
+	defer closeServer()
+
+	torrent := Torrent{
+		TorrentId:   "flemzerd_id",
+		Link:        "http://indexer/torrent.torrent",
+		DownloadDir: "/tmp/download",
+	}
+
+	id, err := d.AddTorrent(torrent)
+	if err != nil {
+		t.Error("Expected torrent to be added, got error: ", err)
+	}
+	if id != fakeHash {
+		t.Errorf("Expected downloader id to be '%s', got '%s' instead", fakeHash, id)
+	}
+	if fake.torrents[fakeHash].SavePath != "/tmp/download" {
+		t.Error("Expected torrent to be downloaded into torrent download dir")
+	}
+
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get torrent status, got error: ", err)
+	}
+	if torrent.Status != TORRENT_DOWNLOADING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_DOWNLOADING, torrent.Status)
+	}
+	if torrent.PercentDone != 0.5 || torrent.TotalSize != 1000 {
+		t.Error("Expected torrent progress to be updated from deluge")
+	}
+
+	if err := d.RemoveTorrent(torrent); err != nil {
+		t.Error("Expected torrent to be removed, got error: ", err)
+	}
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of a removed torrent")
+	}
+	if torrent.Status != TORRENT_UNKNOWN_STATUS {
+		t.Error("Expected removed torrent status to be unknown")
+	}
+}
+
+func TestAddTorrentMapping(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = delugeTorrent{State: "Seeding", Progress: 100}
+	torrent := Torrent{TorrentId: "recovered_id"}
+
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of an unmapped torrent")
+	}
+
+	d.AddTorrentMapping(torrent.TorrentId, fakeHash)
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get status of recovered torrent, got error: ", err)
+	}
+	if torrent.Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_SEEDING, torrent.Status)
+	}
+}
+
+func TestGetTorrentsStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = delugeTorrent{State: "Seeding", Progress: 100}
+	d.AddTorrentMapping("seeding_id", fakeHash)
+	d.AddTorrentMapping("removed_id", "removed_hash")
+
+	torrents := []*Torrent{
+		&Torrent{TorrentId: "seeding_id"},
+		&Torrent{TorrentId: "removed_id"},
+		&Torrent{TorrentId: "unmapped_id"},
+	}
+	statusErrors := d.GetTorrentsStatus(torrents)
+	if statusErrors[0] != nil || torrents[0].Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead (error: %v)", TORRENT_SEEDING, torrents[0].Status, statusErrors[0])
+	}
+	for i := 1; i < len(torrents); i++ {
+		if statusErrors[i] == nil || torrents[i].Status != TORRENT_UNKNOWN_STATUS {
+			t.Errorf("Expected an error and unknown status for torrent %s", torrents[i].TorrentId)
+		}
+	}
+}
+
+func TestConvertState(t *testing.T) {
+	testData := []struct {
+		Torrent  delugeTorrent
+		Expected int
+	}{
+		{delugeTorrent{State: "Downloading", Progress: 50}, TORRENT_DOWNLOADING},
+		{delugeTorrent{State: "Seeding", Progress: 100}, TORRENT_SEEDING},
+		{delugeTorrent{State: "Paused", Progress: 50}, TORRENT_STOPPED},
+		{delugeTorrent{State: "Paused", Progress: 100}, TORRENT_SEEDING},
+		{delugeTorrent{State: "Queued"}, TORRENT_DOWNLOAD_PENDING},
+		{delugeTorrent{State: "Error"}, TORRENT_UNKNOWN_STATUS},
+	}
+
+	for _, testCase := range testData {
+		if result := convertState(testCase.Torrent); result != testCase.Expected {
+			t.Errorf("Expected deluge torrent %+v state to be converted to %d, got %d instead", testCase.Torrent, testCase.Expected, result)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package nzbget
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/upgear/go-kit/retry"
+)
+
+var module Module
+
+// Since downloads in nzbget have specific ID and torrent objects in flemzerd have their own ID, we need to know which nzbget download correspond to which flemzerd torrent
+// This map stores "flemzerd torrent id" -> "nzbget NZBID" relations
+var torrentsMapping map[string]string
+var torrentsMappingMutex sync.Mutex
+
+type NzbgetDownloader struct {
+	Address  string
+	Port     int
+	User     string
+	Password string
+	Category string
+	client   *http.Client
+}
+
+type NzbgetError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e NzbgetError) Error() string {
+	return fmt.Sprintf("nzbget error (code %d): %s", e.Code, e.Message)
+}
+
+type nzbgetRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Id     int           `json:"id"`
+}
+
+type nzbgetResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *NzbgetError    `json:"error"`
+}
+
+type nzbgetGroup struct {
+	NZBID           int    `json:"NZBID"`
+	NZBName         string `json:"NZBName"`
+	Status          string `json:"Status"`
+	FileSizeMB      int64  `json:"FileSizeMB"`
+	RemainingSizeMB int64  `json:"RemainingSizeMB"`
+}
+
+type nzbgetHistoryItem struct {
+	NZBID      int    `json:"NZBID"`
+	Name       string `json:"Name"`
+	Status     string `json:"Status"`
+	DestDir    string `json:"DestDir"`
+	FinalDir   string `json:"FinalDir"`
+	FileSizeMB int64  `json:"FileSizeMB"`
+}
+
+type nzbgetStatus struct {
+	DownloadRate int64 `json:"DownloadRate"`
+}
+
+func New(address string, port int, user string, password string, category string) *NzbgetDownloader {
+	module = Module{
+		Name: "nzbget",
+		Type: "downloader",
+		Status: ModuleStatus{
+			Alive:   true,
+			Message: "",
+		},
+	}
+
+	return &NzbgetDownloader{
+		Address:  address,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Category: category,
+	}
+}
+
+func (d *NzbgetDownloader) Init() error {
+	if !strings.HasPrefix(d.Address, "http") {
+		d.Address = fmt.Sprintf("http://%s", d.Address)
+	}
+
+	d.client = &http.Client{
+		Timeout: time.Duration(HTTP_TIMEOUT * time.Second),
+	}
+
+	torrentsMappingMutex.Lock()
+	torrentsMapping = make(map[string]string)
+	torrentsMappingMutex.Unlock()
+
+	return nil
+}
+
+func (d *NzbgetDownloader) GetName() string {
+	return "nzbget"
+}
+
+func (d *NzbgetDownloader) GetProtocol() string {
+	return PROTOCOL_USENET
+}
+
+// call performs a JSON-RPC call to nzbget and decodes its result into result
+func (d *NzbgetDownloader) call(method string, result interface{}, params ...interface{}) error {
+	if d.client == nil {
+		return errors.New("nzbget client not initialized")
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, _ := json.Marshal(nzbgetRequest{
+		Method: method,
+		Params: params,
+		Id:     1,
+	})
+
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s:%d/jsonrpc", d.Address, d.Port), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "cannot create HTTP request for nzbget")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.SetBasicAuth(d.User, d.Password)
+
+	res, err := d.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "cannot perform HTTP request to nzbget")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return errors.New("Credentials refused when attempting to connect to nzbget")
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("nzbget returned unexpected status code %d", res.StatusCode)
+	}
+
+	var response nzbgetResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return errors.Wrap(err, "cannot parse nzbget response")
+	}
+	if response.Error != nil {
+		return *response.Error
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return errors.Wrapf(err, "cannot parse nzbget %s result", method)
+		}
+	}
+
+	return nil
+}
+
+func (d *NzbgetDownloader) Status() (Module, error) {
+	log.Debug("Checking nzbget downloader status")
+
+	var version string
+	if err := d.call("version", &version); err != nil {
+		module.Status.Alive = false
+		module.Status.Message = err.Error()
+
+		return module, err
+	}
+
+	module.Status.Alive = true
+	module.Status.Message = ""
+
+	return module, nil
+}
+
+func (d *NzbgetDownloader) AddTorrent(t Torrent) (string, error) {
+	var id int
+	err := d.call("append", &id,
+		fmt.Sprintf("%s.nzb", t.Name), // NZBFilename
+		t.Link,                        // Content: URL of the nzb file
+		d.Category,                    // Category
+		0,                             // Priority
+		false,                         // AddToTop
+		false,                         // AddPaused
+		"",                            // DupeKey
+		0,                             // DupeScore
+		"FORCE",                       // DupeMode
+		[]interface{}{},               // PPParameters
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot add nzb to nzbget")
+	}
+	if id <= 0 {
+		return "", errors.New("nzbget refused nzb")
+	}
+
+	nzbID := strconv.Itoa(id)
+	d.AddTorrentMapping(t.TorrentId, nzbID)
+
+	return nzbID, nil
+}
+
+func (d *NzbgetDownloader) AddTorrentMapping(flemzerID string, nzbgetID string) {
+	torrentsMappingMutex.Lock()
+	torrentsMapping[flemzerID] = nzbgetID
+	torrentsMappingMutex.Unlock()
+}
+
+func getNzbId(t Torrent) (int, error) {
+	torrentsMappingMutex.Lock()
+	id, ok := torrentsMapping[t.TorrentId]
+	torrentsMappingMutex.Unlock()
+
+	if !ok {
+		return 0, errors.New("Could not find corresponding nzbget download")
+	}
+
+	return strconv.Atoi(id)
+}
+
+// RemoveTorrent removes download from nzbget queue and history. Downloaded files are kept
+func (d *NzbgetDownloader) RemoveTorrent(t Torrent) error {
+	id, err := getNzbId(t)
+	if err != nil {
+		return err
+	}
+
+	var groups []nzbgetGroup
+	if err := d.call("listgroups", &groups); err != nil {
+		return errors.Wrap(err, "cannot get download list from nzbget")
+	}
+
+	command := "HistoryDelete"
+	for _, group := range groups {
+		if group.NZBID == id {
+			command = "GroupDelete"
+		}
+	}
+
+	var success bool
+	if err := d.call("editqueue", &success, command, "", []int{id}); err != nil {
+		return errors.Wrap(err, "cannot remove download from nzbget")
+	}
+	if !success {
+		return errors.New("nzbget could not remove download")
+	}
+
+	return nil
+}
+
+// convertState maps nzbget download status to torrent status. Successful downloads are considered as seeding torrents (download done) and failed downloads as stopped torrents
+func convertState(status string) int {
+	switch {
+	case status == "QUEUED":
+		return TORRENT_DOWNLOAD_PENDING
+	case status == "PAUSED":
+		return TORRENT_STOPPED
+	case strings.HasPrefix(status, "SUCCESS"):
+		return TORRENT_SEEDING
+	case strings.HasPrefix(status, "FAILURE"), strings.HasPrefix(status, "DELETED"):
+		return TORRENT_STOPPED
+	case strings.HasPrefix(status, "WARNING"):
+		return TORRENT_UNKNOWN_STATUS
+	case status == "":
+		return TORRENT_UNKNOWN_STATUS
+	default:
+		// Downloading or post-processing
+		return TORRENT_DOWNLOADING
+	}
+}
+
+func (d *NzbgetDownloader) GetTorrentStatus(t *Torrent) error {
+	id, err := getNzbId(*t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	var groups []nzbgetGroup
+	var history []nzbgetHistoryItem
+	var status nzbgetStatus
+	if err := retry.Double(3).Run(func() error {
+		if err := d.call("listgroups", &groups); err != nil {
+			return err
+		}
+		if err := d.call("history", &history, false); err != nil {
+			return err
+		}
+		return d.call("status", &status)
+	}); err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.Wrap(err, "cannot get download list from nzbget")
+	}
+
+	return convertStatus(t, id, groups, history, status)
+}
+
+// GetTorrentsStatus retrieves status of several downloads with a single set of requests to nzbget
+func (d *NzbgetDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	var groups []nzbgetGroup
+	var history []nzbgetHistoryItem
+	var status nzbgetStatus
+	if err := retry.Double(3).Run(func() error {
+		if err := d.call("listgroups", &groups); err != nil {
+			return err
+		}
+		if err := d.call("history", &history, false); err != nil {
+			return err
+		}
+		return d.call("status", &status)
+	}); err != nil {
+		for i := range torrents {
+			torrents[i].Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.Wrap(err, "cannot get download list from nzbget")
+		}
+		return statusErrors
+	}
+
+	for i, t := range torrents {
+		id, err := getNzbId(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+			continue
+		}
+		statusErrors[i] = convertStatus(t, id, groups, history, status)
+	}
+
+	return statusErrors
+}
+
+// convertStatus updates torrent t from nzbget download id found in download queue or history
+func convertStatus(t *Torrent, id int, groups []nzbgetGroup, history []nzbgetHistoryItem, status nzbgetStatus) error {
+	for _, group := range groups {
+		if group.NZBID != id {
+			continue
+		}
+
+		t.ETA = time.Now()
+		if status.DownloadRate > 0 {
+			t.ETA = t.ETA.Add(time.Duration(group.RemainingSizeMB*1024*1024/status.DownloadRate) * time.Second)
+		}
+		if group.FileSizeMB > 0 {
+			t.PercentDone = float64(group.FileSizeMB-group.RemainingSizeMB) / float64(group.FileSizeMB)
+		}
+		t.TotalSize = group.FileSizeMB * 1024 * 1024
+		t.RateDownload = status.DownloadRate
+		t.RateUpload = 0
+		t.Status = convertState(group.Status)
+
+		return nil
+	}
+
+	for _, item := range history {
+		if item.NZBID != id {
+			continue
+		}
+
+		t.ETA = time.Now()
+		t.RateDownload = 0
+		t.RateUpload = 0
+		t.TotalSize = item.FileSizeMB * 1024 * 1024
+		t.Status = convertState(item.Status)
+
+		if t.Status == TORRENT_SEEDING {
+			t.PercentDone = 1
+			// Downloads cannot be done in a specific directory: downloaded files are moved into library from nzbget destination folder
+			destination := item.FinalDir
+			if destination == "" {
+				destination = item.DestDir
+			}
+			if destination != "" {
+				t.DownloadDir = destination
+				t.Name = filepath.Base(destination)
+			}
+		}
+
+		return nil
+	}
+
+	t.Status = TORRENT_UNKNOWN_STATUS
+	return errors.New("Could not find download in nzbget")
+}
@@ -0,0 +1,225 @@
+package nzbget
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+const fakeNzbId = 42
+
+type fakeNzbget struct {
+	groups  map[int]nzbgetGroup
+	history map[int]nzbgetHistoryItem
+}
+
+func (f *fakeNzbget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if r.URL.Path != "/jsonrpc" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reply := func(result interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}
+
+	switch req.Method {
+	case "version":
+		reply("21.0")
+	case "append":
+		var name string
+		json.Unmarshal(req.Params[0], &name)
+		f.groups[fakeNzbId] = nzbgetGroup{
+			NZBID:           fakeNzbId,
+			NZBName:         name,
+			Status:          "DOWNLOADING",
+			FileSizeMB:      1000,
+			RemainingSizeMB: 500,
+		}
+		reply(fakeNzbId)
+	case "listgroups":
+		list := []nzbgetGroup{}
+		for _, group := range f.groups {
+			list = append(list, group)
+		}
+		reply(list)
+	case "history":
+		list := []nzbgetHistoryItem{}
+		for _, item := range f.history {
+			list = append(list, item)
+		}
+		reply(list)
+	case "status":
+		reply(nzbgetStatus{DownloadRate: 1024 * 1024})
+	case "editqueue":
+		var command string
+		var ids []int
+		json.Unmarshal(req.Params[0], &command)
+		json.Unmarshal(req.Params[2], &ids)
+		for _, id := range ids {
+			if command == "GroupDelete" {
+				delete(f.groups, id)
+			} else {
+				delete(f.history, id)
+			}
+		}
+		reply(true)
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": NzbgetError{Code: 1, Message: "Invalid procedure"}})
+	}
+}
+
+func newTestDownloader(t *testing.T, user string, password string) (*NzbgetDownloader, *fakeNzbget, func()) {
+	fake := &fakeNzbget{
+		groups:  make(map[int]nzbgetGroup),
+		history: make(map[int]nzbgetHistoryItem),
+	}
+	server := httptest.NewServer(fake)
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	d := New(host, port, user, password, "")
+	if err := d.Init(); err != nil {
+		t.Fatal("Expected nzbget downloader init to succeed, got error: ", err)
+	}
+
+	return d, fake, server.Close
+}
+
+func TestStatus(t *testing.T) {
+	d, _, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	if mod, err := d.Status(); err != nil || !mod.Status.Alive {
+		t.Error("Expected nzbget to be alive, got error: ", err)
+	}
+
+	refused, _, closeRefused := newTestDownloader(t, "user", "wrong")
+	defer closeRefused()
+
+	if mod, err := refused.Status(); err == nil || mod.Status.Alive {
+		t.Error("Expected status check to fail with refused credentials")
+	}
+}
+
+func TestDownloadLifecycle(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	release := Torrent{
+		TorrentId: "flemzerd_id",
+		Name:      "Test.Show.S01E01.720p",
+		Link:      "http://indexer/getnzb/1.nzb",
+		Protocol:  PROTOCOL_USENET,
+	}
+
+	id, err := d.AddTorrent(release)
+	if err != nil {
+		t.Error("Expected nzb to be added, got error: ", err)
+	}
+	if id != strconv.Itoa(fakeNzbId) {
+		t.Errorf("Expected downloader id to be '%d', got '%s' instead", fakeNzbId, id)
+	}
+	if fake.groups[fakeNzbId].NZBName != "Test.Show.S01E01.720p.nzb" {
+		t.Error("Expected nzb to be named after release name")
+	}
+
+	if err := d.GetTorrentStatus(&release); err != nil {
+		t.Error("Expected to get download status, got error: ", err)
+	}
+	if release.Status != TORRENT_DOWNLOADING || release.PercentDone != 0.5 || release.RateDownload != 1024*1024 {
+		t.Errorf("Expected queued download progress to be retrieved from nzbget, got %+v", release)
+	}
+
+	delete(fake.groups, fakeNzbId)
+	fake.history[fakeNzbId] = nzbgetHistoryItem{
+		NZBID:    fakeNzbId,
+		Status:   "SUCCESS/UNPACK",
+		DestDir:  "/downloads/dst/Test.Show.S01E01.720p",
+		FinalDir: "",
+	}
+	if err := d.GetTorrentStatus(&release); err != nil {
+		t.Error("Expected to get download status, got error: ", err)
+	}
+	if release.Status != TORRENT_SEEDING {
+		t.Errorf("Expected successful download status to be %d, got %d instead", TORRENT_SEEDING, release.Status)
+	}
+	if release.DownloadDir != "/downloads/dst/Test.Show.S01E01.720p" {
+		t.Errorf("Expected download location to be retrieved from nzbget history, got '%s'", release.DownloadDir)
+	}
+
+	if err := d.RemoveTorrent(release); err != nil {
+		t.Error("Expected download to be removed, got error: ", err)
+	}
+	if len(fake.history) != 0 {
+		t.Error("Expected download to be removed from nzbget history")
+	}
+	if err := d.GetTorrentStatus(&release); err == nil || release.Status != TORRENT_UNKNOWN_STATUS {
+		t.Error("Expected an error when getting status of a removed download")
+	}
+}
+
+func TestGetTorrentsStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	fake.groups[1] = nzbgetGroup{NZBID: 1, Status: "DOWNLOADING", FileSizeMB: 1000, RemainingSizeMB: 500}
+	fake.history[2] = nzbgetHistoryItem{NZBID: 2, Status: "SUCCESS/UNPACK", DestDir: "/downloads/dst/job"}
+	d.AddTorrentMapping("queued_id", "1")
+	d.AddTorrentMapping("done_id", "2")
+	d.AddTorrentMapping("removed_id", "3")
+
+	torrents := []*Torrent{
+		&Torrent{TorrentId: "queued_id"},
+		&Torrent{TorrentId: "done_id"},
+		&Torrent{TorrentId: "removed_id"},
+		&Torrent{TorrentId: "unmapped_id"},
+	}
+	statusErrors := d.GetTorrentsStatus(torrents)
+	if statusErrors[0] != nil || torrents[0].Status != TORRENT_DOWNLOADING {
+		t.Errorf("Expected queued download status to be %d, got %d instead (error: %v)", TORRENT_DOWNLOADING, torrents[0].Status, statusErrors[0])
+	}
+	if statusErrors[1] != nil || torrents[1].Status != TORRENT_SEEDING || torrents[1].DownloadDir != "/downloads/dst/job" {
+		t.Errorf("Expected successful download status to be %d, got %d instead (error: %v)", TORRENT_SEEDING, torrents[1].Status, statusErrors[1])
+	}
+	for i := 2; i < len(torrents); i++ {
+		if statusErrors[i] == nil || torrents[i].Status != TORRENT_UNKNOWN_STATUS {
+			t.Errorf("Expected an error and unknown status for download %s", torrents[i].TorrentId)
+		}
+	}
+}
+
+func TestConvertState(t *testing.T) {
+	testData := map[string]int{
+		"DOWNLOADING":    TORRENT_DOWNLOADING,
+		"UNPACKING":      TORRENT_DOWNLOADING,
+		"QUEUED":         TORRENT_DOWNLOAD_PENDING,
+		"PAUSED":         TORRENT_STOPPED,
+		"SUCCESS/ALL":    TORRENT_SEEDING,
+		"FAILURE/PAR":    TORRENT_STOPPED,
+		"DELETED/MANUAL": TORRENT_STOPPED,
+		"WARNING/SCRIPT": TORRENT_UNKNOWN_STATUS,
+		"":               TORRENT_UNKNOWN_STATUS,
+	}
+
+	for state, expected := range testData {
+		if result := convertState(state); result != expected {
+			t.Errorf("Expected nzbget status '%s' to be converted to %d, got %d instead", state, expected, result)
+		}
+	}
+}
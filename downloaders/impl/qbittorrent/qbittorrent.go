@@ -0,0 +1,365 @@
+package qbittorrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/upgear/go-kit/retry"
+)
+
+var module Module
+
+// Since torrents in qBittorrent are identified by their info hash and torrent objects in flemzerd have their own ID, we need to know which qBittorrent torrent correspond to which flemzerd torrent
+// This map stores "flemzerd torrent id" -> "qBittorrent torrent hash" relations
+var torrentsMapping map[string]string
+var torrentsMappingMutex sync.Mutex
+
+type QBittorrentDownloader struct {
+	Address  string
+	Port     int
+	User     string
+	Password string
+	client   *http.Client
+}
+
+type qBittorrentTorrent struct {
+	Hash      string  `json:"hash"`
+	Name      string  `json:"name"`
+	State     string  `json:"state"`
+	Progress  float64 `json:"progress"`
+	Size      int64   `json:"size"`
+	DlSpeed   int64   `json:"dlspeed"`
+	UpSpeed   int64   `json:"upspeed"`
+	Eta       int64   `json:"eta"`
+	NumSeeds  int     `json:"num_seeds"`
+	NumLeechs int     `json:"num_leechs"`
+	Ratio     float64 `json:"ratio"`
+	SavePath  string  `json:"save_path"`
+}
+
+func New(address string, port int, user string, password string) *QBittorrentDownloader {
+	module = Module{
+		Name: "qbittorrent",
+		Type: "downloader",
+		Status: ModuleStatus{
+			Alive:   true,
+			Message: "",
+		},
+	}
+
+	return &QBittorrentDownloader{
+		Address:  address,
+		Port:     port,
+		User:     user,
+		Password: password,
+	}
+}
+
+func (d *QBittorrentDownloader) Init() error {
+	if !strings.HasPrefix(d.Address, "http") {
+		d.Address = fmt.Sprintf("http://%s", d.Address)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot create qbittorrent session cookie jar")
+	}
+	d.client = &http.Client{
+		Timeout: time.Duration(HTTP_TIMEOUT * time.Second),
+		Jar:     jar,
+	}
+
+	torrentsMappingMutex.Lock()
+	torrentsMapping = make(map[string]string)
+	torrentsMappingMutex.Unlock()
+
+	return nil
+}
+
+func (d *QBittorrentDownloader) GetName() string {
+	return "qbittorrent"
+}
+
+func (d *QBittorrentDownloader) apiUrl(path string) string {
+	return fmt.Sprintf("%s:%d/api/v2/%s", d.Address, d.Port, path)
+}
+
+func (d *QBittorrentDownloader) login() error {
+	res, err := d.client.PostForm(d.apiUrl("auth/login"), url.Values{
+		"username": {d.User},
+		"password": {d.Password},
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot perform HTTP request to qbittorrent")
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return errors.New("Credentials refused when attempting to connect to qbittorrent")
+	}
+
+	return nil
+}
+
+// request performs a call to qBittorrent web API. Session is (re)opened if qBittorrent refuses the request because of a missing or expired session cookie
+func (d *QBittorrentDownloader) request(method string, path string, params url.Values) ([]byte, error) {
+	if d.client == nil {
+		return nil, errors.New("qbittorrent client not initialized")
+	}
+
+	do := func() (*http.Response, error) {
+		if method == "POST" {
+			return d.client.PostForm(d.apiUrl(path), params)
+		}
+		return d.client.Get(fmt.Sprintf("%s?%s", d.apiUrl(path), params.Encode()))
+	}
+
+	res, err := do()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot perform HTTP request to qbittorrent")
+	}
+	if res.StatusCode == http.StatusForbidden {
+		res.Body.Close()
+		if err := d.login(); err != nil {
+			return nil, err
+		}
+		if res, err = do(); err != nil {
+			return nil, errors.Wrap(err, "cannot perform HTTP request to qbittorrent")
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("qbittorrent returned unexpected status code %d for %s", res.StatusCode, path)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read qbittorrent response")
+	}
+
+	return body, nil
+}
+
+func (d *QBittorrentDownloader) getTorrents(params url.Values) ([]qBittorrentTorrent, error) {
+	body, err := d.request("GET", "torrents/info", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var torrents []qBittorrentTorrent
+	if err := json.Unmarshal(body, &torrents); err != nil {
+		return nil, errors.Wrap(err, "cannot parse qbittorrent torrent list")
+	}
+
+	return torrents, nil
+}
+
+func (d *QBittorrentDownloader) Status() (Module, error) {
+	log.Debug("Checking qbittorrent downloader status")
+
+	if _, err := d.request("GET", "app/version", url.Values{}); err != nil {
+		module.Status.Alive = false
+		module.Status.Message = err.Error()
+
+		return module, err
+	}
+
+	module.Status.Alive = true
+	module.Status.Message = ""
+
+	return module, nil
+}
+
+// AddTorrent adds torrent to qBittorrent. Since qBittorrent does not return the hash of added torrents, torrents are tagged with flemzerd torrent ID to be able to find them afterwards
+func (d *QBittorrentDownloader) AddTorrent(t Torrent) (string, error) {
+	_, err := d.request("POST", "torrents/add", url.Values{
+		"urls":     {t.Link},
+		"savepath": {t.DownloadDir},
+		"tags":     {t.TorrentId},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "cannot add torrent to qbittorrent")
+	}
+
+	var hash string
+	if err := retry.Double(3).Run(func() error {
+		torrents, err := d.getTorrents(url.Values{"tag": {t.TorrentId}})
+		if err != nil {
+			return err
+		}
+		if len(torrents) == 0 {
+			return errors.New("added torrent not found in qbittorrent")
+		}
+
+		hash = torrents[0].Hash
+		return nil
+	}); err != nil {
+		return "", errors.Wrap(err, "cannot retrieve hash of torrent added to qbittorrent")
+	}
+
+	d.AddTorrentMapping(t.TorrentId, hash)
+
+	return hash, nil
+}
+
+func (d *QBittorrentDownloader) AddTorrentMapping(flemzerID string, qbittorrentID string) {
+	torrentsMappingMutex.Lock()
+	torrentsMapping[flemzerID] = qbittorrentID
+	torrentsMappingMutex.Unlock()
+}
+
+func getTorrentHash(t Torrent) (string, error) {
+	torrentsMappingMutex.Lock()
+	hash, ok := torrentsMapping[t.TorrentId]
+	torrentsMappingMutex.Unlock()
+
+	if !ok {
+		return "", errors.New("Could not find corresponding qbittorrent torrent")
+	}
+
+	return hash, nil
+}
+
+func (d *QBittorrentDownloader) RemoveTorrent(t Torrent) error {
+	hash, err := getTorrentHash(t)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.request("POST", "torrents/delete", url.Values{
+		"hashes":      {hash},
+		"deleteFiles": {"false"},
+	}); err != nil {
+		return errors.Wrap(err, "cannot remove torrent from qbittorrent")
+	}
+
+	return nil
+}
+
+func convertState(state string) int {
+	switch state {
+	case "downloading", "stalledDL", "metaDL", "forcedDL", "forcedMetaDL", "allocating", "checkingDL", "moving":
+		return TORRENT_DOWNLOADING
+	// Completed torrents paused by seeding limits are done downloading
+	case "uploading", "stalledUP", "forcedUP", "checkingUP", "pausedUP", "stoppedUP":
+		return TORRENT_SEEDING
+	case "pausedDL", "stoppedDL":
+		return TORRENT_STOPPED
+	case "queuedDL", "queuedUP", "checkingResumeData":
+		return TORRENT_DOWNLOAD_PENDING
+	default:
+		return TORRENT_UNKNOWN_STATUS
+	}
+}
+
+func (d *QBittorrentDownloader) GetTorrentStatus(t *Torrent) error {
+	hash, err := getTorrentHash(*t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	var torrents []qBittorrentTorrent
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		torrents, err = d.getTorrents(url.Values{"hashes": {hash}})
+		return err
+	}); err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.Wrap(err, "cannot get torrent list from qbittorrent")
+	}
+
+	for _, torrent := range torrents {
+		if !strings.EqualFold(torrent.Hash, hash) {
+			continue
+		}
+
+		convertStatus(t, torrent)
+		return nil
+	}
+
+	t.Status = TORRENT_UNKNOWN_STATUS
+	return errors.New("Could not find torrent in qbittorrent")
+}
+
+// GetTorrentsStatus retrieves status of several torrents with a single torrent list request to qbittorrent
+func (d *QBittorrentDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	hashes := make([]string, len(torrents))
+	var requestedHashes []string
+	for i, t := range torrents {
+		hash, err := getTorrentHash(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+			continue
+		}
+		hashes[i] = strings.ToLower(hash)
+		requestedHashes = append(requestedHashes, hash)
+	}
+	if len(requestedHashes) == 0 {
+		return statusErrors
+	}
+
+	var qbTorrents []qBittorrentTorrent
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		qbTorrents, err = d.getTorrents(url.Values{"hashes": {strings.Join(requestedHashes, "|")}})
+		return err
+	}); err != nil {
+		for i, t := range torrents {
+			if hashes[i] == "" {
+				continue
+			}
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.Wrap(err, "cannot get torrent list from qbittorrent")
+		}
+		return statusErrors
+	}
+
+	torrentsByHash := make(map[string]qBittorrentTorrent)
+	for _, torrent := range qbTorrents {
+		torrentsByHash[strings.ToLower(torrent.Hash)] = torrent
+	}
+
+	for i, t := range torrents {
+		if hashes[i] == "" {
+			continue
+		}
+
+		torrent, ok := torrentsByHash[hashes[i]]
+		if !ok {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.New("Could not find torrent in qbittorrent")
+			continue
+		}
+		convertStatus(t, torrent)
+	}
+
+	return statusErrors
+}
+
+func convertStatus(t *Torrent, torrent qBittorrentTorrent) {
+	t.ETA = time.Now().Add(time.Duration(torrent.Eta) * time.Second)
+	t.PercentDone = torrent.Progress
+	t.TotalSize = torrent.Size
+	t.RateDownload = torrent.DlSpeed
+	t.RateUpload = torrent.UpSpeed
+	t.PeersConnected = torrent.NumSeeds + torrent.NumLeechs
+	t.UploadRatio = torrent.Ratio
+	t.Status = convertState(torrent.State)
+}
@@ -0,0 +1,220 @@
+package qbittorrent
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+const fakeHash = "8c4adbf9ebe66f1d804fb6a4fb9b74966c3ab609"
+
+type fakeQBittorrent struct {
+	torrents map[string]qBittorrentTorrent
+	tags     map[string]string
+}
+
+func (f *fakeQBittorrent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v2/auth/login" {
+		r.ParseForm()
+		if r.Form.Get("username") != "user" || r.Form.Get("password") != "password" {
+			w.Write([]byte("Fails."))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+		w.Write([]byte("Ok."))
+		return
+	}
+
+	if cookie, err := r.Cookie("SID"); err != nil || cookie.Value != "session" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	r.ParseForm()
+	switch r.URL.Path {
+	case "/api/v2/app/version":
+		w.Write([]byte("v4.2.5"))
+	case "/api/v2/torrents/add":
+		f.torrents[fakeHash] = qBittorrentTorrent{
+			Hash:     fakeHash,
+			Name:     "torrent",
+			State:    "downloading",
+			Progress: 0.5,
+			Size:     1000,
+			SavePath: r.Form.Get("savepath"),
+		}
+		f.tags[r.Form.Get("tags")] = fakeHash
+		w.Write([]byte("Ok."))
+	case "/api/v2/torrents/delete":
+		delete(f.torrents, r.Form.Get("hashes"))
+	case "/api/v2/torrents/info":
+		list := []qBittorrentTorrent{}
+		if tag := r.Form.Get("tag"); tag != "" {
+			if hash, ok := f.tags[tag]; ok {
+				list = append(list, f.torrents[hash])
+			}
+		} else {
+			for _, hash := range strings.Split(r.Form.Get("hashes"), "|") {
+				if torrent, ok := f.torrents[hash]; ok {
+					list = append(list, torrent)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestDownloader(t *testing.T, user string, password string) (*QBittorrentDownloader, *fakeQBittorrent, func()) {
+	fake := &fakeQBittorrent{
+		torrents: make(map[string]qBittorrentTorrent),
+		tags:     make(map[string]string),
+	}
+	server := httptest.NewServer(fake)
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	d := New(host, port, user, password)
+	if err := d.Init(); err != nil {
+		t.Fatal("Expected qbittorrent downloader init to succeed, got error: ", err)
+	}
+
+	return d, fake, server.Close
+}
+
+func TestStatus(t *testing.T) {
+	d, _, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	mod, err := d.Status()
+	if err != nil {
+		t.Error("Expected status check to succeed, got error: ", err)
+	}
+	if !mod.Status.Alive {
+		t.Error("Expected module to be alive")
+	}
+
+	refused, _, closeRefused := newTestDownloader(t, "user", "wrong")
+	defer closeRefused()
+
+	mod, err = refused.Status()
+	if err == nil {
+		t.Error("Expected status check to fail with refused credentials")
+	}
+	if mod.Status.Alive {
+		t.Error("Expected module not to be alive with refused credentials")
+	}
+}
+
+func TestTorrentLifecycle(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	torrent := Torrent{
+		TorrentId:   "flemzerd_id",
+		Link:        "magnet:?xt=urn:btih:" + fakeHash,
+		DownloadDir: "/tmp/download",
+	}
+
+	id, err := d.AddTorrent(torrent)
+	if err != nil {
+		t.Error("Expected torrent to be added, got error: ", err)
+	}
+	if id != fakeHash {
+		t.Errorf("Expected downloader id to be '%s', got '%s' instead", fakeHash, id)
+	}
+	if fake.torrents[fakeHash].SavePath != "/tmp/download" {
+		t.Error("Expected torrent to be downloaded into torrent download dir")
+	}
+
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get torrent status, got error: ", err)
+	}
+	if torrent.Status != TORRENT_DOWNLOADING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_DOWNLOADING, torrent.Status)
+	}
+	if torrent.PercentDone != 0.5 || torrent.TotalSize != 1000 {
+		t.Error("Expected torrent progress to be updated from qbittorrent")
+	}
+
+	if err := d.RemoveTorrent(torrent); err != nil {
+		t.Error("Expected torrent to be removed, got error: ", err)
+	}
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of a removed torrent")
+	}
+	if torrent.Status != TORRENT_UNKNOWN_STATUS {
+		t.Error("Expected removed torrent status to be unknown")
+	}
+}
+
+func TestAddTorrentMapping(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = qBittorrentTorrent{Hash: fakeHash, State: "uploading", Progress: 1}
+	torrent := Torrent{TorrentId: "recovered_id"}
+
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of an unmapped torrent")
+	}
+
+	d.AddTorrentMapping(torrent.TorrentId, fakeHash)
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get status of recovered torrent, got error: ", err)
+	}
+	if torrent.Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_SEEDING, torrent.Status)
+	}
+}
+
+func TestGetTorrentsStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = qBittorrentTorrent{Hash: fakeHash, State: "uploading", Progress: 1}
+	d.AddTorrentMapping("seeding_id", fakeHash)
+	d.AddTorrentMapping("removed_id", "removed_hash")
+
+	torrents := []*Torrent{
+		&Torrent{TorrentId: "seeding_id"},
+		&Torrent{TorrentId: "removed_id"},
+		&Torrent{TorrentId: "unmapped_id"},
+	}
+	statusErrors := d.GetTorrentsStatus(torrents)
+	if statusErrors[0] != nil || torrents[0].Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead (error: %v)", TORRENT_SEEDING, torrents[0].Status, statusErrors[0])
+	}
+	for i := 1; i < len(torrents); i++ {
+		if statusErrors[i] == nil || torrents[i].Status != TORRENT_UNKNOWN_STATUS {
+			t.Errorf("Expected an error and unknown status for torrent %s", torrents[i].TorrentId)
+		}
+	}
+}
+
+func TestConvertState(t *testing.T) {
+	testData := map[string]int{
+		"downloading": TORRENT_DOWNLOADING,
+		"stalledDL":   TORRENT_DOWNLOADING,
+		"uploading":   TORRENT_SEEDING,
+		"pausedDL":    TORRENT_STOPPED,
+		"pausedUP":    TORRENT_SEEDING,
+		"stoppedUP":   TORRENT_SEEDING,
+		"queuedDL":    TORRENT_DOWNLOAD_PENDING,
+		"error":       TORRENT_UNKNOWN_STATUS,
+	}
+
+	for state, expected := range testData {
+		if result := convertState(state); result != expected {
+			t.Errorf("Expected qbittorrent state '%s' to be converted to %d, got %d instead", state, expected, result)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package rtorrent
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/upgear/go-kit/retry"
+)
+
+// Custom rtorrent field used to tag torrents with their flemzerd ID
+const FLEMZERD_ID_FIELD = "flemzerd_id"
+
+var module Module
+
+// Since torrents in rtorrent are identified by their info hash and torrent objects in flemzerd have their own ID, we need to know which rtorrent torrent correspond to which flemzerd torrent
+// This map stores "flemzerd torrent id" -> "rtorrent torrent hash" relations
+var torrentsMapping map[string]string
+var torrentsMappingMutex sync.Mutex
+
+// Fields retrieved for each torrent. Order must match rtorrentTorrent fields parsing in getTorrents
+var torrentFields = []interface{}{
+	"",
+	"main",
+	"d.hash=",
+	"d.name=",
+	fmt.Sprintf("d.custom=%s", FLEMZERD_ID_FIELD),
+	"d.state=",
+	"d.is_active=",
+	"d.complete=",
+	"d.hashing=",
+	"d.size_bytes=",
+	"d.completed_bytes=",
+	"d.down.rate=",
+	"d.up.rate=",
+	"d.ratio=",
+}
+
+type RTorrentDownloader struct {
+	Address  string
+	Port     int
+	Path     string
+	User     string
+	Password string
+	client   *http.Client
+}
+
+type rtorrentTorrent struct {
+	Hash           string
+	Name           string
+	FlemzerdId     string
+	State          int64
+	Active         int64
+	Complete       int64
+	Hashing        int64
+	SizeBytes      int64
+	CompletedBytes int64
+	DownRate       int64
+	UpRate         int64
+	// Upload ratio multiplied by 1000
+	Ratio int64
+}
+
+func New(address string, port int, path string, user string, password string) *RTorrentDownloader {
+	module = Module{
+		Name: "rtorrent",
+		Type: "downloader",
+		Status: ModuleStatus{
+			Alive:   true,
+			Message: "",
+		},
+	}
+
+	if path == "" {
+		path = "/RPC2"
+	}
+
+	return &RTorrentDownloader{
+		Address:  address,
+		Port:     port,
+		Path:     path,
+		User:     user,
+		Password: password,
+	}
+}
+
+func (d *RTorrentDownloader) Init() error {
+	if !strings.HasPrefix(d.Address, "http") {
+		d.Address = fmt.Sprintf("http://%s", d.Address)
+	}
+	if !strings.HasPrefix(d.Path, "/") {
+		d.Path = fmt.Sprintf("/%s", d.Path)
+	}
+
+	d.client = &http.Client{
+		Timeout: time.Duration(HTTP_TIMEOUT * time.Second),
+	}
+
+	torrentsMappingMutex.Lock()
+	torrentsMapping = make(map[string]string)
+	torrentsMappingMutex.Unlock()
+
+	return nil
+}
+
+func (d *RTorrentDownloader) GetName() string {
+	return "rtorrent"
+}
+
+// call performs a XML-RPC call to rtorrent and returns its decoded result
+func (d *RTorrentDownloader) call(method string, params ...interface{}) (interface{}, error) {
+	if d.client == nil {
+		return nil, errors.New("rtorrent client not initialized")
+	}
+
+	body, err := encodeMethodCall(method, params...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot encode rtorrent %s call", method)
+	}
+
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s:%d%s", d.Address, d.Port, d.Path), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create HTTP request for rtorrent")
+	}
+	request.Header.Set("Content-Type", "text/xml")
+	if d.User != "" {
+		request.SetBasicAuth(d.User, d.Password)
+	}
+
+	res, err := d.client.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot perform HTTP request to rtorrent")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, errors.New("Credentials refused when attempting to connect to rtorrent")
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rtorrent returned unexpected status code %d", res.StatusCode)
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read rtorrent response")
+	}
+
+	return decodeMethodResponse(resBody)
+}
+
+func (d *RTorrentDownloader) getTorrents() ([]rtorrentTorrent, error) {
+	result, err := d.call("d.multicall2", torrentFields...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get torrent list from rtorrent")
+	}
+
+	rows, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected torrent list format returned by rtorrent")
+	}
+
+	var torrents []rtorrentTorrent
+	for _, row := range rows {
+		fields, ok := row.([]interface{})
+		if !ok || len(fields) != len(torrentFields)-2 {
+			return nil, errors.New("unexpected torrent format returned by rtorrent")
+		}
+
+		torrent := rtorrentTorrent{}
+		torrent.Hash, _ = fields[0].(string)
+		torrent.Name, _ = fields[1].(string)
+		torrent.FlemzerdId, _ = fields[2].(string)
+		torrent.State, _ = fields[3].(int64)
+		torrent.Active, _ = fields[4].(int64)
+		torrent.Complete, _ = fields[5].(int64)
+		torrent.Hashing, _ = fields[6].(int64)
+		torrent.SizeBytes, _ = fields[7].(int64)
+		torrent.CompletedBytes, _ = fields[8].(int64)
+		torrent.DownRate, _ = fields[9].(int64)
+		torrent.UpRate, _ = fields[10].(int64)
+		torrent.Ratio, _ = fields[11].(int64)
+
+		torrents = append(torrents, torrent)
+	}
+
+	return torrents, nil
+}
+
+func (d *RTorrentDownloader) Status() (Module, error) {
+	log.Debug("Checking rtorrent downloader status")
+
+	if _, err := d.call("system.client_version"); err != nil {
+		module.Status.Alive = false
+		module.Status.Message = err.Error()
+
+		return module, err
+	}
+
+	module.Status.Alive = true
+	module.Status.Message = ""
+
+	return module, nil
+}
+
+// AddTorrent loads torrent into rtorrent. Since rtorrent does not return the hash of loaded torrents, torrents are tagged with flemzerd torrent ID to be able to find them afterwards
+func (d *RTorrentDownloader) AddTorrent(t Torrent) (string, error) {
+	_, err := d.call("load.start",
+		"",
+		t.Link,
+		fmt.Sprintf("d.directory.set=\"%s\"", t.DownloadDir),
+		fmt.Sprintf("d.custom.set=%s,%s", FLEMZERD_ID_FIELD, t.TorrentId),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot add torrent to rtorrent")
+	}
+
+	var hash string
+	if err := retry.Double(3).Run(func() error {
+		torrents, err := d.getTorrents()
+		if err != nil {
+			return err
+		}
+
+		for _, torrent := range torrents {
+			if torrent.FlemzerdId == t.TorrentId {
+				hash = torrent.Hash
+				return nil
+			}
+		}
+		return errors.New("added torrent not found in rtorrent")
+	}); err != nil {
+		return "", errors.Wrap(err, "cannot retrieve hash of torrent added to rtorrent")
+	}
+
+	d.AddTorrentMapping(t.TorrentId, hash)
+
+	return hash, nil
+}
+
+func (d *RTorrentDownloader) AddTorrentMapping(flemzerID string, rtorrentID string) {
+	torrentsMappingMutex.Lock()
+	torrentsMapping[flemzerID] = rtorrentID
+	torrentsMappingMutex.Unlock()
+}
+
+func getTorrentHash(t Torrent) (string, error) {
+	torrentsMappingMutex.Lock()
+	hash, ok := torrentsMapping[t.TorrentId]
+	torrentsMappingMutex.Unlock()
+
+	if !ok {
+		return "", errors.New("Could not find corresponding rtorrent torrent")
+	}
+
+	return hash, nil
+}
+
+func (d *RTorrentDownloader) RemoveTorrent(t Torrent) error {
+	hash, err := getTorrentHash(t)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.call("d.erase", hash); err != nil {
+		return errors.Wrap(err, "cannot remove torrent from rtorrent")
+	}
+
+	return nil
+}
+
+func convertState(torrent rtorrentTorrent) int {
+	switch {
+	case torrent.Hashing != 0:
+		return TORRENT_DOWNLOADING
+	// Completed torrents stopped by seeding limits are done downloading
+	case torrent.Complete != 0:
+		return TORRENT_SEEDING
+	case torrent.State == 0 || torrent.Active == 0:
+		return TORRENT_STOPPED
+	default:
+		return TORRENT_DOWNLOADING
+	}
+}
+
+func (d *RTorrentDownloader) GetTorrentStatus(t *Torrent) error {
+	hash, err := getTorrentHash(*t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	var torrents []rtorrentTorrent
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		torrents, err = d.getTorrents()
+		return err
+	}); err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	for _, torrent := range torrents {
+		if !strings.EqualFold(torrent.Hash, hash) {
+			continue
+		}
+
+		convertStatus(t, torrent)
+		return nil
+	}
+
+	t.Status = TORRENT_UNKNOWN_STATUS
+	return errors.New("Could not find torrent in rtorrent")
+}
+
+// GetTorrentsStatus retrieves status of several torrents with a single torrent list request to rtorrent
+func (d *RTorrentDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	var rtorrentTorrents []rtorrentTorrent
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		rtorrentTorrents, err = d.getTorrents()
+		return err
+	}); err != nil {
+		for i := range torrents {
+			torrents[i].Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+		}
+		return statusErrors
+	}
+
+	torrentsByHash := make(map[string]rtorrentTorrent)
+	for _, torrent := range rtorrentTorrents {
+		torrentsByHash[strings.ToLower(torrent.Hash)] = torrent
+	}
+
+	for i, t := range torrents {
+		hash, err := getTorrentHash(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+			continue
+		}
+
+		torrent, ok := torrentsByHash[strings.ToLower(hash)]
+		if !ok {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.New("Could not find torrent in rtorrent")
+			continue
+		}
+		convertStatus(t, torrent)
+	}
+
+	return statusErrors
+}
+
+func convertStatus(t *Torrent, torrent rtorrentTorrent) {
+	t.ETA = time.Now()
+	if torrent.DownRate > 0 {
+		t.ETA = t.ETA.Add(time.Duration((torrent.SizeBytes-torrent.CompletedBytes)/torrent.DownRate) * time.Second)
+	}
+	if torrent.SizeBytes > 0 {
+		t.PercentDone = float64(torrent.CompletedBytes) / float64(torrent.SizeBytes)
+	}
+	t.TotalSize = torrent.SizeBytes
+	t.RateDownload = torrent.DownRate
+	t.RateUpload = torrent.UpRate
+	t.UploadRatio = float64(torrent.Ratio) / 1000
+	t.Status = convertState(torrent)
+}
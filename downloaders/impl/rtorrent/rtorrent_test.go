@@ -0,0 +1,286 @@
+package rtorrent
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+const fakeHash = "8C4ADBF9EBE66F1D804FB6A4FB9B74966C3AB609"
+
+type fakeRTorrent struct {
+	torrents    map[string]rtorrentTorrent
+	directories map[string]string
+}
+
+func (f *fakeRTorrent) reply(w http.ResponseWriter, value interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0"?><methodResponse><params><param>`)
+	encodeValue(&buf, value)
+	buf.WriteString("</param></params></methodResponse>")
+	w.Write(buf.Bytes())
+}
+
+func (f *fakeRTorrent) fault(w http.ResponseWriter, code int, message string) {
+	w.Write([]byte(`<?xml version="1.0"?><methodResponse><fault><value><struct>` +
+		`<member><name>faultCode</name><value><i4>` + strconv.Itoa(code) + `</i4></value></member>` +
+		`<member><name>faultString</name><value><string>` + message + `</string></value></member>` +
+		`</struct></value></fault></methodResponse>`))
+}
+
+func (f *fakeRTorrent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var call struct {
+		MethodName string        `xml:"methodName"`
+		Params     []xmlRpcValue `xml:"params>param>value"`
+	}
+	if r.URL.Path != "/RPC2" || xml.NewDecoder(r.Body).Decode(&call) != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var params []string
+	for _, param := range call.Params {
+		decoded, _ := param.decode()
+		value, _ := decoded.(string)
+		params = append(params, value)
+	}
+
+	switch call.MethodName {
+	case "system.client_version":
+		f.reply(w, "0.9.8")
+	case "load.start":
+		torrent := rtorrentTorrent{
+			Hash:      fakeHash,
+			Name:      "torrent",
+			State:     1,
+			Active:    1,
+			SizeBytes: 1000,
+		}
+		for _, command := range params[2:] {
+			if strings.HasPrefix(command, "d.custom.set="+FLEMZERD_ID_FIELD+",") {
+				torrent.FlemzerdId = strings.TrimPrefix(command, "d.custom.set="+FLEMZERD_ID_FIELD+",")
+			}
+			if strings.HasPrefix(command, "d.directory.set=") {
+				f.directories[fakeHash] = strings.Trim(strings.TrimPrefix(command, "d.directory.set="), "\"")
+			}
+		}
+		torrent.CompletedBytes = 500
+		torrent.DownRate = 100
+		f.torrents[fakeHash] = torrent
+		f.reply(w, int64(0))
+	case "d.erase":
+		if _, ok := f.torrents[params[0]]; !ok {
+			f.fault(w, -501, "Could not find info-hash.")
+			return
+		}
+		delete(f.torrents, params[0])
+		f.reply(w, int64(0))
+	case "d.multicall2":
+		rows := []interface{}{}
+		for _, torrent := range f.torrents {
+			rows = append(rows, []interface{}{
+				torrent.Hash,
+				torrent.Name,
+				torrent.FlemzerdId,
+				torrent.State,
+				torrent.Active,
+				torrent.Complete,
+				torrent.Hashing,
+				torrent.SizeBytes,
+				torrent.CompletedBytes,
+				torrent.DownRate,
+				torrent.UpRate,
+				torrent.Ratio,
+			})
+		}
+		f.reply(w, rows)
+	default:
+		f.fault(w, -506, "Method not defined")
+	}
+}
+
+func newTestDownloader(t *testing.T, user string, password string) (*RTorrentDownloader, *fakeRTorrent, func()) {
+	fake := &fakeRTorrent{
+		torrents:    make(map[string]rtorrentTorrent),
+		directories: make(map[string]string),
+	}
+	server := httptest.NewServer(fake)
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	d := New(host, port, "", user, password)
+	if err := d.Init(); err != nil {
+		t.Fatal("Expected rtorrent downloader init to succeed, got error: ", err)
+	}
+
+	return d, fake, server.Close
+}
+
+func TestStatus(t *testing.T) {
+	d, _, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	mod, err := d.Status()
+	if err != nil {
+		t.Error("Expected status check to succeed, got error: ", err)
+	}
+	if !mod.Status.Alive {
+		t.Error("Expected module to be alive")
+	}
+
+	refused, _, closeRefused := newTestDownloader(t, "user", "wrong")
+	defer closeRefused()
+
+	mod, err = refused.Status()
+	if err == nil {
+		t.Error("Expected status check to fail with refused credentials")
+	}
+	if mod.Status.Alive {
+		t.Error("Expected module not to be alive with refused credentials")
+	}
+}
+
+func TestTorrentLifecycle(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	torrent := Torrent{
+		TorrentId:   "flemzerd_id",
+		Link:        "http://indexer/torrent.torrent",
+		DownloadDir: "/tmp/download",
+	}
+
+	id, err := d.AddTorrent(torrent)
+	if err != nil {
+		t.Error("Expected torrent to be added, got error: ", err)
+	}
+	if id != fakeHash {
+		t.Errorf("Expected downloader id to be '%s', got '%s' instead", fakeHash, id)
+	}
+	if fake.directories[fakeHash] != "/tmp/download" {
+		t.Error("Expected torrent to be downloaded into torrent download dir")
+	}
+
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get torrent status, got error: ", err)
+	}
+	if torrent.Status != TORRENT_DOWNLOADING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_DOWNLOADING, torrent.Status)
+	}
+	if torrent.PercentDone != 0.5 || torrent.TotalSize != 1000 {
+		t.Error("Expected torrent progress to be updated from rtorrent")
+	}
+
+	if err := d.RemoveTorrent(torrent); err != nil {
+		t.Error("Expected torrent to be removed, got error: ", err)
+	}
+	if err := d.RemoveTorrent(torrent); err == nil {
+		t.Error("Expected an error when removing an unknown torrent")
+	}
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of a removed torrent")
+	}
+	if torrent.Status != TORRENT_UNKNOWN_STATUS {
+		t.Error("Expected removed torrent status to be unknown")
+	}
+}
+
+func TestAddTorrentMapping(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = rtorrentTorrent{Hash: fakeHash, State: 1, Active: 1, Complete: 1}
+	torrent := Torrent{TorrentId: "recovered_id"}
+
+	if err := d.GetTorrentStatus(&torrent); err == nil {
+		t.Error("Expected an error when getting status of an unmapped torrent")
+	}
+
+	d.AddTorrentMapping(torrent.TorrentId, fakeHash)
+	if err := d.GetTorrentStatus(&torrent); err != nil {
+		t.Error("Expected to get status of recovered torrent, got error: ", err)
+	}
+	if torrent.Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead", TORRENT_SEEDING, torrent.Status)
+	}
+}
+
+func TestGetTorrentsStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "user", "password")
+	defer closeServer()
+
+	fake.torrents[fakeHash] = rtorrentTorrent{Hash: fakeHash, State: 1, Active: 1, Complete: 1}
+	d.AddTorrentMapping("seeding_id", fakeHash)
+	d.AddTorrentMapping("removed_id", "removed_hash")
+
+	torrents := []*Torrent{
+		&Torrent{TorrentId: "seeding_id"},
+		&Torrent{TorrentId: "removed_id"},
+		&Torrent{TorrentId: "unmapped_id"},
+	}
+	statusErrors := d.GetTorrentsStatus(torrents)
+	if statusErrors[0] != nil || torrents[0].Status != TORRENT_SEEDING {
+		t.Errorf("Expected torrent status to be %d, got %d instead (error: %v)", TORRENT_SEEDING, torrents[0].Status, statusErrors[0])
+	}
+	for i := 1; i < len(torrents); i++ {
+		if statusErrors[i] == nil || torrents[i].Status != TORRENT_UNKNOWN_STATUS {
+			t.Errorf("Expected an error and unknown status for torrent %s", torrents[i].TorrentId)
+		}
+	}
+}
+
+func TestConvertState(t *testing.T) {
+	testData := []struct {
+		Torrent  rtorrentTorrent
+		Expected int
+	}{
+		{rtorrentTorrent{State: 1, Active: 1}, TORRENT_DOWNLOADING},
+		{rtorrentTorrent{State: 1, Active: 1, Complete: 1}, TORRENT_SEEDING},
+		{rtorrentTorrent{State: 0, Active: 0}, TORRENT_STOPPED},
+		{rtorrentTorrent{State: 1, Active: 0}, TORRENT_STOPPED},
+		{rtorrentTorrent{State: 0, Active: 0, Complete: 1}, TORRENT_SEEDING},
+		{rtorrentTorrent{State: 1, Active: 1, Hashing: 1}, TORRENT_DOWNLOADING},
+	}
+
+	for _, testCase := range testData {
+		if result := convertState(testCase.Torrent); result != testCase.Expected {
+			t.Errorf("Expected rtorrent torrent %+v state to be converted to %d, got %d instead", testCase.Torrent, testCase.Expected, result)
+		}
+	}
+}
+
+func TestDecodeMethodResponse(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?><methodResponse><params><param><value><array><data>` +
+		`<value>untyped</value><value><i8>42</i8></value><value><boolean>1</boolean></value>` +
+		`<value><struct><member><name>key</name><value><string>value</string></value></member></struct></value>` +
+		`</data></array></value></param></params></methodResponse>`)
+
+	result, err := decodeMethodResponse(body)
+	if err != nil {
+		t.Fatal("Expected response to be decoded, got error: ", err)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		t.Fatalf("Expected an array of 4 values, got %v instead", result)
+	}
+	if values[0] != "untyped" || values[1] != int64(42) || values[2] != true {
+		t.Errorf("Unexpected decoded values: %v", values)
+	}
+	if members, ok := values[3].(map[string]interface{}); !ok || members["key"] != "value" {
+		t.Errorf("Expected struct to be decoded, got %v instead", values[3])
+	}
+}
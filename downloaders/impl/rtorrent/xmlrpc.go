@@ -0,0 +1,175 @@
+package rtorrent
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type XmlRpcFault struct {
+	Code   int
+	String string
+}
+
+func (f XmlRpcFault) Error() string {
+	return fmt.Sprintf("rtorrent error (code %d): %s", f.Code, f.String)
+}
+
+type xmlRpcArray struct {
+	Values []xmlRpcValue `xml:"data>value"`
+}
+
+type xmlRpcMember struct {
+	Name  string      `xml:"name"`
+	Value xmlRpcValue `xml:"value"`
+}
+
+type xmlRpcValue struct {
+	String  *string        `xml:"string"`
+	Int     *string        `xml:"int"`
+	I4      *string        `xml:"i4"`
+	I8      *string        `xml:"i8"`
+	Boolean *string        `xml:"boolean"`
+	Double  *string        `xml:"double"`
+	Array   *xmlRpcArray   `xml:"array"`
+	Struct  []xmlRpcMember `xml:"struct>member"`
+	Text    string         `xml:",chardata"`
+}
+
+type xmlRpcResponse struct {
+	Params []xmlRpcValue `xml:"params>param>value"`
+	Fault  *xmlRpcValue  `xml:"fault>value"`
+}
+
+func encodeValue(buf *bytes.Buffer, value interface{}) error {
+	buf.WriteString("<value>")
+	switch v := value.(type) {
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<i8>%d</i8>", v)
+	case int64:
+		fmt.Fprintf(buf, "<i8>%d</i8>", v)
+	case bool:
+		if v {
+			buf.WriteString("<boolean>1</boolean>")
+		} else {
+			buf.WriteString("<boolean>0</boolean>")
+		}
+	case []string:
+		buf.WriteString("<array><data>")
+		for _, item := range v {
+			encodeValue(buf, item)
+		}
+		buf.WriteString("</data></array>")
+	case []interface{}:
+		buf.WriteString("<array><data>")
+		for _, item := range v {
+			if err := encodeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	default:
+		return fmt.Errorf("unsupported XML-RPC parameter type %T", value)
+	}
+	buf.WriteString("</value>")
+
+	return nil
+}
+
+func encodeMethodCall(method string, params ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(`<?xml version="1.0"?><methodCall><methodName>`)
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString("</methodName><params>")
+	for _, param := range params {
+		buf.WriteString("<param>")
+		if err := encodeValue(&buf, param); err != nil {
+			return nil, err
+		}
+		buf.WriteString("</param>")
+	}
+	buf.WriteString("</params></methodCall>")
+
+	return buf.Bytes(), nil
+}
+
+// decode converts an XML-RPC value into string, int64, bool, float64, []interface{} or map[string]interface{}
+func (v xmlRpcValue) decode() (interface{}, error) {
+	switch {
+	case v.String != nil:
+		return *v.String, nil
+	case v.Int != nil, v.I4 != nil, v.I8 != nil:
+		raw := v.Int
+		if v.I4 != nil {
+			raw = v.I4
+		} else if v.I8 != nil {
+			raw = v.I8
+		}
+		return strconv.ParseInt(strings.TrimSpace(*raw), 10, 64)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1", nil
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Array != nil:
+		values := make([]interface{}, len(v.Array.Values))
+		for i, item := range v.Array.Values {
+			decoded, err := item.decode()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = decoded
+		}
+		return values, nil
+	case v.Struct != nil:
+		members := make(map[string]interface{})
+		for _, member := range v.Struct {
+			decoded, err := member.Value.decode()
+			if err != nil {
+				return nil, err
+			}
+			members[member.Name] = decoded
+		}
+		return members, nil
+	default:
+		// Values without type are strings
+		return v.Text, nil
+	}
+}
+
+func decodeMethodResponse(body []byte) (interface{}, error) {
+	var response xmlRpcResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "cannot parse XML-RPC response")
+	}
+
+	if response.Fault != nil {
+		decoded, err := response.Fault.decode()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse XML-RPC fault")
+		}
+
+		fault := XmlRpcFault{}
+		if members, ok := decoded.(map[string]interface{}); ok {
+			if code, ok := members["faultCode"].(int64); ok {
+				fault.Code = int(code)
+			}
+			fault.String, _ = members["faultString"].(string)
+		}
+		return nil, fault
+	}
+
+	if len(response.Params) == 0 {
+		return nil, nil
+	}
+
+	return response.Params[0].decode()
+}
@@ -0,0 +1,370 @@
+package sabnzbd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/upgear/go-kit/retry"
+)
+
+var module Module
+
+// Since jobs in sabnzbd have specific ID and torrent objects in flemzerd have their own ID, we need to know which sabnzbd job correspond to which flemzerd torrent
+// This map stores "flemzerd torrent id" -> "sabnzbd nzo id" relations
+var torrentsMapping map[string]string
+var torrentsMappingMutex sync.Mutex
+
+type SabnzbdDownloader struct {
+	Address  string
+	Port     int
+	ApiKey   string
+	Category string
+	client   *http.Client
+}
+
+type sabnzbdResponse struct {
+	Status *bool    `json:"status"`
+	Error  string   `json:"error"`
+	NzoIds []string `json:"nzo_ids"`
+	Queue  struct {
+		KbPerSec string        `json:"kbpersec"`
+		Slots    []sabnzbdSlot `json:"slots"`
+	} `json:"queue"`
+	History struct {
+		Slots []sabnzbdSlot `json:"slots"`
+	} `json:"history"`
+}
+
+type sabnzbdSlot struct {
+	NzoId       string `json:"nzo_id"`
+	Name        string `json:"name"`
+	Filename    string `json:"filename"`
+	Status      string `json:"status"`
+	Percentage  string `json:"percentage"`
+	Mb          string `json:"mb"`
+	TimeLeft    string `json:"timeleft"`
+	Storage     string `json:"storage"`
+	Bytes       int64  `json:"bytes"`
+	FailMessage string `json:"fail_message"`
+}
+
+func New(address string, port int, apikey string, category string) *SabnzbdDownloader {
+	module = Module{
+		Name: "sabnzbd",
+		Type: "downloader",
+		Status: ModuleStatus{
+			Alive:   true,
+			Message: "",
+		},
+	}
+
+	return &SabnzbdDownloader{
+		Address:  address,
+		Port:     port,
+		ApiKey:   apikey,
+		Category: category,
+	}
+}
+
+func (d *SabnzbdDownloader) Init() error {
+	if !strings.HasPrefix(d.Address, "http") {
+		d.Address = fmt.Sprintf("http://%s", d.Address)
+	}
+
+	d.client = &http.Client{
+		Timeout: time.Duration(HTTP_TIMEOUT * time.Second),
+	}
+
+	torrentsMappingMutex.Lock()
+	torrentsMapping = make(map[string]string)
+	torrentsMappingMutex.Unlock()
+
+	return nil
+}
+
+func (d *SabnzbdDownloader) GetName() string {
+	return "sabnzbd"
+}
+
+func (d *SabnzbdDownloader) GetProtocol() string {
+	return PROTOCOL_USENET
+}
+
+// request performs a call to sabnzbd API with mode and params
+func (d *SabnzbdDownloader) request(mode string, params url.Values) (sabnzbdResponse, error) {
+	if d.client == nil {
+		return sabnzbdResponse{}, errors.New("sabnzbd client not initialized")
+	}
+
+	params.Set("mode", mode)
+	params.Set("apikey", d.ApiKey)
+	params.Set("output", "json")
+
+	res, err := d.client.Get(fmt.Sprintf("%s:%d/api?%s", d.Address, d.Port, params.Encode()))
+	if err != nil {
+		return sabnzbdResponse{}, errors.Wrap(err, "cannot perform HTTP request to sabnzbd")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return sabnzbdResponse{}, fmt.Errorf("sabnzbd returned unexpected status code %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return sabnzbdResponse{}, errors.Wrap(err, "cannot read sabnzbd response")
+	}
+
+	var response sabnzbdResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return sabnzbdResponse{}, errors.Wrap(err, "cannot parse sabnzbd response")
+	}
+	if response.Status != nil && !*response.Status {
+		return response, fmt.Errorf("sabnzbd error: %s", response.Error)
+	}
+
+	return response, nil
+}
+
+func (d *SabnzbdDownloader) Status() (Module, error) {
+	log.Debug("Checking sabnzbd downloader status")
+
+	if _, err := d.request("queue", url.Values{"limit": {"0"}}); err != nil {
+		module.Status.Alive = false
+		module.Status.Message = err.Error()
+
+		return module, err
+	}
+
+	module.Status.Alive = true
+	module.Status.Message = ""
+
+	return module, nil
+}
+
+func (d *SabnzbdDownloader) AddTorrent(t Torrent) (string, error) {
+	params := url.Values{
+		"name":    {t.Link},
+		"nzbname": {t.Name},
+	}
+	if d.Category != "" {
+		params.Set("cat", d.Category)
+	}
+
+	response, err := d.request("addurl", params)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot add nzb to sabnzbd")
+	}
+	if len(response.NzoIds) == 0 {
+		return "", errors.New("sabnzbd did not return added job id")
+	}
+
+	d.AddTorrentMapping(t.TorrentId, response.NzoIds[0])
+
+	return response.NzoIds[0], nil
+}
+
+func (d *SabnzbdDownloader) AddTorrentMapping(flemzerID string, sabnzbdID string) {
+	torrentsMappingMutex.Lock()
+	torrentsMapping[flemzerID] = sabnzbdID
+	torrentsMappingMutex.Unlock()
+}
+
+func getJobId(t Torrent) (string, error) {
+	torrentsMappingMutex.Lock()
+	id, ok := torrentsMapping[t.TorrentId]
+	torrentsMappingMutex.Unlock()
+
+	if !ok {
+		return "", errors.New("Could not find corresponding sabnzbd job")
+	}
+
+	return id, nil
+}
+
+// RemoveTorrent removes job from sabnzbd queue and history. Downloaded files are kept
+func (d *SabnzbdDownloader) RemoveTorrent(t Torrent) error {
+	id, err := getJobId(t)
+	if err != nil {
+		return err
+	}
+
+	for _, mode := range []string{"queue", "history"} {
+		if _, err := d.request(mode, url.Values{
+			"name":      {"delete"},
+			"value":     {id},
+			"del_files": {"0"},
+		}); err != nil {
+			return errors.Wrap(err, "cannot remove job from sabnzbd")
+		}
+	}
+
+	return nil
+}
+
+// convertState maps sabnzbd job status to torrent status. Completed jobs are considered as seeding torrents (download done) and failed jobs as stopped torrents
+func convertState(status string) int {
+	switch status {
+	case "Downloading", "Fetching", "Grabbing", "Propagating", "Checking", "QuickCheck", "Verifying", "Repairing", "Extracting", "Moving", "Running":
+		return TORRENT_DOWNLOADING
+	case "Completed":
+		return TORRENT_SEEDING
+	case "Paused", "Failed":
+		return TORRENT_STOPPED
+	case "Queued":
+		return TORRENT_DOWNLOAD_PENDING
+	default:
+		return TORRENT_UNKNOWN_STATUS
+	}
+}
+
+func parseTimeLeft(timeLeft string) time.Duration {
+	var duration time.Duration
+	for _, part := range strings.Split(timeLeft, ":") {
+		value, _ := strconv.Atoi(part)
+		duration = duration*60 + time.Duration(value)
+	}
+
+	return duration * time.Second
+}
+
+func (d *SabnzbdDownloader) GetTorrentStatus(t *Torrent) error {
+	id, err := getJobId(*t)
+	if err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return err
+	}
+
+	var queue, history sabnzbdResponse
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		queue, err = d.request("queue", url.Values{"nzo_ids": {id}})
+		if err != nil {
+			return err
+		}
+		history, err = d.request("history", url.Values{"nzo_ids": {id}})
+		return err
+	}); err != nil {
+		t.Status = TORRENT_UNKNOWN_STATUS
+		return errors.Wrap(err, "cannot get job list from sabnzbd")
+	}
+
+	return convertStatus(t, id, queue, history)
+}
+
+// GetTorrentsStatus retrieves status of several jobs with a single queue request and a single history request to sabnzbd
+func (d *SabnzbdDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	ids := make([]string, len(torrents))
+	var requestedIds []string
+	for i, t := range torrents {
+		id, err := getJobId(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+			continue
+		}
+		ids[i] = id
+		requestedIds = append(requestedIds, id)
+	}
+	if len(requestedIds) == 0 {
+		return statusErrors
+	}
+
+	var queue, history sabnzbdResponse
+	if err := retry.Double(3).Run(func() error {
+		var err error
+		queue, err = d.request("queue", url.Values{"nzo_ids": {strings.Join(requestedIds, ",")}})
+		if err != nil {
+			return err
+		}
+		history, err = d.request("history", url.Values{"nzo_ids": {strings.Join(requestedIds, ",")}})
+		return err
+	}); err != nil {
+		for i, t := range torrents {
+			if ids[i] == "" {
+				continue
+			}
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.Wrap(err, "cannot get job list from sabnzbd")
+		}
+		return statusErrors
+	}
+
+	for i, t := range torrents {
+		if ids[i] == "" {
+			continue
+		}
+		statusErrors[i] = convertStatus(t, ids[i], queue, history)
+	}
+
+	return statusErrors
+}
+
+// convertStatus updates torrent t from sabnzbd job id found in queue or history
+func convertStatus(t *Torrent, id string, queue sabnzbdResponse, history sabnzbdResponse) error {
+	for _, slot := range queue.Queue.Slots {
+		if slot.NzoId != id {
+			continue
+		}
+
+		percentage, _ := strconv.ParseFloat(slot.Percentage, 64)
+		sizeMb, _ := strconv.ParseFloat(slot.Mb, 64)
+		rate, _ := strconv.ParseFloat(queue.Queue.KbPerSec, 64)
+
+		t.ETA = time.Now().Add(parseTimeLeft(slot.TimeLeft))
+		t.PercentDone = percentage / 100
+		t.TotalSize = int64(sizeMb * 1024 * 1024)
+		t.RateDownload = int64(rate * 1024)
+		t.RateUpload = 0
+		t.Status = convertState(slot.Status)
+
+		return nil
+	}
+
+	for _, slot := range history.History.Slots {
+		if slot.NzoId != id {
+			continue
+		}
+
+		t.ETA = time.Now()
+		t.RateDownload = 0
+		t.RateUpload = 0
+		t.Status = convertState(slot.Status)
+		if slot.Bytes > 0 {
+			t.TotalSize = slot.Bytes
+		}
+
+		if t.Status == TORRENT_SEEDING {
+			t.PercentDone = 1
+			// Jobs cannot be downloaded in a specific directory: downloaded files are moved into library from sabnzbd completed folder
+			if slot.Storage != "" {
+				t.DownloadDir = slot.Storage
+				t.Name = filepath.Base(slot.Storage)
+			}
+		} else if slot.FailMessage != "" {
+			log.WithFields(log.Fields{
+				"name":  slot.Name,
+				"error": slot.FailMessage,
+			}).Warning("Sabnzbd job failed")
+		}
+
+		return nil
+	}
+
+	t.Status = TORRENT_UNKNOWN_STATUS
+	return errors.New("Could not find job in sabnzbd")
+}
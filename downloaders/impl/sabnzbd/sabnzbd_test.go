@@ -0,0 +1,218 @@
+package sabnzbd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+const fakeJobId = "SABnzbd_nzo_test"
+
+type fakeSabnzbd struct {
+	queue   map[string]sabnzbdSlot
+	history map[string]sabnzbdSlot
+}
+
+func (f *fakeSabnzbd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if r.URL.Path != "/api" || params.Get("output") != "json" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if params.Get("apikey") != "apikey" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "error": "API Key Incorrect"})
+		return
+	}
+
+	slots := func(jobs map[string]sabnzbdSlot) []sabnzbdSlot {
+		list := []sabnzbdSlot{}
+		for id, job := range jobs {
+			if params.Get("nzo_ids") == "" || contains(strings.Split(params.Get("nzo_ids"), ","), id) {
+				list = append(list, job)
+			}
+		}
+		return list
+	}
+
+	switch params.Get("mode") {
+	case "addurl":
+		f.queue[fakeJobId] = sabnzbdSlot{
+			NzoId:      fakeJobId,
+			Filename:   params.Get("nzbname"),
+			Status:     "Downloading",
+			Percentage: "50",
+			Mb:         "1000",
+			TimeLeft:   "0:01:40",
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "nzo_ids": []string{fakeJobId}})
+	case "queue", "history":
+		jobs := f.queue
+		if params.Get("mode") == "history" {
+			jobs = f.history
+		}
+		if params.Get("name") == "delete" {
+			delete(jobs, params.Get("value"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			params.Get("mode"): map[string]interface{}{
+				"kbpersec": "1024",
+				"slots":    slots(jobs),
+			},
+		})
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "error": "not implemented"})
+	}
+}
+
+func contains(list []string, item string) bool {
+	for _, elt := range list {
+		if elt == item {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestDownloader(t *testing.T, apikey string) (*SabnzbdDownloader, *fakeSabnzbd, func()) {
+	fake := &fakeSabnzbd{
+		queue:   make(map[string]sabnzbdSlot),
+		history: make(map[string]sabnzbdSlot),
+	}
+	server := httptest.NewServer(fake)
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	d := New(host, port, apikey, "")
+	if err := d.Init(); err != nil {
+		t.Fatal("Expected sabnzbd downloader init to succeed, got error: ", err)
+	}
+
+	return d, fake, server.Close
+}
+
+func TestStatus(t *testing.T) {
+	d, _, closeServer := newTestDownloader(t, "apikey")
+	defer closeServer()
+
+	if mod, err := d.Status(); err != nil || !mod.Status.Alive {
+		t.Error("Expected sabnzbd to be alive, got error: ", err)
+	}
+
+	refused, _, closeRefused := newTestDownloader(t, "wrong")
+	defer closeRefused()
+
+	if mod, err := refused.Status(); err == nil || mod.Status.Alive {
+		t.Error("Expected status check to fail with wrong API key")
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "apikey")
+	defer closeServer()
+
+	release := Torrent{
+		TorrentId: "flemzerd_id",
+		Name:      "Test.Show.S01E01.720p",
+		Link:      "http://indexer/getnzb/1.nzb",
+		Protocol:  PROTOCOL_USENET,
+	}
+
+	id, err := d.AddTorrent(release)
+	if err != nil {
+		t.Error("Expected nzb to be added, got error: ", err)
+	}
+	if id != fakeJobId {
+		t.Errorf("Expected downloader id to be '%s', got '%s' instead", fakeJobId, id)
+	}
+
+	if err := d.GetTorrentStatus(&release); err != nil {
+		t.Error("Expected to get job status, got error: ", err)
+	}
+	if release.Status != TORRENT_DOWNLOADING || release.PercentDone != 0.5 || release.RateDownload != 1024*1024 {
+		t.Errorf("Expected queued job progress to be retrieved from sabnzbd, got %+v", release)
+	}
+
+	delete(fake.queue, fakeJobId)
+	fake.history[fakeJobId] = sabnzbdSlot{
+		NzoId:   fakeJobId,
+		Status:  "Completed",
+		Storage: "/downloads/complete/Test.Show.S01E01.720p",
+		Bytes:   1000,
+	}
+	if err := d.GetTorrentStatus(&release); err != nil {
+		t.Error("Expected to get job status, got error: ", err)
+	}
+	if release.Status != TORRENT_SEEDING {
+		t.Errorf("Expected completed job status to be %d, got %d instead", TORRENT_SEEDING, release.Status)
+	}
+	if release.DownloadDir != "/downloads/complete/Test.Show.S01E01.720p" || release.Name != "Test.Show.S01E01.720p" {
+		t.Errorf("Expected completed job location to be retrieved from sabnzbd history, got '%s'", release.DownloadDir)
+	}
+
+	if err := d.RemoveTorrent(release); err != nil {
+		t.Error("Expected job to be removed, got error: ", err)
+	}
+	if err := d.GetTorrentStatus(&release); err == nil || release.Status != TORRENT_UNKNOWN_STATUS {
+		t.Error("Expected an error when getting status of a removed job")
+	}
+}
+
+func TestGetTorrentsStatus(t *testing.T) {
+	d, fake, closeServer := newTestDownloader(t, "apikey")
+	defer closeServer()
+
+	fake.queue["queued_job"] = sabnzbdSlot{NzoId: "queued_job", Status: "Downloading", Percentage: "50", Mb: "1000"}
+	fake.history["done_job"] = sabnzbdSlot{NzoId: "done_job", Status: "Completed", Storage: "/tmp/complete/job"}
+	d.AddTorrentMapping("queued_id", "queued_job")
+	d.AddTorrentMapping("done_id", "done_job")
+	d.AddTorrentMapping("removed_id", "removed_job")
+
+	torrents := []*Torrent{
+		&Torrent{TorrentId: "queued_id"},
+		&Torrent{TorrentId: "done_id"},
+		&Torrent{TorrentId: "removed_id"},
+		&Torrent{TorrentId: "unmapped_id"},
+	}
+	statusErrors := d.GetTorrentsStatus(torrents)
+	if statusErrors[0] != nil || torrents[0].Status != TORRENT_DOWNLOADING {
+		t.Errorf("Expected queued job status to be %d, got %d instead (error: %v)", TORRENT_DOWNLOADING, torrents[0].Status, statusErrors[0])
+	}
+	if statusErrors[1] != nil || torrents[1].Status != TORRENT_SEEDING || torrents[1].DownloadDir != "/tmp/complete/job" {
+		t.Errorf("Expected completed job status to be %d, got %d instead (error: %v)", TORRENT_SEEDING, torrents[1].Status, statusErrors[1])
+	}
+	for i := 2; i < len(torrents); i++ {
+		if statusErrors[i] == nil || torrents[i].Status != TORRENT_UNKNOWN_STATUS {
+			t.Errorf("Expected an error and unknown status for job %s", torrents[i].TorrentId)
+		}
+	}
+}
+
+func TestConvertState(t *testing.T) {
+	testData := map[string]int{
+		"Downloading": TORRENT_DOWNLOADING,
+		"Extracting":  TORRENT_DOWNLOADING,
+		"Completed":   TORRENT_SEEDING,
+		"Failed":      TORRENT_STOPPED,
+		"Queued":      TORRENT_DOWNLOAD_PENDING,
+		"Unknown":     TORRENT_UNKNOWN_STATUS,
+	}
+
+	for state, expected := range testData {
+		if result := convertState(state); result != expected {
+			t.Errorf("Expected sabnzbd status '%s' to be converted to %d, got %d instead", state, expected, result)
+		}
+	}
+
+	if duration := parseTimeLeft("1:02:03"); duration.Seconds() != 3723 {
+		t.Errorf("Expected time left to be 3723 seconds, got %v instead", duration)
+	}
+}
@@ -212,6 +212,38 @@ func (d TransmissionDownloader) RemoveTorrent(t Torrent) error {
 	return nil
 }
 
+// convertStatus copies status of transmission torrent into flemzerd torrent t
+func convertStatus(t *Torrent, torrent tr.Torrent) {
+	// Transmission ETA is the number of remaining seconds (negative if unknown)
+	t.ETA = time.Now()
+	if torrent.Eta > 0 {
+		t.ETA = t.ETA.Add(time.Duration(torrent.Eta) * time.Second)
+	}
+	t.PercentDone = torrent.PercentDone
+	t.TotalSize = torrent.TotalSize
+	t.RateDownload = torrent.RateDownload
+	t.RateUpload = torrent.RateUpload
+	t.PeersConnected = torrent.PeersConnected
+	// Transmission returns a negative ratio when nothing has been downloaded yet
+	t.UploadRatio = 0
+	if torrent.UploadRatio > 0 {
+		t.UploadRatio = torrent.UploadRatio
+	}
+
+	switch torrent.Status {
+	case tr.StatusDownloading:
+		t.Status = TORRENT_DOWNLOADING
+	case tr.StatusSeeding:
+		t.Status = TORRENT_SEEDING
+	case tr.StatusStopped:
+		t.Status = TORRENT_STOPPED
+	case tr.StatusDownloadPending:
+		t.Status = TORRENT_DOWNLOAD_PENDING
+	default:
+		t.Status = TORRENT_UNKNOWN_STATUS
+	}
+}
+
 func (d TransmissionDownloader) GetTorrentStatus(t *Torrent) error {
 	if err := retry.Double(3).Run(func() error {
 		return updateTorrentList()
@@ -234,32 +266,39 @@ func (d TransmissionDownloader) GetTorrentStatus(t *Torrent) error {
 				return nil
 			}
 
-			t.ETA = time.Unix(torrent.Eta, 0)
-			t.PercentDone = torrent.PercentDone
-			t.TotalSize = torrent.TotalSize
-			t.RateDownload = torrent.RateDownload
-			t.RateUpload = torrent.RateUpload
-
-			switch (*torrent).Status {
-			case tr.StatusDownloading:
-				t.Status = TORRENT_DOWNLOADING
-				return nil
-			case tr.StatusSeeding:
-				t.Status = TORRENT_SEEDING
-				return nil
-			case tr.StatusStopped:
-				t.Status = TORRENT_STOPPED
-				return nil
-			case tr.StatusDownloadPending:
-				t.Status = TORRENT_DOWNLOAD_PENDING
-				return nil
-			default:
-				t.Status = TORRENT_UNKNOWN_STATUS
-				return nil
-			}
+			convertStatus(t, *torrent)
+			return nil
 		}
 	}
 
 	t.Status = TORRENT_UNKNOWN_STATUS
 	return errors.New("Could not find torrent in transmission")
 }
+
+// GetTorrentsStatus retrieves status of all torrents with a single torrent list request to transmission
+func (d TransmissionDownloader) GetTorrentsStatus(torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	if err := retry.Double(3).Run(func() error {
+		return updateTorrentList()
+	}); err != nil {
+		for i := range torrents {
+			torrents[i].Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.Wrap(err, "cannot update transmission torrent list")
+		}
+		return statusErrors
+	}
+
+	for i, t := range torrents {
+		transmissionTorrent, err := getTransmissionTorrent(*t)
+		if err != nil {
+			t.Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = errors.New("Could not find torrent in transmission")
+			continue
+		}
+
+		convertStatus(t, transmissionTorrent)
+	}
+
+	return statusErrors
+}
@@ -0,0 +1,227 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/mediacenters"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+)
+
+// getImportMode returns the mode used to import downloaded files into library. Files that must be kept for seeding are hardlinked instead of moved
+func getImportMode(keepSource bool) string {
+	switch mode := configuration.Config.Library.ImportMode; mode {
+	case IMPORT_COPY, IMPORT_HARDLINK, IMPORT_SYMLINK:
+		return mode
+	}
+
+	if keepSource {
+		return IMPORT_HARDLINK
+	}
+	return IMPORT_MOVE
+}
+
+func isCrossDeviceError(err error) bool {
+	linkErr, ok := err.(*os.LinkError)
+	return ok && linkErr.Err == syscall.EXDEV
+}
+
+func copyFile(src string, dst string, mode os.FileMode) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+
+	return destination.Close()
+}
+
+// replaceFile creates file dst with create, under a temporary name next to dst, and renames it over dst once created.
+// A file already located at dst (release replaced by an upgrade) is left untouched until the new file is complete, and files hardlinked to it are never modified
+func replaceFile(dst string, create func(path string) error) error {
+	temporaryPath := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.%s", filepath.Base(dst), xid.New()))
+	if err := create(temporaryPath); err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+	if err := os.Rename(temporaryPath, dst); err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	return nil
+}
+
+// copyPath copies file or folder src to dst. When link is true, files are hardlinked instead, or copied if hardlinks cannot be created
+func copyPath(src string, dst string, link bool) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relativePath)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		if link {
+			err := replaceFile(target, func(linkPath string) error {
+				return os.Link(path, linkPath)
+			})
+			if err == nil || os.IsExist(err) {
+				return err
+			}
+		}
+
+		return replaceFile(target, func(copyTarget string) error {
+			return copyFile(path, copyTarget, info.Mode().Perm())
+		})
+	})
+}
+
+// verifyCopy checks that each file of src has a copy of the same size in dst
+func verifyCopy(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		copyInfo, err := os.Stat(filepath.Join(dst, relativePath))
+		if err != nil {
+			return err
+		}
+		if copyInfo.Size() != info.Size() {
+			return fmt.Errorf("size of copied file %s does not match source file size", relativePath)
+		}
+
+		return nil
+	})
+}
+
+// movePath moves file or folder src to dst. If src and dst are on different filesystems, data is copied, verified and then removed from src
+func movePath(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"source":      src,
+		"destination": dst,
+	}).Debug("Source and destination are on different filesystems. Copying data instead of moving it")
+
+	if err := copyPath(src, dst, false); err != nil {
+		return errors.Wrap(err, "cannot copy data across filesystems")
+	}
+	if err := verifyCopy(src, dst); err != nil {
+		return errors.Wrap(err, "copied data does not match source")
+	}
+
+	return os.RemoveAll(src)
+}
+
+// importPath makes data located at src (file or folder) available at dst using configured import mode.
+// When source must be kept for seeding, files are hardlinked instead of moved
+func importPath(src string, dst string, keepSource bool) error {
+	switch getImportMode(keepSource) {
+	case IMPORT_COPY:
+		return copyPath(src, dst, false)
+	case IMPORT_HARDLINK:
+		return copyPath(src, dst, true)
+	case IMPORT_SYMLINK:
+		absolutePath, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		return replaceFile(dst, func(linkPath string) error {
+			return os.Symlink(absolutePath, linkPath)
+		})
+	default:
+		return movePath(src, dst)
+	}
+}
+
+// setImportFailed records that downloaded files of item d could not be imported into library, for the import to be retried later
+func setImportFailed(d downloadable.Downloadable, importErr error) {
+	downloadingItem := d.GetDownloadingItem()
+	downloadingItem.ImportFailed = true
+	downloadingItem.ImportFailureReason = importErr.Error()
+
+	// Archive extraction failures are also reported as failure reason of the downloaded torrent
+	var extractionErr *ExtractionError
+	if errors.As(importErr, &extractionErr) {
+		for i := range downloadingItem.TorrentList {
+			if !downloadingItem.TorrentList[i].Failed {
+				downloadingItem.TorrentList[i].FailureReason = extractionErr.Error()
+				break
+			}
+		}
+	}
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+}
+
+// RetryImport imports again into library downloaded files of item d after a failed import
+func RetryImport(d downloadable.Downloadable) error {
+	downloadingItem := d.GetDownloadingItem()
+	if !downloadingItem.ImportFailed {
+		return errors.New("No failed import to retry for this item")
+	}
+
+	temporaryPath := downloadingItem.CurrentTorrent().DownloadDir
+	if err := MoveItemToLibrary(d); err != nil {
+		setImportFailed(d, err)
+		return errors.Wrap(err, "cannot import item into library")
+	}
+
+	downloadingItem = d.GetDownloadingItem()
+	downloadingItem.ImportFailed = false
+	downloadingItem.ImportFailureReason = ""
+	for i := range downloadingItem.TorrentList {
+		if !downloadingItem.TorrentList[i].Failed {
+			downloadingItem.TorrentList[i].FailureReason = ""
+		}
+	}
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+
+	// Data kept for seeding can now be removed with the torrent
+	if getImportMode(true) != IMPORT_SYMLINK {
+		db.Client.Model(&SeedingTorrent{}).Where("torrent_id = ?", downloadingItem.CurrentTorrent().TorrentId).Update("path", temporaryPath)
+	}
+
+	d.GetLog().Info("Item imported into library")
+	mediacenter.RefreshLibrary()
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package downloader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macarrie/flemzerd/configuration"
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/naming"
+	"github.com/macarrie/flemzerd/rar"
+	"github.com/macarrie/flemzerd/vidocq"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+// getMovieLibraryPath returns the library folder of a movie downloaded with release t, and the file name (without extension) of the movie. An empty file name means release name is kept
+func getMovieLibraryPath(movie Movie, t Torrent) (string, string) {
+	vars := naming.Variables{
+		Title:         movie.GetTitle(),
+		OriginalTitle: movie.OriginalTitle,
+		Year:          movie.Date.Year(),
+		Quality:       t.MediaInfo.Quality,
+		ReleaseName:   t.Name,
+		ImdbId:        movie.MediaIds.Imdb,
+		TmdbId:        movie.MediaIds.Tmdb,
+		TvdbId:        movie.MediaIds.Tvdb,
+		TraktId:       movie.MediaIds.Trakt,
+	}
+
+	folder, file := configuration.Config.Naming.MoviePath(vars)
+	return fmt.Sprintf("%s/%s", configuration.Config.Library.MoviePath, folder), file
+}
+
+var subtitleExtensions = []string{".srt", ".sub", ".idx", ".ass", ".ssa", ".vtt", ".sup"}
+
+func isSubtitleFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, subtitleExtension := range subtitleExtensions {
+		if extension == subtitleExtension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// releaseFiles sorts files of a downloaded release
+type releaseFiles struct {
+	// Main video files of the release, in path order
+	videos    []string
+	subtitles []string
+	// Other files (samples, small video files, nfo, pictures, ...)
+	extras []string
+}
+
+// remaining returns files of release that are not in imported list
+func (f releaseFiles) remaining(imported map[string]bool) []string {
+	var files []string
+	for _, list := range [][]string{f.videos, f.subtitles, f.extras} {
+		for _, file := range list {
+			if !imported[file] {
+				files = append(files, file)
+			}
+		}
+	}
+
+	return files
+}
+
+// listReleaseFiles sorts files of release downloaded in path (file or folder).
+// Main video files are the biggest video files of the release. Samples and video files smaller than half of the biggest one (bonus, featurettes) are considered as extra files
+func listReleaseFiles(path string) (releaseFiles, error) {
+	var files releaseFiles
+	var videos []string
+	sizes := make(map[string]int64)
+	var biggest int64
+
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		// Archives are extracted before import
+		case rar.IsVolume(filePath):
+			return nil
+		case isSubtitleFile(filePath):
+			files.subtitles = append(files.subtitles, filePath)
+		case vidocq.Parse(info.Name()).Container != "" && !vidocq.IsSample(relativePath):
+			videos = append(videos, filePath)
+			sizes[filePath] = info.Size()
+			if info.Size() > biggest {
+				biggest = info.Size()
+			}
+		default:
+			files.extras = append(files.extras, filePath)
+		}
+		return nil
+	})
+
+	for _, video := range videos {
+		if sizes[video]*2 >= biggest {
+			files.videos = append(files.videos, video)
+		} else {
+			files.extras = append(files.extras, video)
+		}
+	}
+
+	return files, err
+}
+
+func getFileBaseName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// getMatchingSubtitles returns subtitle files of video. Subtitles named after video file match it. If the release contains a single video, all subtitles of the release (ex: Subs/English.srt) match it
+func getMatchingSubtitles(video string, subtitles []string, singleVideo bool) []string {
+	var matching []string
+	for _, subtitle := range subtitles {
+		if singleVideo || strings.HasPrefix(filepath.Base(subtitle), getFileBaseName(video)+".") {
+			matching = append(matching, subtitle)
+		}
+	}
+
+	return matching
+}
+
+// getSubtitleName returns library name of subtitle file matching video imported as videoName (without extension). Language suffixes are kept (movie.en.srt becomes <videoName>.en.srt)
+func getSubtitleName(subtitle string, video string, videoName string) string {
+	subtitleName := filepath.Base(subtitle)
+	if suffix := strings.TrimPrefix(subtitleName, getFileBaseName(video)+"."); suffix != subtitleName {
+		return fmt.Sprintf("%s.%s", videoName, suffix)
+	}
+
+	return fmt.Sprintf("%s.%s%s", videoName, getFileBaseName(subtitle), strings.ToLower(filepath.Ext(subtitle)))
+}
+
+// importVideoFile imports video file and its subtitles into library folder destinationPath. Video is renamed to fileName (without extension) if it is not empty.
+// Library path of the video and list of imported source files are returned
+func importVideoFile(video string, subtitles []string, destinationPath string, fileName string, keepSource bool) (string, []string, error) {
+	videoName := fileName
+	if videoName == "" {
+		videoName = getFileBaseName(video)
+	}
+
+	target := fmt.Sprintf("%s/%s%s", destinationPath, videoName, strings.ToLower(filepath.Ext(video)))
+	if err := importPath(video, target, keepSource); err != nil {
+		return "", nil, err
+	}
+
+	imported := []string{video}
+	for _, subtitle := range subtitles {
+		if err := importPath(subtitle, fmt.Sprintf("%s/%s", destinationPath, getSubtitleName(subtitle, video, videoName)), keepSource); err != nil {
+			log.WithFields(log.Fields{
+				"subtitle": subtitle,
+				"error":    err,
+			}).Warning("Could not import subtitle file into library")
+			continue
+		}
+		imported = append(imported, subtitle)
+	}
+
+	return target, imported, nil
+}
+
+// cleanRelease handles files of release t that were not imported into library. Files are moved into extra files folder if it is configured, and discarded otherwise.
+// Downloaded data kept by import mode (seeding, copies or links) is not removed
+func cleanRelease(t Torrent, files []string) {
+	keepSource := hasSeedingGoals(t)
+
+	if extraFilesPath := configuration.Config.Library.ExtraFilesPath; extraFilesPath != "" {
+		for _, file := range files {
+			relativePath, err := filepath.Rel(t.DownloadDir, file)
+			if err != nil {
+				continue
+			}
+
+			target := filepath.Join(extraFilesPath, t.Name, relativePath)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = importPath(file, target, keepSource)
+			}
+			if err != nil {
+				log.WithFields(log.Fields{
+					"file":  file,
+					"error": err,
+				}).Warning("Could not archive release extra file")
+			}
+		}
+	}
+
+	if getImportMode(keepSource) != IMPORT_MOVE {
+		return
+	}
+	if err := os.RemoveAll(t.DownloadDir); err != nil {
+		log.WithFields(log.Fields{
+			"path":  t.DownloadDir,
+			"error": err,
+		}).Warning("Could not remove release temporary folder")
+	}
+}
+
+// importRelease imports video files of downloaded release t and their subtitles into library folder destinationPath, and returns the library path of the release.
+// Video files are renamed to fileName when it is not empty. Other files of the release are not imported
+func importRelease(t Torrent, destinationPath string, fileName string) (string, error) {
+	// Torrents with seeding goals keep seeding from temporary download folder
+	keepSource := hasSeedingGoals(t)
+
+	files, err := listReleaseFiles(t.DownloadDir)
+	if err != nil {
+		return "", errors.Wrap(err, "could not list release files")
+	}
+
+	// Releases without video files (archives, unknown formats) are imported with all their files
+	if len(files.videos) == 0 {
+		target := fmt.Sprintf("%s/%s", destinationPath, t.Name)
+		return target, importPath(t.DownloadDir, target, keepSource)
+	}
+
+	var target string
+	imported := make(map[string]bool)
+	for index, video := range files.videos {
+		videoName := fileName
+		if videoName != "" && len(files.videos) > 1 {
+			videoName = fmt.Sprintf("%s - part%d", fileName, index+1)
+		}
+
+		videoTarget, importedFiles, err := importVideoFile(video, getMatchingSubtitles(video, files.subtitles, len(files.videos) == 1), destinationPath, videoName, keepSource)
+		if err != nil {
+			return "", err
+		}
+		if index == 0 {
+			target = videoTarget
+		}
+		for _, file := range importedFiles {
+			imported[file] = true
+		}
+	}
+
+	cleanRelease(t, files.remaining(imported))
+
+	return target, nil
+}
+
+// removeLibraryRelease removes release located at path from library, along with subtitles named after its video file. Subtitles are kept if they also match newReleasePath (release replaced by a file with the same name)
+func removeLibraryRelease(path string, newReleasePath string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	baseName := getFileBaseName(path)
+	if filepath.Dir(path) == filepath.Dir(newReleasePath) && baseName == getFileBaseName(newReleasePath) {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && isSubtitleFile(entry.Name()) && strings.HasPrefix(entry.Name(), baseName+".") {
+			_ = os.Remove(filepath.Join(filepath.Dir(path), entry.Name()))
+		}
+	}
+
+	return nil
+}
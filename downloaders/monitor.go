@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+const DEFAULT_DOWNLOAD_CHECK_INTERVAL = 20
+
+// BatchDownloader is implemented by downloaders able to retrieve status of several torrents with a single request to the download client.
+// Returned errors have the same indexes as torrents
+type BatchDownloader interface {
+	GetTorrentsStatus(torrents []*Torrent) []error
+}
+
+// torrentUpdate contains torrent status retrieved from download client
+type torrentUpdate struct {
+	Torrent Torrent
+	Err     error
+}
+
+// torrentWatcher is used by a download routine to receive status updates of the torrent it is waiting for
+type torrentWatcher struct {
+	downloader string
+	torrent    Torrent
+	updates    chan torrentUpdate
+}
+
+// downloadMonitor periodically retrieves status of watched torrents. Status of all torrents handled by a downloader is retrieved in a single batch and sent to waiting download routines
+type downloadMonitor struct {
+	sync.Mutex
+	once     sync.Once
+	watchers map[string]map[*torrentWatcher]bool
+	pending  map[string]bool
+	wake     chan bool
+}
+
+var monitor = &downloadMonitor{
+	watchers: make(map[string]map[*torrentWatcher]bool),
+	pending:  make(map[string]bool),
+	wake:     make(chan bool, 1),
+}
+
+func getDownloadCheckInterval() time.Duration {
+	interval := configuration.Config.System.DownloadCheckInterval
+	if interval <= 0 {
+		interval = DEFAULT_DOWNLOAD_CHECK_INTERVAL
+	}
+
+	return time.Duration(interval) * time.Second
+}
+
+// watch registers torrent t held by downloader downloaderName in monitor. A status check for this downloader is triggered immediately
+func (m *downloadMonitor) watch(downloaderName string, t Torrent) (*torrentWatcher, error) {
+	d, err := getTorrentDownloader(downloaderName, t)
+	if err != nil {
+		return nil, err
+	}
+
+	m.once.Do(func() {
+		go m.run()
+	})
+
+	watcher := &torrentWatcher{
+		downloader: d.GetName(),
+		torrent:    t,
+		updates:    make(chan torrentUpdate, 1),
+	}
+
+	m.Lock()
+	if _, ok := m.watchers[watcher.downloader]; !ok {
+		m.watchers[watcher.downloader] = make(map[*torrentWatcher]bool)
+	}
+	m.watchers[watcher.downloader][watcher] = true
+	m.Unlock()
+
+	m.trigger(watcher.downloader)
+
+	return watcher, nil
+}
+
+func (m *downloadMonitor) unwatch(watcher *torrentWatcher) {
+	m.Lock()
+	delete(m.watchers[watcher.downloader], watcher)
+	m.Unlock()
+}
+
+// trigger requests an immediate status check of torrents held by downloader downloaderName
+func (m *downloadMonitor) trigger(downloaderName string) {
+	m.Lock()
+	m.pending[downloaderName] = true
+	m.Unlock()
+
+	select {
+	case m.wake <- true:
+	default:
+	}
+}
+
+func (m *downloadMonitor) run() {
+	ticker := time.NewTicker(getDownloadCheckInterval())
+	for {
+		var downloaders []string
+
+		select {
+		case <-ticker.C:
+			m.Lock()
+			for name := range m.watchers {
+				downloaders = append(downloaders, name)
+			}
+			m.pending = make(map[string]bool)
+			m.Unlock()
+		case <-m.wake:
+			m.Lock()
+			for name := range m.pending {
+				downloaders = append(downloaders, name)
+			}
+			m.pending = make(map[string]bool)
+			m.Unlock()
+		}
+
+		for _, name := range downloaders {
+			m.check(name)
+		}
+	}
+}
+
+// check retrieves status of all watched torrents held by downloader downloaderName and sends it to watchers
+func (m *downloadMonitor) check(downloaderName string) {
+	m.Lock()
+	var watchers []*torrentWatcher
+	for watcher := range m.watchers[downloaderName] {
+		watchers = append(watchers, watcher)
+	}
+	m.Unlock()
+
+	if len(watchers) == 0 {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"downloader": downloaderName,
+		"torrents":   len(watchers),
+	}).Debug("Checking torrents download progress")
+
+	torrents := make([]*Torrent, len(watchers))
+	for i, watcher := range watchers {
+		torrents[i] = &watcher.torrent
+	}
+
+	statusErrors := getTorrentsStatus(downloaderName, torrents)
+	for i, watcher := range watchers {
+		update := torrentUpdate{
+			Torrent: watcher.torrent,
+			Err:     statusErrors[i],
+		}
+
+		// Only the latest update is kept if previous one has not been read yet
+		select {
+		case <-watcher.updates:
+		default:
+		}
+		watcher.updates <- update
+	}
+}
+
+// getTorrentsStatus updates status of torrents held by downloader downloaderName. Downloaders not implementing BatchDownloader are queried for each torrent
+func getTorrentsStatus(downloaderName string, torrents []*Torrent) []error {
+	statusErrors := make([]error, len(torrents))
+
+	d, err := GetDownloader(downloaderName)
+	if err != nil {
+		for i := range torrents {
+			torrents[i].Status = TORRENT_UNKNOWN_STATUS
+			statusErrors[i] = err
+		}
+		return statusErrors
+	}
+
+	if batchDownloader, ok := d.(BatchDownloader); ok {
+		return batchDownloader.GetTorrentsStatus(torrents)
+	}
+
+	for i, t := range torrents {
+		statusErrors[i] = d.GetTorrentStatus(t)
+	}
+
+	return statusErrors
+}
+
+// NotifyDownloader triggers an immediate progress check of downloads handled by downloader name. It is used by download clients able to notify flemzerd when a download ends
+func NotifyDownloader(name string) error {
+	if _, err := GetDownloader(name); err != nil {
+		return errors.Wrap(err, "cannot notify downloader")
+	}
+
+	log.WithFields(log.Fields{
+		"downloader": name,
+	}).Debug("Download client notification received, checking downloads progress")
+
+	monitor.trigger(name)
+
+	return nil
+}
@@ -0,0 +1,406 @@
+package downloader
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+// QueueItem describes a queued or active download
+type QueueItem struct {
+	MediaType  string
+	Id         uint
+	Title      string
+	Priority   int
+	Position   int
+	Downloader string
+}
+
+// QueueStatus describes the state of the download queue
+type QueueStatus struct {
+	Paused             bool
+	MaxActiveDownloads int
+	Active             []QueueItem
+	Queued             []QueueItem
+}
+
+// activeDownload describes a download started by queue. Item description is taken when download starts since the item itself is modified by the download process
+type activeDownload struct {
+	key        string
+	info       QueueItem
+	downloader string
+	startedAt  time.Time
+}
+
+// downloadQueue holds items waiting for a download slot. Queued items are started in queue order when active downloads limits (global and per downloader) allow it.
+// Queue state is persisted in downloading items (queued flag and position) and in session data (paused state) to be restored after a restart
+type downloadQueue struct {
+	sync.Mutex
+	paused bool
+	items  []downloadable.Downloadable
+	active map[string]*activeDownload
+}
+
+var queue = &downloadQueue{
+	active: make(map[string]*activeDownload),
+}
+
+func getQueueKey(d downloadable.Downloadable) string {
+	return fmt.Sprintf("%s_%d", getMediaType(d), d.GetId())
+}
+
+// getMaxActiveDownloads returns the maximum number of simultaneous downloads allowed for downloader name. 0 means no limit
+func getMaxActiveDownloads(name string) int {
+	limit, _ := strconv.Atoi(configuration.Config.Downloaders[name]["max_active_downloads"])
+	return limit
+}
+
+// countActive returns the number of active downloads in downloader name ("" counts downloads in all downloaders), without counting item with key ignoredKey
+func (q *downloadQueue) countActive(name string, ignoredKey string) int {
+	count := 0
+	for key, download := range q.active {
+		if key == ignoredKey {
+			continue
+		}
+		if name == "" || download.downloader == name {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+func (q *downloadQueue) hasFreeSlot(name string, ignoredKey string) bool {
+	limit := getMaxActiveDownloads(name)
+	return limit <= 0 || q.countActive(name, ignoredKey) < limit
+}
+
+// getAvailableDownloader returns the name of a downloader with a free slot able to download item d. Items that no downloader can handle are started anyway to fail the download properly
+func (q *downloadQueue) getAvailableDownloader(d downloadable.Downloadable) (string, bool) {
+	downloadingItem := d.GetDownloadingItem()
+	candidates := getDownloaders(d, downloadingItem.CurrentTorrent())
+	if len(candidates) == 0 {
+		return "", true
+	}
+
+	for _, candidate := range candidates {
+		if q.hasFreeSlot(candidate.GetName(), getQueueKey(d)) {
+			return candidate.GetName(), true
+		}
+	}
+
+	return "", false
+}
+
+// persist saves queue position of queued items. Must be called with queue lock held
+func (q *downloadQueue) persist() {
+	for index, d := range q.items {
+		downloadingItem := d.GetDownloadingItem()
+		downloadingItem.Pending = true
+		downloadingItem.Queued = true
+		downloadingItem.QueuePosition = index + 1
+		d.SetDownloadingItem(downloadingItem)
+		db.Client.Model(&DownloadingItem{}).Where("id = ?", downloadingItem.ID).Updates(map[string]interface{}{
+			"pending":        true,
+			"queued":         true,
+			"queue_position": index + 1,
+		})
+	}
+}
+
+// insert adds item d in queue after items with the same or a higher priority. Must be called with queue lock held
+func (q *downloadQueue) insert(d downloadable.Downloadable) {
+	index := len(q.items)
+	for i, item := range q.items {
+		if item.GetDownloadingItem().Priority < d.GetDownloadingItem().Priority {
+			index = i
+			break
+		}
+	}
+
+	q.items = append(q.items, nil)
+	copy(q.items[index+1:], q.items[index:])
+	q.items[index] = d
+}
+
+// find returns the index of queued item with key. Must be called with queue lock held
+func (q *downloadQueue) find(key string) int {
+	for index, item := range q.items {
+		if getQueueKey(item) == key {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// start launches download of item d. Download slot is released when download process ends. Must be called with queue lock held
+func (q *downloadQueue) start(d downloadable.Downloadable, downloaderName string) {
+	key := getQueueKey(d)
+	q.active[key] = &activeDownload{
+		key:        key,
+		info:       getQueueItem(d, 0, ""),
+		downloader: downloaderName,
+		startedAt:  time.Now(),
+	}
+
+	downloadingItem := d.GetDownloadingItem()
+	downloadingItem.Queued = false
+	downloadingItem.QueuePosition = 0
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+
+	go func() {
+		if err := Download(d); err != nil {
+			d.GetLog().WithFields(log.Fields{
+				"error": err,
+			}).Debug("Download process ended with error")
+		}
+
+		q.Lock()
+		delete(q.active, key)
+		q.Unlock()
+
+		q.dispatch()
+	}()
+}
+
+// dispatch starts queued items while active downloads limits allow it
+func (q *downloadQueue) dispatch() {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.paused {
+		return
+	}
+
+	var waiting []downloadable.Downloadable
+	for _, d := range q.items {
+		if limit := configuration.Config.System.MaxActiveDownloads; limit > 0 && q.countActive("", "") >= limit {
+			waiting = append(waiting, d)
+			continue
+		}
+
+		downloaderName, ok := q.getAvailableDownloader(d)
+		if !ok {
+			waiting = append(waiting, d)
+			continue
+		}
+
+		d.GetLog().WithFields(log.Fields{
+			"downloader": downloaderName,
+		}).Debug("Starting queued download")
+		q.start(d, downloaderName)
+	}
+
+	if len(waiting) != len(q.items) {
+		q.items = waiting
+		q.persist()
+	}
+}
+
+// setDownloader records that active download d is handled by downloader name
+func (q *downloadQueue) setDownloader(d downloadable.Downloadable, name string) {
+	q.Lock()
+	defer q.Unlock()
+
+	if download, ok := q.active[getQueueKey(d)]; ok {
+		download.downloader = name
+	}
+}
+
+// remove removes item d from queue
+func (q *downloadQueue) remove(d downloadable.Downloadable) {
+	q.Lock()
+	defer q.Unlock()
+
+	index := q.find(getQueueKey(d))
+	if index < 0 {
+		return
+	}
+
+	q.items = append(q.items[:index], q.items[index+1:]...)
+
+	downloadingItem := d.GetDownloadingItem()
+	downloadingItem.Queued = false
+	downloadingItem.QueuePosition = 0
+	d.SetDownloadingItem(downloadingItem)
+	db.Client.Model(&DownloadingItem{}).Where("id = ?", downloadingItem.ID).Updates(map[string]interface{}{
+		"queued":         false,
+		"queue_position": 0,
+	})
+
+	q.persist()
+}
+
+// Enqueue adds item d in download queue. Download starts as soon as a download slot is available.
+// Items already added in a download client (download recovery) are started immediately
+func Enqueue(d downloadable.Downloadable) {
+	downloadingItem := d.GetDownloadingItem()
+
+	queue.Lock()
+	key := getQueueKey(d)
+	if _, ok := queue.active[key]; ok || queue.find(key) >= 0 {
+		queue.Unlock()
+		d.GetLog().Debug("Item already in download queue")
+		return
+	}
+
+	if downloadingItem.CurrentDownloaderId != "" {
+		queue.start(d, downloadingItem.CurrentDownloader)
+		queue.Unlock()
+		return
+	}
+
+	queue.insert(d)
+	queue.persist()
+	queue.Unlock()
+
+	d.GetLog().WithFields(log.Fields{
+		"priority": downloadingItem.Priority,
+	}).Debug("Item added in download queue")
+
+	queue.dispatch()
+}
+
+// PauseQueue stops starting queued downloads. Active downloads are not affected
+func PauseQueue() {
+	log.Info("Download queue paused")
+
+	queue.Lock()
+	queue.paused = true
+	db.SaveQueuePaused(true)
+	queue.Unlock()
+}
+
+// ResumeQueue starts queued downloads again
+func ResumeQueue() {
+	log.Info("Download queue resumed")
+
+	queue.Lock()
+	queue.paused = false
+	db.SaveQueuePaused(false)
+	queue.Unlock()
+
+	queue.dispatch()
+}
+
+// LoadQueueState restores paused state of download queue saved in database
+func LoadQueueState() {
+	queue.Lock()
+	queue.paused = db.Session.QueuePaused
+	queue.Unlock()
+
+	if db.Session.QueuePaused {
+		log.Info("Download queue paused")
+	}
+}
+
+func getQueueItem(d downloadable.Downloadable, position int, downloaderName string) QueueItem {
+	return QueueItem{
+		MediaType:  getMediaType(d),
+		Id:         d.GetId(),
+		Title:      d.GetTitle(),
+		Priority:   d.GetDownloadingItem().Priority,
+		Position:   position,
+		Downloader: downloaderName,
+	}
+}
+
+// GetQueue returns active downloads started by queue, in start order, and queued items, in queue order
+func GetQueue() QueueStatus {
+	queue.Lock()
+	defer queue.Unlock()
+
+	status := QueueStatus{
+		Paused:             queue.paused,
+		MaxActiveDownloads: configuration.Config.System.MaxActiveDownloads,
+		Active:             []QueueItem{},
+		Queued:             []QueueItem{},
+	}
+
+	var active []*activeDownload
+	for _, download := range queue.active {
+		active = append(active, download)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if !active[i].startedAt.Equal(active[j].startedAt) {
+			return active[i].startedAt.Before(active[j].startedAt)
+		}
+		return active[i].key < active[j].key
+	})
+	for _, download := range active {
+		item := download.info
+		item.Downloader = download.downloader
+		status.Active = append(status.Active, item)
+	}
+	for index, d := range queue.items {
+		status.Queued = append(status.Queued, getQueueItem(d, index+1, ""))
+	}
+
+	return status
+}
+
+// MoveQueueItem moves queued item to position (starting at 1) in queue
+func MoveQueueItem(mediaType string, id uint, position int) error {
+	queue.Lock()
+
+	index := queue.find(fmt.Sprintf("%s_%d", mediaType, id))
+	if index < 0 {
+		queue.Unlock()
+		return errors.New("Item not found in download queue")
+	}
+	if position < 1 || position > len(queue.items) {
+		queue.Unlock()
+		return fmt.Errorf("Queue position must be between 1 and %d", len(queue.items))
+	}
+
+	d := queue.items[index]
+	queue.items = append(queue.items[:index], queue.items[index+1:]...)
+	queue.items = append(queue.items, nil)
+	copy(queue.items[position:], queue.items[position-1:])
+	queue.items[position-1] = d
+	queue.persist()
+	queue.Unlock()
+
+	queue.dispatch()
+
+	return nil
+}
+
+// SetQueueItemPriority changes priority of queued item. Item is moved after queued items with the same or a higher priority
+func SetQueueItemPriority(mediaType string, id uint, priority int) error {
+	queue.Lock()
+
+	index := queue.find(fmt.Sprintf("%s_%d", mediaType, id))
+	if index < 0 {
+		queue.Unlock()
+		return errors.New("Item not found in download queue")
+	}
+
+	d := queue.items[index]
+	queue.items = append(queue.items[:index], queue.items[index+1:]...)
+
+	downloadingItem := d.GetDownloadingItem()
+	downloadingItem.Priority = priority
+	d.SetDownloadingItem(downloadingItem)
+	db.Client.Model(&DownloadingItem{}).Where("id = ?", downloadingItem.ID).Update("priority", priority)
+
+	queue.insert(d)
+	queue.persist()
+	queue.Unlock()
+
+	queue.dispatch()
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package downloader
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/downloadable"
+	"github.com/macarrie/flemzerd/indexers"
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// RoutingRules restrict the releases sent to a downloader. Rules are read from downloader configuration, empty rules accept every release
+type RoutingRules struct {
+	// Downloaders with higher priority are used first
+	Priority int
+	// Accepted media types (movie, episode)
+	MediaTypes []string
+	// Accepted release sizes (in bytes). 0 means no limit
+	MinSize int64
+	MaxSize int64
+	// Accepted tags. Tags of a release are the name of the quality profile of the media and the name of the indexer the release comes from
+	Tags []string
+}
+
+func splitList(list string) []string {
+	var retList []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			retList = append(retList, item)
+		}
+	}
+
+	return retList
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == strings.ToLower(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetRoutingRules returns routing rules defined in configuration for downloader name
+func GetRoutingRules(name string) RoutingRules {
+	rules := RoutingRules{}
+
+	downloaderConf, ok := configuration.Config.Downloaders[name]
+	if !ok {
+		return rules
+	}
+
+	rules.Priority, _ = strconv.Atoi(downloaderConf["priority"])
+	rules.MediaTypes = splitList(downloaderConf["media_types"])
+	minSize, _ := strconv.ParseInt(downloaderConf["min_size"], 10, 64)
+	maxSize, _ := strconv.ParseInt(downloaderConf["max_size"], 10, 64)
+	rules.MinSize = minSize * 1024 * 1024
+	rules.MaxSize = maxSize * 1024 * 1024
+	rules.Tags = splitList(downloaderConf["tags"])
+
+	return rules
+}
+
+func getMediaType(d downloadable.Downloadable) string {
+	switch d.(type) {
+	case *Movie:
+		return "movie"
+	case *Episode:
+		return "episode"
+	default:
+		return ""
+	}
+}
+
+// Accepts returns true if release t downloaded for media d matches routing rules.
+// Releases with unknown size are accepted by size rules
+func (r RoutingRules) Accepts(d downloadable.Downloadable, t Torrent) bool {
+	if len(r.MediaTypes) > 0 && !contains(r.MediaTypes, getMediaType(d)) {
+		return false
+	}
+
+	if t.TotalSize > 0 {
+		if r.MinSize > 0 && t.TotalSize < r.MinSize {
+			return false
+		}
+		if r.MaxSize > 0 && t.TotalSize > r.MaxSize {
+			return false
+		}
+	}
+
+	if len(r.Tags) > 0 {
+		tags := []string{t.Indexer}
+		if d != nil {
+			tags = append(tags, indexer.GetQualityProfile(d).Name)
+		}
+
+		for _, tag := range tags {
+			if contains(r.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// getDownloaders returns registered downloaders able to download release t for media d (matching protocol and routing rules), sorted by routing priority (highest priority first)
+func getDownloaders(d downloadable.Downloadable, t Torrent) []Downloader {
+	var candidates []Downloader
+	for _, dl := range downloadersCollection {
+		if getDownloaderProtocol(dl) != t.GetProtocol() {
+			continue
+		}
+		if !GetRoutingRules(dl.GetName()).Accepts(d, t) {
+			continue
+		}
+
+		candidates = append(candidates, dl)
+	}
+
+	// Downloaders with the same priority are sorted by name so that routing does not depend on registration order
+	sort.Slice(candidates, func(i, j int) bool {
+		iPriority := GetRoutingRules(candidates[i].GetName()).Priority
+		jPriority := GetRoutingRules(candidates[j].GetName()).Priority
+		if iPriority != jPriority {
+			return iPriority > jPriority
+		}
+		return candidates[i].GetName() < candidates[j].GetName()
+	})
+
+	return candidates
+}
@@ -0,0 +1,182 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/vidocq"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+// errEpisodeNotInSeasonPack is returned when a season pack does not contain the episode it has been downloaded for
+var errEpisodeNotInSeasonPack = errors.New("Episode file not found in season pack")
+
+// getSeasonPackEpisodeFiles returns video files found in season pack download folder, indexed by episode number.
+// Samples and files from other seasons are ignored
+func getSeasonPackEpisodeFiles(path string, season int) (map[int]string, error) {
+	files := make(map[int]string)
+
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		if vidocq.IsSample(relativePath) {
+			return nil
+		}
+
+		mediaInfo := vidocq.Parse(info.Name())
+		if mediaInfo.Container == "" || mediaInfo.Episode == 0 {
+			return nil
+		}
+		if mediaInfo.Season != 0 && mediaInfo.Season != season {
+			return nil
+		}
+
+		if _, ok := files[mediaInfo.Episode]; !ok {
+			files[mediaInfo.Episode] = filePath
+		}
+		return nil
+	})
+	if err != nil {
+		return files, errors.Wrap(err, "could not list season pack files")
+	}
+
+	return files, nil
+}
+
+// moveSeasonPackToLibrary splits the season pack downloaded for episode into episode library folders.
+// Every episode of the season found in the pack is marked as downloaded, unless it already has been downloaded separately
+func moveSeasonPackToLibrary(episode *Episode, downloadingItem *DownloadingItem) error {
+	pack := downloadingItem.CurrentTorrent()
+
+	files, err := getSeasonPackEpisodeFiles(pack.DownloadDir, episode.Season)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("No episode files found in season pack")
+	}
+
+	releaseFiles, err := listReleaseFiles(pack.DownloadDir)
+	if err != nil {
+		return errors.Wrap(err, "Could not list season pack files")
+	}
+
+	leadFound := false
+	imported := make(map[string]bool)
+	for number, filePath := range files {
+		fileName := filepath.Base(filePath)
+		mediaInfo := vidocq.Parse(fileName)
+		containsLead := mediaInfo.CoversEpisode(episode.Number)
+
+		target := *episode
+		if !containsLead {
+			var ok bool
+			if target, ok = getCoveredEpisode(*episode, number); !ok {
+				continue
+			}
+		}
+
+		destinationPath, libraryFileName := getEpisodeLibraryPath(target, pack, mediaInfo.Episode, mediaInfo.LastEpisode)
+		if err := os.MkdirAll(destinationPath, 0755); err != nil {
+			return errors.Wrap(err, "Could not create library folder for episode")
+		}
+
+		libraryPath, importedFiles, err := importVideoFile(filePath, getMatchingSubtitles(filePath, releaseFiles.subtitles, false), destinationPath, libraryFileName, hasSeedingGoals(pack))
+		if err != nil {
+			return errors.Wrap(err, "Could not move season pack file to library")
+		}
+		fileName = filepath.Base(libraryPath)
+		for _, file := range importedFiles {
+			imported[file] = true
+		}
+
+		target.GetLog().WithFields(log.Fields{
+			"file":        fileName,
+			"season_pack": pack.Name,
+		}).Info("Episode moved into library from season pack")
+
+		release := Torrent{
+			Name:        pack.Name,
+			Link:        pack.Link,
+			DownloadDir: destinationPath,
+			LibraryPath: libraryPath,
+			MediaInfo:   pack.MediaInfo,
+			Indexer:     pack.Indexer,
+			Status:      pack.Status,
+			TotalSize:   pack.TotalSize,
+			SeasonPack:  true,
+		}
+		if containsLead {
+			leadFound = true
+			for i := range downloadingItem.TorrentList {
+				if downloadingItem.TorrentList[i].ID == pack.ID {
+					downloadingItem.TorrentList[i].DownloadDir = destinationPath
+					downloadingItem.TorrentList[i].LibraryPath = libraryPath
+					db.Client.Save(&downloadingItem.TorrentList[i])
+				}
+			}
+		} else {
+			markEpisodeDownloaded(&target, release)
+		}
+
+		if mediaInfo.IsMultiEpisode() {
+			markCoveredEpisodesDownloaded(target, release, mediaInfo.EpisodeNumbers())
+		}
+	}
+
+	// Remaining files (samples, nfo, episodes already downloaded, ...) are archived or discarded
+	cleanRelease(pack, releaseFiles.remaining(imported))
+
+	if !leadFound {
+		return errEpisodeNotInSeasonPack
+	}
+
+	return nil
+}
+
+// rejectSeasonPack handles season pack t that did not contain the episode d it was downloaded for. Other episodes of the pack have been imported.
+// Pack is removed from download client and marked as failed so that the episode is downloaded with next torrent
+func rejectSeasonPack(d *downloadable.Downloadable, downloaderName string, t *Torrent, temporaryPath string) error {
+	(*d).GetLog().WithFields(log.Fields{
+		"season_pack": t.Name,
+	}).Warning("Episode file not found in season pack. Skipping to next torrent in list")
+
+	if err := RemoveTorrent(downloaderName, *t); err != nil {
+		log.WithFields(log.Fields{
+			"torrent": t.Name,
+			"error":   err,
+		}).Error("Could not remove season pack from downloader")
+	}
+	// Symlinked episodes point to season pack data
+	if getImportMode(hasSeedingGoals(*t)) != IMPORT_SYMLINK && temporaryPath != "" && strings.HasPrefix(temporaryPath, configuration.Config.Library.CustomTmpPath) {
+		if err := os.RemoveAll(temporaryPath); err != nil {
+			log.WithFields(log.Fields{
+				"path":  temporaryPath,
+				"error": err,
+			}).Warning("Could not remove season pack data")
+		}
+	}
+
+	t.Failed = true
+	t.FailureReason = errEpisodeNotInSeasonPack.Error()
+	db.Client.Save(t)
+
+	return errors.Wrap(errEpisodeNotInSeasonPack, "season pack rejected")
+}
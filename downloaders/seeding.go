@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// getSeedingGoals returns the upload ratio and seeding time (in hours) torrent t must reach before being removed from download client.
+// Goals defined for the indexer the torrent comes from override system goals. Usenet releases have no seeding goals
+func getSeedingGoals(t Torrent) (float64, int) {
+	if t.GetProtocol() != PROTOCOL_TORRENT {
+		return 0, 0
+	}
+
+	ratio := configuration.Config.System.SeedRatio
+	seedTime := configuration.Config.System.SeedTime
+	if t.Indexer == "" {
+		return ratio, seedTime
+	}
+
+	for _, indexerList := range configuration.Config.Indexers {
+		for _, indexer := range indexerList {
+			if indexer["name"] != t.Indexer {
+				continue
+			}
+
+			if value, ok := indexer["seed_ratio"]; ok {
+				if indexerRatio, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64); err == nil {
+					ratio = indexerRatio
+				}
+			}
+			if value, ok := indexer["seed_time"]; ok {
+				if indexerSeedTime, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64); err == nil {
+					seedTime = int(indexerSeedTime)
+				}
+			}
+			return ratio, seedTime
+		}
+	}
+
+	return ratio, seedTime
+}
+
+// hasSeedingGoals returns true if torrent t must keep seeding after download
+func hasSeedingGoals(t Torrent) bool {
+	ratio, seedTime := getSeedingGoals(t)
+	return ratio > 0 || seedTime > 0
+}
+
+// startSeeding keeps track of downloaded torrent t left seeding in download client until its seeding goals are reached.
+// Data located in path is removed with the torrent. An empty path keeps downloaded data
+func startSeeding(downloaderName string, downloaderId string, t Torrent, path string) {
+	ratio, seedTime := getSeedingGoals(t)
+
+	seedingTorrent := SeedingTorrent{
+		Name:         t.Name,
+		TorrentId:    t.TorrentId,
+		Downloader:   downloaderName,
+		DownloaderId: downloaderId,
+		Indexer:      t.Indexer,
+		Path:         path,
+		SeedRatio:    ratio,
+		SeedTime:     seedTime,
+		UploadRatio:  t.UploadRatio,
+		CompletedAt:  time.Now(),
+	}
+	db.Client.Create(&seedingTorrent)
+
+	log.WithFields(log.Fields{
+		"torrent":    t.Name,
+		"downloader": downloaderName,
+		"seed_ratio": ratio,
+		"seed_time":  seedTime,
+	}).Info("Torrent left seeding in download client until seeding goals are reached")
+}
+
+// removeSeedingTorrent removes seeding torrent from download client and deletes its downloaded data
+func removeSeedingTorrent(s SeedingTorrent) error {
+	torrent := Torrent{
+		TorrentId: s.TorrentId,
+		Name:      s.Name,
+	}
+	if err := RemoveTorrent(s.Downloader, torrent); err != nil {
+		return err
+	}
+
+	// Only remove data located in temporary download folder, to avoid removing library files if path is incomplete
+	if s.Path != "" && strings.HasPrefix(s.Path, configuration.Config.Library.CustomTmpPath) {
+		if err := os.RemoveAll(s.Path); err != nil {
+			log.WithFields(log.Fields{
+				"torrent": s.Name,
+				"path":    s.Path,
+				"error":   err,
+			}).Warning("Could not remove seeded torrent data")
+		}
+	}
+
+	db.Client.Unscoped().Delete(&s)
+
+	return nil
+}
+
+// ReapSeedingTorrents retrieves status of seeding torrents and removes from download client the torrents that reached their seeding goals
+func ReapSeedingTorrents() {
+	var seedingTorrents []SeedingTorrent
+	if err := db.Client.Find(&seedingTorrents).Error; err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Could not retrieve seeding torrents from database")
+		return
+	}
+
+	now := time.Now()
+	for _, seedingTorrent := range seedingTorrents {
+		torrent := Torrent{
+			TorrentId: seedingTorrent.TorrentId,
+			Name:      seedingTorrent.Name,
+		}
+		// Mapping is lost when daemon restarts
+		AddTorrentMapping(seedingTorrent.Downloader, torrent, seedingTorrent.DownloaderId)
+
+		if err := GetTorrentStatus(seedingTorrent.Downloader, &torrent); err != nil {
+			log.WithFields(log.Fields{
+				"torrent":    seedingTorrent.Name,
+				"downloader": seedingTorrent.Downloader,
+				"error":      err,
+			}).Warning("Could not get seeding torrent status")
+			continue
+		}
+
+		seedingTorrent.UploadRatio = torrent.UploadRatio
+		if !seedingTorrent.GoalsReached(now) {
+			db.Client.Model(&SeedingTorrent{}).Where("id = ?", seedingTorrent.ID).Update("upload_ratio", torrent.UploadRatio)
+			continue
+		}
+
+		if err := removeSeedingTorrent(seedingTorrent); err != nil {
+			log.WithFields(log.Fields{
+				"torrent":    seedingTorrent.Name,
+				"downloader": seedingTorrent.Downloader,
+				"error":      err,
+			}).Error("Could not remove torrent from download client after seeding goals were reached")
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"torrent":      seedingTorrent.Name,
+			"downloader":   seedingTorrent.Downloader,
+			"upload_ratio": seedingTorrent.UploadRatio,
+		}).Info("Seeding goals reached, torrent removed from download client")
+	}
+}
@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// Downloads ETA is unreliable when download starts. Max ETA rule is only applied after this delay
+const STALL_ETA_GRACE_PERIOD = 10 * time.Minute
+
+// stallTracker keeps track of a torrent download progress to detect stalled downloads
+type stallTracker struct {
+	start            time.Time
+	lastProgress     float64
+	lastProgressTime time.Time
+	lastPeersTime    time.Time
+}
+
+func newStallTracker(now time.Time) *stallTracker {
+	return &stallTracker{
+		start:            now,
+		lastProgressTime: now,
+		lastPeersTime:    now,
+	}
+}
+
+// check updates tracker with torrent t status and returns the reason why download is considered stalled according to configured rules.
+// An empty string is returned if download is not stalled
+func (s *stallTracker) check(t Torrent, now time.Time) string {
+	// Torrents waiting in download client queue are not downloading yet
+	if t.Status == TORRENT_DOWNLOAD_PENDING {
+		s.start = now
+		s.lastProgressTime = now
+		s.lastPeersTime = now
+		return ""
+	}
+
+	if t.PercentDone > s.lastProgress {
+		s.lastProgress = t.PercentDone
+		s.lastProgressTime = now
+	}
+	// Download clients not reporting peers are considered to have peers while data is downloaded
+	if t.PeersConnected > 0 || t.RateDownload > 0 {
+		s.lastPeersTime = now
+	}
+
+	if timeout := configuration.Config.System.StallNoProgressTimeout; timeout > 0 && now.Sub(s.lastProgressTime) >= time.Duration(timeout)*time.Minute {
+		return fmt.Sprintf("No download progress for %d minutes", timeout)
+	}
+
+	if timeout := configuration.Config.System.StallNoPeersTimeout; timeout > 0 && t.GetProtocol() == PROTOCOL_TORRENT && now.Sub(s.lastPeersTime) >= time.Duration(timeout)*time.Minute {
+		return fmt.Sprintf("No peers connected for %d minutes", timeout)
+	}
+
+	if maxEta := configuration.Config.System.StallMaxEta; maxEta > 0 && t.Status == TORRENT_DOWNLOADING && now.Sub(s.start) >= STALL_ETA_GRACE_PERIOD && t.ETA.Sub(now) > time.Duration(maxEta)*time.Hour {
+		return fmt.Sprintf("Download ETA is beyond %d hours", maxEta)
+	}
+
+	return ""
+}
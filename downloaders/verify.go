@@ -0,0 +1,185 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+	"github.com/macarrie/flemzerd/probe"
+	"github.com/macarrie/flemzerd/vidocq"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
+)
+
+// Minimum ratio between probed duration and runtime retrieved from providers
+const MIN_DURATION_RATIO = 0.5
+
+// Minimum ratio between probed resolution and resolution of release quality. Width or height can be lower than expected for cropped or anamorphic videos
+const MIN_RESOLUTION_RATIO = 0.75
+
+// Nominal resolution (width, height) of release qualities
+var qualityResolutions = map[string][2]int{
+	"2160p": {3840, 2160},
+	"1440p": {2560, 1440},
+	"1080p": {1920, 1080},
+	"900p":  {1600, 900},
+	"720p":  {1280, 720},
+	"576p":  {720, 576},
+	"480p":  {640, 480},
+}
+
+// VerificationError is returned when downloaded video file does not match downloaded item (fake release, wrong quality or runtime)
+type VerificationError struct {
+	Path   string
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("media verification failed (%s): %s", filepath.Base(e.Path), e.Reason)
+}
+
+// getProviderRuntime returns runtime (in minutes) of item d retrieved from providers, or 0 if runtime is unknown
+func getProviderRuntime(d downloadable.Downloadable) int {
+	switch d.(type) {
+	case *Movie:
+		return d.(*Movie).Runtime
+	case *Episode:
+		return d.(*Episode).TvShow.Runtime
+	}
+
+	return 0
+}
+
+// probeVideoFile probes downloaded video file located at path and checks that it contains a video track with a resolution matching quality.
+// Returns false if file cannot be probed (unsupported container)
+func probeVideoFile(d downloadable.Downloadable, path string, quality string) (probe.Result, bool, error) {
+	result, err := probe.File(path)
+	switch {
+	case err == probe.ErrFormat:
+		return result, false, &VerificationError{
+			Path:   path,
+			Reason: "file content is not a valid video container",
+		}
+	case err == probe.ErrUnsupported:
+		d.GetLog().WithFields(log.Fields{
+			"path": path,
+		}).Debug("Video container not supported by media verification")
+		return result, false, nil
+	case err != nil:
+		d.GetLog().WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Warning("Could not probe downloaded video file")
+		return result, false, nil
+	}
+
+	if result.VideoCodec == "" {
+		return result, true, &VerificationError{
+			Path:   path,
+			Reason: "no video track found",
+		}
+	}
+
+	if resolution, ok := qualityResolutions[quality]; ok {
+		if float64(result.Width) < float64(resolution[0])*MIN_RESOLUTION_RATIO && float64(result.Height) < float64(resolution[1])*MIN_RESOLUTION_RATIO {
+			return result, true, &VerificationError{
+				Path:   path,
+				Reason: fmt.Sprintf("resolution %dx%d does not match %s quality", result.Width, result.Height, quality),
+			}
+		}
+	}
+
+	return result, true, nil
+}
+
+// verifyReleaseMedia probes main video files of release t downloaded for item d, and checks that their resolution matches quality of the release and that their total duration matches runtime of the episodes they contain.
+// Releases without video files and releases containing files that cannot be probed (unsupported containers) are not verified
+func verifyReleaseMedia(d downloadable.Downloadable, t Torrent, episodes int) (MediaProbe, error) {
+	files, err := listReleaseFiles(t.DownloadDir)
+	if err != nil || len(files.videos) == 0 {
+		d.GetLog().WithFields(log.Fields{
+			"path": t.DownloadDir,
+		}).Debug("No video file to verify in downloaded release")
+		return MediaProbe{}, nil
+	}
+
+	quality := t.MediaInfo.Quality
+	if quality == "" {
+		quality = vidocq.Parse(t.Name).Quality
+	}
+
+	// Multi-part releases are verified part by part, and their duration is the sum of the duration of each part
+	var results []probe.Result
+	var duration time.Duration
+	for _, video := range files.videos {
+		result, probed, err := probeVideoFile(d, video, quality)
+		if err != nil {
+			return MediaProbe{}, err
+		}
+		if !probed {
+			return MediaProbe{}, nil
+		}
+		results = append(results, result)
+		duration += result.Duration
+	}
+
+	// Probe result of the first part describes the release
+	mediaProbe := MediaProbe{
+		Probed:         true,
+		Container:      results[0].Container,
+		Duration:       int(duration.Seconds()),
+		Width:          results[0].Width,
+		Height:         results[0].Height,
+		VideoCodec:     results[0].VideoCodec,
+		AudioCodecs:    strings.Join(results[0].AudioCodecs, ","),
+		AudioLanguages: strings.Join(results[0].AudioLanguages, ","),
+	}
+
+	runtime := getProviderRuntime(d) * episodes
+	if runtime > 0 && duration > 0 && duration.Minutes() < float64(runtime)*MIN_DURATION_RATIO {
+		return mediaProbe, &VerificationError{
+			Path:   files.videos[0],
+			Reason: fmt.Sprintf("duration of %d minutes is too short for a runtime of %d minutes", int(duration.Minutes()), runtime),
+		}
+	}
+
+	return mediaProbe, nil
+}
+
+// rejectDownloadedRelease removes torrent t rejected by media verification from download client along with its downloaded data, and marks it as failed so that next torrent of item d is downloaded
+func rejectDownloadedRelease(d *downloadable.Downloadable, downloaderName string, t *Torrent, temporaryPath string, verificationErr *VerificationError) error {
+	(*d).GetLog().WithFields(log.Fields{
+		"torrent": t.Name,
+		"reason":  verificationErr.Reason,
+	}).Warning("Downloaded release rejected by media verification. Skipping to next torrent in list")
+
+	if err := RemoveTorrent(downloaderName, *t); err != nil {
+		log.WithFields(log.Fields{
+			"torrent": t.Name,
+			"error":   err,
+		}).Error("Could not remove rejected torrent from downloader")
+	}
+	// Only remove data located in temporary download folder
+	if temporaryPath != "" && strings.HasPrefix(temporaryPath, configuration.Config.Library.CustomTmpPath) {
+		if err := os.RemoveAll(temporaryPath); err != nil {
+			log.WithFields(log.Fields{
+				"path":  temporaryPath,
+				"error": err,
+			}).Warning("Could not remove downloaded data of rejected torrent")
+		}
+	}
+
+	t.Failed = true
+	t.FailureReason = verificationErr.Error()
+	db.Client.Save(t)
+
+	return errors.Wrap(verificationErr, "downloaded release rejected")
+}
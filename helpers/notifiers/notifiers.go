@@ -78,6 +78,15 @@ func GetNotificationText(notif Notification) (notif_title, notif_content string,
 			content = fmt.Sprintf("Failed to download movie: %v", getMovieTitle(notif.Movie))
 		}
 
+	case NOTIFICATION_DOWNLOAD_STALLED:
+		if notif.Episode.ID != 0 {
+			title = fmt.Sprintf("%v S%03dE%03d: Download stalled", getShowTitle(notif.Episode.TvShow), notif.Episode.Season, notif.Episode.Number)
+		}
+		if notif.Movie.ID != 0 {
+			title = fmt.Sprintf("%v: Download stalled", getMovieTitle(notif.Movie))
+		}
+		content = notif.Content
+
 	default:
 		return "", "", fmt.Errorf("Unable to send notification: Unknown notification type (%d)", notif.Type)
 	}
@@ -3,7 +3,6 @@ package indexer
 import (
 	"fmt"
 	"regexp"
-	"sort"
 	"strings"
 	"sync"
 
@@ -67,7 +66,17 @@ func Reset() {
 	indexersCollection = []Indexer{}
 }
 
+// GetTorrents searches torrents for downloadable in all indexers. Returned torrents are sorted by score, and torrents rejected by filters are removed from the list
 func GetTorrents(d downloadable.Downloadable) ([]Torrent, error) {
+	torrentList, err := SearchTorrents(d)
+	torrentList = applyFilters(torrentList, GetFilters(d))
+
+	return torrentList, err
+}
+
+// SearchTorrents searches torrents for downloadable in all indexers, parses media info of found torrents and sorts them by score. No filtering is done on results.
+// A non nil error is returned only if all indexers returned an error
+func SearchTorrents(d downloadable.Downloadable) ([]Torrent, error) {
 	var torrentList []Torrent
 	var errorList *multierror.Error
 	var totalError bool = true
@@ -86,6 +95,9 @@ func GetTorrents(d downloadable.Downloadable) ([]Torrent, error) {
 		}
 
 		if len(indexerSearch) != 0 {
+			for i := range indexerSearch {
+				indexerSearch[i].Indexer = indexer.GetName()
+			}
 			torrentList = append(torrentList, indexerSearch...)
 			d.GetLog().WithFields(log.Fields{
 				"indexer": indexer.GetName(),
@@ -98,40 +110,183 @@ func GetTorrents(d downloadable.Downloadable) ([]Torrent, error) {
 		}
 	}
 
-	sort.Slice(torrentList[:], func(i, j int) bool {
-		return torrentList[i].Seeders > torrentList[j].Seeders
-	})
+	ParseTorrentsMediaInfo(torrentList)
+	ScoreTorrents(d, torrentList)
+
+	if totalError {
+		return torrentList, errorList.ErrorOrNil()
+	}
+
+	return torrentList, nil
+}
 
+// ParseTorrentsMediaInfo parses media info from the name of each torrent in list and stores it in the torrent object.
+// Torrents already parsed are skipped, so that filters and scoring can reuse parsed info without parsing torrent names again
+func ParseTorrentsMediaInfo(list []Torrent) {
+	for i := range list {
+		_, _ = GetTorrentMediaInfo(&list[i])
+	}
+}
+
+// GetTorrentMediaInfo returns media info parsed from torrent name. Parsing result is cached in torrent object, and torrent name is parsed only if it has not already been done
+func GetTorrentMediaInfo(torrent *Torrent) (MediaInfo, error) {
+	if torrent.Name != "" && torrent.MediaInfo.Raw == torrent.Name {
+		return torrent.MediaInfo, nil
+	}
+
+	mediaInfo, err := vidocq.GetInfo(torrent.Name)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	torrent.MediaInfo = mediaInfo
+	return mediaInfo, nil
+}
+
+// GetQualityProfile returns the quality profile used to filter and sort torrents for downloadable.
+// If no profile is assigned to the movie (or the show of the episode), a default profile is built from global configuration
+func GetQualityProfile(d downloadable.Downloadable) QualityProfile {
 	switch d.(type) {
 	case *Movie:
-		torrentList = FilterMovieTorrents(*d.(*Movie), torrentList)
+		movie := d.(*Movie)
+		if movie.QualityProfileID != 0 && movie.QualityProfile.ID != 0 {
+			return movie.QualityProfile
+		}
 	case *Episode:
-		torrentList = FilterEpisodeTorrents(*d.(*Episode), torrentList)
+		episode := d.(*Episode)
+		if episode.TvShow.QualityProfileID != 0 && episode.TvShow.QualityProfile.ID != 0 {
+			return episode.TvShow.QualityProfile
+		}
 	}
 
-	if totalError {
-		return torrentList, errorList.ErrorOrNil()
+	return QualityProfile{
+		Name:      "default",
+		Qualities: configuration.Config.System.PreferredMediaQuality,
 	}
+}
 
-	return torrentList, nil
+// Runtime (in minutes) used when providers do not return any runtime for media
+const (
+	EPISODE_EXPECTED_RUNTIME = 45
+	MOVIE_EXPECTED_RUNTIME   = 120
+)
+
+// GetExpectedRuntime returns runtime (in minutes) of the movie, or of the episodes of the show, as retrieved from providers. A default runtime is returned if runtime is unknown
+func GetExpectedRuntime(d downloadable.Downloadable) int {
+	switch d.(type) {
+	case *Movie:
+		if runtime := d.(*Movie).Runtime; runtime > 0 {
+			return runtime
+		}
+		return MOVIE_EXPECTED_RUNTIME
+	case *Episode:
+		if runtime := d.(*Episode).TvShow.Runtime; runtime > 0 {
+			return runtime
+		}
+	}
+
+	return EPISODE_EXPECTED_RUNTIME
 }
 
-func FilterEpisodeTorrents(episode Episode, torrentList []Torrent) []Torrent {
-	torrentList = FilterTorrentEpisodeNumber(torrentList, episode)
-	torrentList = FilterTorrentQuality(torrentList)
-	torrentList = FilterTorrentReleaseType(torrentList)
+// TorrentFilter is a step of the torrent filtering chain. Reason is optional and explains why a torrent is rejected by the filter
+type TorrentFilter struct {
+	Name   string
+	Filter func(list []Torrent) []Torrent
+	Reason func(torrent *Torrent) string
+}
+
+// GetFilters returns the filtering chain applied on torrents found for downloadable
+func GetFilters(d downloadable.Downloadable) []TorrentFilter {
+	switch d.(type) {
+	case *Movie:
+		return GetMovieFilters(*d.(*Movie))
+	case *Episode:
+		return GetEpisodeFilters(*d.(*Episode))
+	}
 
-	return torrentList
+	return []TorrentFilter{}
 }
 
-func FilterMovieTorrents(movie Movie, torrentList []Torrent) []Torrent {
-	torrentList = FilterTorrentQuality(torrentList)
+func GetEpisodeFilters(episode Episode) []TorrentFilter {
+	profile := GetQualityProfile(&episode)
+	releaseFilters := GetReleaseFilters(&episode)
+	runtime := GetExpectedRuntime(&episode)
+
+	return []TorrentFilter{
+		TorrentFilter{
+			Name:   "episode_number",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentEpisodeNumber(list, episode) },
+		},
+		TorrentFilter{
+			Name:   "release_rules",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentReleaseRules(list, releaseFilters) },
+			Reason: func(torrent *Torrent) string { return releaseRulesRejectionReason(torrent, releaseFilters) },
+		},
+		TorrentFilter{
+			Name:   "size",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentSize(list, profile, runtime) },
+			Reason: func(torrent *Torrent) string { return sizeRejectionReason(torrent, profile, runtime) },
+		},
+		TorrentFilter{
+			Name:   "quality",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentQuality(list, profile) },
+		},
+		TorrentFilter{
+			Name:   "release_type",
+			Filter: FilterTorrentReleaseType,
+		},
+	}
+}
+
+func GetMovieFilters(movie Movie) []TorrentFilter {
+	profile := GetQualityProfile(&movie)
+	releaseFilters := GetReleaseFilters(&movie)
+	runtime := GetExpectedRuntime(&movie)
+
+	filters := []TorrentFilter{
+		TorrentFilter{
+			Name:   "release_rules",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentReleaseRules(list, releaseFilters) },
+			Reason: func(torrent *Torrent) string { return releaseRulesRejectionReason(torrent, releaseFilters) },
+		},
+		TorrentFilter{
+			Name:   "size",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentSize(list, profile, runtime) },
+			Reason: func(torrent *Torrent) string { return sizeRejectionReason(torrent, profile, runtime) },
+		},
+		TorrentFilter{
+			Name:   "quality",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentQuality(list, profile) },
+		},
+	}
 	if movie.Date.Year() != 1 {
-		torrentList = FilterTorrentYear(torrentList, movie.Date.Year())
+		filters = append(filters, TorrentFilter{
+			Name:   "year",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentYear(list, movie.Date.Year()) },
+		})
 	}
-	torrentList = FilterTorrentReleaseType(torrentList)
+	filters = append(filters, TorrentFilter{
+		Name:   "release_type",
+		Filter: FilterTorrentReleaseType,
+	})
+
+	return filters
+}
+
+func applyFilters(list []Torrent, filters []TorrentFilter) []Torrent {
+	for _, filter := range filters {
+		list = filter.Filter(list)
+	}
+
+	return list
+}
+
+func FilterEpisodeTorrents(episode Episode, torrentList []Torrent) []Torrent {
+	return applyFilters(torrentList, GetEpisodeFilters(episode))
+}
 
-	return torrentList
+func FilterMovieTorrents(movie Movie, torrentList []Torrent) []Torrent {
+	return applyFilters(torrentList, GetMovieFilters(movie))
 }
 
 func FilterTorrentEpisodeNumber(list []Torrent, episode Episode) []Torrent {
@@ -142,29 +297,31 @@ func FilterTorrentEpisodeNumber(list []Torrent, episode Episode) []Torrent {
 	var returnList []Torrent
 	var otherTorrents []Torrent
 
-	for _, torrent := range list {
+	for i := range list {
+		torrent := &list[i]
 		if episode.TvShow.IsAnime {
 			if episode.AbsoluteNumber != 0 {
 				match, err := regexp.Match(fmt.Sprintf("%v", episode.AbsoluteNumber), []byte(torrent.Name))
 				if match && err == nil {
-					returnList = append(returnList, torrent)
+					returnList = append(returnList, *torrent)
 				}
 			} else {
-				otherTorrents = append(otherTorrents, torrent)
+				otherTorrents = append(otherTorrents, *torrent)
 			}
 		} else {
-			episodeInfo, err := vidocq.GetInfo(torrent.Name)
+			episodeInfo, err := GetTorrentMediaInfo(torrent)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"torrent": torrent.Name,
 				}).Warning("Error while getting media info for torrent: ", err)
 
-				otherTorrents = append(otherTorrents, torrent)
+				otherTorrents = append(otherTorrents, *torrent)
 				continue
 			}
 
-			if episodeInfo.Season != 0 && episodeInfo.Season == episode.Season && episodeInfo.Episode != 0 && episodeInfo.Episode == episode.Number {
-				returnList = append(returnList, torrent)
+			// Multi-episode releases are accepted for any episode they contain
+			if episodeInfo.Season != 0 && episodeInfo.Season == episode.Season && episodeInfo.CoversEpisode(episode.Number) {
+				returnList = append(returnList, *torrent)
 			}
 		}
 	}
@@ -183,19 +340,20 @@ func FilterTorrentYear(list []Torrent, year int) []Torrent {
 	var returnList []Torrent
 	var otherTorrents []Torrent
 
-	for _, torrent := range list {
-		movieInfo, err := vidocq.GetInfo(torrent.Name)
+	for i := range list {
+		torrent := &list[i]
+		movieInfo, err := GetTorrentMediaInfo(torrent)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"torrent": torrent.Name,
 			}).Warning("Error while getting media info for torrent: ", err)
 
-			otherTorrents = append(otherTorrents, torrent)
+			otherTorrents = append(otherTorrents, *torrent)
 			continue
 		}
 
 		if (movieInfo.Year != 0 && movieInfo.Year == year) || movieInfo.Year == 0 {
-			returnList = append(returnList, torrent)
+			returnList = append(returnList, *torrent)
 		}
 	}
 
@@ -220,11 +378,14 @@ func FilterTorrentReleaseType(list []Torrent) []Torrent {
 		releaseTypeFilters[i] = strings.TrimSpace(releaseTypeFilters[i])
 	}
 
-	for _, torrent := range list {
-		mediaInfo, err := vidocq.GetInfo(torrent.Name)
+	for i := range list {
+		torrent := &list[i]
+		mediaInfo, err := GetTorrentMediaInfo(torrent)
 		if err != nil {
-			log.Warning("An error occured during vidocq request: ", err.Error())
-			otherTorrents = append(otherTorrents, torrent)
+			log.WithFields(log.Fields{
+				"torrent": torrent.Name,
+			}).Warning("Could not parse media info from torrent name: ", err)
+			otherTorrents = append(otherTorrents, *torrent)
 
 			continue
 		}
@@ -237,7 +398,7 @@ func FilterTorrentReleaseType(list []Torrent) []Torrent {
 		}
 
 		if !releaseTypeExcluded {
-			releaseFilteredList = append(releaseFilteredList, torrent)
+			releaseFilteredList = append(releaseFilteredList, *torrent)
 		}
 	}
 
@@ -248,41 +409,38 @@ func FilterTorrentReleaseType(list []Torrent) []Torrent {
 	return append(releaseFilteredList, otherTorrents...)
 }
 
-func FilterTorrentQuality(list []Torrent) []Torrent {
+// FilterTorrentQuality keeps torrents whose quality is allowed by profile. Torrents order is kept, torrents are sorted by score beforehand.
+// Torrents with other qualities are appended at the end of the list unless strict torrent check is enabled. If profile defines no qualities, the list is returned as is
+func FilterTorrentQuality(list []Torrent, profile QualityProfile) []Torrent {
 	log.WithFields(log.Fields{
-		"quality_filter": configuration.Config.System.PreferredMediaQuality,
-		"strict_check":   configuration.Config.System.StrictTorrentCheck,
-	}).Debug("Sorting list according to quality preferences")
+		"quality_profile": profile.Name,
+		"quality_filter":  profile.Qualities,
+		"strict_check":    configuration.Config.System.StrictTorrentCheck,
+	}).Debug("Filtering list according to quality preferences")
+
+	if len(profile.GetQualities()) == 0 {
+		return list
+	}
 
 	var qualityFilteredList []Torrent
 	var otherTorrents []Torrent
-	var qualityFilters []string
 
-	qualityFilters = strings.Split(configuration.Config.System.PreferredMediaQuality, ",")
-	for i := range qualityFilters {
-		qualityFilters[i] = strings.TrimSpace(qualityFilters[i])
-	}
-
-	for _, torrent := range list {
-		mediaInfo, err := vidocq.GetInfo(torrent.Name)
+	for i := range list {
+		torrent := &list[i]
+		mediaInfo, err := GetTorrentMediaInfo(torrent)
 		if err != nil {
-			log.Warning("An error occured during vidocq request: ", err.Error())
-			otherTorrents = append(otherTorrents, torrent)
+			log.WithFields(log.Fields{
+				"torrent": torrent.Name,
+			}).Warning("Could not parse media info from torrent name: ", err)
+			otherTorrents = append(otherTorrents, *torrent)
 
 			continue
 		}
 
-		qualityMatches := false
-		for _, quality := range qualityFilters {
-			if quality == mediaInfo.Quality {
-				qualityMatches = true
-			}
-		}
-
-		if qualityMatches {
-			qualityFilteredList = append(qualityFilteredList, torrent)
+		if profile.QualityRank(mediaInfo.Quality) != -1 {
+			qualityFilteredList = append(qualityFilteredList, *torrent)
 		} else {
-			otherTorrents = append(otherTorrents, torrent)
+			otherTorrents = append(otherTorrents, *torrent)
 		}
 	}
 
@@ -293,6 +451,65 @@ func FilterTorrentQuality(list []Torrent) []Torrent {
 	return append(qualityFilteredList, otherTorrents...)
 }
 
+// sizeRejectionReason returns the reason why torrent size is not acceptable for media with given runtime (in minutes). An empty string is returned if size is acceptable
+func sizeRejectionReason(torrent *Torrent, profile QualityProfile, runtime int) string {
+	if torrent.TotalSize <= 0 {
+		return ""
+	}
+
+	if !profile.SizeAllowed(torrent.TotalSize) {
+		return fmt.Sprintf("Size outside of quality profile limits (min: %d MB, max: %d MB)", profile.MinSize, profile.MaxSize)
+	}
+
+	mediaInfo, err := GetTorrentMediaInfo(torrent)
+	if err != nil || runtime <= 0 {
+		return ""
+	}
+	if mediaInfo.IsMultiEpisode() {
+		runtime *= len(mediaInfo.EpisodeNumbers())
+	}
+	limit, ok := configuration.Config.SizeLimits[mediaInfo.Quality]
+	if !ok {
+		return ""
+	}
+
+	sizeMB := float64(torrent.TotalSize) / (1024 * 1024)
+	if limit.Min > 0 && sizeMB < limit.Min*float64(runtime) {
+		return fmt.Sprintf("Size too small for %s quality (%.0f MB, minimum is %.0f MB for %d minutes)", mediaInfo.Quality, sizeMB, limit.Min*float64(runtime), runtime)
+	}
+	if limit.Max > 0 && sizeMB > limit.Max*float64(runtime) {
+		return fmt.Sprintf("Size too big for %s quality (%.0f MB, maximum is %.0f MB for %d minutes)", mediaInfo.Quality, sizeMB, limit.Max*float64(runtime), runtime)
+	}
+
+	return ""
+}
+
+// FilterTorrentSize removes torrents whose size is outside of profile size limits, or outside of size limits defined for torrent quality (in MB per minute of runtime).
+// Torrents with unknown size are kept
+func FilterTorrentSize(list []Torrent, profile QualityProfile, runtime int) []Torrent {
+	log.WithFields(log.Fields{
+		"quality_profile": profile.Name,
+		"runtime":         runtime,
+	}).Debug("Excluding torrents with size outside of size limits")
+
+	var returnList []Torrent
+	for i := range list {
+		torrent := &list[i]
+		if reason := sizeRejectionReason(torrent, profile, runtime); reason != "" {
+			log.WithFields(log.Fields{
+				"torrent": torrent.Name,
+				"size":    torrent.TotalSize,
+				"reason":  reason,
+			}).Info("Torrent rejected because of its size")
+			continue
+		}
+
+		returnList = append(returnList, *torrent)
+	}
+
+	return returnList
+}
+
 // GetIndexer returns the registered indexer with name "name". An non-nil error is returned if no registered indexer are found with the required name
 func GetIndexer(name string) (Indexer, error) {
 	for _, ind := range indexersCollection {
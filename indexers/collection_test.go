@@ -5,10 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jinzhu/gorm"
 	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/downloadable"
 	"github.com/macarrie/flemzerd/mocks"
 	. "github.com/macarrie/flemzerd/objects"
-	"github.com/macarrie/flemzerd/vidocq"
 )
 
 func init() {
@@ -115,22 +116,10 @@ func TestGetTorrentForEpisode(t *testing.T) {
 		return
 	}
 
-	//Get torrent when vidocq is not available
+	// Get torrent with strict checking enabled
 	episode.Season = 1
 	episode.Number = 1
 	episode.TvShow.IsAnime = false
-	vidocq.LocalVidocqAvailable = false
-	torrentList, _ = GetTorrents(&episode)
-	if len(torrentList) != 14 {
-		t.Errorf("Expected 14 torrents, got %d instead\n", len(torrentList))
-		return
-	}
-
-	// Get torrent when vidocq is available and strict checking enabled
-	episode.Season = 1
-	episode.Number = 1
-	episode.TvShow.IsAnime = false
-	vidocq.LocalVidocqAvailable = true
 	configuration.Config.System.StrictTorrentCheck = true
 	torrentList, _ = GetTorrents(&episode)
 	if len(torrentList) != 6 {
@@ -138,22 +127,9 @@ func TestGetTorrentForEpisode(t *testing.T) {
 		return
 	}
 
-	// Get torrent when vidocq is not available and strict checking enabled
-	episode.Season = 1
-	episode.Number = 1
-	episode.TvShow.IsAnime = false
-	vidocq.LocalVidocqAvailable = false
-	configuration.Config.System.StrictTorrentCheck = true
-	torrentList, _ = GetTorrents(&episode)
-	if len(torrentList) != 0 {
-		t.Errorf("Expected 0 torrents, got %d instead\n", len(torrentList))
-		return
-	}
-
 	//Get torrents with filters disabled
 	configuration.Config.System.PreferredMediaQuality = ""
 	configuration.Config.System.ExcludedReleaseTypes = ""
-	vidocq.LocalVidocqAvailable = true
 	configuration.Config.System.StrictTorrentCheck = false
 	torrentList, _ = GetTorrents(&episode)
 
@@ -172,7 +148,6 @@ func TestGetTorrentForMovie(t *testing.T) {
 	configuration.Config.System.PreferredMediaQuality = "720p,1080p"
 	configuration.Config.System.ExcludedReleaseTypes = "cam,screener,telesync,telecine"
 	configuration.Config.System.StrictTorrentCheck = false
-	vidocq.LocalVidocqAvailable = true
 
 	movieDate := time.Date(2018, time.January, 10, 13, 0, 0, 0, time.UTC)
 
@@ -209,55 +184,468 @@ func TestGetTorrentForMovie(t *testing.T) {
 		t.Error("Expected to have no torrents when getting torrents for movie")
 	}
 
-	//Get torrent when vidocq is not available
-	vidocq.LocalVidocqAvailable = false
+	// Get torrent with strict checking enabled
+	configuration.Config.System.StrictTorrentCheck = true
 	torrentList, _ = GetTorrents(&Movie{
 		Title:         "Test movie",
 		OriginalTitle: "Test movie",
 		Date:          movieDate,
 	})
-	if len(torrentList) != 14 {
-		t.Errorf("Expected 14 torrents when vidocq is not available, got %d instead\n", len(torrentList))
+	if len(torrentList) != 6 {
+		t.Errorf("Expected 6 torrents when strict checking is enabled, got %d instead\n", len(torrentList))
 	}
 
-	// Get torrent when vidocq is available and strict checking enabled
-	vidocq.LocalVidocqAvailable = true
-	configuration.Config.System.StrictTorrentCheck = true
+	//Get torrents with no filters
+	configuration.Config.System.PreferredMediaQuality = ""
+	configuration.Config.System.ExcludedReleaseTypes = ""
+	configuration.Config.System.StrictTorrentCheck = false
 	torrentList, _ = GetTorrents(&Movie{
 		Title:         "Test movie",
 		OriginalTitle: "Test movie",
 		Date:          movieDate,
 	})
-	if len(torrentList) != 6 {
-		t.Errorf("Expected 6 torrents when vidocq is available and strict checking enabled, got %d instead\n", len(torrentList))
+	if len(torrentList) != 12 {
+		t.Errorf("Expected 12 torrents, got %d instead\n", len(torrentList))
 	}
 
-	// Get torrent when vidocq is not available and strict checking enabled
-	vidocq.LocalVidocqAvailable = false
+}
+
+func TestGetTorrentsWithQualityProfile(t *testing.T) {
+	configuration.Config.System.PreferredMediaQuality = "720p"
+	configuration.Config.System.ExcludedReleaseTypes = ""
 	configuration.Config.System.StrictTorrentCheck = true
-	torrentList, _ = GetTorrents(&Movie{
-		Title:         "Test movie",
-		OriginalTitle: "Test movie",
-		Date:          movieDate,
+	indexersCollection = []Indexer{mock.TVIndexer{}}
+
+	episode := Episode{
+		Season: 1,
+		Number: 1,
+		TvShow: TvShow{
+			Title: "Test show",
+			QualityProfile: QualityProfile{
+				Model:     gorm.Model{ID: 1},
+				Name:      "HD",
+				Qualities: "1080p,720p",
+			},
+			QualityProfileID: 1,
+		},
+	}
+
+	torrentList, _ := GetTorrents(&episode)
+	if len(torrentList) != 3 {
+		t.Errorf("Expected 3 torrents matching show quality profile, got %d instead\n", len(torrentList))
+		return
+	}
+	if torrentList[0].MediaInfo.Quality != "1080p" {
+		t.Errorf("Expected preferred quality of profile to be first in list, got '%s' instead", torrentList[0].MediaInfo.Quality)
+	}
+	if torrentList[1].Seeders != 3 {
+		t.Error("Expected torrents of same quality to be sorted by seeders")
+	}
+
+	// Without profile, global configuration is used
+	episode.TvShow.QualityProfileID = 0
+	episode.TvShow.QualityProfile = QualityProfile{}
+	torrentList, _ = GetTorrents(&episode)
+	if len(torrentList) != 2 {
+		t.Errorf("Expected 2 torrents matching configuration quality, got %d instead\n", len(torrentList))
+	}
+}
+
+func TestScoreTorrents(t *testing.T) {
+	configuration.Config.System.PreferredReleaseGroups = "GOOD"
+	configuration.Config.System.BlockedReleaseGroups = "BAD"
+	movie := Movie{
+		QualityProfile: QualityProfile{
+			Model:           gorm.Model{ID: 1},
+			Qualities:       "1080p,720p",
+			PreferredCodecs: "h265,h264",
+		},
+		QualityProfileID: 1,
+	}
+
+	list := []Torrent{
+		Torrent{Name: "Movie.2018.720p.x264", Seeders: 100},
+		Torrent{Name: "Movie.2018.1080p.x264", Seeders: 10},
+		Torrent{Name: "Movie.2018.1080p.XviD", Seeders: 10},
+		Torrent{Name: "Movie.2018.1080p.x265-BAD", Seeders: 10},
+		Torrent{Name: "Movie.2018.1080p.x265", Seeders: 10},
+		Torrent{Name: "Movie.2018.1080p.x265-GOOD", Seeders: 1},
+	}
+	ScoreTorrents(&movie, list)
+
+	expected := []string{
+		"Movie.2018.1080p.x265-GOOD",
+		"Movie.2018.1080p.x265",
+		"Movie.2018.1080p.x264",
+		"Movie.2018.1080p.XviD",
+		"Movie.2018.720p.x264",
+		"Movie.2018.1080p.x265-BAD",
+	}
+	for i, name := range expected {
+		if list[i].Name != name {
+			t.Errorf("Expected torrent %d to be '%s', got '%s' instead", i, name, list[i].Name)
+		}
+	}
+
+	if len(list[0].ScoreBreakdown) != len(scoringFactors) {
+		t.Errorf("Expected score breakdown to contain %d factors, got %d instead", len(scoringFactors), len(list[0].ScoreBreakdown))
+	}
+	total := 0
+	for _, score := range list[0].ScoreBreakdown {
+		total += score.Score
+	}
+	if total != list[0].Score {
+		t.Errorf("Expected torrent score (%d) to be the sum of score breakdown (%d)", list[0].Score, total)
+	}
+
+	configuration.Config.System.PreferredReleaseGroups = ""
+	configuration.Config.System.BlockedReleaseGroups = ""
+
+	usenetRelease := Torrent{Name: "Movie.2018.1080p.x264", Protocol: PROTOCOL_USENET}
+	wellSeededTorrent := Torrent{Name: "Movie.2018.1080p.x264", Seeders: USENET_EQUIVALENT_SEEDERS}
+	factor := SeedersFactor{}
+	if factor.Score(usenetRelease, &movie, movie.QualityProfile) != factor.Score(wellSeededTorrent, &movie, movie.QualityProfile) {
+		t.Error("Expected usenet releases to be scored as well seeded torrents")
+	}
+}
+
+type testScoringFactor struct{}
+
+func (f testScoringFactor) GetName() string {
+	return "test"
+}
+
+func (f testScoringFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	if torrent.Name == "favorite" {
+		return 10000
+	}
+	return 0
+}
+
+func TestAddScoringFactor(t *testing.T) {
+	AddScoringFactor(testScoringFactor{})
+	defer ResetScoringFactors()
+
+	list := []Torrent{
+		Torrent{Name: "Movie.2018.720p", Seeders: 100},
+		Torrent{Name: "favorite"},
+	}
+	ScoreTorrents(&Movie{}, list)
+	if list[0].Name != "favorite" {
+		t.Error("Expected custom scoring factor to be used when sorting torrents")
+	}
+}
+
+func TestGetIndexerPriority(t *testing.T) {
+	configuration.Config.Indexers = map[string][]map[string]interface{}{
+		"torznab": []map[string]interface{}{
+			map[string]interface{}{"name": "first", "priority": int64(10)},
+			map[string]interface{}{"name": "second"},
+		},
+	}
+
+	if priority := GetIndexerPriority("first"); priority != 10 {
+		t.Errorf("Expected indexer priority to be 10, got %d instead", priority)
+	}
+	if priority := GetIndexerPriority("second"); priority != 0 {
+		t.Errorf("Expected indexer priority to be 0 when not defined, got %d instead", priority)
+	}
+	if priority := GetIndexerPriority("unknown"); priority != 0 {
+		t.Errorf("Expected indexer priority to be 0 for unknown indexer, got %d instead", priority)
+	}
+}
+
+func TestFilterTorrentSize(t *testing.T) {
+	configuration.Config.SizeLimits = map[string]configuration.SizeLimit{}
+	profile := QualityProfile{
+		MinSize: 100,
+		MaxSize: 1000,
+	}
+
+	list := FilterTorrentSize([]Torrent{
+		Torrent{Name: "too_small", TotalSize: 10 * 1024 * 1024},
+		Torrent{Name: "ok", TotalSize: 500 * 1024 * 1024},
+		Torrent{Name: "too_big", TotalSize: 2000 * 1024 * 1024},
+		Torrent{Name: "unknown"},
+	}, profile, 45)
+
+	if len(list) != 2 {
+		t.Errorf("Expected 2 torrents within size limits, got %d instead", len(list))
+	}
+	for _, torrent := range list {
+		if torrent.Name == "too_small" || torrent.Name == "too_big" {
+			t.Errorf("Expected torrent '%s' to be excluded by size limits", torrent.Name)
+		}
+	}
+}
+
+func TestFilterTorrentSizePerQuality(t *testing.T) {
+	configuration.Config.SizeLimits = map[string]configuration.SizeLimit{
+		"720p": configuration.SizeLimit{Min: 2, Max: 60},
+	}
+	defer func() {
+		configuration.Config.SizeLimits = map[string]configuration.SizeLimit{}
+	}()
+
+	torrents := []Torrent{
+		Torrent{Name: "Show.S01E01.720p.fake", TotalSize: 80 * 1024 * 1024},
+		Torrent{Name: "Show.S01E01.720p.ok", TotalSize: 1200 * 1024 * 1024},
+		Torrent{Name: "Show.S01E01.720p.remux", TotalSize: 60 * 1024 * 1024 * 1024},
+		Torrent{Name: "Show.S01E01.1080p.no_limit", TotalSize: 60 * 1024 * 1024 * 1024},
+	}
+
+	list := FilterTorrentSize(torrents, QualityProfile{}, 45)
+	if len(list) != 2 {
+		t.Errorf("Expected 2 torrents within size limits for 45 minutes runtime, got %d instead", len(list))
+	}
+
+	// 80 MB is a reasonable size for a short episode
+	list = FilterTorrentSize(torrents, QualityProfile{}, 30)
+	if len(list) != 3 || list[0].Name != "Show.S01E01.720p.fake" {
+		t.Errorf("Expected size limits to depend on runtime, got %d torrents instead", len(list))
+	}
+
+	if runtime := GetExpectedRuntime(&Episode{TvShow: TvShow{Runtime: 22}}); runtime != 22 {
+		t.Errorf("Expected show runtime to be used for episodes, got %d instead", runtime)
+	}
+	if runtime := GetExpectedRuntime(&Movie{}); runtime != MOVIE_EXPECTED_RUNTIME {
+		t.Errorf("Expected default movie runtime when runtime is unknown, got %d instead", runtime)
+	}
+}
+
+func TestFilterTorrentReleaseRules(t *testing.T) {
+	list := []Torrent{
+		Torrent{Name: "Movie.2018.1080p.WEB-DL.x264-GOOD"},
+		Torrent{Name: "Movie.2018.1080p.BluRay.x264-BAD"},
+		Torrent{Name: "Movie.2018.1080p.HDTV.x264.HARDSUB-OTHER"},
+		Torrent{Name: "Movie.2018.720p.BluRay.x264-OTHER"},
+		Torrent{Name: "Movie.2018.720p.BluRay.x264"},
+	}
+
+	testData := []struct {
+		Filters  ReleaseFilters
+		Expected int
+	}{
+		{ReleaseFilters{}, 5},
+		{ReleaseFilters{BlockedReleaseGroups: "bad"}, 4},
+		{ReleaseFilters{AllowedReleaseGroups: "GOOD, OTHER"}, 3},
+		{ReleaseFilters{IgnoredWords: "hardsub"}, 4},
+		{ReleaseFilters{IgnoredWords: "/^movie\\.2018\\.720p/"}, 3},
+		{ReleaseFilters{RequiredWords: "bluray,web-dl"}, 4},
+		{ReleaseFilters{RequiredWords: "bluray", BlockedReleaseGroups: "BAD"}, 2},
+	}
+
+	for _, data := range testData {
+		result := FilterTorrentReleaseRules(list, []ReleaseFilters{data.Filters})
+		if len(result) != data.Expected {
+			t.Errorf("Expected %d torrents with filters %+v, got %d instead", data.Expected, data.Filters, len(result))
+		}
+	}
+
+	// Global and item filters are both applied
+	result := FilterTorrentReleaseRules(list, []ReleaseFilters{
+		ReleaseFilters{BlockedReleaseGroups: "BAD"},
+		ReleaseFilters{IgnoredWords: "720p"},
 	})
-	if len(torrentList) != 0 {
-		t.Errorf("Expected 0 torrents when vidocq is not available, got %d instead\n", len(torrentList))
+	if len(result) != 2 {
+		t.Errorf("Expected 2 torrents when combining global and item filters, got %d instead", len(result))
 	}
+}
 
-	//Get torrents with no filters
+func TestGetReleaseFilters(t *testing.T) {
+	configuration.Config.System.IgnoredWords = "hardsub"
+	defer func() {
+		configuration.Config.System.IgnoredWords = ""
+	}()
+
+	filters := GetReleaseFilters(&Episode{
+		TvShow: TvShow{
+			ReleaseFilters: ReleaseFilters{
+				RequiredWords: "french",
+			},
+		},
+	})
+	if len(filters) != 2 {
+		t.Errorf("Expected global and show filters, got %d filters instead", len(filters))
+		return
+	}
+	if filters[0].IgnoredWords != "hardsub" || filters[1].RequiredWords != "french" {
+		t.Errorf("Expected global filters first and show filters then, got %+v instead", filters)
+	}
+}
+
+func TestGetTorrentMediaInfo(t *testing.T) {
+	torrent := Torrent{
+		Name: "Test.Show.S01E02.720p.HDTV.x264-GROUP",
+	}
+
+	info, err := GetTorrentMediaInfo(&torrent)
+	if err != nil {
+		t.Errorf("Expected no error when parsing torrent name, got %s instead", err.Error())
+	}
+	if info.Quality != "720p" || torrent.MediaInfo.Quality != "720p" {
+		t.Errorf("Expected parsed quality to be stored in torrent, got '%s' instead", torrent.MediaInfo.Quality)
+	}
+
+	// Parsed info must be reused instead of parsing torrent name again
+	torrent.MediaInfo.Quality = "cached"
+	info, _ = GetTorrentMediaInfo(&torrent)
+	if info.Quality != "cached" {
+		t.Error("Expected cached media info to be returned instead of parsing torrent name again")
+	}
+
+	// Name changed since last parsing: info must be computed again
+	torrent.Name = "Test.Show.S01E02.1080p.HDTV.x264-GROUP"
+	info, _ = GetTorrentMediaInfo(&torrent)
+	if info.Quality != "1080p" {
+		t.Errorf("Expected media info to be parsed again when torrent name changes, got quality '%s' instead", info.Quality)
+	}
+
+	if _, err := GetTorrentMediaInfo(&Torrent{}); err == nil {
+		t.Error("Expected to have an error when parsing torrent with empty name")
+	}
+}
+
+func TestGetTorrentsParsesMediaInfo(t *testing.T) {
 	configuration.Config.System.PreferredMediaQuality = ""
 	configuration.Config.System.ExcludedReleaseTypes = ""
-	vidocq.LocalVidocqAvailable = true
 	configuration.Config.System.StrictTorrentCheck = false
-	torrentList, _ = GetTorrents(&Movie{
-		Title:         "Test movie",
-		OriginalTitle: "Test movie",
-		Date:          movieDate,
+	indexersCollection = []Indexer{mock.TVIndexer{}}
+
+	torrentList, _ := GetTorrents(&Episode{
+		Season: 1,
+		Number: 1,
+		TvShow: TvShow{
+			Title: "Test show",
+		},
 	})
-	if len(torrentList) != 12 {
-		t.Errorf("Expected 12 torrents, got %d instead\n", len(torrentList))
+	for _, torrent := range torrentList {
+		if torrent.MediaInfo.Raw != torrent.Name {
+			t.Errorf("Expected media info to be parsed and stored for torrent '%s'", torrent.Name)
+		}
+		if torrent.MediaInfo.Season != 1 || torrent.MediaInfo.Episode != 1 {
+			t.Errorf("Expected parsed season and episode to be 1x01, got %dx%02d instead", torrent.MediaInfo.Season, torrent.MediaInfo.Episode)
+		}
+	}
+}
+
+func TestManualSearch(t *testing.T) {
+	configuration.Config.System.PreferredMediaQuality = "720p"
+	configuration.Config.System.ExcludedReleaseTypes = "cam"
+	configuration.Config.System.StrictTorrentCheck = false
+	indexersCollection = []Indexer{mock.TVIndexer{}}
+
+	results, err := ManualSearch(&Episode{
+		Season: 1,
+		Number: 1,
+		TvShow: TvShow{
+			Title: "Test show",
+		},
+	})
+	if err != nil {
+		t.Error("Expected manual search to succeed, got error: ", err)
+	}
+	if len(results) != 7 {
+		t.Errorf("Expected rejected torrents to be returned by manual search, got %d results instead of 7", len(results))
+		return
 	}
 
+	accepted := 0
+	for _, result := range results {
+		if result.Accepted {
+			accepted++
+		}
+		if len(result.Verdicts) != len(GetEpisodeFilters(Episode{})) {
+			t.Errorf("Expected a verdict for each filter for torrent '%s', got %d", result.Torrent.Name, len(result.Verdicts))
+		}
+
+		switch result.Torrent.Name {
+		case "Torrent4.s01e01.cam":
+			if result.Accepted {
+				t.Error("Expected cam torrent to be rejected")
+			}
+			for _, verdict := range result.Verdicts {
+				if verdict.Filter == "release_type" && (verdict.Accepted || verdict.Reason == "") {
+					t.Error("Expected cam torrent to be rejected by release type filter with a reason")
+				}
+			}
+		case "Torrent4.s02e02.480p":
+			if result.Accepted {
+				t.Error("Expected torrent for another episode to be rejected")
+			}
+		}
+	}
+
+	if accepted != 3 {
+		t.Errorf("Expected 3 accepted torrents, got %d instead", accepted)
+	}
+}
+
+func TestGetSeasonPackTorrents(t *testing.T) {
+	configuration.Config.System.PreferredMediaQuality = ""
+	configuration.Config.System.ExcludedReleaseTypes = ""
+	configuration.Config.System.StrictTorrentCheck = false
+
+	episode := Episode{
+		Season: 1,
+		Number: 1,
+		TvShow: TvShow{
+			Title: "Test show",
+		},
+	}
+
+	indexersCollection = []Indexer{mock.MovieIndexer{}}
+	if _, err := GetSeasonPackTorrents(episode, 10); err == nil {
+		t.Error("Expected an error when no indexer supports season search")
+	}
+
+	indexersCollection = []Indexer{mock.ErrorTVIndexer{}}
+	if _, err := GetSeasonPackTorrents(episode, 10); err == nil {
+		t.Error("Expected an error when all indexers fail")
+	}
+
+	indexersCollection = []Indexer{mock.TVIndexer{}, mock.MovieIndexer{}}
+	torrentList, err := GetSeasonPackTorrents(episode, 10)
+	if err != nil {
+		t.Error("Expected season pack search to succeed, got error: ", err)
+	}
+	if len(torrentList) != 2 {
+		t.Errorf("Expected 2 season packs for season 1, got %d instead", len(torrentList))
+		return
+	}
+	for _, torrent := range torrentList {
+		if !torrent.SeasonPack {
+			t.Errorf("Expected torrent '%s' to be marked as season pack", torrent.Name)
+		}
+		if torrent.MediaInfo.Season != 1 || torrent.MediaInfo.Episode != 0 {
+			t.Errorf("Expected only full season 1 torrents, got '%s'", torrent.Name)
+		}
+	}
+}
+
+func TestFilterTorrentEpisodeNumberMultiEpisode(t *testing.T) {
+	configuration.Config.System.StrictTorrentCheck = true
+
+	torrentList := []Torrent{
+		Torrent{Name: "Test.Show.S02E05E06.720p"},
+		Torrent{Name: "Test.Show.S02E04-07.720p"},
+		Torrent{Name: "Test.Show.S02E07E08.720p"},
+		Torrent{Name: "Test.Show.S01E05E06.720p"},
+	}
+
+	filteredList := FilterTorrentEpisodeNumber(torrentList, Episode{Season: 2, Number: 6})
+	if len(filteredList) != 2 {
+		t.Errorf("Expected 2 multi-episode torrents containing episode S02E06, got %d instead", len(filteredList))
+	}
+
+	torrent := Torrent{Name: "Test.Show.S02E05E06.720p", TotalSize: 600 * 1024 * 1024}
+	configuration.Config.SizeLimits = map[string]configuration.SizeLimit{
+		"720p": configuration.SizeLimit{Min: 1, Max: 10},
+	}
+	if reason := sizeRejectionReason(&torrent, QualityProfile{}, 45); reason != "" {
+		t.Errorf("Expected size limits to take all episodes of multi-episode torrents into account, got rejection: %s", reason)
+	}
+	configuration.Config.SizeLimits = nil
 }
 
 func TestGetSpecificIndexer(t *testing.T) {
@@ -0,0 +1,38 @@
+package newznab
+
+import (
+	"github.com/macarrie/flemzerd/downloadable"
+	"github.com/macarrie/flemzerd/indexers/impl/torznab"
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// NewznabIndexer searches usenet releases. Torznab is an extension of the newznab API, so search and capabilities requests are shared with the torznab indexer
+type NewznabIndexer struct {
+	torznab.TorznabIndexer
+}
+
+func New(name string, url string, apikey string) NewznabIndexer {
+	return NewznabIndexer{
+		TorznabIndexer: torznab.New(name, url, apikey),
+	}
+}
+
+// markUsenetReleases sets usenet protocol on search results. Newznab results do not have seeders
+func markUsenetReleases(releases []Torrent, err error) ([]Torrent, error) {
+	for i := range releases {
+		releases[i].Protocol = PROTOCOL_USENET
+		releases[i].Seeders = 0
+		releases[i].Freeleech = false
+	}
+
+	return releases, err
+}
+
+func (newznabIndexer NewznabIndexer) GetTorrents(d downloadable.Downloadable) ([]Torrent, error) {
+	return markUsenetReleases(newznabIndexer.TorznabIndexer.GetTorrents(d))
+}
+
+// GetSeasonTorrents searches usenet releases for a full season of show
+func (newznabIndexer NewznabIndexer) GetSeasonTorrents(show TvShow, season int) ([]Torrent, error) {
+	return markUsenetReleases(newznabIndexer.TorznabIndexer.GetSeasonTorrents(show, season))
+}
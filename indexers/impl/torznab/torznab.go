@@ -131,6 +131,7 @@ type TorznabTorrent struct {
 	Link        string `xml:"link"`
 	Category    string `xml:"category"`
 	PubDate     string `xml:"pubDate"`
+	Size        int64  `xml:"size"`
 	Attr        []struct {
 		Name  string `xml:"name,attr"`
 		Value string `xml:"value,attr"`
@@ -143,6 +144,8 @@ func convertTorrent(t TorznabTorrent) Torrent {
 		TorrentId: id.String(),
 		Name:      t.Title,
 		Link:      t.Link,
+		TotalSize: t.Size,
+		Protocol:  PROTOCOL_TORRENT,
 	}
 }
 
@@ -209,20 +212,6 @@ func (torznabIndexer TorznabIndexer) GetTorrents(d downloadable.Downloadable) ([
 		}).Info("Torznab indexer does not support torrent search for this item. Search results may be less precise for this indexer")
 	}
 
-	baseURL := torznabIndexer.Url
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(HTTP_TIMEOUT * time.Second),
-	}
-
-	urlObject, _ := url.ParseRequestURI(baseURL)
-
-	var request *http.Request
-
 	params := url.Values{}
 	params.Add("apikey", torznabIndexer.ApiKey)
 	switch d.(type) {
@@ -249,6 +238,37 @@ func (torznabIndexer TorznabIndexer) GetTorrents(d downloadable.Downloadable) ([
 		return []Torrent{}, errors.New("Unknown downloadable type")
 	}
 
+	return torznabIndexer.search(params)
+}
+
+// GetSeasonTorrents searches torrents for a full season of show (tvsearch with season parameter only)
+func (torznabIndexer TorznabIndexer) GetSeasonTorrents(show TvShow, season int) ([]Torrent, error) {
+	if !torznabIndexer.Caps.Searching.TVSearch.Available || torznabIndexer.Caps.Searching.TVSearch.SupportedParams.SeasonParam == "" {
+		return []Torrent{}, errors.New("Torznab indexer does not support season search")
+	}
+
+	params := url.Values{}
+	params.Add("apikey", torznabIndexer.ApiKey)
+	params.Add("t", "tvsearch")
+	params.Add("q", show.GetTitle())
+	params.Add(torznabIndexer.Caps.Searching.TVSearch.SupportedParams.SeasonParam, strconv.Itoa(season))
+
+	return torznabIndexer.search(params)
+}
+
+func (torznabIndexer TorznabIndexer) search(params url.Values) ([]Torrent, error) {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	httpClient := &http.Client{
+		Transport: tr,
+		Timeout:   time.Duration(HTTP_TIMEOUT * time.Second),
+	}
+
+	urlObject, err := url.ParseRequestURI(torznabIndexer.Url)
+	if err != nil {
+		return []Torrent{}, errors.Wrap(err, "invalid torznab indexer URL")
+	}
 	urlObject.RawQuery = params.Encode()
 
 	request, err := http.NewRequest("GET", urlObject.String(), nil)
@@ -292,7 +312,7 @@ func (torznabIndexer TorznabIndexer) GetTorrents(d downloadable.Downloadable) ([
 		return []Torrent{}, TorznabError{Code: code, Description: desc}
 	}
 
-	// Get seeders count for each torrent
+	// Get seeders count, size and freeleech status for each torrent
 	var results []Torrent
 	for _, torrent := range searchResults.Torrents {
 		resultTorrent := convertTorrent(torrent)
@@ -302,6 +322,13 @@ func (torznabIndexer TorznabIndexer) GetTorrents(d downloadable.Downloadable) ([
 				seedersNb, _ := strconv.Atoi(attr.Value)
 				resultTorrent.Seeders = seedersNb
 			}
+			if attr.Name == "downloadvolumefactor" && attr.Value == "0" {
+				resultTorrent.Freeleech = true
+			}
+			if attr.Name == "size" && resultTorrent.TotalSize == 0 {
+				size, _ := strconv.ParseInt(attr.Value, 10, 64)
+				resultTorrent.TotalSize = size
+			}
 		}
 
 		results = append(results, resultTorrent)
@@ -11,3 +11,8 @@ type Indexer interface {
 	CheckCapabilities(d downloadable.Downloadable) bool
 	GetTorrents(d downloadable.Downloadable) ([]Torrent, error)
 }
+
+// SeasonPackIndexer is implemented by indexers able to search torrents containing a full season of a show
+type SeasonPackIndexer interface {
+	GetSeasonTorrents(show TvShow, season int) ([]Torrent, error)
+}
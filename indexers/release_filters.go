@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// GetReleaseFilters returns release filters to apply on torrents for downloadable: global filters defined in configuration, and filters defined on the movie (or the show of the episode)
+func GetReleaseFilters(d downloadable.Downloadable) []ReleaseFilters {
+	filters := []ReleaseFilters{
+		ReleaseFilters{
+			RequiredWords:        configuration.Config.System.RequiredWords,
+			IgnoredWords:         configuration.Config.System.IgnoredWords,
+			AllowedReleaseGroups: configuration.Config.System.AllowedReleaseGroups,
+			BlockedReleaseGroups: configuration.Config.System.BlockedReleaseGroups,
+		},
+	}
+
+	switch d.(type) {
+	case *Movie:
+		filters = append(filters, d.(*Movie).ReleaseFilters)
+	case *Episode:
+		filters = append(filters, d.(*Episode).TvShow.ReleaseFilters)
+	}
+
+	return filters
+}
+
+// rejectionReason checks torrent against release filters and returns the rule rejecting the torrent, and the reason. An empty rule is returned if torrent is accepted
+func rejectionReason(torrent *Torrent, filters ReleaseFilters) (rule string, reason string) {
+	if filters.RequiredWords != "" {
+		if _, found := MatchingTerm(filters.RequiredWords, torrent.Name); !found {
+			return "required_words", "Torrent name does not contain any required word (" + filters.RequiredWords + ")"
+		}
+	}
+
+	if term, found := MatchingTerm(filters.IgnoredWords, torrent.Name); found {
+		return "ignored_words", "Torrent name contains ignored word " + term
+	}
+
+	if filters.AllowedReleaseGroups == "" && filters.BlockedReleaseGroups == "" {
+		return "", ""
+	}
+
+	mediaInfo, _ := GetTorrentMediaInfo(torrent)
+	if ContainsReleaseGroup(filters.BlockedReleaseGroups, mediaInfo.ReleaseGroup) {
+		return "blocked_release_groups", "Release group " + mediaInfo.ReleaseGroup + " is blocked"
+	}
+
+	if filters.AllowedReleaseGroups != "" && !ContainsReleaseGroup(filters.AllowedReleaseGroups, mediaInfo.ReleaseGroup) {
+		if mediaInfo.ReleaseGroup == "" {
+			return "allowed_release_groups", "Release group could not be detected and only allowed release groups are accepted"
+		}
+		return "allowed_release_groups", "Release group " + mediaInfo.ReleaseGroup + " is not in allowed release groups"
+	}
+
+	return "", ""
+}
+
+// releaseRulesRejectionReason returns the reason why torrent is rejected by one of the release filters. An empty string is returned if torrent is accepted
+func releaseRulesRejectionReason(torrent *Torrent, filtersList []ReleaseFilters) string {
+	for _, filters := range filtersList {
+		if filters.IsEmpty() {
+			continue
+		}
+
+		if rule, reason := rejectionReason(torrent, filters); rule != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+// FilterTorrentReleaseRules removes torrents rejected by one of the release filters (required and ignored words, allowed and blocked release groups)
+func FilterTorrentReleaseRules(list []Torrent, filtersList []ReleaseFilters) []Torrent {
+	var returnList []Torrent
+
+	for i := range list {
+		torrent := &list[i]
+		rejected := false
+
+		for _, filters := range filtersList {
+			if filters.IsEmpty() {
+				continue
+			}
+
+			if rule, reason := rejectionReason(torrent, filters); rule != "" {
+				log.WithFields(log.Fields{
+					"torrent": torrent.Name,
+					"rule":    rule,
+					"reason":  reason,
+				}).Debug("Torrent rejected by release filters")
+				rejected = true
+				break
+			}
+		}
+
+		if !rejected {
+			returnList = append(returnList, *torrent)
+		}
+	}
+
+	return returnList
+}
@@ -0,0 +1,240 @@
+package indexer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/downloadable"
+	log "github.com/macarrie/flemzerd/logging"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// ScoringFactor computes a part of the score of a torrent. Torrents are sorted by the sum of the scores of all registered factors
+type ScoringFactor interface {
+	GetName() string
+	Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int
+}
+
+// Expected size (in MB per minute of runtime) for each quality
+var expectedSizeRates = map[string]float64{
+	"480p":  4,
+	"576p":  5,
+	"720p":  10,
+	"900p":  14,
+	"1080p": 20,
+	"1440p": 35,
+	"2160p": 60,
+}
+
+// Number of seeders used to score usenet releases
+const USENET_EQUIVALENT_SEEDERS = 50
+
+var scoringFactors []ScoringFactor
+
+func init() {
+	ResetScoringFactors()
+}
+
+// AddScoringFactor registers a new scoring factor used when computing torrent scores
+func AddScoringFactor(factor ScoringFactor) {
+	scoringFactors = append(scoringFactors, factor)
+	log.WithFields(log.Fields{
+		"factor": factor.GetName(),
+	}).Debug("Scoring factor loaded")
+}
+
+// ResetScoringFactors restores default scoring factors
+func ResetScoringFactors() {
+	scoringFactors = []ScoringFactor{
+		SeedersFactor{},
+		SizeFactor{},
+		QualityFactor{},
+		CodecFactor{},
+		ReleaseGroupFactor{},
+		FreeleechFactor{},
+		IndexerPriorityFactor{},
+	}
+}
+
+// ScoreTorrents computes score and score breakdown of each torrent in list for downloadable d, and sorts list by score (best torrent first).
+// Torrents with the same score keep their relative order
+func ScoreTorrents(d downloadable.Downloadable, list []Torrent) {
+	profile := GetQualityProfile(d)
+
+	for i := range list {
+		torrent := &list[i]
+		_, _ = GetTorrentMediaInfo(torrent)
+
+		torrent.Score = 0
+		torrent.ScoreBreakdown = []TorrentScore{}
+		for _, factor := range scoringFactors {
+			score := factor.Score(*torrent, d, profile)
+			torrent.Score += score
+			torrent.ScoreBreakdown = append(torrent.ScoreBreakdown, TorrentScore{
+				Factor: factor.GetName(),
+				Score:  score,
+			})
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Score > list[j].Score
+	})
+}
+
+// SeedersFactor favors torrents with many seeders. Score grows logarithmically to avoid torrents with lots of seeders to always win
+type SeedersFactor struct{}
+
+func (f SeedersFactor) GetName() string {
+	return "seeders"
+}
+
+func (f SeedersFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	// Usenet releases have no seeders but do not depend on peers availability: they are scored as well seeded torrents
+	if torrent.GetProtocol() == PROTOCOL_USENET {
+		return int(10 * math.Log2(float64(USENET_EQUIVALENT_SEEDERS+1)))
+	}
+
+	if torrent.Seeders <= 0 {
+		return 0
+	}
+
+	return int(10 * math.Log2(float64(torrent.Seeders+1)))
+}
+
+// SizeFactor favors torrents whose size is consistent with the expected runtime of the media and the torrent quality
+type SizeFactor struct{}
+
+func (f SizeFactor) GetName() string {
+	return "size"
+}
+
+func (f SizeFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	rate, ok := expectedSizeRates[torrent.MediaInfo.Quality]
+	if torrent.TotalSize <= 0 || !ok {
+		return 0
+	}
+
+	runtime := GetExpectedRuntime(d)
+	if torrent.MediaInfo.IsMultiEpisode() {
+		runtime *= len(torrent.MediaInfo.EpisodeNumbers())
+	}
+
+	ratio := float64(torrent.TotalSize) / (1024 * 1024) / (rate * float64(runtime))
+	switch {
+	case ratio >= 0.5 && ratio <= 2:
+		return 20
+	case ratio < 0.25 || ratio > 4:
+		return -20
+	default:
+		return 0
+	}
+}
+
+// QualityFactor favors qualities listed first in quality profile. Quality has the biggest weight in torrent score
+type QualityFactor struct{}
+
+func (f QualityFactor) GetName() string {
+	return "quality"
+}
+
+func (f QualityFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	rank := profile.QualityRank(torrent.MediaInfo.Quality)
+	if rank == -1 {
+		return 0
+	}
+
+	return (len(profile.GetQualities()) - rank) * 100
+}
+
+// CodecFactor favors codecs listed first in preferred codecs of quality profile
+type CodecFactor struct{}
+
+func (f CodecFactor) GetName() string {
+	return "codec"
+}
+
+func (f CodecFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	rank := profile.CodecRank(torrent.MediaInfo.VideoCodec)
+	if rank == -1 {
+		return 0
+	}
+
+	return (len(profile.GetPreferredCodecs()) - rank) * 20
+}
+
+// ReleaseGroupFactor favors preferred release groups and penalizes blocked release groups
+type ReleaseGroupFactor struct{}
+
+func (f ReleaseGroupFactor) GetName() string {
+	return "release_group"
+}
+
+func (f ReleaseGroupFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	group := torrent.MediaInfo.ReleaseGroup
+	if group == "" {
+		return 0
+	}
+
+	if ContainsReleaseGroup(configuration.Config.System.BlockedReleaseGroups, group) {
+		return -1000
+	}
+	if ContainsReleaseGroup(configuration.Config.System.PreferredReleaseGroups, group) {
+		return 50
+	}
+
+	return 0
+}
+
+// FreeleechFactor favors freeleech torrents, that do not count in ratio on private trackers
+type FreeleechFactor struct{}
+
+func (f FreeleechFactor) GetName() string {
+	return "freeleech"
+}
+
+func (f FreeleechFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	if torrent.Freeleech {
+		return 25
+	}
+
+	return 0
+}
+
+// IndexerPriorityFactor adds the priority defined in configuration for the indexer the torrent has been retrieved from
+type IndexerPriorityFactor struct{}
+
+func (f IndexerPriorityFactor) GetName() string {
+	return "indexer_priority"
+}
+
+func (f IndexerPriorityFactor) Score(torrent Torrent, d downloadable.Downloadable, profile QualityProfile) int {
+	return GetIndexerPriority(torrent.Indexer)
+}
+
+// GetIndexerPriority returns the priority defined in configuration for indexer with name "name". 0 is returned if indexer has no priority defined
+func GetIndexerPriority(name string) int {
+	if name == "" {
+		return 0
+	}
+
+	for _, indexerList := range configuration.Config.Indexers {
+		for _, indexer := range indexerList {
+			if indexer["name"] != name {
+				continue
+			}
+
+			priority, err := strconv.Atoi(fmt.Sprintf("%v", indexer["priority"]))
+			if err != nil {
+				return 0
+			}
+			return priority
+		}
+	}
+
+	return 0
+}
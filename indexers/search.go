@@ -0,0 +1,64 @@
+package indexer
+
+import (
+	"github.com/macarrie/flemzerd/downloadable"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// FilterVerdict is the result of a filter of the filtering chain for a torrent
+type FilterVerdict struct {
+	Filter   string
+	Accepted bool
+	Reason   string
+}
+
+// SearchResult is a torrent found during a manual search, with the verdict of each filter. Torrent is accepted only if all filters accepted it
+type SearchResult struct {
+	Torrent  Torrent
+	Accepted bool
+	Verdicts []FilterVerdict
+}
+
+// ManualSearch searches torrents for downloadable in all indexers and returns all results, sorted by score, including torrents rejected by filters.
+// Each filter is evaluated independently on each torrent so that all rejection reasons are known
+func ManualSearch(d downloadable.Downloadable) ([]SearchResult, error) {
+	torrentList, err := SearchTorrents(d)
+	filters := GetFilters(d)
+
+	results := []SearchResult{}
+	for _, torrent := range torrentList {
+		results = append(results, evaluateTorrent(torrent, filters))
+	}
+
+	return results, err
+}
+
+func evaluateTorrent(torrent Torrent, filters []TorrentFilter) SearchResult {
+	result := SearchResult{
+		Torrent:  torrent,
+		Accepted: true,
+		Verdicts: []FilterVerdict{},
+	}
+
+	for _, filter := range filters {
+		verdict := FilterVerdict{
+			Filter:   filter.Name,
+			Accepted: len(filter.Filter([]Torrent{torrent})) != 0,
+		}
+
+		if !verdict.Accepted {
+			result.Accepted = false
+			verdict.Reason = "Rejected by " + filter.Name + " filter"
+			if filter.Reason != nil {
+				if reason := filter.Reason(&torrent); reason != "" {
+					verdict.Reason = reason
+				}
+			}
+		}
+
+		result.Verdicts = append(result.Verdicts, verdict)
+	}
+
+	return result
+}
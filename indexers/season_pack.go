@@ -0,0 +1,129 @@
+package indexer
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	log "github.com/macarrie/flemzerd/logging"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// GetSeasonPackTorrents searches torrents containing the full season of episode in indexers supporting season search.
+// Episode is used to get the quality profile and release filters of the show, and episodeCount (number of episodes in season) to compute size limits of season packs.
+// Returned torrents are filtered, sorted by score and marked as season packs. A non nil error is returned if no indexer could perform the search
+func GetSeasonPackTorrents(episode Episode, episodeCount int) ([]Torrent, error) {
+	var torrentList []Torrent
+	var errorList *multierror.Error
+	var totalError bool = true
+
+	for _, indexer := range indexersCollection {
+		seasonIndexer, ok := indexer.(SeasonPackIndexer)
+		if !ok {
+			continue
+		}
+
+		indexerSearch, err := seasonIndexer.GetSeasonTorrents(episode.TvShow, episode.Season)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"indexer": indexer.GetName(),
+				"show":    episode.TvShow.GetTitle(),
+				"season":  episode.Season,
+				"error":   err,
+			}).Warning("Couldn't get season torrents from indexer")
+			errorList = multierror.Append(errorList, err)
+			continue
+		}
+		totalError = false
+
+		for i := range indexerSearch {
+			indexerSearch[i].Indexer = indexer.GetName()
+		}
+		torrentList = append(torrentList, indexerSearch...)
+	}
+
+	if totalError {
+		if errorList == nil {
+			return []Torrent{}, errors.New("No indexer supporting season search found")
+		}
+		return []Torrent{}, errorList.ErrorOrNil()
+	}
+
+	ParseTorrentsMediaInfo(torrentList)
+	ScoreTorrents(&episode, torrentList)
+	torrentList = applyFilters(torrentList, GetSeasonPackFilters(episode, episodeCount))
+
+	for i := range torrentList {
+		torrentList[i].SeasonPack = true
+	}
+
+	log.WithFields(log.Fields{
+		"show":   episode.TvShow.GetTitle(),
+		"season": episode.Season,
+		"nb":     len(torrentList),
+	}).Info("Season pack torrents found")
+
+	return torrentList, nil
+}
+
+// GetSeasonPackFilters returns the filtering chain applied on season pack torrents found for season of episode.
+// Profile size limits and runtime are multiplied by the number of episodes in season
+func GetSeasonPackFilters(episode Episode, episodeCount int) []TorrentFilter {
+	if episodeCount <= 0 {
+		episodeCount = 1
+	}
+
+	profile := GetQualityProfile(&episode)
+	profile.MinSize *= int64(episodeCount)
+	profile.MaxSize *= int64(episodeCount)
+	releaseFilters := GetReleaseFilters(&episode)
+	runtime := GetExpectedRuntime(&episode) * episodeCount
+
+	return []TorrentFilter{
+		TorrentFilter{
+			Name:   "season_pack",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentSeasonPack(list, episode.Season) },
+		},
+		TorrentFilter{
+			Name:   "release_rules",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentReleaseRules(list, releaseFilters) },
+			Reason: func(torrent *Torrent) string { return releaseRulesRejectionReason(torrent, releaseFilters) },
+		},
+		TorrentFilter{
+			Name:   "size",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentSize(list, profile, runtime) },
+			Reason: func(torrent *Torrent) string { return sizeRejectionReason(torrent, profile, runtime) },
+		},
+		TorrentFilter{
+			Name:   "quality",
+			Filter: func(list []Torrent) []Torrent { return FilterTorrentQuality(list, profile) },
+		},
+		TorrentFilter{
+			Name:   "release_type",
+			Filter: FilterTorrentReleaseType,
+		},
+	}
+}
+
+// FilterTorrentSeasonPack keeps torrents containing a full season: season number parsed from torrent name must match season, and no episode number must be found.
+// Torrents whose name cannot be parsed are always removed, regardless of strict torrent check
+func FilterTorrentSeasonPack(list []Torrent, season int) []Torrent {
+	log.WithFields(log.Fields{
+		"season": season,
+	}).Debug("Checking torrent list for season packs")
+
+	var returnList []Torrent
+	for i := range list {
+		torrent := &list[i]
+		mediaInfo, err := GetTorrentMediaInfo(torrent)
+		if err != nil {
+			continue
+		}
+
+		if mediaInfo.Season == season && mediaInfo.Episode == 0 {
+			returnList = append(returnList, *torrent)
+		}
+	}
+
+	return returnList
+}
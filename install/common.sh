@@ -40,9 +40,7 @@ GROUP=flemzer
 function copy_binary {
     # Copy exec file
     log_line "- Copying flemzerd binary"
-    cp dependencies/vidocq $BIN/vidocq
     cp bin/flemzerd $BIN/flemzerd
-    chmod a+x $BIN/vidocq
     chmod a+x $BIN/flemzerd
     print_done
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/macarrie/flemzerd/providers/impl/tvdb"
 
 	indexer "github.com/macarrie/flemzerd/indexers"
+	"github.com/macarrie/flemzerd/indexers/impl/newznab"
 	"github.com/macarrie/flemzerd/indexers/impl/torznab"
 
 	notifier "github.com/macarrie/flemzerd/notifiers"
@@ -28,6 +29,11 @@ import (
 	"github.com/macarrie/flemzerd/notifiers/impl/telegram"
 
 	downloader "github.com/macarrie/flemzerd/downloaders"
+	"github.com/macarrie/flemzerd/downloaders/impl/deluge"
+	"github.com/macarrie/flemzerd/downloaders/impl/nzbget"
+	"github.com/macarrie/flemzerd/downloaders/impl/qbittorrent"
+	"github.com/macarrie/flemzerd/downloaders/impl/rtorrent"
+	"github.com/macarrie/flemzerd/downloaders/impl/sabnzbd"
 	"github.com/macarrie/flemzerd/downloaders/impl/transmission"
 
 	watchlist "github.com/macarrie/flemzerd/watchlists"
@@ -108,6 +114,13 @@ func initIndexers() {
 				apikey := indexer["apikey"].(string)
 				newIndexers = append(newIndexers, torznab.New(name, url, apikey))
 			}
+		case "newznab":
+			for _, indexer := range indexerList {
+				name := indexer["name"].(string)
+				url := indexer["url"].(string)
+				apikey := indexer["apikey"].(string)
+				newIndexers = append(newIndexers, newznab.New(name, url, apikey))
+			}
 		default:
 			log.WithFields(log.Fields{
 				"indexerType": indexerType,
@@ -129,6 +142,7 @@ func initIndexers() {
 func initDownloaders() {
 	log.Debug("Initializing Downloaders")
 	downloader.Reset()
+	downloader.LoadQueueState()
 
 	var newDownloaders []downloader.Downloader
 	for name, downloaderObject := range configuration.Config.Downloaders {
@@ -145,6 +159,36 @@ func initDownloaders() {
 
 			transmissionDownloader := transmission.New(address, port, user, password)
 			newDownloaders = append(newDownloaders, transmissionDownloader)
+		case "qbittorrent":
+			address := downloaderObject["address"]
+			port, _ := strconv.Atoi(downloaderObject["port"])
+
+			qbittorrentDownloader := qbittorrent.New(address, port, downloaderObject["user"], downloaderObject["password"])
+			newDownloaders = append(newDownloaders, qbittorrentDownloader)
+		case "deluge":
+			address := downloaderObject["address"]
+			port, _ := strconv.Atoi(downloaderObject["port"])
+
+			delugeDownloader := deluge.New(address, port, downloaderObject["password"])
+			newDownloaders = append(newDownloaders, delugeDownloader)
+		case "rtorrent":
+			address := downloaderObject["address"]
+			port, _ := strconv.Atoi(downloaderObject["port"])
+
+			rtorrentDownloader := rtorrent.New(address, port, downloaderObject["path"], downloaderObject["user"], downloaderObject["password"])
+			newDownloaders = append(newDownloaders, rtorrentDownloader)
+		case "sabnzbd":
+			address := downloaderObject["address"]
+			port, _ := strconv.Atoi(downloaderObject["port"])
+
+			sabnzbdDownloader := sabnzbd.New(address, port, downloaderObject["apikey"], downloaderObject["category"])
+			newDownloaders = append(newDownloaders, sabnzbdDownloader)
+		case "nzbget":
+			address := downloaderObject["address"]
+			port, _ := strconv.Atoi(downloaderObject["port"])
+
+			nzbgetDownloader := nzbget.New(address, port, downloaderObject["user"], downloaderObject["password"], downloaderObject["category"])
+			newDownloaders = append(newDownloaders, nzbgetDownloader)
 		default:
 			log.WithFields(log.Fields{
 				"downloaderType": name,
@@ -3,6 +3,7 @@ package mock
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	. "github.com/macarrie/flemzerd/objects"
 )
@@ -12,7 +13,29 @@ type ErrorDownloader struct{}
 type StalledDownloader struct{}
 type DLErrorDownloader struct{}
 
+// Torrents count is shared by mock downloaders, which can be used by several download processes at once
 var testTorrentsCount int
+var testTorrentsMutex sync.Mutex
+
+func addTestTorrent() {
+	testTorrentsMutex.Lock()
+	defer testTorrentsMutex.Unlock()
+	testTorrentsCount += 1
+}
+
+func removeTestTorrent() {
+	testTorrentsMutex.Lock()
+	defer testTorrentsMutex.Unlock()
+	if testTorrentsCount > 0 {
+		testTorrentsCount -= 1
+	}
+}
+
+func getTestTorrentCount() int {
+	testTorrentsMutex.Lock()
+	defer testTorrentsMutex.Unlock()
+	return testTorrentsCount
+}
 
 // Status
 func (d Downloader) Status() (Module, error) {
@@ -75,18 +98,18 @@ func (d DLErrorDownloader) GetName() string {
 
 // AddTorrent
 func (d Downloader) AddTorrent(t Torrent) (string, error) {
-	testTorrentsCount += 1
+	addTestTorrent()
 	return "id", nil
 }
 func (d ErrorDownloader) AddTorrent(t Torrent) (string, error) {
 	return "id", errors.New("Downloader error")
 }
 func (d StalledDownloader) AddTorrent(t Torrent) (string, error) {
-	testTorrentsCount += 1
+	addTestTorrent()
 	return "id", nil
 }
 func (d DLErrorDownloader) AddTorrent(t Torrent) (string, error) {
-	testTorrentsCount += 1
+	addTestTorrent()
 	return "id", nil
 }
 
@@ -106,24 +129,18 @@ func (d DLErrorDownloader) AddTorrentMapping(flemzerId string, downloaderId stri
 
 // RemoveTorrent
 func (d Downloader) RemoveTorrent(t Torrent) error {
-	if testTorrentsCount > 0 {
-		testTorrentsCount -= 1
-	}
+	removeTestTorrent()
 	return nil
 }
 func (d ErrorDownloader) RemoveTorrent(t Torrent) error {
 	return errors.New("downloader error")
 }
 func (d StalledDownloader) RemoveTorrent(t Torrent) error {
-	if testTorrentsCount > 0 {
-		testTorrentsCount -= 1
-	}
+	removeTestTorrent()
 	return nil
 }
 func (d DLErrorDownloader) RemoveTorrent(t Torrent) error {
-	if testTorrentsCount > 0 {
-		testTorrentsCount -= 1
-	}
+	removeTestTorrent()
 	return nil
 }
 
@@ -161,14 +178,30 @@ func (d DLErrorDownloader) GetTorrentStatus(t *Torrent) error {
 
 // GetTorrentCount
 func (d Downloader) GetTorrentCount() int {
-	return testTorrentsCount
+	return getTestTorrentCount()
 }
 func (d ErrorDownloader) GetTorrentCount() int {
-	return testTorrentsCount
+	return getTestTorrentCount()
 }
 func (d StalledDownloader) GetTorrentCount() int {
-	return testTorrentsCount
+	return getTestTorrentCount()
 }
 func (d DLErrorDownloader) GetTorrentCount() int {
-	return testTorrentsCount
+	return getTestTorrentCount()
+}
+
+// UsenetDownloader behaves like Downloader, but handles usenet releases
+type UsenetDownloader struct {
+	Downloader
+}
+
+func (d UsenetDownloader) GetName() string {
+	return "UsenetDownloader"
+}
+func (d UsenetDownloader) GetProtocol() string {
+	return PROTOCOL_USENET
+}
+func (d UsenetDownloader) AddTorrent(t Torrent) (string, error) {
+	addTestTorrent()
+	return "usenet_id", nil
 }
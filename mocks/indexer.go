@@ -166,6 +166,38 @@ func (ind ErrorTVIndexer) GetTorrents(d downloadable.Downloadable) ([]Torrent, e
 	return getTorrentForEpisode(*d.(*Episode))
 }
 
+func (ind TVIndexer) GetSeasonTorrents(show TvShow, season int) ([]Torrent, error) {
+	if season != 1 {
+		return []Torrent{}, nil
+	}
+
+	return []Torrent{
+		Torrent{
+			Name:    "Test.Show.S01.720p",
+			Link:    "season1.720p.torrent",
+			Seeders: 1,
+		},
+		Torrent{
+			Name:    "Test.Show.S01.1080p",
+			Link:    "season1.1080p.torrent",
+			Seeders: 2,
+		},
+		Torrent{
+			Name:    "Test.Show.S01E01.720p",
+			Link:    "episode1.torrent",
+			Seeders: 3,
+		},
+		Torrent{
+			Name:    "Test.Show.S02.720p",
+			Link:    "season2.torrent",
+			Seeders: 4,
+		},
+	}, nil
+}
+func (ind ErrorTVIndexer) GetSeasonTorrents(show TvShow, season int) ([]Torrent, error) {
+	return []Torrent{}, fmt.Errorf("Indexer error")
+}
+
 func getTorrentForMovie(movieName string) ([]Torrent, error) {
 	if movieName == "" {
 		return []Torrent{}, nil
@@ -0,0 +1,183 @@
+// Package naming builds library folder and file names of downloaded items from Go templates defined in configuration.
+package naming
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	log "github.com/macarrie/flemzerd/logging"
+)
+
+// Patterns contains templates used to name library folders and files. An empty file template keeps the release name, an empty episode folder template stores episodes directly into season folder
+type Patterns struct {
+	MovieFolder   string `mapstructure:"movie_folder"`
+	MovieFile     string `mapstructure:"movie_file"`
+	ShowFolder    string `mapstructure:"show_folder"`
+	SeasonFolder  string `mapstructure:"season_folder"`
+	EpisodeFolder string `mapstructure:"episode_folder"`
+	EpisodeFile   string `mapstructure:"episode_file"`
+}
+
+// Variables contains values available in templates. Ids are movie ids for movies, and show ids for episodes
+type Variables struct {
+	Title         string
+	OriginalTitle string
+	Year          int
+	Quality       string
+	ReleaseName   string
+	ImdbId        string
+	TmdbId        int
+	TvdbId        int
+	TraktId       int
+	// Episode variables. LastEpisode is the number of the last episode of multi-episode releases (0 for single episodes)
+	Show           string
+	Season         int
+	Episode        int
+	LastEpisode    int
+	EpisodeTitle   string
+	AbsoluteNumber int
+}
+
+// DefaultPatterns reproduce historical library layout (<movie>/<release> and <show>/season_<n>/sXXeYY/<release>)
+var DefaultPatterns = Patterns{
+	MovieFolder:   "{{ sanitize .Title }}",
+	MovieFile:     "",
+	ShowFolder:    "{{ sanitize .Show }}",
+	SeasonFolder:  "season_{{ .Season }}",
+	EpisodeFolder: "s{{ pad .Season 2 }}e{{ pad .Episode 2 }}{{ if .LastEpisode }}-e{{ pad .LastEpisode 2 }}{{ end }}",
+	EpisodeFile:   "",
+}
+
+// Sample variables used to validate templates and preview library names
+var SampleMovie = Variables{
+	Title:         "The Matrix",
+	OriginalTitle: "The Matrix",
+	Year:          1999,
+	Quality:       "1080p",
+	ReleaseName:   "The.Matrix.1999.1080p.BluRay.x264-GROUP",
+	ImdbId:        "tt0133093",
+	TmdbId:        603,
+	TraktId:       481,
+}
+
+var SampleEpisode = Variables{
+	Title:          "The Expanse",
+	OriginalTitle:  "The Expanse",
+	Year:           2015,
+	Quality:        "720p",
+	ReleaseName:    "The.Expanse.S02E05.720p.HDTV.x264-GROUP",
+	ImdbId:         "tt3230854",
+	TmdbId:         63639,
+	TvdbId:         280619,
+	TraktId:        77199,
+	Show:           "The Expanse",
+	Season:         2,
+	Episode:        5,
+	EpisodeTitle:   "Home",
+	AbsoluteNumber: 15,
+}
+
+var invalidCharacters = regexp.MustCompile(`[/\\:*?"<>|]`)
+var sanitizeRegexp = regexp.MustCompile("[^a-z0-9]+")
+
+var templateFunctions = template.FuncMap{
+	// sanitize converts value into a lowercase name containing only letters, digits and underscores
+	"sanitize": func(value string) string {
+		return sanitizeRegexp.ReplaceAllString(strings.ToLower(value), "_")
+	},
+	// pad left pads number with zeros up to width digits
+	"pad": func(number int, width int) string {
+		return fmt.Sprintf("%0*d", width, number)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Render executes template pattern with variables vars. Characters not allowed in file names are removed from result, so that rendered name is a single path element
+func Render(pattern string, vars Variables) (string, error) {
+	tmpl, err := template.New("naming").Funcs(templateFunctions).Parse(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, vars); err != nil {
+		return "", err
+	}
+
+	name := invalidCharacters.ReplaceAllString(buffer.String(), "")
+	return strings.Trim(strings.TrimSpace(name), "."), nil
+}
+
+// Validate renders each template of p with sample variables and returns errors indexed by template key. Folder templates must not render empty names, except episode folder template
+func (p Patterns) Validate() map[string]error {
+	problems := make(map[string]error)
+
+	templates := []struct {
+		Key        string
+		Pattern    string
+		Vars       Variables
+		AllowEmpty bool
+	}{
+		{"movie_folder", p.MovieFolder, SampleMovie, false},
+		{"movie_file", p.MovieFile, SampleMovie, true},
+		{"show_folder", p.ShowFolder, SampleEpisode, false},
+		{"season_folder", p.SeasonFolder, SampleEpisode, false},
+		{"episode_folder", p.EpisodeFolder, SampleEpisode, true},
+		{"episode_file", p.EpisodeFile, SampleEpisode, true},
+	}
+
+	for _, t := range templates {
+		name, err := Render(t.Pattern, t.Vars)
+		if err != nil {
+			problems[t.Key] = err
+			continue
+		}
+		if name == "" && (!t.AllowEmpty || strings.TrimSpace(t.Pattern) != "") {
+			problems[t.Key] = fmt.Errorf("template renders an empty name")
+		}
+	}
+
+	return problems
+}
+
+// renderName renders template pattern. Default template is used if pattern cannot be rendered, or renders an empty name when a name is required
+func renderName(key string, pattern string, defaultPattern string, vars Variables, allowEmpty bool) string {
+	name, err := Render(pattern, vars)
+	if err == nil && (name != "" || allowEmpty) {
+		return name
+	}
+
+	log.WithFields(log.Fields{
+		"template": key,
+		"error":    err,
+	}).Warning("Invalid naming template, using default template instead")
+
+	name, _ = Render(defaultPattern, vars)
+	return name
+}
+
+// MoviePath returns library folder of a movie (relative to movie library) and its file name without extension. An empty file name means release name is kept
+func (p Patterns) MoviePath(vars Variables) (string, string) {
+	folder := renderName("movie_folder", p.MovieFolder, DefaultPatterns.MovieFolder, vars, false)
+	file := renderName("movie_file", p.MovieFile, DefaultPatterns.MovieFile, vars, true)
+
+	return folder, file
+}
+
+// EpisodePath returns library folder of an episode (relative to show library) and its file name without extension. An empty file name means release name is kept
+func (p Patterns) EpisodePath(vars Variables) (string, string) {
+	folders := []string{
+		renderName("show_folder", p.ShowFolder, DefaultPatterns.ShowFolder, vars, false),
+		renderName("season_folder", p.SeasonFolder, DefaultPatterns.SeasonFolder, vars, false),
+	}
+	if episodeFolder := renderName("episode_folder", p.EpisodeFolder, DefaultPatterns.EpisodeFolder, vars, true); episodeFolder != "" {
+		folders = append(folders, episodeFolder)
+	}
+	file := renderName("episode_file", p.EpisodeFile, DefaultPatterns.EpisodeFile, vars, true)
+
+	return strings.Join(folders, "/"), file
+}
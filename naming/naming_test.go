@@ -0,0 +1,84 @@
+package naming
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	testData := []struct {
+		Pattern  string
+		Vars     Variables
+		Expected string
+	}{
+		{"{{ sanitize .Title }}", SampleMovie, "the_matrix"},
+		{"{{ .Title }} ({{ .Year }})", SampleMovie, "The Matrix (1999)"},
+		{"{{ .Title }}: {{ .Quality }}/{{ .ImdbId }}", SampleMovie, "The Matrix 1080ptt0133093"},
+		{"Season {{ pad .Season 2 }}", SampleEpisode, "Season 02"},
+		{"{{ .Show }} - {{ .AbsoluteNumber }} - {{ upper .EpisodeTitle }}", SampleEpisode, "The Expanse - 15 - HOME"},
+		{"  ..{{ .Title }}.. ", SampleMovie, "The Matrix"},
+	}
+
+	for _, data := range testData {
+		result, err := Render(data.Pattern, data.Vars)
+		if err != nil {
+			t.Errorf("Expected template '%s' to be rendered, got error: %s", data.Pattern, err)
+			continue
+		}
+		if result != data.Expected {
+			t.Errorf("Expected template '%s' to render '%s', got '%s' instead", data.Pattern, data.Expected, result)
+		}
+	}
+
+	for _, pattern := range []string{"{{ .Title", "{{ .Unknown }}", "{{ pad .Title 2 }}"} {
+		if _, err := Render(pattern, SampleMovie); err == nil {
+			t.Errorf("Expected an error when rendering invalid template '%s'", pattern)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if problems := DefaultPatterns.Validate(); len(problems) != 0 {
+		t.Errorf("Expected default templates to be valid, got %d errors", len(problems))
+	}
+
+	patterns := DefaultPatterns
+	patterns.MovieFolder = ""
+	patterns.EpisodeFile = "{{ .Unknown }}"
+	patterns.EpisodeFolder = ""
+	problems := patterns.Validate()
+	if len(problems) != 2 || problems["movie_folder"] == nil || problems["episode_file"] == nil {
+		t.Errorf("Expected empty movie folder and invalid episode file templates to be reported, got %v", problems)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	folder, file := DefaultPatterns.MoviePath(SampleMovie)
+	if folder != "the_matrix" || file != "" {
+		t.Errorf("Expected default movie path to be 'the_matrix' without file name, got '%s' and '%s'", folder, file)
+	}
+
+	multiEpisode := SampleEpisode
+	multiEpisode.LastEpisode = 7
+	folder, file = DefaultPatterns.EpisodePath(multiEpisode)
+	if folder != "the_expanse/season_2/s02e05-e07" || file != "" {
+		t.Errorf("Expected default episode path to be 'the_expanse/season_2/s02e05-e07' without file name, got '%s' and '%s'", folder, file)
+	}
+
+	patterns := Patterns{
+		MovieFolder:   "{{ .Unknown }}",
+		MovieFile:     "{{ .Title }} ({{ .Year }})",
+		ShowFolder:    "{{ .Show }}",
+		SeasonFolder:  "Season {{ pad .Season 2 }}",
+		EpisodeFolder: "",
+		EpisodeFile:   "{{ .Show }} - S{{ pad .Season 2 }}E{{ pad .Episode 2 }}",
+	}
+
+	folder, file = patterns.MoviePath(SampleMovie)
+	if folder != "the_matrix" || file != "The Matrix (1999)" {
+		t.Errorf("Expected invalid movie folder template to be replaced by default template, got '%s' and '%s'", folder, file)
+	}
+	folder, file = patterns.EpisodePath(SampleEpisode)
+	if folder != "The Expanse/Season 02" || file != "The Expanse - S02E05" {
+		t.Errorf("Expected episode to be stored in season folder, got '%s' and '%s'", folder, file)
+	}
+}
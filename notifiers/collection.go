@@ -195,6 +195,34 @@ func NotifyFailedDownload(d downloadable.Downloadable) error {
 	return nil
 }
 
+// NotifyStalledDownload sends notification on registered notifiers to alert that the download of torrent t stalled and that the next torrent will be downloaded instead
+func NotifyStalledDownload(d downloadable.Downloadable, t Torrent) error {
+	notification := Notification{
+		Type:    NOTIFICATION_DOWNLOAD_STALLED,
+		Content: fmt.Sprintf("Download of %s stalled: %s. Trying next torrent", t.Name, t.FailureReason),
+	}
+
+	switch d.(type) {
+	case *Movie:
+		notification.Movie = *(d.(*Movie))
+	case *Episode:
+		notification.Episode = *(d.(*Episode))
+	default:
+		d.GetLog().Debug("Unknown Downloadable object type when sending stalled download notification")
+		return nil
+	}
+
+	if !configuration.Config.Notifications.Enabled || !configuration.Config.Notifications.NotifyFailure {
+		return nil
+	}
+
+	if err := SendNotification(notification); err != nil {
+		return errors.Wrap(err, "Errors detected when sending notification")
+	}
+
+	return nil
+}
+
 // NotifyTorrentNotFound sends notification on registered notifiers to alert that torrents could not be found (either no torrents found or no available indexers)
 func NotifyTorrentsNotFound(d downloadable.Downloadable) error {
 	notification := Notification{}
@@ -394,6 +394,44 @@ func TestNotifiyTorrentsNotFound(t *testing.T) {
 	}
 }
 
+func TestNotifyStalledDownload(t *testing.T) {
+	db.ResetDb()
+	notifiersCollection = []Notifier{}
+	n := mock.Notifier{}
+	AddNotifier(n)
+
+	movie := Movie{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Title:         "Test movie",
+		OriginalTitle: "Test movie",
+	}
+	torrent := Torrent{
+		Name:          "Test.Movie.720p",
+		FailureReason: "No peers connected for 60 minutes",
+	}
+
+	count := n.GetNotificationCount()
+	NotifyStalledDownload(&movie, torrent)
+	if n.GetNotificationCount() != count+1 {
+		t.Error("Expected notification to be sent when notifying stalled download")
+	}
+
+	configuration.Config.Notifications.NotifyFailure = false
+	count = n.GetNotificationCount()
+	NotifyStalledDownload(&movie, torrent)
+	if n.GetNotificationCount() != count {
+		t.Error("Expected notification not to be sent when notifying stalled download because of configuration params")
+	}
+
+	configuration.Config.Notifications.NotifyFailure = true
+	notifiersCollection = []Notifier{mock.ErrorNotifier{}}
+	if err := NotifyStalledDownload(&movie, torrent); err == nil {
+		t.Error("Expected error when notifying stalled download with mock.ErrorNotifier")
+	}
+}
+
 func TestGetNotifier(t *testing.T) {
 	notifiersCollection = []Notifier{mock.Notifier{}}
 
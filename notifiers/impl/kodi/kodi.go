@@ -129,6 +129,15 @@ func (k *KodiNotifier) Send(notif Notification) error {
 		}
 		content = "No torrents found"
 
+	case NOTIFICATION_DOWNLOAD_STALLED:
+		if notif.Episode.ID != 0 {
+			title = fmt.Sprintf("%v S%03dE%03d", notif.Episode.TvShow.GetTitle(), notif.Episode.Season, notif.Episode.Number)
+		}
+		if notif.Movie.ID != 0 {
+			title = fmt.Sprintf("%v", notif.Movie.GetTitle())
+		}
+		content = "Download stalled, trying next torrent"
+
 	default:
 		return fmt.Errorf("Unable to send notification: Unknown notification type (%d)", notif.Type)
 	}
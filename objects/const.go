@@ -13,6 +13,20 @@ const (
 	TORRENT_UNKNOWN_STATUS
 )
 
+// Protocols used to download releases retrieved from indexers
+const (
+	PROTOCOL_TORRENT = "torrent"
+	PROTOCOL_USENET  = "usenet"
+)
+
+// Modes used to import downloaded files into library
+const (
+	IMPORT_MOVE     = "move"
+	IMPORT_COPY     = "copy"
+	IMPORT_HARDLINK = "hardlink"
+	IMPORT_SYMLINK  = "symlink"
+)
+
 const (
 	NOTIFICATION_NEW_EPISODE = iota
 	NOTIFICATION_NEW_MOVIE
@@ -21,6 +35,7 @@ const (
 	NOTIFICATION_DOWNLOAD_FAILURE
 	NOTIFICATION_TEXT
 	NOTIFICATION_NO_TORRENTS
+	NOTIFICATION_DOWNLOAD_STALLED
 )
 
 const (
@@ -1,6 +1,10 @@
 package objects
 
-import "github.com/jinzhu/gorm"
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
 
 type DownloadingItem struct {
 	gorm.Model
@@ -9,8 +13,29 @@ type DownloadingItem struct {
 	Downloaded          bool
 	TorrentList         []Torrent `gorm:"foreignkey:TorrentListID"`
 	CurrentDownloaderId string
-	DownloadFailed      bool
-	TorrentsNotFound    bool
+	// Name of the downloader holding the current torrent
+	CurrentDownloader string
+	DownloadFailed    bool
+	TorrentsNotFound  bool
+	// Upgrade of an already downloaded item in progress
+	Upgrading bool
+	// Torrent of the release currently in library, replaced if upgrade succeeds
+	UpgradedTorrentID uint
+	LastUpgradeCheck  time.Time
+	ReplacedReleases  []ReplacedRelease
+	// Last time missing item was searched by backfill. Failed searches are retried after a delay
+	LastSearch time.Time
+	// Item waiting in download queue for a download slot
+	Queued bool
+	// Position of item in download queue (starting at 1)
+	QueuePosition int
+	// Items with higher priority are downloaded first
+	Priority int
+	// Downloaded files could not be imported into library. Import can be retried
+	ImportFailed        bool
+	ImportFailureReason string
+	// Properties of the video file imported into library, when media verification is enabled
+	Probe MediaProbe `gorm:"embedded;embedded_prefix:probe_"`
 }
 
 func (d *DownloadingItem) CurrentTorrent() Torrent {
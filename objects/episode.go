@@ -23,6 +23,8 @@ type Episode struct {
 	Notified          bool
 	DownloadingItem   DownloadingItem
 	DownloadingItemID uint
+	// Unmonitored episodes are never downloaded automatically
+	Monitored bool `gorm:"default:true"`
 }
 
 //////////////////////////////
@@ -0,0 +1,15 @@
+package objects
+
+// MediaProbe contains properties read from the container of an imported video file
+type MediaProbe struct {
+	Probed    bool
+	Container string
+	// Duration in seconds
+	Duration   int
+	Width      int
+	Height     int
+	VideoCodec string
+	// Comma separated lists, ordered by audio track
+	AudioCodecs    string
+	AudioLanguages string
+}
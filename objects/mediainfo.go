@@ -8,6 +8,8 @@ type MediaInfo struct {
 	AudioQuality string `json:"audio_quality"`
 	Container    string `json:"container"`
 	Episode      int    `json:"episode"`
+	// Last episode of multi-episode releases (S02E05E06, S02E05-07). 0 if release contains a single episode
+	LastEpisode  int    `json:"last_episode"`
 	Quality      string `json:"quality"`
 	Raw          string `json:"raw"`
 	ReleaseType  string `json:"release_type"`
@@ -16,9 +18,43 @@ type MediaInfo struct {
 	Size         string `json:"size"`
 	Title        string `json:"title"`
 	Type         string `json:"media_type"`
+	VideoCodec   string `json:"video_codec"`
 	Year         int    `json:"year"`
 }
 
+// IsMultiEpisode returns true if media info describes a release containing several episodes
+func (m MediaInfo) IsMultiEpisode() bool {
+	return m.Episode != 0 && m.LastEpisode > m.Episode
+}
+
+// EpisodeNumbers returns the numbers of all episodes contained in release
+func (m MediaInfo) EpisodeNumbers() []int {
+	if m.Episode == 0 {
+		return []int{}
+	}
+	if !m.IsMultiEpisode() {
+		return []int{m.Episode}
+	}
+
+	var numbers []int
+	for n := m.Episode; n <= m.LastEpisode; n++ {
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// CoversEpisode returns true if release contains episode with given number
+func (m MediaInfo) CoversEpisode(number int) bool {
+	if number == 0 || m.Episode == 0 {
+		return false
+	}
+	if !m.IsMultiEpisode() {
+		return m.Episode == number
+	}
+
+	return number >= m.Episode && number <= m.LastEpisode
+}
+
 type MediaInfoEpisodes map[int]MediaInfo
 type MediaInfoSeasons map[int]MediaInfoEpisodes
 type MediaInfoGroupedByShow map[string]MediaInfoSeasons
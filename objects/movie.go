@@ -24,6 +24,11 @@ type Movie struct {
 	DownloadingItem   DownloadingItem
 	DownloadingItemID int
 	UseDefaultTitle   bool
+	// Runtime in minutes
+	Runtime          int
+	QualityProfile   QualityProfile
+	QualityProfileID uint
+	ReleaseFilters   ReleaseFilters `gorm:"embedded;embedded_prefix:release_filters_"`
 }
 
 //////////////////////////////
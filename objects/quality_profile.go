@@ -0,0 +1,135 @@
+package objects
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Known media qualities, from lowest to highest
+var MediaQualities = []string{"480p", "576p", "720p", "900p", "1080p", "1440p", "2160p", "5k", "8k", "16k"}
+
+// QualityProfile defines which torrents are acceptable for a media, and in which order they should be preferred.
+// Lists are stored as comma separated strings, like in configuration file.
+type QualityProfile struct {
+	gorm.Model
+	Name string
+	// Ordered list of allowed qualities. First quality is the preferred one
+	Qualities string
+	// Ordered list of preferred video codecs (h264, h265, ...)
+	PreferredCodecs string
+	// Torrent size limits (in MB). 0 means no limit
+	MinSize int64
+	MaxSize int64
+	// Quality from which a downloaded media is considered good enough and will not be upgraded anymore
+	Cutoff string
+}
+
+func splitList(list string) []string {
+	var retList []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			retList = append(retList, item)
+		}
+	}
+
+	return retList
+}
+
+// IsValidQuality returns true if quality is a known media quality
+func IsValidQuality(quality string) bool {
+	for _, q := range MediaQualities {
+		if q == quality {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetQualities returns the ordered list of allowed qualities for profile
+func (p QualityProfile) GetQualities() []string {
+	return splitList(p.Qualities)
+}
+
+// GetPreferredCodecs returns the ordered list of preferred codecs for profile
+func (p QualityProfile) GetPreferredCodecs() []string {
+	return splitList(p.PreferredCodecs)
+}
+
+// QualityRank returns the position of quality in the allowed qualities list of the profile (0 being the preferred quality). -1 is returned if quality is not allowed
+func (p QualityProfile) QualityRank(quality string) int {
+	for index, q := range p.GetQualities() {
+		if q == quality {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// CodecRank returns the position of codec in the preferred codecs list of the profile (0 being the preferred codec). -1 is returned if codec is not in the preferred list
+func (p QualityProfile) CodecRank(codec string) int {
+	for index, c := range p.GetPreferredCodecs() {
+		if c == codec {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// CutoffReached returns true if quality is good enough for profile and media does not need to be upgraded anymore.
+// Profiles without cutoff never require upgrades
+func (p QualityProfile) CutoffReached(quality string) bool {
+	cutoffRank := p.QualityRank(p.Cutoff)
+	if p.Cutoff == "" || cutoffRank == -1 {
+		return true
+	}
+
+	rank := p.QualityRank(quality)
+	return rank != -1 && rank <= cutoffRank
+}
+
+// SizeAllowed checks if size (in bytes) is within profile size limits. Unknown sizes (0) are always allowed
+func (p QualityProfile) SizeAllowed(size int64) bool {
+	if size <= 0 {
+		return true
+	}
+
+	sizeMB := size / (1024 * 1024)
+	if p.MinSize > 0 && sizeMB < p.MinSize {
+		return false
+	}
+	if p.MaxSize > 0 && sizeMB > p.MaxSize {
+		return false
+	}
+
+	return true
+}
+
+// Check returns a list of problems found in profile definition. An empty list is returned if profile is valid
+func (p QualityProfile) Check() []string {
+	var problems []string
+
+	if strings.TrimSpace(p.Name) == "" {
+		problems = append(problems, "Profile name cannot be empty")
+	}
+
+	for _, quality := range p.GetQualities() {
+		if !IsValidQuality(quality) {
+			problems = append(problems, "Unknown quality: "+quality)
+		}
+	}
+
+	if p.Cutoff != "" && p.QualityRank(p.Cutoff) == -1 {
+		problems = append(problems, "Cutoff quality must be one of the profile allowed qualities")
+	}
+
+	if p.MinSize > 0 && p.MaxSize > 0 && p.MinSize > p.MaxSize {
+		problems = append(problems, "Minimum size cannot be greater than maximum size")
+	}
+
+	return problems
+}
@@ -0,0 +1,72 @@
+package objects
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ReleaseFilters defines rules applied on torrent names and release groups when looking for torrents.
+// Lists are comma separated. Words between slashes (/regex/) are regular expressions, other words are matched case insensitively
+type ReleaseFilters struct {
+	// Torrent name must contain at least one of the required words
+	RequiredWords string
+	// Torrents containing one of the ignored words are rejected
+	IgnoredWords string
+	// If defined, only torrents from these release groups are accepted
+	AllowedReleaseGroups string
+	// Torrents from these release groups are rejected
+	BlockedReleaseGroups string
+}
+
+func compileTerm(term string) (*regexp.Regexp, error) {
+	if len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/") {
+		return regexp.Compile("(?i)" + term[1:len(term)-1])
+	}
+
+	return regexp.Compile("(?i)" + regexp.QuoteMeta(term))
+}
+
+// MatchingTerm returns the first term of the comma separated list "terms" found in "value". Invalid regular expressions are ignored
+func MatchingTerm(terms string, value string) (string, bool) {
+	for _, term := range splitList(terms) {
+		exp, err := compileTerm(term)
+		if err != nil {
+			continue
+		}
+
+		if exp.MatchString(value) {
+			return term, true
+		}
+	}
+
+	return "", false
+}
+
+// ContainsReleaseGroup returns true if group is in the comma separated list "groups" (case insensitive)
+func ContainsReleaseGroup(groups string, group string) bool {
+	for _, g := range splitList(groups) {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsEmpty returns true if no rule is defined
+func (f ReleaseFilters) IsEmpty() bool {
+	return len(splitList(f.RequiredWords)) == 0 && len(splitList(f.IgnoredWords)) == 0 && len(splitList(f.AllowedReleaseGroups)) == 0 && len(splitList(f.BlockedReleaseGroups)) == 0
+}
+
+// Check returns a list of invalid regular expressions found in required and ignored words. An empty list is returned if filters are valid
+func (f ReleaseFilters) Check() []string {
+	var problems []string
+
+	for _, term := range append(splitList(f.RequiredWords), splitList(f.IgnoredWords)...) {
+		if _, err := compileTerm(term); err != nil {
+			problems = append(problems, "Invalid regular expression "+term+": "+err.Error())
+		}
+	}
+
+	return problems
+}
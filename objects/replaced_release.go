@@ -0,0 +1,13 @@
+package objects
+
+import "github.com/jinzhu/gorm"
+
+// ReplacedRelease keeps track of a release that has been removed from library because a better release has been downloaded for the same item
+type ReplacedRelease struct {
+	gorm.Model
+	DownloadingItemID uint
+	Name              string
+	Quality           string
+	Path              string
+	ReplacedBy        string
+}
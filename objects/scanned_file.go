@@ -0,0 +1,32 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Status of files found in library by library scans
+const (
+	SCAN_MATCHED   = "matched"
+	SCAN_UNMATCHED = "unmatched"
+	SCAN_AMBIGUOUS = "ambiguous"
+)
+
+// ScannedFile keeps track of a video file found in library by library scans, and of the tracked item it has been linked to
+type ScannedFile struct {
+	gorm.Model
+	Path string
+	// MOVIE or EPISODE
+	MediaType int
+	// Size and modification time of the file when it was last scanned. Unchanged matched files are skipped by next scans
+	Size    int64
+	ModTime time.Time
+	Status  string
+	// Why file could not be linked to a tracked item
+	Reason    string
+	MovieID   uint
+	EpisodeID uint
+	// Media info parsed from file path
+	Info MediaInfo `gorm:"-"`
+}
@@ -0,0 +1,37 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SeedingTorrent keeps track of a downloaded torrent left in download client until its seeding goals are reached
+type SeedingTorrent struct {
+	gorm.Model
+	Name string
+	// Flemzerd torrent id, and id of the torrent in download client
+	TorrentId    string
+	Downloader   string
+	DownloaderId string
+	Indexer      string
+	// Path of downloaded data in temporary download folder. Data is removed with the torrent
+	Path string
+	// Seeding goals: upload ratio and seeding time (in hours). A goal set to 0 is disabled
+	SeedRatio   float64
+	SeedTime    int
+	UploadRatio float64
+	CompletedAt time.Time
+}
+
+// GoalsReached returns true if torrent upload ratio or seeding time reached its seeding goal
+func (s SeedingTorrent) GoalsReached(now time.Time) bool {
+	if s.SeedRatio > 0 && s.UploadRatio >= s.SeedRatio {
+		return true
+	}
+	if s.SeedTime > 0 && now.Sub(s.CompletedAt) >= time.Duration(s.SeedTime)*time.Hour {
+		return true
+	}
+
+	return s.SeedRatio <= 0 && s.SeedTime <= 0
+}
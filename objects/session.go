@@ -6,4 +6,6 @@ type SessionData struct {
 	gorm.Model
 	TraktToken     string
 	TelegramChatID int64
+	// Download queue paused by user
+	QueuePaused bool
 }
@@ -21,4 +21,38 @@ type Torrent struct {
 	RateDownload  int64
 	RateUpload    int64
 	Status        int
+	// Number of peers connected to the torrent, when reported by download client
+	PeersConnected int
+	// Ratio between uploaded and downloaded data, when reported by download client
+	UploadRatio float64
+	// Reason why torrent download failed
+	FailureReason string
+	// Media info parsed from torrent name. Parsing is done once when torrent is retrieved from indexers
+	MediaInfo MediaInfo `gorm:"embedded;embedded_prefix:media_info_"`
+	// Name of the indexer the torrent has been retrieved from
+	Indexer   string
+	Freeleech bool
+	// Protocol used to download the release (torrent or usenet). Releases without protocol are torrents
+	Protocol string
+	// Torrent contains a full season. Files are split into episode folders when moved into library
+	SeasonPack bool
+	// Path of the release video file in library, once release has been imported
+	LibraryPath string
+	// Total score used to sort torrents, and score of each scoring factor
+	Score          int
+	ScoreBreakdown []TorrentScore
+}
+
+// GetProtocol returns the protocol used to download the release
+func (t Torrent) GetProtocol() string {
+	if t.Protocol == "" {
+		return PROTOCOL_TORRENT
+	}
+
+	return t.Protocol
+}
+
+// AfterDelete removes score details of deleted torrent
+func (t *Torrent) AfterDelete(tx *gorm.DB) error {
+	return tx.Unscoped().Where("torrent_id = ?", t.ID).Delete(&TorrentScore{}).Error
 }
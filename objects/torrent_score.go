@@ -0,0 +1,11 @@
+package objects
+
+import "github.com/jinzhu/gorm"
+
+// TorrentScore is the part of a torrent score computed by a single scoring factor. It is kept to explain why a torrent has been chosen over another
+type TorrentScore struct {
+	gorm.Model
+	TorrentID uint
+	Factor    string
+	Score     int
+}
@@ -1,6 +1,8 @@
 package objects
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,18 @@ const (
 	TVSHOW_UNKNOWN   = 4
 )
 
+// Show monitoring modes: episodes considered as wanted when missing from library
+const (
+	// All episodes of all seasons (specials excepted)
+	MONITOR_ALL = "all"
+	// Episodes aired after the show was added
+	MONITOR_FUTURE = "future"
+	// Episodes of the latest aired season
+	MONITOR_LATEST_SEASON = "latest_season"
+	// Episodes of seasons listed in MonitoredSeasons
+	MONITOR_SEASONS = "seasons"
+)
+
 type TvShow struct {
 	gorm.Model
 	MediaIds         MediaIds
@@ -34,6 +48,15 @@ type TvShow struct {
 	Seasons          []TvSeason
 	UseDefaultTitle  bool
 	IsAnime          bool
+	// Episode runtime in minutes
+	Runtime          int
+	QualityProfile   QualityProfile
+	QualityProfileID uint
+	ReleaseFilters   ReleaseFilters `gorm:"embedded;embedded_prefix:release_filters_"`
+	// Monitoring mode (all, future, latest_season or seasons). Defaults to future if empty
+	MonitoringMode string
+	// Comma separated list of season numbers monitored in "seasons" monitoring mode
+	MonitoredSeasons string
 }
 
 type TvSeason struct {
@@ -43,6 +66,8 @@ type TvSeason struct {
 	SeasonNumber int
 	PosterPath   string
 	TvShowID     int
+	// Episodes of unmonitored seasons are never downloaded automatically
+	Monitored bool `gorm:"default:true"`
 }
 type SeasonDetails struct {
 	Info        TvSeason
@@ -61,6 +86,122 @@ func (s TvShow) GetTitle() string {
 	return s.OriginalTitle
 }
 
+// AfterCreate disables monitoring of specials (season 0). Monitored defaults to true in database and cannot be set to false on creation
+func (s *TvSeason) AfterCreate(tx *gorm.DB) error {
+	if s.SeasonNumber != 0 {
+		return nil
+	}
+
+	s.Monitored = false
+	return tx.Model(s).Update("monitored", false).Error
+}
+
+// IsSeasonMonitored returns false if season with given number is unmonitored. Seasons unknown from providers are monitored, except specials (season 0)
+func (s TvShow) IsSeasonMonitored(number int) bool {
+	for _, season := range s.Seasons {
+		if season.SeasonNumber == number {
+			return season.Monitored
+		}
+	}
+
+	return number != 0
+}
+
+// IsEpisodeMonitored returns true if episode and its season are monitored
+func (s TvShow) IsEpisodeMonitored(episode Episode) bool {
+	return episode.Monitored && s.IsSeasonMonitored(episode.Season)
+}
+
+// GetMonitoringMode returns show monitoring mode. Only episodes aired after the show was added are monitored if no mode is defined
+func (s TvShow) GetMonitoringMode() string {
+	if s.MonitoringMode == "" {
+		return MONITOR_FUTURE
+	}
+
+	return s.MonitoringMode
+}
+
+// GetMonitoredSeasons returns the season numbers listed in MonitoredSeasons. Invalid values are ignored
+func (s TvShow) GetMonitoredSeasons() []int {
+	var seasons []int
+	for _, value := range splitList(s.MonitoredSeasons) {
+		if season, err := strconv.Atoi(value); err == nil {
+			seasons = append(seasons, season)
+		}
+	}
+
+	return seasons
+}
+
+// GetLatestSeason returns the number of the latest season of the show that already started airing
+func (s TvShow) GetLatestSeason() int {
+	latest := 0
+	for _, season := range s.Seasons {
+		if season.AirDate != nil && season.AirDate.After(time.Now()) {
+			continue
+		}
+		if season.SeasonNumber > latest {
+			latest = season.SeasonNumber
+		}
+	}
+
+	if latest == 0 {
+		return s.NumberOfSeasons
+	}
+	return latest
+}
+
+// GetMonitoredSeasonNumbers returns the numbers of the seasons monitored with show monitoring mode. Specials (season 0) are only monitored when listed explicitly and their monitoring has been enabled.
+// Seasons whose monitoring has been disabled are never returned
+func (s TvShow) GetMonitoredSeasonNumbers() []int {
+	switch s.GetMonitoringMode() {
+	case MONITOR_ALL:
+		var seasons []int
+		for n := 1; n <= s.GetLatestSeason(); n++ {
+			if s.IsSeasonMonitored(n) {
+				seasons = append(seasons, n)
+			}
+		}
+		return seasons
+	case MONITOR_SEASONS:
+		var seasons []int
+		for _, n := range s.GetMonitoredSeasons() {
+			if s.IsSeasonMonitored(n) {
+				seasons = append(seasons, n)
+			}
+		}
+		return seasons
+	default:
+		if latest := s.GetLatestSeason(); latest > 0 && s.IsSeasonMonitored(latest) {
+			return []int{latest}
+		}
+		return []int{}
+	}
+}
+
+// CheckMonitoring validates show monitoring settings and returns a list of problems. An empty list is returned if monitoring settings are valid
+func (s TvShow) CheckMonitoring() []string {
+	var problems []string
+
+	switch s.GetMonitoringMode() {
+	case MONITOR_ALL, MONITOR_FUTURE, MONITOR_LATEST_SEASON:
+	case MONITOR_SEASONS:
+		values := splitList(s.MonitoredSeasons)
+		if len(values) == 0 {
+			problems = append(problems, "At least one season must be defined in monitored seasons")
+		}
+		for _, value := range values {
+			if season, err := strconv.Atoi(value); err != nil || season < 0 {
+				problems = append(problems, fmt.Sprintf("Invalid season number in monitored seasons: %s", value))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("Unknown monitoring mode: %s", s.MonitoringMode))
+	}
+
+	return problems
+}
+
 //////////////////////////////
 // Cachable implementation
 //////////////////////////////
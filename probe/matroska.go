@@ -0,0 +1,293 @@
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Matroska element ids
+const (
+	idEBML           = 0x1a45dfa3
+	idSegment        = 0x18538067
+	idCluster        = 0x1f43b675
+	idInfo           = 0x1549a966
+	idTimecodeScale  = 0x2ad7b1
+	idDuration       = 0x4489
+	idTracks         = 0x1654ae6b
+	idTrackEntry     = 0xae
+	idTrackType      = 0x83
+	idCodecID        = 0x86
+	idLanguage       = 0x22b59c
+	idVideo          = 0xe0
+	idPixelWidth     = 0xb0
+	idPixelHeight    = 0xba
+	trackTypeVideo   = 1
+	trackTypeAudio   = 2
+	unknownSize      = -1
+	maxElementLoaded = 16 * 1024 * 1024
+)
+
+type element struct {
+	id   uint64
+	size int64
+	data []byte
+}
+
+// readVint reads an EBML variable length integer. Length marker is kept for element ids
+func readVint(r io.Reader, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return 0, 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, ErrFormat
+	}
+
+	value := uint64(first[0])
+	if !keepMarker {
+		value &= uint64(0xff >> uint(length))
+	}
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, ErrFormat
+	}
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+// readElementHeader reads id and size of next element. Size is unknownSize for elements with unknown size
+func readElementHeader(r io.Reader) (uint64, int64, error) {
+	id, _, err := readVint(r, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size, length, err := readVint(r, false)
+	if err != nil {
+		return 0, 0, ErrFormat
+	}
+	if size == uint64(1)<<(7*uint(length))-1 {
+		return id, unknownSize, nil
+	}
+	if size > math.MaxInt64 {
+		return 0, 0, ErrFormat
+	}
+
+	return id, int64(size), nil
+}
+
+// children parses child elements of master element data
+func children(data []byte) ([]element, error) {
+	var elements []element
+	for len(data) > 0 {
+		r := &sliceReader{data: data}
+		id, size, err := readElementHeader(r)
+		if err != nil || size == unknownSize || size > int64(len(r.data)) {
+			return elements, ErrFormat
+		}
+		elements = append(elements, element{
+			id:   id,
+			size: size,
+			data: r.data[:size],
+		})
+		data = r.data[size:]
+	}
+
+	return elements, nil
+}
+
+func readUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func readFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+func probeMatroska(r io.ReadSeeker) (Result, error) {
+	result := Result{Container: CONTAINER_MATROSKA}
+
+	id, size, err := readElementHeader(r)
+	if err != nil || id != idEBML || size == unknownSize {
+		return result, ErrFormat
+	}
+	if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+		return result, ErrFormat
+	}
+
+	id, _, err = readElementHeader(r)
+	if err != nil || id != idSegment {
+		return result, ErrFormat
+	}
+
+	infoFound, tracksFound := false, false
+	for !infoFound || !tracksFound {
+		id, size, err := readElementHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, ErrFormat
+		}
+		// Clusters of unknown size cannot be skipped
+		if size == unknownSize {
+			break
+		}
+
+		switch id {
+		case idInfo, idTracks:
+			if size > maxElementLoaded {
+				return result, ErrFormat
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return result, ErrFormat
+			}
+			if id == idInfo {
+				infoFound = true
+				err = parseMatroskaInfo(data, &result)
+			} else {
+				tracksFound = true
+				err = parseMatroskaTracks(data, &result)
+			}
+			if err != nil {
+				return result, err
+			}
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return result, ErrFormat
+			}
+		}
+	}
+
+	if !tracksFound {
+		return result, ErrFormat
+	}
+
+	return result, nil
+}
+
+func parseMatroskaInfo(data []byte, result *Result) error {
+	elements, err := children(data)
+	if err != nil {
+		return err
+	}
+
+	var timecodeScale uint64 = 1000000
+	var duration float64
+	for _, e := range elements {
+		switch e.id {
+		case idTimecodeScale:
+			timecodeScale = readUint(e.data)
+		case idDuration:
+			duration = readFloat(e.data)
+		}
+	}
+	result.Duration = time.Duration(duration * float64(timecodeScale))
+
+	return nil
+}
+
+func parseMatroskaTracks(data []byte, result *Result) error {
+	entries, err := children(data)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.id != idTrackEntry {
+			continue
+		}
+		fields, err := children(entry.data)
+		if err != nil {
+			return err
+		}
+
+		var trackType uint64
+		var codec string
+		var width, height int
+		// Default language of Matroska tracks
+		language := "eng"
+		for _, field := range fields {
+			switch field.id {
+			case idTrackType:
+				trackType = readUint(field.data)
+			case idCodecID:
+				codec = codecName(trimString(field.data))
+			case idLanguage:
+				language = trimString(field.data)
+			case idVideo:
+				video, err := children(field.data)
+				if err != nil {
+					return err
+				}
+				for _, v := range video {
+					switch v.id {
+					case idPixelWidth:
+						width = int(readUint(v.data))
+					case idPixelHeight:
+						height = int(readUint(v.data))
+					}
+				}
+			}
+		}
+
+		switch trackType {
+		case trackTypeVideo:
+			// First video track is the main one
+			if result.VideoCodec == "" {
+				result.VideoCodec = codec
+				result.Width = width
+				result.Height = height
+			}
+		case trackTypeAudio:
+			result.AudioCodecs = append(result.AudioCodecs, codec)
+			result.AudioLanguages = append(result.AudioLanguages, language)
+		}
+	}
+
+	return nil
+}
+
+// trimString returns EBML string value, which can be padded with null bytes
+func trimString(data []byte) string {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
+
+type sliceReader struct {
+	data []byte
+}
+
+func (s *sliceReader) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	return n, nil
+}
@@ -0,0 +1,230 @@
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Maximum size of moov box loaded in memory
+const maxMoovSize = 64 * 1024 * 1024
+
+type box struct {
+	kind string
+	data []byte
+}
+
+// readBoxHeader reads type and payload size of next box. Size is unknownSize for boxes extending to the end of file
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return "", 0, err
+		}
+		return "", 0, ErrFormat
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	kind := string(header[4:8])
+	switch size {
+	case 0:
+		return kind, unknownSize, nil
+	case 1:
+		large := make([]byte, 8)
+		if _, err := io.ReadFull(r, large); err != nil {
+			return "", 0, ErrFormat
+		}
+		size = int64(binary.BigEndian.Uint64(large)) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, ErrFormat
+	}
+
+	return kind, size, nil
+}
+
+// boxes parses boxes contained in data
+func boxes(data []byte) ([]box, error) {
+	var list []box
+	for len(data) > 0 {
+		r := &sliceReader{data: data}
+		kind, size, err := readBoxHeader(r)
+		if err != nil {
+			return list, ErrFormat
+		}
+		if size == unknownSize {
+			size = int64(len(r.data))
+		}
+		if size > int64(len(r.data)) {
+			return list, ErrFormat
+		}
+		list = append(list, box{
+			kind: kind,
+			data: r.data[:size],
+		})
+		data = r.data[size:]
+	}
+
+	return list, nil
+}
+
+// findBox returns first box of type kind contained in data
+func findBox(data []byte, kind string) (box, bool) {
+	list, _ := boxes(data)
+	for _, b := range list {
+		if b.kind == kind {
+			return b, true
+		}
+	}
+
+	return box{}, false
+}
+
+func probeMP4(r io.ReadSeeker) (Result, error) {
+	result := Result{Container: CONTAINER_MP4}
+
+	for {
+		kind, size, err := readBoxHeader(r)
+		if err == io.EOF || (err == nil && size == unknownSize && kind != "moov") {
+			return result, ErrFormat
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if kind != "moov" {
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return result, ErrFormat
+			}
+			continue
+		}
+
+		if size == unknownSize || size > maxMoovSize {
+			return result, ErrFormat
+		}
+		moov := make([]byte, size)
+		if _, err := io.ReadFull(r, moov); err != nil {
+			return result, ErrFormat
+		}
+		return result, parseMoov(moov, &result)
+	}
+}
+
+func parseMoov(moov []byte, result *Result) error {
+	list, err := boxes(moov)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range list {
+		switch b.kind {
+		case "mvhd":
+			timescale, duration, ok := readHeaderDuration(b.data, 12, 20)
+			if !ok {
+				return ErrFormat
+			}
+			if timescale > 0 {
+				result.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+			}
+		case "trak":
+			if err := parseTrak(b.data, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readHeaderDuration reads timescale and duration of mvhd and mdhd boxes. Offsets of timescale field are given for version 0 and 1 of the box
+func readHeaderDuration(data []byte, offsetV0 int, offsetV1 int) (uint32, uint64, bool) {
+	if len(data) < 1 {
+		return 0, 0, false
+	}
+
+	if data[0] == 1 {
+		if len(data) < offsetV1+12 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(data[offsetV1:]), binary.BigEndian.Uint64(data[offsetV1+4:]), true
+	}
+
+	if len(data) < offsetV0+8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(data[offsetV0:]), uint64(binary.BigEndian.Uint32(data[offsetV0+4:])), true
+}
+
+func parseTrak(trak []byte, result *Result) error {
+	mdia, ok := findBox(trak, "mdia")
+	if !ok {
+		return nil
+	}
+
+	hdlr, ok := findBox(mdia.data, "hdlr")
+	if !ok || len(hdlr.data) < 12 {
+		return ErrFormat
+	}
+	handler := string(hdlr.data[8:12])
+
+	var codec string
+	if minf, ok := findBox(mdia.data, "minf"); ok {
+		if stbl, ok := findBox(minf.data, "stbl"); ok {
+			if stsd, ok := findBox(stbl.data, "stsd"); ok && len(stsd.data) > 8 {
+				if entries, err := boxes(stsd.data[8:]); err == nil && len(entries) > 0 {
+					codec = codecName(entries[0].kind)
+				}
+			}
+		}
+	}
+
+	switch handler {
+	case "vide":
+		if result.VideoCodec != "" {
+			return nil
+		}
+		result.VideoCodec = codec
+		// Track dimensions are stored as 16.16 fixed point numbers at the end of tkhd box
+		if tkhd, ok := findBox(trak, "tkhd"); ok && len(tkhd.data) >= 8 {
+			dimensions := tkhd.data[len(tkhd.data)-8:]
+			result.Width = int(binary.BigEndian.Uint32(dimensions[0:4]) >> 16)
+			result.Height = int(binary.BigEndian.Uint32(dimensions[4:8]) >> 16)
+		}
+	case "soun":
+		language := "und"
+		if mdhd, ok := findBox(mdia.data, "mdhd"); ok {
+			language = readLanguage(mdhd.data)
+		}
+		result.AudioCodecs = append(result.AudioCodecs, codec)
+		result.AudioLanguages = append(result.AudioLanguages, language)
+	}
+
+	return nil
+}
+
+// readLanguage decodes ISO 639-2 language code packed in mdhd box (3 letters of 5 bits)
+func readLanguage(mdhd []byte) string {
+	offset := 20
+	if len(mdhd) > 0 && mdhd[0] == 1 {
+		offset = 32
+	}
+	if len(mdhd) < offset+2 {
+		return "und"
+	}
+
+	packed := binary.BigEndian.Uint16(mdhd[offset:])
+	language := []byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	}
+	for _, c := range language {
+		if c < 'a' || c > 'z' {
+			return "und"
+		}
+	}
+
+	return string(language)
+}
@@ -0,0 +1,132 @@
+// Package probe reads properties of video files (duration, resolution, codecs and audio languages) from their container.
+// Matroska (mkv, webm) and MP4 (mp4, m4v, mov) containers are supported
+package probe
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CONTAINER_MATROSKA = "matroska"
+	CONTAINER_MP4      = "mp4"
+)
+
+var (
+	// ErrUnsupported is returned for containers that cannot be probed
+	ErrUnsupported = errors.New("unsupported container format")
+	// ErrFormat is returned when container data is invalid, or does not match file extension
+	ErrFormat = errors.New("invalid container data")
+)
+
+// Result contains properties of a probed video file. Codecs use short names (h264, hevc, aac, ac3, ...) and languages are ISO 639-2 codes
+type Result struct {
+	Container      string
+	Duration       time.Duration
+	Width          int
+	Height         int
+	VideoCodec     string
+	AudioCodecs    []string
+	AudioLanguages []string
+}
+
+var matroskaSignature = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// Extensions of containers that must be recognized when probing a file
+var probedExtensions = []string{".mkv", ".mk3d", ".webm", ".mp4", ".m4v", ".mov"}
+
+// File probes video file located at path. Container format is detected from file content
+func File(path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return Result{}, errorForExtension(path)
+	}
+	header = header[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Result{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, matroskaSignature):
+		return probeMatroska(f)
+	case len(header) >= 8 && (string(header[4:8]) == "ftyp" || string(header[4:8]) == "moov"):
+		return probeMP4(f)
+	default:
+		return Result{}, errorForExtension(path)
+	}
+}
+
+// errorForExtension returns the error reported for files whose content is not recognized: files using the extension of a supported container are invalid
+func errorForExtension(path string) error {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, probedExtension := range probedExtensions {
+		if extension == probedExtension {
+			return ErrFormat
+		}
+	}
+
+	return ErrUnsupported
+}
+
+var codecNames = []struct {
+	Prefix string
+	Name   string
+}{
+	{"V_MPEG4/ISO/AVC", "h264"},
+	{"V_MPEGH/ISO/HEVC", "hevc"},
+	{"V_MPEG4/ISO", "mpeg4"},
+	{"V_MPEG2", "mpeg2"},
+	{"V_AV1", "av1"},
+	{"V_VP9", "vp9"},
+	{"V_VP8", "vp8"},
+	{"A_AAC", "aac"},
+	{"A_AC3", "ac3"},
+	{"A_EAC3", "eac3"},
+	{"A_DTS", "dts"},
+	{"A_TRUEHD", "truehd"},
+	{"A_FLAC", "flac"},
+	{"A_OPUS", "opus"},
+	{"A_VORBIS", "vorbis"},
+	{"A_MPEG/L3", "mp3"},
+	{"A_MPEG/L2", "mp2"},
+	{"avc1", "h264"},
+	{"avc3", "h264"},
+	{"hev1", "hevc"},
+	{"hvc1", "hevc"},
+	{"av01", "av1"},
+	{"vp09", "vp9"},
+	{"mp4v", "mpeg4"},
+	{"mp4a", "aac"},
+	{"ac-3", "ac3"},
+	{"ec-3", "eac3"},
+	{"dtsc", "dts"},
+	{"dtsh", "dts"},
+	{"dtsl", "dts"},
+	{"fLaC", "flac"},
+	{"Opus", "opus"},
+	{".mp3", "mp3"},
+}
+
+// codecName returns short name of codec identified by container codec id
+func codecName(id string) string {
+	for _, codec := range codecNames {
+		if strings.HasPrefix(id, codec.Prefix) {
+			return codec.Name
+		}
+	}
+
+	return strings.ToLower(strings.TrimSpace(id))
+}
@@ -0,0 +1,211 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ebmlElement(id uint64, data ...[]byte) []byte {
+	var idBytes []byte
+	for shift := uint(24); ; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(idBytes) > 0 {
+			idBytes = append(idBytes, b)
+		}
+		if shift == 0 {
+			break
+		}
+	}
+
+	content := bytes.Join(data, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(content)))
+	size[0] = 0x01
+
+	return append(append(idBytes, size...), content...)
+}
+
+func ebmlUint(id uint64, value uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	return ebmlElement(id, data)
+}
+
+func ebmlFloat(id uint64, value float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return ebmlElement(id, data)
+}
+
+func ebmlUnknownSize(id uint64, data ...[]byte) []byte {
+	element := ebmlElement(id)
+	copy(element[len(element)-8:], []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	return append(element, bytes.Join(data, nil)...)
+}
+
+func matroskaFile() []byte {
+	return bytes.Join([][]byte{
+		ebmlElement(idEBML, ebmlElement(0x4282, []byte("matroska"))),
+		ebmlUnknownSize(idSegment,
+			ebmlElement(0x114d9b74, make([]byte, 32)),
+			ebmlElement(idInfo,
+				ebmlUint(idTimecodeScale, 1000000),
+				ebmlFloat(idDuration, 5400000),
+			),
+			ebmlElement(idTracks,
+				ebmlElement(idTrackEntry,
+					ebmlUint(idTrackType, trackTypeVideo),
+					ebmlElement(idCodecID, []byte("V_MPEGH/ISO/HEVC")),
+					ebmlElement(idVideo,
+						ebmlUint(idPixelWidth, 1920),
+						ebmlUint(idPixelHeight, 800),
+					),
+				),
+				ebmlElement(idTrackEntry,
+					ebmlUint(idTrackType, trackTypeAudio),
+					ebmlElement(idCodecID, []byte("A_EAC3")),
+					ebmlElement(idLanguage, []byte("fre\x00")),
+				),
+				ebmlElement(idTrackEntry,
+					ebmlUint(idTrackType, trackTypeAudio),
+					ebmlElement(idCodecID, []byte("A_AC3")),
+				),
+			),
+			ebmlUnknownSize(idCluster, make([]byte, 64)),
+		),
+	}, nil)
+}
+
+func mp4Box(kind string, data ...[]byte) []byte {
+	content := bytes.Join(data, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(content)+8))
+	copy(header[4:], kind)
+	return append(header, content...)
+}
+
+func mp4Track(handler string, codec string, language string, width int, height int) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], uint32(width)<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], uint32(height)<<16)
+
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint16(mdhd[20:], uint16(language[0]-0x60)<<10|uint16(language[1]-0x60)<<5|uint16(language[2]-0x60))
+
+	hdlr := append(make([]byte, 8), []byte(handler)...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+
+	stsd := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	stsd = append(stsd, mp4Box(codec, make([]byte, 78))...)
+
+	return mp4Box("trak",
+		mp4Box("tkhd", tkhd),
+		mp4Box("mdia",
+			mp4Box("mdhd", mdhd),
+			mp4Box("hdlr", hdlr),
+			mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd))),
+		),
+	)
+}
+
+func mp4File() []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 2700000)
+
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomavc1")),
+		mp4Box("mdat", make([]byte, 1024)),
+		mp4Box("moov",
+			mp4Box("mvhd", mvhd),
+			mp4Track("vide", "avc1", "und", 1280, 720),
+			mp4Track("soun", "mp4a", "ger", 0, 0),
+		),
+	}, nil)
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "flemzerd_probe")
+	defer os.RemoveAll(dir)
+
+	testData := []struct {
+		Name    string
+		Content []byte
+		Result  Result
+	}{
+		{
+			"movie.mkv",
+			matroskaFile(),
+			Result{
+				Container:      CONTAINER_MATROSKA,
+				Duration:       90 * time.Minute,
+				Width:          1920,
+				Height:         800,
+				VideoCodec:     "hevc",
+				AudioCodecs:    []string{"eac3", "ac3"},
+				AudioLanguages: []string{"fre", "eng"},
+			},
+		},
+		{
+			"episode.mp4",
+			mp4File(),
+			Result{
+				Container:      CONTAINER_MP4,
+				Duration:       45 * time.Minute,
+				Width:          1280,
+				Height:         720,
+				VideoCodec:     "h264",
+				AudioCodecs:    []string{"aac"},
+				AudioLanguages: []string{"ger"},
+			},
+		},
+	}
+
+	for _, data := range testData {
+		result, err := File(writeTestFile(t, dir, data.Name, data.Content))
+		if err != nil {
+			t.Errorf("Expected %s to be probed, got error: %s", data.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, data.Result) {
+			t.Errorf("Expected probe result of %s to be %+v, got %+v", data.Name, data.Result, result)
+		}
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "flemzerd_probe")
+	defer os.RemoveAll(dir)
+
+	testData := []struct {
+		Name    string
+		Content []byte
+		Err     error
+	}{
+		{"fake.mkv", []byte("MZ\x90\x00 this is an executable"), ErrFormat},
+		{"fake.mp4", []byte{}, ErrFormat},
+		{"truncated.mkv", matroskaFile()[:60], ErrFormat},
+		{"no_moov.mp4", mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")), ErrFormat},
+		{"movie.avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), ErrUnsupported},
+	}
+
+	for _, data := range testData {
+		if _, err := File(writeTestFile(t, dir, data.Name, data.Content)); err != data.Err {
+			t.Errorf("Expected error %v when probing %s, got %v", data.Err, data.Name, err)
+		}
+	}
+}
@@ -269,7 +269,11 @@ func GetSeasonEpisodeList(show TvShow, seasonNumber int) ([]Episode, error) {
 		var retList []Episode
 		for _, ep := range episodes {
 			var epFromDb Episode
-			req := db.Client.Where(ep).Find(&epFromDb)
+			req := db.Client.Where(Episode{
+				TvShowID: show.ID,
+				Season:   ep.Season,
+				Number:   ep.Number,
+			}).Find(&epFromDb)
 			if req.RecordNotFound() {
 				ep.TvShow = show
 				db.Client.Create(&ep)
@@ -510,9 +514,35 @@ func mergeRecentShowProperties(currentShow *TvShow, updatedShow *TvShow) {
 	currentShow.NumberOfEpisodes = updatedShow.NumberOfEpisodes
 	currentShow.FirstAired = updatedShow.FirstAired
 	currentShow.Status = updatedShow.Status
+	if updatedShow.Runtime != 0 {
+		currentShow.Runtime = updatedShow.Runtime
+	}
 	if !currentShow.IsCached(currentShow.Poster) {
 		currentShow.Poster = updatedShow.Poster
 	}
+	mergeSeasons(currentShow, updatedShow.Seasons)
+}
+
+// mergeSeasons adds seasons released since last refresh to show and refreshes known seasons. Monitored state of known seasons is kept
+func mergeSeasons(show *TvShow, seasons []TvSeason) {
+	for _, updatedSeason := range seasons {
+		found := false
+		for i := range show.Seasons {
+			if show.Seasons[i].SeasonNumber != updatedSeason.SeasonNumber {
+				continue
+			}
+			found = true
+			show.Seasons[i].AirDate = updatedSeason.AirDate
+			show.Seasons[i].EpisodeCount = updatedSeason.EpisodeCount
+			show.Seasons[i].PosterPath = updatedSeason.PosterPath
+			break
+		}
+		if !found {
+			updatedSeason.ID = 0
+			updatedSeason.Monitored = true
+			show.Seasons = append(show.Seasons, updatedSeason)
+		}
+	}
 }
 
 // Updates currentMovie (saved in DB) with updates retrieved from Provider
@@ -520,6 +550,9 @@ func mergeRecentMovieProperties(currentMovie *Movie, updatedMovie *Movie) {
 	currentMovie.Title = updatedMovie.Title
 	currentMovie.OriginalTitle = updatedMovie.OriginalTitle
 	currentMovie.Overview = updatedMovie.Overview
+	if updatedMovie.Runtime != 0 {
+		currentMovie.Runtime = updatedMovie.Runtime
+	}
 	if !currentMovie.IsCached(currentMovie.Poster) {
 		currentMovie.Poster = updatedMovie.Poster
 	}
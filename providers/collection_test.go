@@ -332,6 +332,45 @@ func TestMergeMediaIds(t *testing.T) {
 	}
 }
 
+func TestMergeRecentShowProperties(t *testing.T) {
+	currentShow := TvShow{
+		Title: "Test show",
+		Seasons: []TvSeason{
+			TvSeason{
+				SeasonNumber: 1,
+				EpisodeCount: 10,
+				Monitored:    false,
+			},
+		},
+	}
+	updatedShow := TvShow{
+		Title:           "Test show",
+		NumberOfSeasons: 2,
+		Seasons: []TvSeason{
+			TvSeason{
+				SeasonNumber: 1,
+				EpisodeCount: 12,
+			},
+			TvSeason{
+				SeasonNumber: 2,
+				EpisodeCount: 8,
+			},
+		},
+	}
+
+	mergeRecentShowProperties(&currentShow, &updatedShow)
+
+	if len(currentShow.Seasons) != 2 {
+		t.Fatalf("Expected new season to be added to show, got %d seasons", len(currentShow.Seasons))
+	}
+	if currentShow.Seasons[0].EpisodeCount != 12 || currentShow.Seasons[0].Monitored {
+		t.Error("Expected known season to be refreshed and to keep its monitored state")
+	}
+	if currentShow.Seasons[1].SeasonNumber != 2 || !currentShow.Seasons[1].Monitored {
+		t.Error("Expected new season to be added as monitored")
+	}
+}
+
 func TestGetSpecificProvider(t *testing.T) {
 	p := mock.TVProvider{}
 	providersCollection = []Provider{p}
@@ -307,6 +307,11 @@ func convertShow(tvShow tmdb.TV) TvShow {
 		return seasons[i].SeasonNumber < seasons[j].SeasonNumber
 	})
 
+	runtime := 0
+	if len(tvShow.EpisodeRunTime) > 0 {
+		runtime = tvShow.EpisodeRunTime[0]
+	}
+
 	isAnime := false
 	for _, genre := range tvShow.Genres {
 		if genre.ID == 16 {
@@ -329,6 +334,7 @@ func convertShow(tvShow tmdb.TV) TvShow {
 		},
 		UseDefaultTitle: true,
 		IsAnime:         isAnime,
+		Runtime:         runtime,
 	}
 }
 
@@ -360,6 +366,7 @@ func convertMovie(movie tmdb.Movie) Movie {
 		Overview:        movie.Overview,
 		Date:            releaseDate,
 		UseDefaultTitle: true,
+		Runtime:         int(movie.Runtime),
 	}
 }
 
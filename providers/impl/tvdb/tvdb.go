@@ -312,10 +312,13 @@ func convertShow(tvShow tvdb.Series) TvShow {
 		firstAired = time.Time{}
 	}
 
+	runtime, _ := strconv.Atoi(tvShow.Runtime)
+
 	return TvShow{
 		Banner:          tvShow.Banner,
 		FirstAired:      firstAired,
 		Overview:        tvShow.Overview,
+		Runtime:         runtime,
 		Title:           tvShow.SeriesName,
 		UseDefaultTitle: true,
 		// TODO: Update with correct status
@@ -0,0 +1,312 @@
+package rar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var signature4 = []byte("Rar!\x1a\x07\x00")
+var signature5 = []byte("Rar!\x1a\x07\x01\x00")
+
+// Maximum header size allowed by RAR 5 format
+const maxHeaderSize5 = 2 * 1024 * 1024
+
+type blockKind int
+
+const (
+	blockOther blockKind = iota
+	blockMain
+	blockFile
+	blockEncryption
+	blockEnd
+)
+
+// block contains fields of an archive header needed for extraction. Block data (dataSize bytes) follows the header
+type block struct {
+	kind     blockKind
+	dataSize int64
+	// File blocks
+	name        string
+	size        int64
+	crc         uint32
+	hasCRC      bool
+	dir         bool
+	stored      bool
+	encrypted   bool
+	splitBefore bool
+	splitAfter  bool
+	// Main archive blocks
+	encryptedHeaders bool
+	// End of archive blocks
+	nextVolume bool
+}
+
+// readSignature reads archive signature and returns archive format version (4 or 5)
+func readSignature(r *bufio.Reader) (int, error) {
+	header, err := r.Peek(len(signature5))
+	if err != nil && len(header) < len(signature4) {
+		return 0, ErrFormat
+	}
+
+	switch {
+	case bytes.HasPrefix(header, signature4):
+		_, err = r.Discard(len(signature4))
+		return 4, err
+	case bytes.Equal(header, signature5):
+		_, err = r.Discard(len(signature5))
+		return 5, err
+	default:
+		return 0, ErrFormat
+	}
+}
+
+// readBlock reads next archive header. io.EOF is returned at the end of the volume
+func readBlock(r *bufio.Reader, version int) (block, error) {
+	if version == 5 {
+		return readBlock5(r)
+	}
+
+	return readBlock4(r)
+}
+
+func readBlock4(r *bufio.Reader) (block, error) {
+	var b block
+
+	base := make([]byte, 7)
+	if _, err := io.ReadFull(r, base); err != nil {
+		if err == io.EOF {
+			return b, err
+		}
+		return b, ErrCorrupt
+	}
+
+	headerType := base[2]
+	flags := binary.LittleEndian.Uint16(base[3:5])
+	size := int(binary.LittleEndian.Uint16(base[5:7]))
+	if size < len(base) {
+		return b, ErrCorrupt
+	}
+
+	header := make([]byte, size)
+	copy(header, base)
+	if _, err := io.ReadFull(r, header[len(base):]); err != nil {
+		return b, ErrCorrupt
+	}
+	if uint16(crc32.ChecksumIEEE(header[2:])) != binary.LittleEndian.Uint16(header[0:2]) {
+		return b, ErrCorrupt
+	}
+
+	fields := header[len(base):]
+	switch headerType {
+	case 0x73:
+		b.kind = blockMain
+		b.encryptedHeaders = flags&0x0080 != 0
+	case 0x74:
+		if len(fields) < 25 {
+			return b, ErrCorrupt
+		}
+
+		packSize := int64(binary.LittleEndian.Uint32(fields[0:4]))
+		unpackedSize := int64(binary.LittleEndian.Uint32(fields[4:8]))
+		nameSize := int(binary.LittleEndian.Uint16(fields[19:21]))
+		offset := 25
+		// Files larger than 4GB
+		if flags&0x0100 != 0 {
+			if len(fields) < 33 {
+				return b, ErrCorrupt
+			}
+			packSize |= int64(binary.LittleEndian.Uint32(fields[25:29])) << 32
+			unpackedSize |= int64(binary.LittleEndian.Uint32(fields[29:33])) << 32
+			offset = 33
+		}
+		if len(fields) < offset+nameSize {
+			return b, ErrCorrupt
+		}
+
+		b.kind = blockFile
+		b.dataSize = packSize
+		b.size = unpackedSize
+		b.crc = binary.LittleEndian.Uint32(fields[9:13])
+		b.hasCRC = true
+		b.stored = fields[18] == 0x30
+		b.name = decodeName4(fields[offset:offset+nameSize], flags&0x0200 != 0)
+		b.dir = flags&0x00e0 == 0x00e0
+		b.encrypted = flags&0x0004 != 0
+		b.splitBefore = flags&0x0001 != 0
+		b.splitAfter = flags&0x0002 != 0
+		return b, nil
+	case 0x7b:
+		b.kind = blockEnd
+		b.nextVolume = flags&0x0001 != 0
+	}
+
+	// Other blocks are followed by data when long block flag is set
+	if flags&0x8000 != 0 {
+		if len(fields) < 4 {
+			return b, ErrCorrupt
+		}
+		b.dataSize = int64(binary.LittleEndian.Uint32(fields[0:4]))
+	}
+
+	return b, nil
+}
+
+// decodeName4 decodes RAR 4 file names. Unicode names are stored after an ASCII version of the name, which is used
+func decodeName4(name []byte, unicode bool) string {
+	if unicode {
+		if index := bytes.IndexByte(name, 0); index >= 0 {
+			name = name[:index]
+		}
+	}
+
+	return strings.Replace(string(name), "\\", "/", -1)
+}
+
+func readBlock5(r *bufio.Reader) (block, error) {
+	var b block
+
+	crc := make([]byte, 4)
+	if _, err := io.ReadFull(r, crc); err != nil {
+		if err == io.EOF {
+			return b, err
+		}
+		return b, ErrCorrupt
+	}
+
+	// Header size is a variable length integer, included in header checksum
+	var sizeBytes []byte
+	var size uint64
+	for i := uint(0); ; i++ {
+		c, err := r.ReadByte()
+		if err != nil || i > 2 {
+			return b, ErrCorrupt
+		}
+		sizeBytes = append(sizeBytes, c)
+		size |= uint64(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	if size == 0 || size > maxHeaderSize5 {
+		return b, ErrCorrupt
+	}
+
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return b, ErrCorrupt
+	}
+	if crc32.ChecksumIEEE(append(sizeBytes, header...)) != binary.LittleEndian.Uint32(crc) {
+		return b, ErrCorrupt
+	}
+
+	f := fieldReader{data: header}
+	headerType := f.vint()
+	flags := f.vint()
+	var extraSize uint64
+	if flags&0x0001 != 0 {
+		extraSize = f.vint()
+	}
+	if flags&0x0002 != 0 {
+		b.dataSize = int64(f.vint())
+	}
+
+	switch headerType {
+	case 1:
+		b.kind = blockMain
+	case 2:
+		fileFlags := f.vint()
+		b.size = int64(f.vint())
+		// Attributes
+		f.vint()
+		if fileFlags&0x0002 != 0 {
+			f.bytes(4)
+		}
+		if fileFlags&0x0004 != 0 {
+			b.crc = f.uint32()
+			b.hasCRC = true
+		}
+		compression := f.vint()
+		// Host OS
+		f.vint()
+		b.name = string(f.bytes(f.vint()))
+
+		b.kind = blockFile
+		b.dir = fileFlags&0x0001 != 0
+		b.stored = (compression>>7)&0x07 == 0
+		b.splitBefore = flags&0x0008 != 0
+		b.splitAfter = flags&0x0010 != 0
+		if extraSize > 0 && extraSize <= size {
+			b.encrypted = hasExtraRecord5(header[size-extraSize:], 0x01)
+		}
+	case 4:
+		b.kind = blockEncryption
+	case 5:
+		b.kind = blockEnd
+		b.nextVolume = f.vint()&0x0001 != 0
+	}
+
+	if f.err != nil {
+		return b, ErrCorrupt
+	}
+
+	return b, nil
+}
+
+// hasExtraRecord5 returns true if extra area of a RAR 5 header contains a record of type recordType
+func hasExtraRecord5(extra []byte, recordType uint64) bool {
+	f := fieldReader{data: extra}
+	for len(f.data) > 0 && f.err == nil {
+		record := fieldReader{data: f.bytes(f.vint())}
+		if record.vint() == recordType && record.err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldReader reads RAR 5 header fields. Reading past the end of the header sets err
+type fieldReader struct {
+	data []byte
+	err  error
+}
+
+func (f *fieldReader) vint() uint64 {
+	var value uint64
+	for i := uint(0); i < 10 && len(f.data) > 0; i++ {
+		c := f.data[0]
+		f.data = f.data[1:]
+		value |= uint64(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return value
+		}
+	}
+
+	f.err = ErrCorrupt
+	return 0
+}
+
+func (f *fieldReader) bytes(n uint64) []byte {
+	if uint64(len(f.data)) < n {
+		f.err = ErrCorrupt
+		f.data = nil
+		return nil
+	}
+
+	value := f.data[:n]
+	f.data = f.data[n:]
+	return value
+}
+
+func (f *fieldReader) uint32() uint32 {
+	value := f.bytes(4)
+	if value == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(value)
+}
@@ -0,0 +1,240 @@
+// Package rar extracts files from RAR archives (RAR 4 and RAR 5 formats), split in several volumes or not.
+// Only stored entries can be extracted: video releases are archived without compression, and compressed entries are reported with ErrUnsupportedMethod
+package rar
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrFormat            = errors.New("not a RAR archive")
+	ErrCorrupt           = errors.New("corrupted RAR archive")
+	ErrChecksum          = errors.New("checksum mismatch in extracted file")
+	ErrEncrypted         = errors.New("encrypted RAR archives are not supported")
+	ErrUnsupportedMethod = errors.New("compressed RAR entries are not supported")
+	ErrMissingVolume     = errors.New("missing RAR volume")
+)
+
+var partVolumeRegexp = regexp.MustCompile(`(?i)\.part(\d+)\.rar$`)
+var oldVolumeRegexp = regexp.MustCompile(`(?i)\.([rs])(\d\d)$`)
+
+// IsVolume returns true if name is the name of a RAR volume (name.rar, name.partN.rar, name.rNN, name.sNN)
+func IsVolume(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".rar") || oldVolumeRegexp.MatchString(name)
+}
+
+// IsFirstVolume returns true if name is the name of the first volume of a RAR archive (name.rar or name.part1.rar)
+func IsFirstVolume(name string) bool {
+	if match := partVolumeRegexp.FindStringSubmatch(name); match != nil {
+		number, _ := strconv.Atoi(match[1])
+		return number == 1
+	}
+
+	return strings.EqualFold(filepath.Ext(name), ".rar")
+}
+
+// nextVolume returns the path of the volume following volume path. Both naming schemes are supported: name.part1.rar, name.part2.rar, ... and name.rar, name.r00, ..., name.r99, name.s00, ...
+func nextVolume(path string) string {
+	if match := partVolumeRegexp.FindStringSubmatchIndex(path); match != nil {
+		number, _ := strconv.Atoi(path[match[2]:match[3]])
+		return fmt.Sprintf("%s%0*d%s", path[:match[2]], match[3]-match[2], number+1, path[match[3]:])
+	}
+
+	if match := oldVolumeRegexp.FindStringSubmatch(path); match != nil {
+		prefix := path[:len(path)-len(match[0])]
+		number, _ := strconv.Atoi(match[2])
+		if number < 99 {
+			return fmt.Sprintf("%s.%s%02d", prefix, match[1], number+1)
+		}
+		next := "s"
+		if match[1] == "R" || match[1] == "S" {
+			next = "S"
+		}
+		return fmt.Sprintf("%s.%s00", prefix, next)
+	}
+
+	extension := filepath.Ext(path)
+	if extension == ".RAR" {
+		return strings.TrimSuffix(path, extension) + ".R00"
+	}
+	return strings.TrimSuffix(path, extension) + ".r00"
+}
+
+// Volumes returns paths of the existing volumes of the archive whose first volume is located at path
+func Volumes(path string) []string {
+	var volumes []string
+	for volume := path; ; volume = nextVolume(volume) {
+		if _, err := os.Stat(volume); err != nil {
+			return volumes
+		}
+		volumes = append(volumes, volume)
+	}
+}
+
+// Extract extracts files of the archive whose first volume is located at path into destination folder, and returns paths of extracted files.
+// Following volumes are searched for in the folder of the first volume. Extracted files are removed if extraction fails
+func Extract(path string, destination string) ([]string, error) {
+	e := extractor{destination: destination}
+
+	volume := path
+	for {
+		more, err := e.extractVolume(volume)
+		if err != nil {
+			e.abort()
+			return nil, errors.Wrap(err, filepath.Base(volume))
+		}
+		if !more {
+			break
+		}
+
+		volume = nextVolume(volume)
+		if _, err := os.Stat(volume); err != nil {
+			e.abort()
+			return nil, errors.Wrap(ErrMissingVolume, filepath.Base(volume))
+		}
+	}
+
+	return e.extracted, nil
+}
+
+type extractor struct {
+	destination string
+	extracted   []string
+	// File being extracted, split across volumes
+	file     *os.File
+	entry    block
+	checksum hash.Hash32
+	written  int64
+}
+
+// extractVolume extracts files contained in volume path. Returns true if files continue in next volume
+func (e *extractor) extractVolume(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	version, err := readSignature(r)
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		b, err := readBlock(r, version)
+		if err == io.EOF {
+			// Archives without end of archive block
+			return e.file != nil, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		switch b.kind {
+		case blockEncryption:
+			return false, ErrEncrypted
+		case blockMain:
+			if b.encryptedHeaders {
+				return false, ErrEncrypted
+			}
+		case blockEnd:
+			return b.nextVolume || e.file != nil, nil
+		case blockFile:
+			if err := e.extractEntry(r, b); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		if _, err := io.CopyN(ioutil.Discard, r, b.dataSize); err != nil {
+			return false, ErrCorrupt
+		}
+	}
+}
+
+// extractEntry writes data of file block b read from r. Extracted files are checked against unpacked size and checksum once their last part is written
+func (e *extractor) extractEntry(r io.Reader, b block) error {
+	if b.splitBefore {
+		if e.file == nil || e.entry.name != b.name {
+			return errors.Wrap(ErrCorrupt, "file continued from a missing volume")
+		}
+	} else {
+		if e.file != nil {
+			return errors.Wrap(ErrCorrupt, "file not continued in next volume")
+		}
+		if b.encrypted {
+			return ErrEncrypted
+		}
+
+		target := filepath.Join(e.destination, filepath.Clean("/"+b.name))
+		if b.dir {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			_, err := io.CopyN(ioutil.Discard, r, b.dataSize)
+			return err
+		}
+		if !b.stored {
+			return errors.Wrap(ErrUnsupportedMethod, b.name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+
+		e.file = file
+		e.entry = b
+		e.checksum = crc32.NewIEEE()
+		e.written = 0
+		e.extracted = append(e.extracted, target)
+	}
+
+	written, err := io.CopyN(io.MultiWriter(e.file, e.checksum), r, b.dataSize)
+	e.written += written
+	if err != nil {
+		return errors.Wrap(ErrCorrupt, "truncated file data")
+	}
+	if b.splitAfter {
+		return nil
+	}
+
+	err = e.file.Close()
+	e.file = nil
+	if err != nil {
+		return err
+	}
+	if e.written != b.size || (b.hasCRC && e.checksum.Sum32() != b.crc) {
+		return errors.Wrap(ErrChecksum, b.name)
+	}
+
+	return nil
+}
+
+// abort removes files extracted by a failed extraction
+func (e *extractor) abort() {
+	if e.file != nil {
+		e.file.Close()
+		e.file = nil
+	}
+	for _, path := range e.extracted {
+		os.Remove(path)
+	}
+	e.extracted = nil
+}
@@ -0,0 +1,329 @@
+package rar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type testEntry struct {
+	name       string
+	content    []byte
+	compressed bool
+}
+
+func vint(value uint64) []byte {
+	var b []byte
+	for value >= 0x80 {
+		b = append(b, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(b, byte(value))
+}
+
+func block4(headerType byte, flags uint16, fields []byte) []byte {
+	header := make([]byte, 7, 7+len(fields))
+	header[2] = headerType
+	binary.LittleEndian.PutUint16(header[3:5], flags)
+	binary.LittleEndian.PutUint16(header[5:7], uint16(7+len(fields)))
+	header = append(header, fields...)
+	binary.LittleEndian.PutUint16(header[0:2], uint16(crc32.ChecksumIEEE(header[2:])))
+	return header
+}
+
+func block5(fields []byte) []byte {
+	header := append(vint(uint64(len(fields))), fields...)
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(header))
+	return append(crc, header...)
+}
+
+func mainHeader(version int, volume int) []byte {
+	if version == 4 {
+		var flags uint16 = 0x0001 | 0x0010
+		if volume == 0 {
+			flags |= 0x0100
+		}
+		return block4(0x73, flags, make([]byte, 6))
+	}
+
+	fields := append(vint(1), vint(0)...)
+	if volume == 0 {
+		fields = append(fields, vint(0x0001)...)
+	} else {
+		fields = append(fields, vint(0x0003)...)
+		fields = append(fields, vint(uint64(volume))...)
+	}
+	return block5(fields)
+}
+
+func fileHeader(version int, entry testEntry, partSize int, checksum uint32, splitBefore bool, splitAfter bool) []byte {
+	if version == 4 {
+		var flags uint16 = 0x8000
+		if splitBefore {
+			flags |= 0x0001
+		}
+		if splitAfter {
+			flags |= 0x0002
+		}
+		var method byte = 0x30
+		if entry.compressed {
+			method = 0x33
+		}
+
+		fields := make([]byte, 25)
+		binary.LittleEndian.PutUint32(fields[0:4], uint32(partSize))
+		binary.LittleEndian.PutUint32(fields[4:8], uint32(len(entry.content)))
+		fields[8] = 3
+		binary.LittleEndian.PutUint32(fields[9:13], checksum)
+		fields[17] = 29
+		fields[18] = method
+		binary.LittleEndian.PutUint16(fields[19:21], uint16(len(entry.name)))
+		fields = append(fields, []byte(entry.name)...)
+		return block4(0x74, flags, fields)
+	}
+
+	var flags uint64 = 0x0002
+	if splitBefore {
+		flags |= 0x0008
+	}
+	if splitAfter {
+		flags |= 0x0010
+	}
+	var compression uint64
+	if entry.compressed {
+		compression = 3 << 7
+	}
+
+	fields := append(vint(2), vint(flags)...)
+	fields = append(fields, vint(uint64(partSize))...)
+	fields = append(fields, vint(0x0004)...)
+	fields = append(fields, vint(uint64(len(entry.content)))...)
+	fields = append(fields, vint(0644)...)
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, checksum)
+	fields = append(fields, crc...)
+	fields = append(fields, vint(compression)...)
+	fields = append(fields, vint(1)...)
+	fields = append(fields, vint(uint64(len(entry.name)))...)
+	fields = append(fields, []byte(entry.name)...)
+	return block5(fields)
+}
+
+func endHeader(version int, nextVolume bool) []byte {
+	if version == 4 {
+		var flags uint16 = 0x4000
+		if nextVolume {
+			flags |= 0x0001
+		}
+		return block4(0x7b, flags, nil)
+	}
+
+	var flags uint64
+	if nextVolume {
+		flags = 0x0001
+	}
+	return block5(append(append(vint(5), vint(0)...), vint(flags)...))
+}
+
+// buildArchive returns volumes of a stored archive containing entries. Volumes contain at most volumeSize bytes of file data (no limit if volumeSize is 0)
+func buildArchive(version int, entries []testEntry, volumeSize int) [][]byte {
+	signature := signature4
+	if version == 5 {
+		signature = signature5
+	}
+
+	var volumes [][]byte
+	current := bytes.NewBuffer(append([]byte{}, signature...))
+	current.Write(mainHeader(version, 0))
+	used := 0
+
+	for _, entry := range entries {
+		data := entry.content
+		splitBefore := false
+		for {
+			size := len(data)
+			if volumeSize > 0 && size > volumeSize-used {
+				size = volumeSize - used
+			}
+			part := data[:size]
+			data = data[size:]
+			splitAfter := len(data) > 0
+
+			checksum := crc32.ChecksumIEEE(entry.content)
+			if splitAfter {
+				checksum = crc32.ChecksumIEEE(part)
+			}
+			current.Write(fileHeader(version, entry, len(part), checksum, splitBefore, splitAfter))
+			current.Write(part)
+			used += len(part)
+
+			if !splitAfter {
+				break
+			}
+
+			current.Write(endHeader(version, true))
+			volumes = append(volumes, current.Bytes())
+			current = bytes.NewBuffer(append([]byte{}, signature...))
+			current.Write(mainHeader(version, len(volumes)))
+			used = 0
+			splitBefore = true
+		}
+	}
+
+	current.Write(endHeader(version, false))
+	return append(volumes, current.Bytes())
+}
+
+// writeArchive writes volumes of archive in dir, starting with firstVolume name, and returns path of first volume
+func writeArchive(t *testing.T, dir string, firstVolume string, volumes [][]byte) string {
+	path := filepath.Join(dir, firstVolume)
+	volume := path
+	for _, content := range volumes {
+		if err := ioutil.WriteFile(volume, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		volume = nextVolume(volume)
+	}
+
+	return path
+}
+
+func TestVolumeNames(t *testing.T) {
+	testData := []struct {
+		Name       string
+		IsVolume   bool
+		IsFirst    bool
+		NextVolume string
+	}{
+		{"release.rar", true, true, "release.r00"},
+		{"release.r00", true, false, "release.r01"},
+		{"release.r99", true, false, "release.s00"},
+		{"RELEASE.RAR", true, true, "RELEASE.R00"},
+		{"release.part1.rar", true, true, "release.part2.rar"},
+		{"release.part01.rar", true, true, "release.part02.rar"},
+		{"release.part09.rar", true, false, "release.part10.rar"},
+		{"release.mkv", false, false, ""},
+		{"release.nfo", false, false, ""},
+	}
+
+	for _, data := range testData {
+		if IsVolume(data.Name) != data.IsVolume {
+			t.Errorf("Expected IsVolume(%s) to be %t", data.Name, data.IsVolume)
+		}
+		if IsFirstVolume(data.Name) != data.IsFirst {
+			t.Errorf("Expected IsFirstVolume(%s) to be %t", data.Name, data.IsFirst)
+		}
+		if data.IsVolume && nextVolume(data.Name) != data.NextVolume {
+			t.Errorf("Expected next volume of %s to be %s, got %s", data.Name, data.NextVolume, nextVolume(data.Name))
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	movie := bytes.Repeat([]byte("movie data "), 100)
+	subtitle := []byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n")
+
+	testData := []struct {
+		Version     int
+		FirstVolume string
+		VolumeSize  int
+		Volumes     int
+	}{
+		{4, "release.rar", 0, 1},
+		{5, "release.rar", 0, 1},
+		{4, "release.rar", 400, 3},
+		{5, "release.part01.rar", 400, 3},
+	}
+
+	for _, data := range testData {
+		t.Run(fmt.Sprintf("rar%d_%d_volumes", data.Version, data.Volumes), func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "flemzerd_rar")
+			defer os.RemoveAll(dir)
+
+			entries := []testEntry{
+				{name: "movie.mkv", content: movie},
+				{name: "Subs/movie.en.srt", content: subtitle},
+			}
+			volumes := buildArchive(data.Version, entries, data.VolumeSize)
+			if len(volumes) != data.Volumes {
+				t.Fatalf("Expected test archive to contain %d volumes, got %d", data.Volumes, len(volumes))
+			}
+			path := writeArchive(t, dir, data.FirstVolume, volumes)
+
+			if len(Volumes(path)) != data.Volumes {
+				t.Errorf("Expected %d volumes to be found, got %d", data.Volumes, len(Volumes(path)))
+			}
+
+			destination := filepath.Join(dir, "extracted")
+			files, err := Extract(path, destination)
+			if err != nil {
+				t.Fatal("Expected archive to be extracted, got error: ", err)
+			}
+			if len(files) != 2 {
+				t.Fatalf("Expected 2 extracted files, got %d", len(files))
+			}
+
+			for _, entry := range entries {
+				content, err := ioutil.ReadFile(filepath.Join(destination, entry.name))
+				if err != nil {
+					t.Errorf("Expected %s to be extracted", entry.name)
+					continue
+				}
+				if !bytes.Equal(content, entry.content) {
+					t.Errorf("Extracted content of %s does not match archived content", entry.name)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "flemzerd_rar")
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("movie data "), 100)
+
+	compressed := writeArchive(t, dir, "compressed.rar", buildArchive(5, []testEntry{{name: "movie.mkv", content: content, compressed: true}}, 0))
+	if _, err := Extract(compressed, filepath.Join(dir, "compressed")); errors.Cause(err) != ErrUnsupportedMethod {
+		t.Errorf("Expected compressed entries to return ErrUnsupportedMethod, got %v", err)
+	}
+
+	volumes := buildArchive(4, []testEntry{{name: "movie.mkv", content: content}}, 400)
+	missing := writeArchive(t, dir, "missing.rar", volumes[:2])
+	if _, err := Extract(missing, filepath.Join(dir, "missing")); errors.Cause(err) != ErrMissingVolume {
+		t.Errorf("Expected missing volume to return ErrMissingVolume, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing", "movie.mkv")); !os.IsNotExist(err) {
+		t.Error("Expected partially extracted file to be removed after extraction failure")
+	}
+
+	archive := buildArchive(4, []testEntry{{name: "movie.mkv", content: content}}, 0)[0]
+	archive[len(archive)-20] ^= 0xff
+	corrupted := writeArchive(t, dir, "corrupted.rar", [][]byte{archive})
+	if _, err := Extract(corrupted, filepath.Join(dir, "corrupted")); errors.Cause(err) != ErrChecksum {
+		t.Errorf("Expected corrupted data to return ErrChecksum, got %v", err)
+	}
+
+	notArchive := filepath.Join(dir, "fake.rar")
+	ioutil.WriteFile(notArchive, []byte("not a rar archive"), 0644)
+	if _, err := Extract(notArchive, filepath.Join(dir, "fake")); errors.Cause(err) != ErrFormat {
+		t.Errorf("Expected invalid archive to return ErrFormat, got %v", err)
+	}
+
+	traversal := writeArchive(t, dir, "traversal.rar", buildArchive(5, []testEntry{{name: "../../evil.txt", content: []byte("evil")}}, 0))
+	files, err := Extract(traversal, filepath.Join(dir, "traversal"))
+	if err != nil {
+		t.Fatal("Expected archive to be extracted, got error: ", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "traversal", "evil.txt") {
+		t.Errorf("Expected files to be extracted into destination folder, got %v", files)
+	}
+}
@@ -0,0 +1,314 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+	provider "github.com/macarrie/flemzerd/providers"
+	"github.com/macarrie/flemzerd/vidocq"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// Media ids that can be found in library folder or file names ("tt0133093", "{tmdb-603}", "[tvdbid=81189]", ...)
+var (
+	imdbIdRegexp = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(tt\d{7,8})(?:$|[^0-9])`)
+	tmdbIdRegexp = regexp.MustCompile(`(?i)tmdb(?:id)?[\s._=:-]*(\d+)`)
+	tvdbIdRegexp = regexp.MustCompile(`(?i)tvdb(?:id)?[\s._=:-]*(\d+)`)
+)
+
+// getPathIds returns media ids found in path
+func getPathIds(path string) MediaIds {
+	var ids MediaIds
+	if match := imdbIdRegexp.FindStringSubmatch(path); match != nil {
+		ids.Imdb = strings.ToLower(match[1])
+	}
+	if match := tmdbIdRegexp.FindStringSubmatch(path); match != nil {
+		ids.Tmdb, _ = strconv.Atoi(match[1])
+	}
+	if match := tvdbIdRegexp.FindStringSubmatch(path); match != nil {
+		ids.Tvdb, _ = strconv.Atoi(match[1])
+	}
+
+	return ids
+}
+
+func hasIds(ids MediaIds) bool {
+	return ids.Imdb != "" || ids.Tmdb != 0 || ids.Tvdb != 0
+}
+
+// idsMatch returns true if one of the ids found in a path is an id of a tracked item
+func idsMatch(pathIds MediaIds, ids MediaIds) bool {
+	return (pathIds.Imdb != "" && strings.ToLower(ids.Imdb) == pathIds.Imdb) ||
+		(pathIds.Tmdb != 0 && ids.Tmdb == pathIds.Tmdb) ||
+		(pathIds.Tvdb != 0 && ids.Tvdb == pathIds.Tvdb)
+}
+
+// normalizeTitle lowercases title and removes everything but letters and digits, so that titles from release names, sanitized folder names and providers can be compared
+func normalizeTitle(title string) string {
+	var normalized strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+
+	return normalized.String()
+}
+
+func titleMatches(name string, titles ...string) bool {
+	normalized := normalizeTitle(name)
+	if normalized == "" {
+		return false
+	}
+
+	for _, title := range titles {
+		if normalizeTitle(title) == normalized {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Release year and year from providers can differ by one year
+func yearMatches(year int, providerYear int) bool {
+	if year == 0 || providerYear <= 1 {
+		return true
+	}
+
+	return year >= providerYear-1 && year <= providerYear+1
+}
+
+// parsePathNames parses each component of path relative to library root, from file name to top level folder
+func parsePathNames(rel string) []MediaInfo {
+	components := strings.Split(filepath.ToSlash(rel), "/")
+	var names []MediaInfo
+	for i := len(components) - 1; i >= 0; i-- {
+		names = append(names, vidocq.Parse(components[i]))
+	}
+
+	return names
+}
+
+// getYear returns first year found in parsed path names
+func getYear(names []MediaInfo) int {
+	for _, name := range names {
+		if name.Year != 0 {
+			return name.Year
+		}
+	}
+
+	return 0
+}
+
+func newScannedFile(status string, reason string, info MediaInfo) ScannedFile {
+	return ScannedFile{
+		Status: status,
+		Reason: reason,
+		Info:   info,
+	}
+}
+
+// matchMovie links movie file to one of the given movies, with ids found in path or with title and year
+func matchMovie(file libraryFile, movies []Movie) ScannedFile {
+	info, _ := vidocq.GetInfo(file.Path, MOVIE)
+	names := parsePathNames(file.Rel)
+	// Movie title is taken from movie folder when file name only contains technical tags
+	if info.Title == "" {
+		info.Title = names[len(names)-1].Title
+	}
+	if info.Year == 0 {
+		info.Year = getYear(names)
+	}
+
+	var candidates []Movie
+	if pathIds := getPathIds(file.Rel); hasIds(pathIds) {
+		for _, movie := range movies {
+			if idsMatch(pathIds, movie.MediaIds) {
+				candidates = append(candidates, movie)
+			}
+		}
+	} else {
+		for _, name := range names {
+			var nameCandidates []Movie
+			for _, movie := range movies {
+				if titleMatches(name.Title, movie.Title, movie.OriginalTitle, movie.CustomTitle) && yearMatches(info.Year, movie.Date.Year()) {
+					nameCandidates = append(nameCandidates, movie)
+				}
+			}
+			// The most precise name is used: a folder name can match several movies when file name only matches one
+			if len(nameCandidates) == 1 || len(candidates) == 0 {
+				candidates = nameCandidates
+			}
+			if len(candidates) == 1 {
+				break
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return newScannedFile(SCAN_UNMATCHED, "no tracked movie found", info)
+	case 1:
+		// Movie is read again from database since it may have been linked to another file earlier in the scan
+		var movie Movie
+		db.Client.Find(&movie, candidates[0].ID)
+		if linkedPath, ok := getOtherLibraryFile(&movie, file.Path); ok {
+			return newScannedFile(SCAN_AMBIGUOUS, "movie already linked to library file "+linkedPath, info)
+		}
+		linkLibraryFile(&movie, file.Path, info)
+		result := newScannedFile(SCAN_MATCHED, "", info)
+		result.MovieID = movie.ID
+		return result
+	default:
+		var titles []string
+		for _, movie := range candidates {
+			titles = append(titles, fmt.Sprintf("%s (%d)", movie.GetTitle(), movie.Date.Year()))
+		}
+		return newScannedFile(SCAN_AMBIGUOUS, "matches several movies: "+strings.Join(titles, ", "), info)
+	}
+}
+
+// episodeMatcher links episode files to episodes of tracked shows. Season episode lists are retrieved from providers once per scan for episodes not found in database
+type episodeMatcher struct {
+	shows   []TvShow
+	fetched map[string]bool
+}
+
+func newEpisodeMatcher(shows []TvShow) *episodeMatcher {
+	return &episodeMatcher{
+		shows:   shows,
+		fetched: make(map[string]bool),
+	}
+}
+
+// findShows returns shows matching path of episode file, with ids found in path or with title and year of show folder or file name
+func (m *episodeMatcher) findShows(rel string, names []MediaInfo) []TvShow {
+	var candidates []TvShow
+	if pathIds := getPathIds(rel); hasIds(pathIds) {
+		for _, show := range m.shows {
+			if idsMatch(pathIds, show.MediaIds) {
+				candidates = append(candidates, show)
+			}
+		}
+		return candidates
+	}
+
+	// Show folder is tried first, then file name
+	showFolder := names[len(names)-1]
+	for _, name := range []MediaInfo{showFolder, names[0]} {
+		var nameCandidates []TvShow
+		for _, show := range m.shows {
+			if titleMatches(name.Title, show.Title, show.OriginalTitle, show.CustomTitle) && yearMatches(name.Year, show.FirstAired.Year()) {
+				nameCandidates = append(nameCandidates, show)
+			}
+		}
+		if len(nameCandidates) == 1 || len(candidates) == 0 {
+			candidates = nameCandidates
+		}
+		if len(candidates) == 1 {
+			break
+		}
+	}
+
+	return candidates
+}
+
+// findEpisode returns episode of show with given season and number. Season episode list is retrieved from providers if episode is not in database yet
+func (m *episodeMatcher) findEpisode(show TvShow, season int, number int) (Episode, bool) {
+	var episode Episode
+	if !db.Client.Where("tv_show_id = ? AND season = ? AND number = ?", show.ID, season, number).First(&episode).RecordNotFound() {
+		return episode, true
+	}
+
+	key := fmt.Sprintf("%d-%d", show.ID, season)
+	if m.fetched[key] {
+		return episode, false
+	}
+	m.fetched[key] = true
+
+	episodes, err := provider.GetSeasonEpisodeList(show, season)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"show":   show.GetTitle(),
+			"season": season,
+			"error":  err,
+		}).Warning("Could not get season episode list for library scan")
+		return episode, false
+	}
+	for _, ep := range episodes {
+		if ep.Number == number {
+			return ep, true
+		}
+	}
+
+	return episode, false
+}
+
+// getEpisodeInfo returns season and episode numbers of episode file. Numbers are taken from file name, or from episode and season folders
+func getEpisodeInfo(file libraryFile, names []MediaInfo) MediaInfo {
+	info, _ := vidocq.GetInfo(file.Path, EPISODE)
+	for _, name := range names {
+		if info.Episode == 0 && name.Episode != 0 {
+			info.Episode = name.Episode
+			info.LastEpisode = name.LastEpisode
+			info.Season = name.Season
+		}
+		if info.Episode != 0 && info.Season == 0 && name.Season != 0 {
+			info.Season = name.Season
+		}
+	}
+	if info.Title == "" {
+		info.Title = names[len(names)-1].Title
+	}
+
+	return info
+}
+
+// match links episode file to episodes of tracked shows. Multi-episode files are linked to every episode they contain
+func (m *episodeMatcher) match(file libraryFile) ScannedFile {
+	names := parsePathNames(file.Rel)
+	info := getEpisodeInfo(file, names)
+	if info.Episode == 0 {
+		return newScannedFile(SCAN_UNMATCHED, "no episode number found", info)
+	}
+
+	shows := m.findShows(file.Rel, names)
+	switch {
+	case len(shows) == 0:
+		return newScannedFile(SCAN_UNMATCHED, "no tracked show found", info)
+	case len(shows) > 1:
+		var titles []string
+		for _, show := range shows {
+			titles = append(titles, show.GetTitle())
+		}
+		return newScannedFile(SCAN_AMBIGUOUS, "matches several shows: "+strings.Join(titles, ", "), info)
+	}
+
+	show := shows[0]
+	var episodes []Episode
+	for _, number := range info.EpisodeNumbers() {
+		episode, found := m.findEpisode(show, info.Season, number)
+		if !found {
+			return newScannedFile(SCAN_UNMATCHED, fmt.Sprintf("episode S%02dE%02d of %s not found", info.Season, number, show.GetTitle()), info)
+		}
+		if linkedPath, ok := getOtherLibraryFile(&episode, file.Path); ok {
+			return newScannedFile(SCAN_AMBIGUOUS, fmt.Sprintf("episode S%02dE%02d of %s already linked to library file %s", info.Season, number, show.GetTitle(), linkedPath), info)
+		}
+		episodes = append(episodes, episode)
+	}
+
+	for i := range episodes {
+		linkLibraryFile(&episodes[i], file.Path, info)
+	}
+	result := newScannedFile(SCAN_MATCHED, "", info)
+	result.EpisodeID = episodes[0].ID
+	return result
+}
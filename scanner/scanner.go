@@ -1,96 +1,285 @@
+// Package scanner walks show and movie libraries and links video files found there to tracked shows, episodes and movies.
+// Linked items are marked as downloaded so that media already present in library are not downloaded again
 package scanner
 
 import (
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	"github.com/macarrie/flemzerd/downloadable"
 	log "github.com/macarrie/flemzerd/logging"
-	. "github.com/macarrie/flemzerd/objects"
 	"github.com/macarrie/flemzerd/vidocq"
 
-	"github.com/rs/xid"
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/pkg/errors"
 )
 
-func scan_dir(directory string, media_type int) ([]MediaInfo, error) {
-	fileList := []string{}
-	err := filepath.Walk(directory, func(path string, f os.FileInfo, err error) error {
-		if !f.IsDir() {
-			fileList = append(fileList, path)
+// ScanReport summarizes the result of a library scan
+type ScanReport struct {
+	// Files linked to a tracked item during scan
+	Matched []ScannedFile
+	// Files that could not be linked to any tracked item
+	Unmatched []ScannedFile
+	// Files matching several tracked items, or an item already linked to another library file
+	Ambiguous []ScannedFile
+	// Number of matched files left unchanged since previous scan
+	Unchanged int
+}
+
+// Only one scan runs at a time
+var scanMutex sync.Mutex
+var lastScan time.Time
+
+type libraryFile struct {
+	Path string
+	// Path relative to library root
+	Rel  string
+	Info os.FileInfo
+}
+
+// matchFunc links a library file to a tracked item. Returned scanned file must have its status set
+type matchFunc func(file libraryFile) ScannedFile
+
+// listVideoFiles returns video files contained in library located at root. Samples are ignored, and libraries not created yet are empty
+func listVideoFiles(root string) ([]libraryFile, error) {
+	var files []libraryFile
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"path":  path,
+				"error": err,
+			}).Warning("Could not read library path during scan")
+			return nil
+		}
+		if f.IsDir() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if vidocq.Parse(f.Name()).Container == "" || vidocq.IsSample(rel) {
+			return nil
 		}
+		files = append(files, libraryFile{
+			Path: path,
+			Rel:  rel,
+			Info: f,
+		})
 		return nil
 	})
 
+	return files, err
+}
+
+// isStillLinked returns true if item linked to file by a previous scan is still marked as downloaded
+func isStillLinked(file ScannedFile) bool {
+	switch {
+	case file.MovieID != 0:
+		var movie Movie
+		return !db.Client.Find(&movie, file.MovieID).RecordNotFound() && movie.DownloadingItem.Downloaded
+	case file.EpisodeID != 0:
+		var episode Episode
+		return !db.Client.Find(&episode, file.EpisodeID).RecordNotFound() && episode.DownloadingItem.Downloaded
+	default:
+		return false
+	}
+}
+
+// scanLibrary links video files found in library located at root using match. Matched files that did not change since previous scan are skipped, unless linked item is not downloaded anymore, and files removed from library are forgotten
+func scanLibrary(root string, mediaType int, match matchFunc) (ScanReport, error) {
+	report := ScanReport{
+		Matched:   []ScannedFile{},
+		Unmatched: []ScannedFile{},
+		Ambiguous: []ScannedFile{},
+	}
+
+	files, err := listVideoFiles(root)
 	if err != nil {
-		return []MediaInfo{}, err
+		return report, errors.Wrap(err, "cannot list library files")
 	}
 
-	media_list := []MediaInfo{}
-	for _, file := range fileList {
-		info, err := vidocq.GetInfo(file, media_type)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"filepath": file,
-			}).Error("Could not get info from vidocq for filename from library")
-		} else {
-			if info.Container != "" {
-				info.Id = xid.New().String()
-				media_list = append(media_list, info)
-			}
+	var knownFiles []ScannedFile
+	db.Client.Where("media_type = ?", mediaType).Find(&knownFiles)
+	knownByPath := make(map[string]ScannedFile)
+	for _, known := range knownFiles {
+		knownByPath[known.Path] = known
+	}
+
+	found := make(map[string]bool)
+	for _, file := range files {
+		found[file.Path] = true
+		modTime := file.Info.ModTime().Truncate(time.Second)
+
+		previous, known := knownByPath[file.Path]
+		if known && previous.Status == SCAN_MATCHED && previous.Size == file.Info.Size() && previous.ModTime.Equal(modTime) && isStillLinked(previous) {
+			report.Unchanged += 1
+			continue
+		}
+
+		result := match(file)
+		result.Model = previous.Model
+		result.Path = file.Path
+		result.MediaType = mediaType
+		result.Size = file.Info.Size()
+		result.ModTime = modTime
+		db.Client.Save(&result)
+
+		switch result.Status {
+		case SCAN_MATCHED:
+			report.Matched = append(report.Matched, result)
+		case SCAN_AMBIGUOUS:
+			report.Ambiguous = append(report.Ambiguous, result)
+		default:
+			report.Unmatched = append(report.Unmatched, result)
+		}
+	}
+
+	for _, known := range knownFiles {
+		if !found[known.Path] {
+			db.Client.Unscoped().Delete(&known)
 		}
 	}
 
-	return media_list, nil
+	return report, nil
 }
 
-func removeDuplicates(array []MediaInfo) []MediaInfo {
-	occurences := make(map[string]bool)
-	var ret []MediaInfo
+// getOtherLibraryFile returns library file item d is already linked to, if it is not located at path and still exists
+func getOtherLibraryFile(d downloadable.Downloadable, path string) (string, bool) {
+	downloadingItem := d.GetDownloadingItem()
+	if !downloadingItem.Downloaded {
+		return "", false
+	}
 
-	for _, media := range array {
-		if !occurences[media.Title] {
-			occurences[media.Title] = true
-			ret = append(ret, media)
-		}
+	linkedPath := downloadingItem.CurrentTorrent().LibraryPath
+	if linkedPath == "" || linkedPath == path {
+		return "", false
+	}
+	if _, err := os.Stat(linkedPath); err != nil {
+		return "", false
 	}
 
-	return ret
+	return linkedPath, true
 }
 
-func groupByTvShow(list []MediaInfo) MediaInfoGroupedByShow {
-	var retList = make(MediaInfoGroupedByShow)
-	for _, episode := range list {
-		if len(retList[episode.Title]) == 0 {
-			retList[episode.Title] = make(MediaInfoSeasons)
-		}
-		if len(retList[episode.Title][episode.Season]) == 0 {
-			retList[episode.Title][episode.Season] = make(MediaInfoEpisodes)
-		}
+// linkLibraryFile marks item d as downloaded with video file located at path, with media info parsed from file path. Items already downloaded or currently downloading are left untouched
+func linkLibraryFile(d downloadable.Downloadable, path string, info MediaInfo) {
+	downloadingItem := d.GetDownloadingItem()
+	if downloadingItem.Downloaded || downloadingItem.Downloading || downloadingItem.Pending || downloadingItem.Queued {
+		return
+	}
 
-		retList[episode.Title][episode.Season][episode.Episode] = episode
+	// Torrents from previous download attempts are replaced by the library file
+	for _, torrent := range downloadingItem.TorrentList {
+		db.Client.Unscoped().Delete(&torrent)
 	}
+	// Raw name matches torrent name so that media info parsed from the whole path (quality found in folder names) is not parsed again from file name
+	info.Raw = filepath.Base(path)
+	downloadingItem.TorrentList = []Torrent{
+		Torrent{
+			Name:        filepath.Base(path),
+			DownloadDir: filepath.Dir(path),
+			LibraryPath: path,
+			MediaInfo:   info,
+		},
+	}
+	downloadingItem.Downloaded = true
+	downloadingItem.DownloadFailed = false
+	downloadingItem.TorrentsNotFound = false
+	downloadingItem.ImportFailed = false
+	downloadingItem.ImportFailureReason = ""
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
 
-	return retList
+	d.GetLog().WithFields(log.Fields{
+		"path": path,
+	}).Info("Item found in library, marked as downloaded")
 }
 
-func ScanMovies() ([]MediaInfo, error) {
-	movies, err := scan_dir(configuration.Config.Library.MoviePath, MOVIE)
+func logReport(report ScanReport, library string) {
+	log.WithFields(log.Fields{
+		"library":   library,
+		"matched":   len(report.Matched),
+		"unmatched": len(report.Unmatched),
+		"ambiguous": len(report.Ambiguous),
+		"unchanged": report.Unchanged,
+	}).Info("Library scan done")
+
+	for _, file := range append(report.Unmatched, report.Ambiguous...) {
+		log.WithFields(log.Fields{
+			"path":   file.Path,
+			"status": file.Status,
+			"reason": file.Reason,
+		}).Debug("Library file not linked to any tracked item")
+	}
+}
+
+// ScanMovies links video files found in movie library to tracked movies
+func ScanMovies() (ScanReport, error) {
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+
+	var movies []Movie
+	db.Client.Find(&movies)
+
+	report, err := scanLibrary(configuration.Config.Library.MoviePath, MOVIE, func(file libraryFile) ScannedFile {
+		return matchMovie(file, movies)
+	})
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Error("Could not scan movie library")
+		return report, err
 	}
 
-	return removeDuplicates(movies), err
+	logReport(report, "movies")
+	return report, nil
 }
 
-func ScanShows() (MediaInfoGroupedByShow, error) {
-	episodes, err := scan_dir(configuration.Config.Library.ShowPath, EPISODE)
+// ScanShows links video files found in show library to episodes of tracked shows
+func ScanShows() (ScanReport, error) {
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+
+	var shows []TvShow
+	db.Client.Find(&shows)
+
+	m := newEpisodeMatcher(shows)
+	report, err := scanLibrary(configuration.Config.Library.ShowPath, EPISODE, m.match)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Error("Could not scan TV show library")
+		return report, err
 	}
 
-	return groupByTvShow(episodes), err
+	logReport(report, "shows")
+	return report, nil
+}
+
+// RunScheduledScan scans libraries of tracked media types if scan interval elapsed since last scheduled scan
+func RunScheduledScan() {
+	interval := configuration.Config.Library.ScanInterval
+	if interval <= 0 || time.Since(lastScan) < time.Duration(interval)*time.Hour {
+		return
+	}
+	lastScan = time.Now()
+
+	log.Debug("Starting scheduled library scan")
+	if configuration.Config.System.TrackMovies {
+		ScanMovies()
+	}
+	if configuration.Config.System.TrackShows {
+		ScanShows()
+	}
 }
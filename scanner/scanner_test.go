@@ -0,0 +1,327 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+func init() {
+	log.Setup(true)
+
+	db.DbPath = "/tmp/flemzerd.db"
+	db.Load()
+	db.ResetDb()
+
+	// go test makes a cd into package directory when testing. We must go up by one level to load our testdata
+	configuration.UseFile("../testdata/test_config.toml")
+	configuration.Load()
+}
+
+func createLibraryFiles(t *testing.T, root string, files []string) {
+	for _, name := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal("Could not create library file: ", err)
+		}
+		f.WriteString(name)
+		f.Close()
+	}
+}
+
+func getReportPaths(files []ScannedFile, root string) map[string]ScannedFile {
+	paths := make(map[string]ScannedFile)
+	for _, file := range files {
+		rel, _ := filepath.Rel(root, file.Path)
+		paths[rel] = file
+	}
+
+	return paths
+}
+
+func TestScanMovies(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_scan_movies"
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	matrix := Movie{
+		Title:         "The Matrix",
+		OriginalTitle: "The Matrix",
+		Date:          time.Date(1999, 3, 30, 0, 0, 0, 0, time.UTC),
+	}
+	dune := Movie{
+		Title:         "Dune",
+		OriginalTitle: "Dune",
+		Date:          time.Date(1984, 12, 14, 0, 0, 0, 0, time.UTC),
+		MediaIds: MediaIds{
+			Tmdb: 841,
+		},
+	}
+	duneRemake := Movie{
+		Title:         "Dune",
+		OriginalTitle: "Dune",
+		Date:          time.Date(2021, 9, 15, 0, 0, 0, 0, time.UTC),
+		MediaIds: MediaIds{
+			Tmdb: 438631,
+		},
+	}
+	downloading := Movie{
+		Title:         "Alien",
+		OriginalTitle: "Alien",
+		Date:          time.Date(1979, 5, 25, 0, 0, 0, 0, time.UTC),
+		DownloadingItem: DownloadingItem{
+			Downloading: true,
+		},
+	}
+	for _, movie := range []*Movie{&matrix, &dune, &duneRemake, &downloading} {
+		db.Client.Save(movie)
+	}
+
+	createLibraryFiles(t, configuration.Config.Library.MoviePath, []string{
+		"the_matrix/The.Matrix.1999.1080p.BluRay.x264.mkv",
+		"the_matrix/Sample/the.matrix.sample.mkv",
+		"the_matrix/The.Matrix.1999.1080p.BluRay.x264.nfo",
+		"Dune (2021) {tmdb-438631}/Dune.mkv",
+		"dune/Dune.720p.mkv",
+		"alien/Alien.1979.mkv",
+		"Unknown.Movie.2010.720p.mkv",
+	})
+
+	report, err := ScanMovies()
+	if err != nil {
+		t.Fatal("Movie library scan failed: ", err)
+	}
+
+	matched := getReportPaths(report.Matched, configuration.Config.Library.MoviePath)
+	if len(matched) != 3 {
+		t.Errorf("Expected 3 matched movie files, got %d", len(matched))
+	}
+	if file, ok := matched["the_matrix/The.Matrix.1999.1080p.BluRay.x264.mkv"]; !ok || file.MovieID != matrix.ID {
+		t.Error("Expected movie file to be matched with title and year")
+	}
+	if file, ok := matched["Dune (2021) {tmdb-438631}/Dune.mkv"]; !ok || file.MovieID != duneRemake.ID {
+		t.Error("Expected movie file to be matched with tmdb id found in folder name")
+	}
+	if file, ok := matched["alien/Alien.1979.mkv"]; !ok || file.MovieID != downloading.ID {
+		t.Error("Expected movie file to be matched with downloading movie")
+	}
+
+	if ambiguous := getReportPaths(report.Ambiguous, configuration.Config.Library.MoviePath); len(ambiguous) != 1 {
+		t.Errorf("Expected 1 ambiguous movie file, got %d", len(ambiguous))
+	} else if _, ok := ambiguous["dune/Dune.720p.mkv"]; !ok {
+		t.Error("Expected movie file matching several movies to be ambiguous")
+	}
+
+	if unmatched := getReportPaths(report.Unmatched, configuration.Config.Library.MoviePath); len(unmatched) != 1 {
+		t.Errorf("Expected 1 unmatched movie file, got %d", len(unmatched))
+	} else if file, ok := unmatched["Unknown.Movie.2010.720p.mkv"]; !ok || file.Info.Title != "Unknown Movie" {
+		t.Error("Expected untracked movie file to be unmatched with parsed media info")
+	}
+
+	var movieFromDb Movie
+	db.Client.Find(&movieFromDb, matrix.ID)
+	if !movieFromDb.DownloadingItem.Downloaded {
+		t.Error("Expected matched movie to be marked as downloaded")
+	}
+	torrent := movieFromDb.DownloadingItem.CurrentTorrent()
+	if torrent.LibraryPath != filepath.Join(configuration.Config.Library.MoviePath, "the_matrix/The.Matrix.1999.1080p.BluRay.x264.mkv") {
+		t.Errorf("Expected matched movie to point to library file, got %s", torrent.LibraryPath)
+	}
+	if torrent.MediaInfo.Quality != "1080p" || torrent.MediaInfo.Raw != torrent.Name {
+		t.Errorf("Expected quality of library file to be recorded, got %+v", torrent.MediaInfo)
+	}
+
+	var downloadingFromDb Movie
+	db.Client.Find(&downloadingFromDb, downloading.ID)
+	if downloadingFromDb.DownloadingItem.Downloaded || !downloadingFromDb.DownloadingItem.Downloading {
+		t.Error("Expected downloading movie to be left untouched by library scan")
+	}
+
+	os.Remove(filepath.Join(configuration.Config.Library.MoviePath, "alien/Alien.1979.mkv"))
+	report, err = ScanMovies()
+	if err != nil {
+		t.Fatal("Movie library scan failed: ", err)
+	}
+	if len(report.Matched) != 0 || report.Unchanged != 2 {
+		t.Errorf("Expected unchanged matched files to be skipped by second scan, got %d matched and %d unchanged files", len(report.Matched), report.Unchanged)
+	}
+	if len(report.Ambiguous) != 1 || len(report.Unmatched) != 1 {
+		t.Error("Expected ambiguous and unmatched files to be scanned again")
+	}
+
+	// Unchanged files are linked again when linked item is not downloaded anymore
+	db.Client.Model(&DownloadingItem{}).Where("id = ?", movieFromDb.DownloadingItem.ID).Update("downloaded", false)
+	report, err = ScanMovies()
+	if err != nil {
+		t.Fatal("Movie library scan failed: ", err)
+	}
+	if len(report.Matched) != 1 || report.Unchanged != 1 || report.Matched[0].MovieID != matrix.ID {
+		t.Errorf("Expected unchanged file to be linked again to movie not downloaded anymore, got %d matched and %d unchanged files", len(report.Matched), report.Unchanged)
+	}
+	movieFromDb = Movie{}
+	db.Client.Find(&movieFromDb, matrix.ID)
+	if !movieFromDb.DownloadingItem.Downloaded {
+		t.Error("Expected movie to be marked as downloaded again by library scan")
+	}
+
+	var scannedFiles []ScannedFile
+	db.Client.Where("media_type = ?", MOVIE).Find(&scannedFiles)
+	if len(scannedFiles) != 4 {
+		t.Errorf("Expected files removed from library to be removed from scanned files, got %d scanned files", len(scannedFiles))
+	}
+}
+
+func TestScanShows(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.ShowPath = "/tmp/flemzerd_test_scan_shows"
+	os.RemoveAll(configuration.Config.Library.ShowPath)
+	defer os.RemoveAll(configuration.Config.Library.ShowPath)
+
+	show := TvShow{
+		Title:         "Breaking Bad",
+		OriginalTitle: "Breaking Bad",
+		FirstAired:    time.Date(2008, 1, 20, 0, 0, 0, 0, time.UTC),
+	}
+	db.Client.Save(&show)
+
+	var episodes []Episode
+	for number := 1; number <= 3; number++ {
+		episode := Episode{
+			TvShow: show,
+			Season: 1,
+			Number: number,
+			Title:  fmt.Sprintf("Episode %d", number),
+		}
+		db.Client.Save(&episode)
+		episodes = append(episodes, episode)
+	}
+
+	createLibraryFiles(t, configuration.Config.Library.ShowPath, []string{
+		"breaking_bad/season_1/s01e01/Breaking.Bad.S01E01.720p.HDTV.x264.mkv",
+		"Breaking Bad (2008)/Season 01/Breaking.Bad.S01E02E03.1080p.mkv",
+		"breaking_bad/season_1/Breaking.Bad.S01E09.720p.mkv",
+		"other_show/Other.Show.S01E01.mkv",
+		"breaking_bad/extras/Behind.The.Scenes.mkv",
+	})
+
+	report, err := ScanShows()
+	if err != nil {
+		t.Fatal("Show library scan failed: ", err)
+	}
+
+	matched := getReportPaths(report.Matched, configuration.Config.Library.ShowPath)
+	if len(matched) != 2 {
+		t.Errorf("Expected 2 matched episode files, got %d", len(matched))
+	}
+	if file, ok := matched["breaking_bad/season_1/s01e01/Breaking.Bad.S01E01.720p.HDTV.x264.mkv"]; !ok || file.EpisodeID != episodes[0].ID {
+		t.Error("Expected episode file to be matched with show folder name and episode number")
+	}
+	if file, ok := matched["Breaking Bad (2008)/Season 01/Breaking.Bad.S01E02E03.1080p.mkv"]; !ok || file.EpisodeID != episodes[1].ID {
+		t.Error("Expected multi-episode file to be matched with its first episode")
+	}
+
+	for _, episode := range episodes {
+		var episodeFromDb Episode
+		db.Client.Find(&episodeFromDb, episode.ID)
+		if !episodeFromDb.DownloadingItem.Downloaded {
+			t.Errorf("Expected episode S01E%02d to be marked as downloaded", episode.Number)
+		}
+	}
+
+	unmatched := getReportPaths(report.Unmatched, configuration.Config.Library.ShowPath)
+	if len(unmatched) != 3 {
+		t.Errorf("Expected 3 unmatched episode files, got %d", len(unmatched))
+	}
+	for _, path := range []string{"breaking_bad/season_1/Breaking.Bad.S01E09.720p.mkv", "other_show/Other.Show.S01E01.mkv", "breaking_bad/extras/Behind.The.Scenes.mkv"} {
+		if _, ok := unmatched[path]; !ok {
+			t.Errorf("Expected %s to be unmatched", path)
+		}
+	}
+}
+
+func TestScanDuplicateFiles(t *testing.T) {
+	db.ResetDb()
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_scan_movies"
+	configuration.Config.Library.ShowPath = "/tmp/flemzerd_test_scan_shows"
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+	os.RemoveAll(configuration.Config.Library.ShowPath)
+	defer os.RemoveAll(configuration.Config.Library.MoviePath)
+	defer os.RemoveAll(configuration.Config.Library.ShowPath)
+
+	movie := Movie{
+		Title:         "The Matrix",
+		OriginalTitle: "The Matrix",
+		Date:          time.Date(1999, 3, 30, 0, 0, 0, 0, time.UTC),
+	}
+	db.Client.Save(&movie)
+	show := TvShow{
+		Title:         "Breaking Bad",
+		OriginalTitle: "Breaking Bad",
+		FirstAired:    time.Date(2008, 1, 20, 0, 0, 0, 0, time.UTC),
+	}
+	db.Client.Save(&show)
+	episode := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 1,
+	}
+	db.Client.Save(&episode)
+
+	createLibraryFiles(t, configuration.Config.Library.MoviePath, []string{
+		"the_matrix/The.Matrix.1999.1080p.mkv",
+		"the_matrix/The.Matrix.1999.720p.mkv",
+	})
+	createLibraryFiles(t, configuration.Config.Library.ShowPath, []string{
+		"breaking_bad/season_1/Breaking.Bad.S01E01.1080p.mkv",
+		"breaking_bad/season_1/Breaking.Bad.S01E01.720p.mkv",
+	})
+
+	movieReport, err := ScanMovies()
+	if err != nil {
+		t.Fatal("Movie library scan failed: ", err)
+	}
+	if len(movieReport.Matched) != 1 || len(movieReport.Ambiguous) != 1 {
+		t.Errorf("Expected only first file of a movie to be matched and other files to be ambiguous, got %d matched and %d ambiguous files", len(movieReport.Matched), len(movieReport.Ambiguous))
+	}
+	var torrentCount int
+	db.Client.Model(&Torrent{}).Count(&torrentCount)
+	if torrentCount != 1 {
+		t.Errorf("Expected movie to be linked to a single library file, got %d torrents", torrentCount)
+	}
+
+	showReport, err := ScanShows()
+	if err != nil {
+		t.Fatal("Show library scan failed: ", err)
+	}
+	if len(showReport.Matched) != 1 || len(showReport.Ambiguous) != 1 {
+		t.Errorf("Expected only first file of an episode to be matched and other files to be ambiguous, got %d matched and %d ambiguous files", len(showReport.Matched), len(showReport.Ambiguous))
+	}
+	db.Client.Model(&Torrent{}).Count(&torrentCount)
+	if torrentCount != 2 {
+		t.Errorf("Expected episode to be linked to a single library file, got %d torrents in total", torrentCount)
+	}
+}
+
+func TestScanMissingLibrary(t *testing.T) {
+	configuration.Config.Library.MoviePath = "/tmp/flemzerd_test_scan_missing"
+	os.RemoveAll(configuration.Config.Library.MoviePath)
+
+	report, err := ScanMovies()
+	if err != nil {
+		t.Error("Expected missing library to be scanned as an empty library, got error: ", err)
+	}
+	if len(report.Matched)+len(report.Unmatched)+len(report.Ambiguous) != 0 {
+		t.Error("Expected empty scan report for missing library")
+	}
+}
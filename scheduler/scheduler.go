@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"sort"
 	"time"
 
 	"github.com/macarrie/flemzerd/configuration"
@@ -12,10 +13,15 @@ import (
 	indexer "github.com/macarrie/flemzerd/indexers"
 	notifier "github.com/macarrie/flemzerd/notifiers"
 	provider "github.com/macarrie/flemzerd/providers"
+	"github.com/macarrie/flemzerd/scanner"
 
 	"github.com/macarrie/flemzerd/downloadable"
 
 	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
 )
 
 var RunTicker *time.Ticker
@@ -56,6 +62,16 @@ func Download(d downloadable.Downloadable) {
 		return
 	}
 
+	if downloadingItem.Queued {
+		d.GetLog().Debug("Item already waiting in download queue, nothing to do")
+		return
+	}
+
+	if episode, ok := d.(*Episode); ok && coveringDownloadInProgress(*episode) {
+		d.GetLog().Debug("Episode already being downloaded in a season pack or a multi-episode release, nothing to do")
+		return
+	}
+
 	if !healthcheck.CanDownload {
 		// Check modules health again just in case
 		healthcheck.CheckHealth()
@@ -119,7 +135,57 @@ func Download(d downloadable.Downloadable) {
 
 	notifier.NotifyDownloadStart(d)
 
-	go downloader.Download(d)
+	downloader.Enqueue(d)
+}
+
+// DownloadTorrent starts the download of a torrent chosen manually for item (from a manual search for example) instead of searching torrents in indexers.
+// An error is returned if item is already downloading or downloaded
+func DownloadTorrent(d downloadable.Downloadable, torrent Torrent) error {
+	downloadingItem := d.GetDownloadingItem()
+	if downloadingItem.Downloaded || downloadingItem.Downloading || downloadingItem.Pending || downloadingItem.Queued {
+		return errors.New("Item is currently downloading or already downloaded")
+	}
+
+	if torrent.Link == "" {
+		return errors.New("Torrent link is empty")
+	}
+
+	if !healthcheck.CanDownload {
+		return errors.New("Download modules are not available")
+	}
+
+	// Torrent is a new download attempt: torrents from previous attempts are removed
+	for _, t := range downloadingItem.TorrentList {
+		db.Client.Unscoped().Delete(&t)
+	}
+
+	torrent.Model = gorm.Model{}
+	torrent.Failed = false
+	if torrent.TorrentId == "" {
+		torrent.TorrentId = xid.New().String()
+	}
+	for i := range torrent.ScoreBreakdown {
+		torrent.ScoreBreakdown[i].Model = gorm.Model{}
+	}
+	_, _ = indexer.GetTorrentMediaInfo(&torrent)
+
+	d.GetLog().WithFields(log.Fields{
+		"torrent": torrent.Name,
+	}).Info("Launching download of manually chosen torrent")
+
+	downloadingItem.TorrentList = []Torrent{torrent}
+	downloadingItem.CurrentDownloaderId = ""
+	downloadingItem.CurrentDownloader = ""
+	downloadingItem.DownloadFailed = false
+	downloadingItem.TorrentsNotFound = false
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+
+	notifier.NotifyDownloadStart(d)
+
+	downloader.Enqueue(d)
+
+	return nil
 }
 
 func RecoverDownloadingItems() {
@@ -134,6 +200,8 @@ func RecoverDownloadingItems() {
 		return
 	}
 
+	var recoveredItems []downloadable.Downloadable
+
 	if len(downloadingEpisodesFromRetention) != 0 {
 		log.Debug("Launching watch threads for downloading episodes found in retention")
 	}
@@ -142,12 +210,11 @@ func RecoverDownloadingItems() {
 		ep.DownloadingItem.Pending = false
 		ep.DownloadingItem.Downloading = false
 		ep.DownloadingItem.Downloaded = false
+		ep.DownloadingItem.Queued = false
 		db.Client.Save(&ep)
 
-		ep.GetLog().Debug("Launched download processing recovery")
 		recoveryEpisode := ep
-		go Download(&recoveryEpisode)
-
+		recoveredItems = append(recoveredItems, &recoveryEpisode)
 	}
 
 	if len(downloadingMoviesFromRetention) != 0 {
@@ -158,12 +225,24 @@ func RecoverDownloadingItems() {
 		m.DownloadingItem.Pending = false
 		m.DownloadingItem.Downloading = false
 		m.DownloadingItem.Downloaded = false
+		m.DownloadingItem.Queued = false
 		db.Client.Save(&m)
 
-		m.GetLog().Debug("Launched download processing recovery")
-
 		recoveryMovie := m
-		go Download(&recoveryMovie)
+		recoveredItems = append(recoveredItems, &recoveryMovie)
+	}
+
+	// Items that were waiting in download queue are added in queue again in their previous order
+	sort.SliceStable(recoveredItems, func(i, j int) bool {
+		return recoveredItems[i].GetDownloadingItem().QueuePosition < recoveredItems[j].GetDownloadingItem().QueuePosition
+	})
+	for _, d := range recoveredItems {
+		d.GetLog().Debug("Launched download processing recovery")
+		if d.GetDownloadingItem().QueuePosition > 0 {
+			Download(d)
+		} else {
+			go Download(d)
+		}
 	}
 }
 
@@ -182,6 +261,9 @@ func poll(recoveryDone *bool) {
 		*recoveryDone = true
 	}
 
+	// Items found in library are marked as downloaded before looking for new downloads
+	scanner.RunScheduledScan()
+
 	if configuration.Config.System.TrackShows {
 		for _, show := range provider.TVShows {
 			recentEpisodes, err := provider.FindRecentlyAiredEpisodesForShow(show)
@@ -193,6 +275,8 @@ func poll(recoveryDone *bool) {
 				continue
 			}
 
+			// Seasons whose missing episodes are downloaded with a season pack
+			seasonPacks := make(map[int]bool)
 			for _, recentEpisode := range recentEpisodes {
 				err := notifier.NotifyRecentEpisode(&recentEpisode)
 				if err != nil {
@@ -200,13 +284,31 @@ func poll(recoveryDone *bool) {
 				}
 
 				downloadDelayPassed := time.Now().After(recentEpisode.Date.Add(time.Duration(configuration.Config.System.ShowDownloadDelay) * time.Hour))
+				if !show.IsEpisodeMonitored(recentEpisode) {
+					recentEpisode.GetLog().Debug("Episode not monitored, skipping download")
+					continue
+				}
+
 				if healthcheck.CanDownload && configuration.Config.System.AutomaticShowDownload && downloadDelayPassed {
+					seasonPack, checked := seasonPacks[recentEpisode.Season]
+					if !checked {
+						seasonPack = DownloadSeasonPack(show, recentEpisode.Season)
+						seasonPacks[recentEpisode.Season] = seasonPack
+					}
+					if seasonPack {
+						continue
+					}
+
 					Download(&recentEpisode)
 				}
 			}
 		}
 	}
 
+	if configuration.Config.System.TrackShows && healthcheck.CanDownload && configuration.Config.System.AutomaticShowDownload {
+		Backfill(provider.TVShows)
+	}
+
 	if configuration.Config.System.TrackMovies {
 		for _, movie := range provider.Movies {
 			if movie.Date.After(time.Now()) {
@@ -229,6 +331,14 @@ func poll(recoveryDone *bool) {
 		}
 	}
 
+	if healthcheck.CanDownload && configuration.Config.System.AutomaticUpgrades {
+		CheckUpgrades()
+	}
+
+	if healthcheck.CanDownload {
+		downloader.ReapSeedingTorrents()
+	}
+
 	log.Debug("========== Polling loop end ==========\n")
 }
 
@@ -33,6 +33,13 @@ func init() {
 	configuration.Load()
 }
 
+// waitForDownloads waits for download processes started by download queue to end, so that they do not run while next tests reset modules
+func waitForDownloads() {
+	for i := 0; i < 100 && len(downloader.GetQueue().Active) > 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 func TestDownloadMedia(t *testing.T) {
 	notifier.Reset()
 	provider.Reset()
@@ -337,6 +344,18 @@ func TestRecovery(t *testing.T) {
 	notifier.AddNotifier(mock.Notifier{})
 	watchlist.AddWatchlist(mock.Watchlist{})
 	mediacenter.AddMediaCenter(mock.MediaCenter{})
+	// Every recovered item must be downloaded before the test returns, since recovered downloads are started in the background
+	defer func() {
+		for i := 0; i < 100; i++ {
+			var count int
+			db.Client.Model(&DownloadingItem{}).Where("downloaded = ?", false).Count(&count)
+			if count == 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		waitForDownloads()
+	}()
 
 	provider.GetTVShowsInfoFromConfig()
 	provider.GetMoviesInfoFromConfig()
@@ -414,3 +433,297 @@ func TestRecovery(t *testing.T) {
 
 	}
 }
+
+func TestUpgrade(t *testing.T) {
+	db.ResetDb()
+	downloader.MovieDownloadRoutines = make(map[uint](downloader.ContextStorage))
+
+	indexer.Reset()
+	downloader.Reset()
+	indexer.AddIndexer(mock.MovieIndexer{})
+	downloader.AddDownloader(mock.ErrorDownloader{})
+
+	configuration.Config.System.UpgradeCheckInterval = 24
+	configuration.Config.System.StrictTorrentCheck = true
+	configuration.Config.System.ExcludedReleaseTypes = ""
+
+	profile := QualityProfile{
+		Name:      "test",
+		Qualities: "720p,480p",
+		Cutoff:    "720p",
+	}
+	db.Client.Create(&profile)
+
+	movie := Movie{
+		Title:          "test movie",
+		OriginalTitle:  "test movie",
+		QualityProfile: profile,
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name: "Test.Movie.2018.720p",
+				},
+			},
+		},
+	}
+	db.Client.Create(&movie)
+
+	if Upgrade(&movie) {
+		t.Error("Expected no upgrade when quality profile cutoff is reached")
+	}
+
+	db.Client.Model(&movie.DownloadingItem.TorrentList[0]).Update("name", "Test.Movie.2018")
+	db.Client.Find(&movie, movie.ID)
+	if Upgrade(&movie) {
+		t.Error("Expected no upgrade when quality of current release is unknown")
+	}
+
+	db.Client.Model(&movie.DownloadingItem.TorrentList[0]).Update("name", "Test.Movie.2018.480p")
+	db.Client.Find(&movie, movie.ID)
+	movie.DownloadingItem.LastUpgradeCheck = time.Now()
+	if Upgrade(&movie) {
+		t.Error("Expected no upgrade when last upgrade check is too recent")
+	}
+
+	movie.DownloadingItem.LastUpgradeCheck = time.Time{}
+	if !Upgrade(&movie) {
+		t.Fatal("Expected upgrade to be started when quality is below cutoff")
+	}
+
+	// Downloader cannot add torrents: upgrade fails and previous release must be kept
+	defer waitForDownloads()
+	for i := 0; i < 50; i++ {
+		var movieFromDB Movie
+		db.Client.Find(&movieFromDB, movie.ID)
+		if !movieFromDB.DownloadingItem.Upgrading {
+			if !movieFromDB.DownloadingItem.Downloaded {
+				t.Error("Expected movie to stay downloaded when upgrade fails")
+			}
+			if movieFromDB.DownloadingItem.CurrentTorrent().Name != "Test.Movie.2018.480p" {
+				t.Errorf("Expected previous release to be kept when upgrade fails, got '%s' instead", movieFromDB.DownloadingItem.CurrentTorrent().Name)
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Error("Expected failed upgrade to be finished (timeout)")
+}
+
+func TestDownloadTorrent(t *testing.T) {
+	db.ResetDb()
+	downloader.MovieDownloadRoutines = make(map[uint](downloader.ContextStorage))
+	downloader.Reset()
+	healthcheck.CanDownload = true
+
+	movie := Movie{
+		Title: "test movie",
+		DownloadingItem: DownloadingItem{
+			Downloaded: true,
+		},
+	}
+	db.Client.Create(&movie)
+
+	if err := DownloadTorrent(&movie, Torrent{Name: "Test.Movie.720p", Link: "test.torrent"}); err == nil {
+		t.Error("Expected an error when downloading a torrent for an already downloaded movie")
+	}
+
+	movie.DownloadingItem.Downloaded = false
+	db.Client.Save(&movie)
+
+	if err := DownloadTorrent(&movie, Torrent{Name: "Test.Movie.720p"}); err == nil {
+		t.Error("Expected an error when downloading a torrent without link")
+	}
+
+	if err := DownloadTorrent(&movie, Torrent{Name: "Test.Movie.720p", Link: "test.torrent"}); err != nil {
+		t.Error("Expected chosen torrent to be downloaded, got error: ", err)
+	}
+
+	var movieFromDB Movie
+	db.Client.Find(&movieFromDB, movie.ID)
+	if len(movieFromDB.DownloadingItem.TorrentList) != 1 || movieFromDB.DownloadingItem.TorrentList[0].Name != "Test.Movie.720p" {
+		t.Error("Expected chosen torrent to be the only torrent in downloading item torrent list")
+	}
+}
+
+func TestShouldDownloadSeasonPack(t *testing.T) {
+	configuration.Config.System.ShowDownloadDelay = 0
+	aired := time.Now().Add(-48 * time.Hour)
+	future := time.Now().Add(48 * time.Hour)
+
+	season := []Episode{
+		Episode{Number: 3, Date: aired, Monitored: true},
+		Episode{Number: 1, Date: aired, Monitored: true, DownloadingItem: DownloadingItem{Downloaded: true}},
+		Episode{Number: 2, Date: aired, Monitored: true},
+		Episode{Number: 4, Date: future, Monitored: true},
+		Episode{Number: 5, Date: aired, Monitored: false},
+	}
+
+	missing := getMissingEpisodes(season)
+	if len(missing) != 2 {
+		t.Errorf("Expected 2 missing episodes, got %d instead", len(missing))
+		return
+	}
+	if missing[0].Number != 2 {
+		t.Errorf("Expected missing episodes to be sorted by number, got episode %d first", missing[0].Number)
+	}
+
+	if !shouldDownloadSeasonPack(season, missing) {
+		t.Error("Expected season pack to be downloaded when most aired episodes are missing")
+	}
+
+	season[2].DownloadingItem.Downloaded = true
+	missing = getMissingEpisodes(season)
+	if shouldDownloadSeasonPack(season, missing) {
+		t.Error("Expected no season pack when only one episode is missing in an airing season")
+	}
+
+	season[2].DownloadingItem.Downloaded = false
+	season[3].Date = aired
+	missing = getMissingEpisodes(season)
+	if !shouldDownloadSeasonPack(season, missing) {
+		t.Error("Expected season pack to be downloaded when several episodes are missing in a finished season")
+	}
+
+	missing[0].DownloadingItem.DownloadFailed = true
+	if shouldDownloadSeasonPack(season, missing) {
+		t.Error("Expected no season pack when last season pack download failed")
+	}
+
+	newSeason := []Episode{
+		Episode{Number: 1, Date: aired, Monitored: true},
+		Episode{Number: 2, Date: future, Monitored: true},
+	}
+	if shouldDownloadSeasonPack(newSeason, getMissingEpisodes(newSeason)) {
+		t.Error("Expected no season pack when only the first episode of an airing season aired")
+	}
+
+	configuration.Config.System.DownloadSeasonPacks = false
+	if DownloadSeasonPack(TvShow{}, 1) {
+		t.Error("Expected no season pack download when season packs are disabled in configuration")
+	}
+}
+
+func TestCoveringDownloadInProgress(t *testing.T) {
+	db.ResetDb()
+
+	show := TvShow{
+		Title: "test show",
+	}
+	db.Client.Create(&show)
+
+	downloadingEpisode := Episode{
+		TvShow: show,
+		Season: 1,
+		Number: 1,
+		DownloadingItem: DownloadingItem{
+			Downloading: true,
+			TorrentList: []Torrent{
+				Torrent{
+					Name: "Test.Show.S01E01E02.720p",
+				},
+			},
+		},
+	}
+	db.Client.Create(&downloadingEpisode)
+
+	covered := Episode{TvShow: show, TvShowID: show.ID, Season: 1, Number: 2}
+	db.Client.Create(&covered)
+	notCovered := Episode{TvShow: show, TvShowID: show.ID, Season: 1, Number: 3}
+	db.Client.Create(&notCovered)
+
+	if !coveringDownloadInProgress(covered) {
+		t.Error("Expected episode contained in multi-episode release being downloaded to be detected")
+	}
+	if coveringDownloadInProgress(notCovered) {
+		t.Error("Expected episode not contained in multi-episode release being downloaded not to be detected")
+	}
+	if coveringDownloadInProgress(downloadingEpisode) {
+		t.Error("Expected episode downloading the release not to be covered by its own download")
+	}
+
+	db.Client.Model(&downloadingEpisode.DownloadingItem.TorrentList[0]).Update("season_pack", true)
+	if !coveringDownloadInProgress(notCovered) {
+		t.Error("Expected episode to be covered by season pack being downloaded")
+	}
+}
+
+func TestSearchBackedOff(t *testing.T) {
+	episode := Episode{}
+	if searchBackedOff(episode) {
+		t.Error("Expected episode never searched to be searched")
+	}
+
+	episode.DownloadingItem.TorrentsNotFound = true
+	episode.DownloadingItem.LastSearch = time.Now().Add(-1 * time.Hour)
+	if !searchBackedOff(episode) {
+		t.Error("Expected episode without torrents not to be searched again before retry delay")
+	}
+
+	episode.DownloadingItem.LastSearch = time.Now().Add(-(BACKFILL_RETRY_DELAY + 1) * time.Hour)
+	if searchBackedOff(episode) {
+		t.Error("Expected episode without torrents to be searched again after retry delay")
+	}
+}
+
+func TestGetWantedEpisodes(t *testing.T) {
+	db.ResetDb()
+	provider.Reset()
+	provider.AddProvider(mock.DownloadDelayTVProvider{})
+	configuration.Config.System.ShowDownloadDelay = 0
+
+	show := TvShow{
+		Title:           "test show",
+		NumberOfSeasons: 1,
+		MonitoringMode:  MONITOR_ALL,
+	}
+	db.Client.Create(&show)
+
+	wanted, err := GetWantedEpisodes(show, true)
+	if err != nil {
+		t.Error("Expected to get wanted episodes, got error: ", err)
+	}
+	if len(wanted) != 1 {
+		t.Errorf("Expected 1 wanted episode, got %d instead", len(wanted))
+		return
+	}
+	if wanted[0].TvShow.ID != show.ID {
+		t.Error("Expected wanted episodes to be linked to their show")
+	}
+	if wanted, _ := GetWantedEpisodes(show, false); len(wanted) != 1 {
+		t.Errorf("Expected wanted episodes to be retrieved from database, got %d wanted episodes", len(wanted))
+	}
+
+	var unmonitored Episode
+	db.Client.Where("tv_show_id = ?", show.ID).First(&unmonitored)
+	db.Client.Model(&unmonitored).Update("monitored", false)
+	wanted, _ = GetWantedEpisodes(show, true)
+	if len(wanted) != 0 {
+		t.Errorf("Expected unmonitored episodes not to be wanted, got %d wanted episodes", len(wanted))
+	}
+	db.Client.Model(&unmonitored).Update("monitored", true)
+
+	show.MonitoringMode = MONITOR_FUTURE
+	show.CreatedAt = time.Now().Add(1 * time.Hour)
+	wanted, _ = GetWantedEpisodes(show, true)
+	if len(wanted) != 0 {
+		t.Errorf("Expected episodes aired before show was added not to be wanted in future monitoring mode, got %d wanted episodes", len(wanted))
+	}
+
+	show.MonitoringMode = MONITOR_SEASONS
+	show.MonitoredSeasons = "1000"
+	if _, err := GetWantedEpisodes(show, true); err == nil {
+		t.Error("Expected an error when season episodes cannot be retrieved")
+	}
+
+	show.MonitoringMode = MONITOR_LATEST_SEASON
+	var episode Episode
+	db.Client.Where("tv_show_id = ?", show.ID).First(&episode)
+	episode.DownloadingItem.Downloaded = true
+	db.Client.Save(&episode)
+	wanted, _ = GetWantedEpisodes(show, true)
+	if len(wanted) != 0 {
+		t.Errorf("Expected downloaded episodes not to be wanted, got %d wanted episodes", len(wanted))
+	}
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+
+	indexer "github.com/macarrie/flemzerd/indexers"
+	provider "github.com/macarrie/flemzerd/providers"
+
+	. "github.com/macarrie/flemzerd/objects"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Minimum number of missing episodes in a finished season to download a season pack instead of single episodes
+const SEASON_PACK_MIN_MISSING_EPISODES = 2
+
+// Minimum number of aired episodes in a season still airing to look for a season pack. Packs are rarely released for the first episodes of a season
+const SEASON_PACK_MIN_AIRED_EPISODES = 3
+
+// hasAired returns true if episode aired and download delay passed
+func hasAired(episode Episode) bool {
+	if episode.Date.IsZero() {
+		return false
+	}
+
+	return time.Now().After(episode.Date.Add(time.Duration(configuration.Config.System.ShowDownloadDelay) * time.Hour))
+}
+
+// getMissingEpisodes returns monitored aired episodes from list that are not downloaded, downloading or waiting for download. Returned episodes are sorted by episode number
+func getMissingEpisodes(episodes []Episode) []Episode {
+	var missing []Episode
+	for _, episode := range episodes {
+		downloadingItem := episode.DownloadingItem
+		if !episode.Monitored || !hasAired(episode) || downloadingItem.Downloaded || downloadingItem.Downloading || downloadingItem.Pending {
+			continue
+		}
+		missing = append(missing, episode)
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Number < missing[j].Number
+	})
+
+	return missing
+}
+
+// shouldDownloadSeasonPack returns true if a season pack should be downloaded for season: most aired episodes of season are missing (once enough episodes aired), or season has ended and several episodes are missing.
+// A season pack is not downloaded again if last season pack download failed, single episodes are downloaded instead
+func shouldDownloadSeasonPack(seasonEpisodes []Episode, missing []Episode) bool {
+	if len(missing) == 0 || missing[0].DownloadingItem.DownloadFailed {
+		return false
+	}
+
+	// Only monitored episodes are taken into account
+	aired := 0
+	ended := true
+	for _, episode := range seasonEpisodes {
+		if !hasAired(episode) {
+			ended = false
+			continue
+		}
+		if episode.Monitored {
+			aired++
+		}
+	}
+
+	if ended && len(missing) >= SEASON_PACK_MIN_MISSING_EPISODES {
+		return true
+	}
+
+	return aired >= SEASON_PACK_MIN_AIRED_EPISODES && len(missing)*2 > aired
+}
+
+// getDownloadingSeasonEpisodes returns episodes of season of show that are downloading or waiting for download
+func getDownloadingSeasonEpisodes(show TvShow, season int) []Episode {
+	var episodes []Episode
+	var retList []Episode
+	db.Client.Where("tv_show_id = ? AND season = ? AND downloading_item_id <> 0", show.ID, season).Find(&episodes)
+
+	for _, episode := range episodes {
+		downloadingItem := episode.DownloadingItem
+		if (downloadingItem.Downloading || downloadingItem.Pending) && !downloadingItem.Downloaded {
+			retList = append(retList, episode)
+		}
+	}
+
+	return retList
+}
+
+// seasonPackInProgress returns true if a season pack is being downloaded for season of show
+func seasonPackInProgress(show TvShow, season int) bool {
+	for _, episode := range getDownloadingSeasonEpisodes(show, season) {
+		if episode.DownloadingItem.CurrentTorrent().SeasonPack {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coveringDownloadInProgress returns true if episode is contained in a season pack or a multi-episode release being downloaded for another episode of the same season
+func coveringDownloadInProgress(episode Episode) bool {
+	if episode.TvShowID == 0 {
+		return false
+	}
+
+	for _, other := range getDownloadingSeasonEpisodes(TvShow{Model: gorm.Model{ID: episode.TvShowID}}, episode.Season) {
+		if other.ID == episode.ID {
+			continue
+		}
+
+		torrent := other.DownloadingItem.CurrentTorrent()
+		if torrent.SeasonPack {
+			return true
+		}
+		if mediaInfo, err := indexer.GetTorrentMediaInfo(&torrent); err == nil && mediaInfo.Season == episode.Season && mediaInfo.CoversEpisode(episode.Number) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DownloadSeasonPack downloads a torrent containing the full season of show when most episodes of the season are missing.
+// The season pack is downloaded by the first missing episode of the season, and episode files are split into episode folders once download is finished.
+// Returns true if season is handled by a season pack download (started or already in progress): missing episodes of season must not be downloaded separately
+func DownloadSeasonPack(show TvShow, season int) bool {
+	if !configuration.Config.System.DownloadSeasonPacks || show.IsAnime || !show.IsSeasonMonitored(season) {
+		return false
+	}
+
+	if seasonPackInProgress(show, season) {
+		return true
+	}
+
+	seasonEpisodes, err := provider.GetSeasonEpisodeList(show, season)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"show":   show.GetTitle(),
+			"season": season,
+			"error":  err,
+		}).Warning("Could not get season episodes to check for season packs")
+		return false
+	}
+
+	missing := getMissingEpisodes(seasonEpisodes)
+	if !shouldDownloadSeasonPack(seasonEpisodes, missing) {
+		return false
+	}
+
+	lead := missing[0]
+	lead.TvShow = show
+
+	torrents, err := indexer.GetSeasonPackTorrents(lead, len(seasonEpisodes))
+	if err != nil || len(torrents) == 0 {
+		log.WithFields(log.Fields{
+			"show":    show.GetTitle(),
+			"season":  season,
+			"missing": len(missing),
+			"error":   err,
+		}).Info("No season pack found, episodes will be downloaded separately")
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"show":        show.GetTitle(),
+		"season":      season,
+		"missing":     len(missing),
+		"season_pack": torrents[0].Name,
+	}).Info("Most episodes of season are missing, downloading season pack")
+
+	for _, torrent := range lead.DownloadingItem.TorrentList {
+		db.Client.Unscoped().Delete(&torrent)
+	}
+	lead.DownloadingItem.TorrentList = torrents
+	lead.DownloadingItem.DownloadFailed = false
+	Download(&lead)
+
+	return true
+}
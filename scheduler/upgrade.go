@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+
+	downloader "github.com/macarrie/flemzerd/downloaders"
+	indexer "github.com/macarrie/flemzerd/indexers"
+	notifier "github.com/macarrie/flemzerd/notifiers"
+
+	"github.com/macarrie/flemzerd/downloadable"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// CheckUpgrades looks for better releases of downloaded episodes and movies whose quality is below the cutoff of their quality profile
+func CheckUpgrades() {
+	log.Debug("Checking downloaded items for quality upgrades")
+
+	episodes, err := db.GetDownloadedEpisodes()
+	if err != nil {
+		log.Error("Could not get downloaded episodes for upgrade check: ", err)
+	}
+	for index := range episodes {
+		// Skip removed episodes and episodes from removed shows
+		if episodes[index].DeletedAt != nil || episodes[index].TvShow.ID == 0 {
+			continue
+		}
+		Upgrade(&episodes[index])
+	}
+
+	movies, err := db.GetDownloadedMovies()
+	if err != nil {
+		log.Error("Could not get downloaded movies for upgrade check: ", err)
+	}
+	for index := range movies {
+		Upgrade(&movies[index])
+	}
+}
+
+// Upgrade searches indexers for a release of a downloaded item with a better quality than the release currently in library, according to the item quality profile.
+// If a better release is found, it is downloaded and replaces the current release once download is finished.
+// Returns true if an upgrade download has been started
+func Upgrade(d downloadable.Downloadable) bool {
+	downloadingItem := d.GetDownloadingItem()
+	if !downloadingItem.Downloaded || downloadingItem.Upgrading {
+		return false
+	}
+
+	if time.Since(downloadingItem.LastUpgradeCheck) < time.Duration(configuration.Config.System.UpgradeCheckInterval)*time.Hour {
+		return false
+	}
+
+	profile := indexer.GetQualityProfile(d)
+	currentTorrent := downloadingItem.CurrentTorrent()
+	if currentTorrent.ID == 0 {
+		return false
+	}
+
+	var currentQuality string
+	if mediaInfo, err := indexer.GetTorrentMediaInfo(&currentTorrent); err == nil {
+		currentQuality = mediaInfo.Quality
+	}
+	// Any release would be considered as better than a release of unknown quality
+	if currentQuality == "" {
+		d.GetLog().WithFields(log.Fields{
+			"torrent": currentTorrent.Name,
+		}).Debug("Quality of release in library is unknown, skipping upgrade")
+		return false
+	}
+	if profile.CutoffReached(currentQuality) {
+		return false
+	}
+
+	d.GetLog().WithFields(log.Fields{
+		"quality":         currentQuality,
+		"cutoff":          profile.Cutoff,
+		"quality_profile": profile.Name,
+	}).Info("Item quality below quality profile cutoff, looking for a better release")
+
+	downloadingItem.LastUpgradeCheck = time.Now()
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+
+	torrentList, err := indexer.GetTorrents(d)
+	if err != nil {
+		d.GetLog().Warning("Could not get torrents for upgrade: ", err)
+		return false
+	}
+
+	currentRank := profile.QualityRank(currentQuality)
+	var candidates []Torrent
+	for _, torrent := range torrentList {
+		rank := profile.QualityRank(torrent.MediaInfo.Quality)
+		if rank == -1 || torrent.Name == currentTorrent.Name {
+			continue
+		}
+
+		if currentRank == -1 || rank < currentRank {
+			candidates = append(candidates, torrent)
+		}
+	}
+
+	candidates = downloader.FillTorrentList(candidates)
+	if len(candidates) == 0 {
+		d.GetLog().Debug("No better release found for upgrade")
+		return false
+	}
+
+	d.GetLog().WithFields(log.Fields{
+		"nb": len(candidates),
+	}).Info("Better releases found. Starting upgrade download")
+
+	// Release currently in library is detached from torrent list and kept until upgrade succeeds
+	db.Client.Model(&currentTorrent).Update("torrent_list_id", 0)
+
+	downloadingItem.Upgrading = true
+	downloadingItem.UpgradedTorrentID = currentTorrent.ID
+	downloadingItem.Downloaded = false
+	downloadingItem.CurrentDownloaderId = ""
+	downloadingItem.CurrentDownloader = ""
+	downloadingItem.TorrentList = candidates
+	d.SetDownloadingItem(downloadingItem)
+	db.SaveDownloadable(&d)
+
+	notifier.NotifyDownloadStart(d)
+
+	downloader.Enqueue(d)
+
+	return true
+}
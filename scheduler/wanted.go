@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+
+	provider "github.com/macarrie/flemzerd/providers"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+// Delay before searching again a wanted episode whose last download failed or for which no torrents were found, in hours
+const BACKFILL_RETRY_DELAY = 24
+
+// GetWantedEpisodes returns aired episodes of show missing from library, according to show monitoring mode.
+// Season episode lists are retrieved from providers if refresh is true, otherwise episodes stored in database are used.
+// Episodes that never failed to download are returned first, then episodes are sorted by air date (oldest first)
+func GetWantedEpisodes(show TvShow, refresh bool) ([]Episode, error) {
+	var wanted []Episode
+
+	for _, season := range show.GetMonitoredSeasonNumbers() {
+		var episodes []Episode
+		if refresh {
+			var err error
+			if episodes, err = provider.GetSeasonEpisodeList(show, season); err != nil {
+				return []Episode{}, err
+			}
+		} else {
+			db.Client.Where("tv_show_id = ? AND season = ?", show.ID, season).Find(&episodes)
+		}
+
+		for _, episode := range getMissingEpisodes(episodes) {
+			if show.GetMonitoringMode() == MONITOR_FUTURE && episode.Date.Before(show.CreatedAt) {
+				continue
+			}
+			if episode.TvShow.ID == 0 {
+				episode.TvShow = show
+			}
+			wanted = append(wanted, episode)
+		}
+	}
+
+	sort.SliceStable(wanted, func(i, j int) bool {
+		iFailed := wanted[i].DownloadingItem.DownloadFailed || wanted[i].DownloadingItem.TorrentsNotFound
+		jFailed := wanted[j].DownloadingItem.DownloadFailed || wanted[j].DownloadingItem.TorrentsNotFound
+		if iFailed != jFailed {
+			return !iFailed
+		}
+		return wanted[i].Date.Before(wanted[j].Date)
+	})
+
+	return wanted, nil
+}
+
+// searchBackedOff returns true if wanted episode download failed or no torrents were found for it less than BACKFILL_RETRY_DELAY hours ago
+func searchBackedOff(episode Episode) bool {
+	downloadingItem := episode.DownloadingItem
+	if !downloadingItem.DownloadFailed && !downloadingItem.TorrentsNotFound {
+		return false
+	}
+
+	return time.Since(downloadingItem.LastSearch) < BACKFILL_RETRY_DELAY*time.Hour
+}
+
+// Backfill downloads missing episodes of shows that aired before recently aired episodes.
+// At most BackfillLimit downloads are started at each call to avoid flooding indexers and download client. Season packs are used when most of a season is missing
+func Backfill(shows []TvShow) {
+	limit := configuration.Config.System.BackfillLimit
+	if limit <= 0 {
+		return
+	}
+
+	started := 0
+	for _, show := range shows {
+		wanted, err := GetWantedEpisodes(show, true)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"show":  show.GetTitle(),
+				"error": err,
+			}).Warning("Could not get wanted episodes for show")
+			continue
+		}
+
+		seasonPacks := make(map[int]bool)
+		for index := range wanted {
+			if started >= limit {
+				log.WithFields(log.Fields{
+					"limit": limit,
+				}).Debug("Backfill limit reached, remaining wanted episodes will be downloaded later")
+				return
+			}
+
+			episode := &wanted[index]
+			seasonPack, checked := seasonPacks[episode.Season]
+			if !checked {
+				seasonPack = DownloadSeasonPack(show, episode.Season)
+				seasonPacks[episode.Season] = seasonPack
+				if seasonPack {
+					started++
+				}
+			}
+			if seasonPack {
+				continue
+			}
+
+			if searchBackedOff(*episode) {
+				episode.GetLog().Debug("Last search for wanted episode failed recently, skipping")
+				continue
+			}
+
+			episode.GetLog().Info("Downloading wanted episode")
+			episode.DownloadingItem.LastSearch = time.Now()
+			Download(episode)
+			started++
+		}
+	}
+}
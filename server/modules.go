@@ -1,9 +1,11 @@
 package server
 
 import (
+	"crypto/subtle"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/macarrie/flemzerd/configuration"
 	"github.com/macarrie/flemzerd/downloaders"
 	"github.com/macarrie/flemzerd/indexers"
 	"github.com/macarrie/flemzerd/mediacenters"
@@ -32,6 +34,22 @@ func getDownloadersStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, mods)
 }
 
+// notifyDownloader is called by download clients when a download ends. Requests are authenticated with the webhook token defined in configuration
+func notifyDownloader(c *gin.Context) {
+	token := configuration.Config.Interface.WebhookToken
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	if err := downloader.NotifyDownloader(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 func getMediacentersStatus(c *gin.Context) {
 	mods, _ := mediacenter.Status()
 	c.JSON(http.StatusOK, mods)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	downloader "github.com/macarrie/flemzerd/downloaders"
+	indexer "github.com/macarrie/flemzerd/indexers"
 	log "github.com/macarrie/flemzerd/logging"
 	"github.com/macarrie/flemzerd/scheduler"
 	"github.com/macarrie/flemzerd/stats"
@@ -104,6 +105,53 @@ func downloadMovie(c *gin.Context) {
 	return
 }
 
+func searchMovieTorrents(c *gin.Context) {
+	id := c.Param("id")
+
+	var movie Movie
+	req := db.Client.Find(&movie, id)
+	if req.RecordNotFound() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	results, err := indexer.ManualSearch(&movie)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func grabMovieTorrent(c *gin.Context) {
+	id := c.Param("id")
+
+	var movie Movie
+	req := db.Client.Find(&movie, id)
+	if req.RecordNotFound() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if movie.DownloadingItem.Downloaded || movie.DownloadingItem.Downloading || movie.DownloadingItem.Pending {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	var torrent Torrent
+	if err := c.BindJSON(&torrent); err != nil {
+		return
+	}
+
+	if err := scheduler.DownloadTorrent(&movie, torrent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 func skipMovieTorrentDownload(c *gin.Context) {
 	id := c.Param("id")
 	var movie Movie
@@ -118,6 +166,23 @@ func skipMovieTorrentDownload(c *gin.Context) {
 	c.AbortWithStatus(http.StatusNoContent)
 }
 
+func retryMovieImport(c *gin.Context) {
+	id := c.Param("id")
+	var movie Movie
+	req := db.Client.Unscoped().Find(&movie, id)
+	if err := req.Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	if err := downloader.RetryImport(&movie); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, movie)
+}
+
 func abortMovieDownload(c *gin.Context) {
 	id := c.Param("id")
 	var movie Movie
@@ -144,8 +209,20 @@ func updateMovie(c *gin.Context) {
 	var movieFromRequest Movie
 	c.Bind(&movieFromRequest)
 
-	movie = movieFromRequest
-	//db.Client.Save(&movie)
+	profile, ok := loadQualityProfile(movieFromRequest.QualityProfileID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown quality profile"})
+		return
+	}
+	if problems := movieFromRequest.ReleaseFilters.Check(); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid release filters", "errors": problems})
+		return
+	}
+
+	movie.QualityProfileID = movieFromRequest.QualityProfileID
+	movie.QualityProfile = profile
+	movie.ReleaseFilters = movieFromRequest.ReleaseFilters
+	db.Client.Save(&movie)
 
 	c.JSON(http.StatusOK, movie)
 }
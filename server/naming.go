@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/macarrie/flemzerd/configuration"
+	"github.com/macarrie/flemzerd/naming"
+)
+
+// getNamingPreviewPath returns library path of a sample item named with templates. Items without file template keep their release name
+func getNamingPreviewPath(libraryPath string, folder string, file string, vars naming.Variables) string {
+	if file == "" {
+		return fmt.Sprintf("%s/%s/%s", libraryPath, folder, vars.ReleaseName)
+	}
+
+	return fmt.Sprintf("%s/%s/%s.mkv", libraryPath, folder, file)
+}
+
+func previewNaming(c *gin.Context) {
+	// Templates missing from request are taken from current configuration
+	patterns := configuration.Config.Naming
+	if err := c.BindJSON(&patterns); err != nil {
+		return
+	}
+
+	if problems := patterns.Validate(); len(problems) > 0 {
+		errors := make(map[string]string)
+		for key, err := range problems {
+			errors[key] = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid naming templates", "errors": errors})
+		return
+	}
+
+	multiEpisode := naming.SampleEpisode
+	multiEpisode.LastEpisode = multiEpisode.Episode + 1
+
+	movieFolder, movieFile := patterns.MoviePath(naming.SampleMovie)
+	episodeFolder, episodeFile := patterns.EpisodePath(naming.SampleEpisode)
+	multiEpisodeFolder, multiEpisodeFile := patterns.EpisodePath(multiEpisode)
+
+	c.JSON(http.StatusOK, gin.H{
+		"movie":         getNamingPreviewPath(configuration.Config.Library.MoviePath, movieFolder, movieFile, naming.SampleMovie),
+		"episode":       getNamingPreviewPath(configuration.Config.Library.ShowPath, episodeFolder, episodeFile, naming.SampleEpisode),
+		"multi_episode": getNamingPreviewPath(configuration.Config.Library.ShowPath, multiEpisodeFolder, multiEpisodeFile, multiEpisode),
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/macarrie/flemzerd/db"
+	log "github.com/macarrie/flemzerd/logging"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+func getQualityProfiles(c *gin.Context) {
+	profiles, err := db.GetQualityProfiles()
+	if err != nil {
+		log.Error("Error while getting quality profiles from db: ", err)
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+func getQualityProfile(c *gin.Context) {
+	id := c.Param("id")
+	var profile QualityProfile
+	req := db.Client.Find(&profile, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func createQualityProfile(c *gin.Context) {
+	var profile QualityProfile
+	if err := c.BindJSON(&profile); err != nil {
+		return
+	}
+
+	profile.ID = 0
+	if problems := profile.Check(); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid quality profile", "errors": problems})
+		return
+	}
+
+	db.Client.Create(&profile)
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func updateQualityProfile(c *gin.Context) {
+	id := c.Param("id")
+	var profile QualityProfile
+	req := db.Client.Find(&profile, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	var profileFromRequest QualityProfile
+	if err := c.BindJSON(&profileFromRequest); err != nil {
+		return
+	}
+
+	profile.Name = profileFromRequest.Name
+	profile.Qualities = profileFromRequest.Qualities
+	profile.PreferredCodecs = profileFromRequest.PreferredCodecs
+	profile.MinSize = profileFromRequest.MinSize
+	profile.MaxSize = profileFromRequest.MaxSize
+	profile.Cutoff = profileFromRequest.Cutoff
+
+	if problems := profile.Check(); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid quality profile", "errors": problems})
+		return
+	}
+
+	db.Client.Save(&profile)
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func deleteQualityProfile(c *gin.Context) {
+	id := c.Param("id")
+	var profile QualityProfile
+	req := db.Client.Find(&profile, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	// Items using deleted profile fall back to default profile built from configuration
+	db.Client.Model(&TvShow{}).Where("quality_profile_id = ?", profile.ID).Update("quality_profile_id", 0)
+	db.Client.Model(&Movie{}).Where("quality_profile_id = ?", profile.ID).Update("quality_profile_id", 0)
+	db.Client.Delete(&profile)
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// loadQualityProfile returns the quality profile with given id. An empty profile is returned for id 0 (no profile assigned). Returns false if profile does not exist
+func loadQualityProfile(id uint) (QualityProfile, bool) {
+	if id == 0 {
+		return QualityProfile{}, true
+	}
+
+	var profile QualityProfile
+	if db.Client.Find(&profile, id).RecordNotFound() {
+		return QualityProfile{}, false
+	}
+
+	return profile, true
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/macarrie/flemzerd/downloaders"
+)
+
+func getQueue(c *gin.Context) {
+	c.JSON(http.StatusOK, downloader.GetQueue())
+}
+
+func pauseQueue(c *gin.Context) {
+	downloader.PauseQueue()
+	c.JSON(http.StatusOK, downloader.GetQueue())
+}
+
+func resumeQueue(c *gin.Context) {
+	downloader.ResumeQueue()
+	c.JSON(http.StatusOK, downloader.GetQueue())
+}
+
+func moveQueueItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Bad item id"})
+		return
+	}
+
+	var itemFromRequest downloader.QueueItem
+	if err := c.BindJSON(&itemFromRequest); err != nil {
+		return
+	}
+
+	if err := downloader.MoveQueueItem(c.Param("media_type"), uint(id), itemFromRequest.Position); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, downloader.GetQueue())
+}
+
+func changeQueueItemPriority(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Bad item id"})
+		return
+	}
+
+	var itemFromRequest downloader.QueueItem
+	if err := c.BindJSON(&itemFromRequest); err != nil {
+		return
+	}
+
+	if err := downloader.SetQueueItemPriority(c.Param("media_type"), uint(id), itemFromRequest.Priority); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, downloader.GetQueue())
+}
@@ -2,7 +2,7 @@ package server
 
 import (
 	"net/http"
-	"strings"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	"github.com/macarrie/flemzerd/db"
@@ -52,13 +52,13 @@ func importScannedMovie(c *gin.Context) {
 		return
 	}
 
-	torrentNamePath := strings.Split(movieInfoFromRequest.Raw, "/")
-	torrentName := torrentNamePath[len(torrentNamePath)-1]
 	movie.DownloadingItem = DownloadingItem{
 		Downloaded: true,
 		TorrentList: []Torrent{
 			Torrent{
-				Name: torrentName,
+				Name:        filepath.Base(movieInfoFromRequest.Raw),
+				DownloadDir: filepath.Dir(movieInfoFromRequest.Raw),
+				LibraryPath: movieInfoFromRequest.Raw,
 			},
 		},
 	}
@@ -105,14 +105,14 @@ func importScannedShow(c *gin.Context) {
 		for episode_nb := range season {
 			for episode := range season_from_provider[nb] {
 				if episode_nb == season_from_provider[nb][episode].Number {
-					torrentNamePath := strings.Split(season[episode_nb].Raw, "/")
-					torrentName := torrentNamePath[len(torrentNamePath)-1]
 					episode_from_provider := season_from_provider[nb][episode]
 					episode_from_provider.DownloadingItem = DownloadingItem{
 						Downloaded: true,
 						TorrentList: []Torrent{
 							Torrent{
-								Name: torrentName,
+								Name:        filepath.Base(season[episode_nb].Raw),
+								DownloadDir: filepath.Dir(season[episode_nb].Raw),
+								LibraryPath: season[episode_nb].Raw,
 							},
 						},
 					}
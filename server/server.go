@@ -171,10 +171,18 @@ func initRouter() {
 				c.JSON(http.StatusOK, gin.H{})
 				return
 			})
+
+			configRoute.POST("/naming/preview", authMiddleware.MiddlewareFunc(), previewNaming)
 		}
 
 		v1.GET("/stats", stats.Handler())
 
+		// Hooks are called by external services and are authenticated with the webhook token instead of user credentials
+		hooksRoute := v1.Group("/hooks")
+		{
+			hooksRoute.POST("/downloaders/:name", notifyDownloader)
+		}
+
 		actionsRoute := v1.Group("/actions")
 		actionsRoute.Use(authMiddleware.MiddlewareFunc())
 		{
@@ -189,19 +197,25 @@ func initRouter() {
 			tvshowsRoute.GET("/downloading", getDownloadingEpisodes)
 			tvshowsRoute.GET("/removed", getRemovedShows)
 			tvshowsRoute.GET("/downloaded", getDownloadedEpisodes)
+			tvshowsRoute.GET("/wanted", getWantedEpisodes)
 			tvshowsRoute.GET("/details/:id", getShowDetails)
 			tvshowsRoute.PUT("/details/:id", updateShow)
 			tvshowsRoute.PUT("/details/:id/custom_title", changeTvshowCustomTitle)
 			tvshowsRoute.PUT("/details/:id/use_default_title", useTvshowDefaultTitle)
 			tvshowsRoute.PUT("/details/:id/change_anime_state", changeTvshowAnimeState)
 			tvshowsRoute.GET("/details/:id/seasons/:season_nb", getSeasonDetails)
+			tvshowsRoute.PUT("/details/:id/seasons/:season_nb/monitored", changeSeasonMonitoredState)
+			tvshowsRoute.PUT("/details/:id/seasons/:season_nb/episodes/:episode_nb/monitored", changeEpisodeMonitoredState)
 			tvshowsRoute.DELETE("/details/:id", deleteShow)
 			tvshowsRoute.POST("/restore/:id", restoreShow)
 			tvshowsRoute.GET("/episodes/:id", getEpisodeDetails)
 			tvshowsRoute.POST("/episodes/:id/download", downloadEpisode)
+			tvshowsRoute.GET("/episodes/:id/search", searchEpisodeTorrents)
+			tvshowsRoute.POST("/episodes/:id/grab", grabEpisodeTorrent)
 			tvshowsRoute.DELETE("/episodes/:id", deleteEpisode)
 			tvshowsRoute.DELETE("/episodes/:id/download", abortEpisodeDownload)
 			tvshowsRoute.POST("/episodes/:id/download/skip_torrent", skipEpisodeTorrentDownload)
+			tvshowsRoute.POST("/episodes/:id/download/import", retryEpisodeImport)
 			tvshowsRoute.PUT("/episodes/:id/download_state", changeEpisodeDownloadedState)
 			tvshowsRoute.POST("/details/:id/refresh_metadata", refreshShowMetadata)
 		}
@@ -216,8 +230,11 @@ func initRouter() {
 			moviesRoute.GET("/details/:id", getMovieDetails)
 			moviesRoute.DELETE("/details/:id", deleteMovie)
 			moviesRoute.POST("/details/:id/download", downloadMovie)
+			moviesRoute.GET("/details/:id/search", searchMovieTorrents)
+			moviesRoute.POST("/details/:id/grab", grabMovieTorrent)
 			moviesRoute.DELETE("/details/:id/download", abortMovieDownload)
 			moviesRoute.POST("/details/:id/download/skip_torrent", skipMovieTorrentDownload)
+			moviesRoute.POST("/details/:id/download/import", retryMovieImport)
 			moviesRoute.PUT("/details/:id", updateMovie)
 			moviesRoute.PUT("/details/:id/download_state", changeMovieDownloadedState)
 			moviesRoute.PUT("/details/:id/custom_title", changeMovieCustomTitle)
@@ -290,6 +307,27 @@ func initRouter() {
 			}
 		}
 
+		// Queued items are identified by media type (movie or episode) and id
+		queueRoute := v1.Group("/queue")
+		queueRoute.Use(authMiddleware.MiddlewareFunc())
+		{
+			queueRoute.GET("/", getQueue)
+			queueRoute.POST("/pause", pauseQueue)
+			queueRoute.POST("/resume", resumeQueue)
+			queueRoute.PUT("/:media_type/:id/position", moveQueueItem)
+			queueRoute.PUT("/:media_type/:id/priority", changeQueueItemPriority)
+		}
+
+		qualityProfilesRoute := v1.Group("/quality_profiles")
+		qualityProfilesRoute.Use(authMiddleware.MiddlewareFunc())
+		{
+			qualityProfilesRoute.GET("/", getQualityProfiles)
+			qualityProfilesRoute.POST("/", createQualityProfile)
+			qualityProfilesRoute.GET("/:id", getQualityProfile)
+			qualityProfilesRoute.PUT("/:id", updateQualityProfile)
+			qualityProfilesRoute.DELETE("/:id", deleteQualityProfile)
+		}
+
 		notificationsRoute := v1.Group("/notifications")
 		notificationsRoute.Use(authMiddleware.MiddlewareFunc())
 		{
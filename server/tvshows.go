@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	downloader "github.com/macarrie/flemzerd/downloaders"
+	indexer "github.com/macarrie/flemzerd/indexers"
 	log "github.com/macarrie/flemzerd/logging"
 	provider "github.com/macarrie/flemzerd/providers"
 	"github.com/macarrie/flemzerd/scheduler"
@@ -49,6 +50,28 @@ func getDownloadedEpisodes(c *gin.Context) {
 	c.JSON(http.StatusOK, episodes)
 }
 
+func getWantedEpisodes(c *gin.Context) {
+	shows, err := db.GetTrackedTvShows()
+	if err != nil {
+		log.Error("Error while gettings tracked shows from db: ", err)
+	}
+
+	wanted := []Episode{}
+	for _, show := range shows {
+		episodes, err := scheduler.GetWantedEpisodes(show, false)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"show":  show.GetTitle(),
+				"error": err,
+			}).Warning("Could not get wanted episodes for show")
+			continue
+		}
+		wanted = append(wanted, episodes...)
+	}
+
+	c.JSON(http.StatusOK, wanted)
+}
+
 func getShowDetails(c *gin.Context) {
 	id := c.Param("id")
 	var show TvShow
@@ -73,6 +96,23 @@ func updateShow(c *gin.Context) {
 	c.Bind(&showFromRequest)
 
 	show = showFromRequest
+
+	if problems := show.ReleaseFilters.Check(); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid release filters", "errors": problems})
+		return
+	}
+
+	if problems := show.CheckMonitoring(); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid monitoring settings", "errors": problems})
+		return
+	}
+
+	profile, ok := loadQualityProfile(show.QualityProfileID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown quality profile"})
+		return
+	}
+	show.QualityProfile = profile
 	db.Client.Save(&show)
 
 	c.JSON(http.StatusOK, show)
@@ -177,6 +217,93 @@ func getSeasonDetails(c *gin.Context) {
 	})
 }
 
+func changeSeasonMonitoredState(c *gin.Context) {
+	id := c.Param("id")
+	var show TvShow
+	req := db.Client.First(&show, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	seasonNb, err := strconv.Atoi(c.Param("season_nb"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Bad season number"})
+		return
+	}
+
+	var seasonFromRequest TvSeason
+	if err := c.BindJSON(&seasonFromRequest); err != nil {
+		return
+	}
+
+	for _, season := range show.Seasons {
+		if season.SeasonNumber != seasonNb {
+			continue
+		}
+
+		db.Client.Model(&season).Update("monitored", seasonFromRequest.Monitored)
+		season.Monitored = seasonFromRequest.Monitored
+
+		c.JSON(http.StatusOK, season)
+		return
+	}
+
+	// Seasons released after show was added may not be in database yet
+	if seasonNb < 0 || seasonNb > show.NumberOfSeasons {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	season := TvSeason{
+		SeasonNumber: seasonNb,
+		TvShowID:     int(show.ID),
+	}
+	db.Client.Create(&season)
+	db.Client.Model(&season).Update("monitored", seasonFromRequest.Monitored)
+	season.Monitored = seasonFromRequest.Monitored
+
+	c.JSON(http.StatusOK, season)
+}
+
+func changeEpisodeMonitoredState(c *gin.Context) {
+	id := c.Param("id")
+	var show TvShow
+	req := db.Client.First(&show, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	seasonNb, err := strconv.Atoi(c.Param("season_nb"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Bad season number"})
+		return
+	}
+	episodeNb, err := strconv.Atoi(c.Param("episode_nb"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Bad episode number"})
+		return
+	}
+
+	var episode Episode
+	req = db.Client.Where("tv_show_id = ? AND season = ? AND number = ?", show.ID, seasonNb, episodeNb).First(&episode)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	var episodeFromRequest Episode
+	if err := c.BindJSON(&episodeFromRequest); err != nil {
+		return
+	}
+
+	db.Client.Model(&episode).Update("monitored", episodeFromRequest.Monitored)
+	episode.Monitored = episodeFromRequest.Monitored
+
+	c.JSON(http.StatusOK, episode)
+}
+
 func deleteShow(c *gin.Context) {
 	id := c.Param("id")
 	var show TvShow
@@ -253,6 +380,53 @@ func downloadEpisode(c *gin.Context) {
 	return
 }
 
+func searchEpisodeTorrents(c *gin.Context) {
+	id := c.Param("id")
+
+	var ep Episode
+	req := db.Client.Find(&ep, id)
+	if req.RecordNotFound() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	results, err := indexer.ManualSearch(&ep)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func grabEpisodeTorrent(c *gin.Context) {
+	id := c.Param("id")
+
+	var ep Episode
+	req := db.Client.Find(&ep, id)
+	if req.RecordNotFound() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if ep.DownloadingItem.Downloaded || ep.DownloadingItem.Downloading || ep.DownloadingItem.Pending {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	var torrent Torrent
+	if err := c.BindJSON(&torrent); err != nil {
+		return
+	}
+
+	if err := scheduler.DownloadTorrent(&ep, torrent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 func deleteEpisode(c *gin.Context) {
 	id := c.Param("id")
 	var ep Episode
@@ -293,6 +467,23 @@ func skipEpisodeTorrentDownload(c *gin.Context) {
 	c.AbortWithStatus(http.StatusNoContent)
 }
 
+func retryEpisodeImport(c *gin.Context) {
+	id := c.Param("id")
+	var ep Episode
+	req := db.Client.Find(&ep, id)
+	if req.RecordNotFound() {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	if err := downloader.RetryImport(&ep); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ep)
+}
+
 func changeEpisodeDownloadedState(c *gin.Context) {
 	id := c.Param("id")
 	var episode Episode
@@ -69,6 +69,9 @@ class NotificationComponent extends React.Component<Props, State> {
             case Const.NOTIFICATION_NO_TORRENT:
                 status = Const.WARNING;
                 break;
+            case Const.NOTIFICATION_DOWNLOAD_STALLED:
+                status = Const.WARNING;
+                break;
             default:
                 status = Const.UNKNOWN;
                 break;
@@ -198,6 +201,14 @@ class NotificationComponent extends React.Component<Props, State> {
             );
         }
 
+        if (this.state.notification.Type === Const.NOTIFICATION_DOWNLOAD_STALLED) {
+            return (
+                <span>
+                    Download stalled: {this.getMediaLink()}
+                </span>
+            );
+        }
+
         return "";
     }
 
@@ -206,7 +217,7 @@ class NotificationComponent extends React.Component<Props, State> {
             return;
         }
 
-        if (this.state.notification.Type === Const.NOTIFICATION_TEXT) {
+        if (this.state.notification.Type === Const.NOTIFICATION_TEXT || this.state.notification.Type === Const.NOTIFICATION_DOWNLOAD_STALLED) {
             return (
                 <span>
                     {this.state.notification.Content}
@@ -3,6 +3,7 @@ import React from "react";
 import API from "../../utils/api";
 import Config from "../../types/config";
 import MediaInfo from "../../types/media_info";
+import ScanReport from "../../types/scan_report";
 import Empty from "../empty";
 import Helpers from "../../utils/helpers";
 
@@ -14,6 +15,7 @@ type Props = {
 type State = {
     config :Config,
     media_list :MediaInfo[],
+    report :ScanReport | null,
     import_status :string,
 };
 
@@ -24,6 +26,7 @@ class LibraryScan extends React.Component<Props, State> {
         this.state = {
             config: this.props.config,
             media_list: [],
+            report: null,
             import_status: "",
         };
 
@@ -39,13 +42,26 @@ class LibraryScan extends React.Component<Props, State> {
 
     scanMovies() {
         API.Modules.Scanner.scan_movies().then(response => {
+            let report :ScanReport = response.data;
+            // Unmatched movies can be added to tracked movies
             let media_list = Array<MediaInfo>();
-            if (response.data !== null) {
-                media_list = response.data;
+            let titles = new Set<string>();
+            for (let file of report.Unmatched) {
+                if (file.Info.title === "" || titles.has(file.Info.title)) {
+                    continue;
+                }
+                titles.add(file.Info.title);
+                media_list.push({
+                    ...file.Info,
+                    "Id": String(file.ID),
+                    "selected": false,
+                } as MediaInfo);
             }
+
             this.setState({
                 import_status: "scanned",
                 media_list: media_list,
+                report: report,
             });
         }).catch(error => {
             console.log("Getting movie scan list error: ", error);
@@ -54,18 +70,32 @@ class LibraryScan extends React.Component<Props, State> {
 
     scanShows() {
         API.Modules.Scanner.scan_shows().then(response => {
+            let report :ScanReport = response.data;
+            // Episodes of unmatched shows are grouped by show and season so that shows can be added to tracked shows
+            let shows :any = {};
+            for (let file of report.Unmatched) {
+                if (file.Info.title === "" || file.Info.episode === 0) {
+                    continue;
+                }
+                let show = shows[file.Info.title] = shows[file.Info.title] || {};
+                let season = show[file.Info.season] = show[file.Info.season] || {};
+                season[file.Info.episode] = file.Info;
+            }
+
             let media_list :MediaInfo[] = new Array<MediaInfo>();
-            for (let show in response.data) {
+            for (let show in shows) {
                 media_list.push({
+                    "Id": show,
                     "title": show,
                     "selected": false,
-                    "data": response.data[show],
+                    "data": shows[show],
                 } as MediaInfo);
             }
 
             this.setState({
                 import_status: "scanned",
                 media_list: media_list,
+                report: report,
             });
         }).catch(error => {
             console.log("Getting tvshow scan list error: ", error);
@@ -267,6 +297,7 @@ class LibraryScan extends React.Component<Props, State> {
     abortImport() {
         this.setState({
             media_list: [],
+            report: null,
             import_status: "",
         });
     }
@@ -275,6 +306,22 @@ class LibraryScan extends React.Component<Props, State> {
         return (
             <div className={"column is-full"}>
                 <hr />
+                    {this.state.report !== null && (
+                        <div className="has-text-grey">
+                            <small>
+                                {this.state.report.Matched.length + this.state.report.Unchanged} files linked to tracked media,
+                                &nbsp;{this.state.report.Unmatched.length} unmatched files,
+                                &nbsp;{this.state.report.Ambiguous.length} ambiguous files
+                            </small>
+                            <ul>
+                                {this.state.report.Ambiguous.map(file =>
+                                    <li key={file.ID}>
+                                        <small><code>{file.Path}</code> <i>{file.Reason}</i></small>
+                                    </li>
+                                )}
+                            </ul>
+                        </div>
+                    )}
                     {Helpers.count(this.state.media_list) > 0 ? (
                         <div className="columns is-mobile is-gapless">
                             <div className="column">
@@ -6,6 +6,7 @@ export default class Const {
     static NOTIFICATION_DOWNLOAD_FAILURE = 4;
     static NOTIFICATION_TEXT = 5;
     static NOTIFICATION_NO_TORRENT = 6;
+    static NOTIFICATION_DOWNLOAD_STALLED = 7;
 
     static OK = 0;
     static WARNING = 1;
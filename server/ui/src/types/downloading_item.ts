@@ -1,3 +1,4 @@
+import MediaProbe from "./media_probe";
 import Torrent from "./torrent";
 
 type DownloadingItem = {
@@ -11,6 +12,9 @@ type DownloadingItem = {
     CurrentDownloaderId: string,
     DownloadFailed: boolean,
     TorrentsNotFound: boolean,
+    ImportFailed: boolean,
+    ImportFailureReason: string,
+    Probe: MediaProbe,
 };
 
 export default DownloadingItem;
@@ -0,0 +1,12 @@
+type MediaProbe = {
+    Probed: boolean,
+    Container: string,
+    Duration: number,
+    Width: number,
+    Height: number,
+    VideoCodec: string,
+    AudioCodecs: string,
+    AudioLanguages: string,
+};
+
+export default MediaProbe;
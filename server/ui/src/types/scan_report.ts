@@ -0,0 +1,21 @@
+export type ScannedFile = {
+    ID: number,
+    Path: string,
+    MediaType: number,
+    Size: number,
+    ModTime: string,
+    Status: string,
+    Reason: string,
+    MovieID: number,
+    EpisodeID: number,
+    Info: any,
+};
+
+type ScanReport = {
+    Matched: ScannedFile[],
+    Unmatched: ScannedFile[],
+    Ambiguous: ScannedFile[],
+    Unchanged: number,
+};
+
+export default ScanReport;
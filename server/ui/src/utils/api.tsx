@@ -40,6 +40,9 @@ export default class API {
         },
         check: function () {
             return API.auth().get('/config/check');
+        },
+        previewNaming: function (patterns: object) {
+            return API.auth().post('/config/naming/preview', patterns);
         }
     };
 
@@ -78,6 +81,9 @@ export default class API {
         skipTorrent: function (id: number) {
             return API.auth().post('/movies/details/' + id + '/download/skip_torrent');
         },
+        retryImport: function (id: number) {
+            return API.auth().post('/movies/details/' + id + '/download/import');
+        },
         abortDownload: function (id: number) {
             return API.auth().delete('/movies/details/' + id + '/download');
         },
@@ -114,6 +120,9 @@ export default class API {
         skipTorrent: function (id: number) {
             return API.auth().post('/tvshows/episodes/' + id + '/download/skip_torrent');
         },
+        retryImport: function (id: number) {
+            return API.auth().post('/tvshows/episodes/' + id + '/download/import');
+        },
         abortDownload: function (id: number) {
             return API.auth().delete('/tvshows/episodes/' + id + '/download');
         },
@@ -1,75 +1,295 @@
+// Package vidocq extracts media information (quality, codecs, release type, season/episode numbers, ...) from release names and file names.
+// Parsing is done in-process so that torrent filtering and library scanning do not depend on any external executable.
 package vidocq
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"os/exec"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
-	log "github.com/macarrie/flemzerd/logging"
 	. "github.com/macarrie/flemzerd/objects"
-
-	"github.com/pkg/errors"
 )
 
-var LocalVidocqAvailable bool
+type tokenRule struct {
+	Value   string
+	Pattern *regexp.Regexp
+}
+
+// Separators used in release names. Tokens are only matched when surrounded by separators (or string boundaries) to avoid matching inside words
+const sep = `(?:^|[\s._\-\[\]\(\)+,])`
+const sepEnd = `(?:$|[\s._\-\[\]\(\)+,])`
+
+func token(expr string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)` + sep + `(?:` + expr + `)` + sepEnd)
+}
+
+var videoContainers = []string{"mkv", "mp4", "avi", "m4v", "mov", "wmv", "mpg", "mpeg", "ts", "m2ts", "webm", "flv", "ogm", "divx", "iso"}
+
+var qualityRules = []tokenRule{
+	{"2160p", token(`2160[pi]|4k|uhd`)},
+	{"1440p", token(`1440[pi]`)},
+	{"1080p", token(`1080[pi]|fhd`)},
+	{"900p", token(`900[pi]`)},
+	{"720p", token(`720[pi]`)},
+	{"576p", token(`576[pi]`)},
+	{"480p", token(`480[pi]`)},
+	{"16k", token(`16k`)},
+	{"8k", token(`8k`)},
+	{"5k", token(`5k`)},
+}
+
+// Order matters: most specific release types are checked first
+var releaseTypeRules = []tokenRule{
+	{"cam", token(`cam|camrip|cam-?rip|hd-?cam`)},
+	{"telesync", token(`ts|telesync|hd-?ts|pdvd|ptvd`)},
+	{"telecine", token(`tc|telecine|hd-?tc`)},
+	{"screener", token(`scr|screener|dvd-?scr|bd-?scr|web-?scr`)},
+	{"webdl", token(`web-?dl|webdl|web-?rip|webrip|web|amzn|nf|dsnp|hulu|hmax`)},
+	{"blurayrip", token(`blu-?ray|bd-?rip|br-?rip|bdrip|brrip|bd|bd-?remux|remux`)},
+	{"hdtv", token(`hdtv|pdtv|sdtv|dsr|tv-?rip|dvb|hdtvrip`)},
+	{"dvdrip", token(`dvd-?rip|dvd|dvdr|dvd-?5|dvd-?9`)},
+}
+
+var videoCodecRules = []tokenRule{
+	{"h265", token(`[xh]\.?265|hevc`)},
+	{"h264", token(`[xh]\.?264|avc`)},
+	{"av1", token(`av1`)},
+	{"vp9", token(`vp9`)},
+	{"xvid", token(`xvid`)},
+	{"divx", token(`divx`)},
+	{"mpeg2", token(`mpeg-?2`)},
+}
+
+var audioCodecRules = []tokenRule{
+	{"truehd", token(`true-?hd`)},
+	{"atmos", token(`atmos`)},
+	{"dts-hd", token(`dts-?hd(?:[\s._\-]?ma)?|dts-?x`)},
+	{"dts", token(`dts`)},
+	{"eac3", token(`e-?ac-?3|ddp(?:5\.1|2\.0)?|dd\+(?:5\.1|2\.0)?`)},
+	{"ac3", token(`ac-?3|dd(?:5\.1|2\.0)?`)},
+	{"aac", token(`aac(?:2\.0|5\.1)?|he-?aac`)},
+	{"flac", token(`flac`)},
+	{"opus", token(`opus`)},
+	{"mp3", token(`mp3`)},
+}
 
-func init() {
-	CheckVidocq()
+var audioChannelsRules = []tokenRule{
+	{"7.1", regexp.MustCompile(`(?i)(?:^|[^0-9])7[\s.]1(?:$|[^0-9])`)},
+	{"5.1", regexp.MustCompile(`(?i)(?:^|[^0-9])5[\s.]1(?:$|[^0-9])|6ch`)},
+	{"2.0", regexp.MustCompile(`(?i)(?:^|[^0-9])2[\s.]0(?:$|[^0-9])|stereo`)},
+	{"1.0", regexp.MustCompile(`(?i)(?:^|[^0-9])1[\s.]0(?:$|[^0-9])|mono`)},
 }
 
-func CheckVidocq() {
-	_, err := exec.LookPath("vidocq")
-	if err != nil {
-		log.Error("Local vidocq executable not found. Quality filters and torrent control will not be done")
-		LocalVidocqAvailable = false
-		return
+var (
+	seasonEpisodeRegexp   = regexp.MustCompile(`(?i)` + sep + `s(\d{1,3})[\s._\-]?e(\d{1,4})`)
+	nextEpisodeRegexp     = regexp.MustCompile(`(?i)^(?:[\s._]?-?[\s._]?e|-)(\d{1,4})(?:$|[\s._\-\[\]\(\)+,]|e\d)`)
+	crossEpisodeRegexp    = regexp.MustCompile(`(?i)` + sep + `(\d{1,2})x(\d{2,3})` + sepEnd)
+	verboseEpisodeRegexp  = regexp.MustCompile(`(?i)` + sep + `season[\s._\-]?(\d{1,3})[\s._\-]*episode[\s._\-]?(\d{1,4})`)
+	seasonOnlyRegexp      = regexp.MustCompile(`(?i)` + sep + `(?:s|season[\s._\-]?)(\d{1,3})` + sepEnd)
+	absoluteEpisodeRegexp = regexp.MustCompile(`\s-\s(\d{2,4})(?:v\d)?` + sepEnd)
+	yearRegexp            = regexp.MustCompile(`(?:19|20)\d{2}`)
+	releaseGroupRegexp    = regexp.MustCompile(`-([A-Za-z0-9]+)(?:\s?\[[^\]]*\])*$`)
+	leadingTagRegexp      = regexp.MustCompile(`^\s*\[[^\]]*\]\s*`)
+	trailingTagRegexp     = regexp.MustCompile(`(?:\s*\[[^\]]*\])+$`)
+	separatorsRegexp      = regexp.MustCompile(`[._]+`)
+	spacesRegexp          = regexp.MustCompile(`\s+`)
+	sampleRegexp          = token("sample")
+)
+
+// GetInfo parses a release name (torrent name, file name or path) and returns media info detected in it.
+// media_type can be given (MOVIE or EPISODE) to force the media type of the returned info.
+// A non nil error is returned if no information could be extracted from given name.
+func GetInfo(name string, media_type ...int) (MediaInfo, error) {
+	if strings.TrimSpace(name) == "" {
+		return MediaInfo{}, errors.New("cannot parse empty release name")
 	}
 
-	cmd := exec.Command("vidocq", "--help")
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
+	info := Parse(name)
+	if len(media_type) > 0 {
+		switch media_type[0] {
+		case MOVIE:
+			info.Type = "movie"
+		case EPISODE:
+			info.Type = "episode"
+		}
+	}
 
-	if err := cmd.Run(); err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("Vidocq found but could not be executed correctly")
-		LocalVidocqAvailable = false
-		return
+	if info.Title == "" && info.Quality == "" && info.Season == 0 && info.Episode == 0 && info.Year == 0 {
+		return info, errors.New("no media information found in release name")
 	}
 
-	LocalVidocqAvailable = true
+	return info, nil
 }
 
-func GetInfo(name string, media_type ...int) (MediaInfo, error) {
-	if !LocalVidocqAvailable {
-		return MediaInfo{}, fmt.Errorf("vidocq not available locally")
+// IsSample returns true if file located at path is a sample: file name contains a sample tag, or file is located in a sample folder.
+// Other path components are ignored so that titles containing "sample" are not mistaken for samples
+func IsSample(path string) bool {
+	return sampleRegexp.MatchString(filepath.Base(path)) || strings.EqualFold(filepath.Base(filepath.Dir(path)), "sample")
+}
+
+// Parse extracts all possible media info from release name. Fields that could not be detected are left empty.
+func Parse(name string) MediaInfo {
+	info := MediaInfo{
+		Raw: name,
+	}
+
+	base := filepath.Base(name)
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(base), "."))
+	for _, container := range videoContainers {
+		if ext == container {
+			info.Container = ext
+			base = strings.TrimSuffix(base, filepath.Ext(base))
+			break
+		}
+	}
+
+	// Anime-like releases often start with the group name between brackets ("[Group] Show - 01 [720p]")
+	if match := leadingTagRegexp.FindString(base); match != "" {
+		info.ReleaseGroup = strings.Trim(strings.TrimSpace(match), "[]")
+		base = base[len(match):]
+	}
+
+	// titleEnd is the index in base where the first technical token starts. Everything before is considered as title
+	titleEnd := len(base)
+	updateTitleEnd := func(index int) {
+		if index >= 0 && index < titleEnd {
+			titleEnd = index
+		}
 	}
 
-	var cmd *exec.Cmd
-	if len(media_type) > 0 && media_type[0] == MOVIE {
-		cmd = exec.Command("vidocq", "--type", "movie", name)
-	} else if len(media_type) > 0 && media_type[0] == EPISODE {
-		cmd = exec.Command("vidocq", "--type", "episode", name)
+	if loc := seasonEpisodeRegexp.FindStringSubmatchIndex(base); loc != nil {
+		info.Season, _ = strconv.Atoi(base[loc[2]:loc[3]])
+		info.Episode, _ = strconv.Atoi(base[loc[4]:loc[5]])
+		info.LastEpisode = parseLastEpisode(base[loc[1]:], info.Episode)
+		updateTitleEnd(loc[0])
+	} else if loc := verboseEpisodeRegexp.FindStringSubmatchIndex(base); loc != nil {
+		info.Season, _ = strconv.Atoi(base[loc[2]:loc[3]])
+		info.Episode, _ = strconv.Atoi(base[loc[4]:loc[5]])
+		updateTitleEnd(loc[0])
+	} else if loc := crossEpisodeRegexp.FindStringSubmatchIndex(base); loc != nil {
+		info.Season, _ = strconv.Atoi(base[loc[2]:loc[3]])
+		info.Episode, _ = strconv.Atoi(base[loc[4]:loc[5]])
+		updateTitleEnd(loc[0])
+	} else if loc := seasonOnlyRegexp.FindStringSubmatchIndex(base); loc != nil {
+		info.Season, _ = strconv.Atoi(base[loc[2]:loc[3]])
+		// Anime releases can use absolute numbering after the season ("Show S2 - 01")
+		if episodeLoc := absoluteEpisodeRegexp.FindStringSubmatchIndex(base[loc[3]:]); episodeLoc != nil && episodeLoc[0] == 0 {
+			info.Episode, _ = strconv.Atoi(base[loc[3]+episodeLoc[2] : loc[3]+episodeLoc[3]])
+		}
+		updateTitleEnd(loc[0])
+	} else if loc := absoluteEpisodeRegexp.FindStringSubmatchIndex(base); loc != nil {
+		// Absolute numbering ("Show - 123"), mostly used for anime releases
+		info.Episode, _ = strconv.Atoi(base[loc[2]:loc[3]])
+		updateTitleEnd(loc[0])
+	}
+
+	// The last year found is used. A year found at the very beginning of the name is part of the title (ex: "2012.2009.720p.BluRay")
+	yearMatches := yearRegexp.FindAllStringIndex(base, -1)
+	for i := len(yearMatches) - 1; i >= 0; i-- {
+		loc := yearMatches[i]
+		if loc[0] == 0 || !isSeparator(base, loc[0]-1) || !isSeparator(base, loc[1]) {
+			continue
+		}
+		info.Year, _ = strconv.Atoi(base[loc[0]:loc[1]])
+		updateTitleEnd(loc[0] - 1)
+		break
+	}
+
+	info.Quality = matchRules(qualityRules, base, updateTitleEnd)
+	info.ReleaseType = matchRules(releaseTypeRules, base, updateTitleEnd)
+	info.VideoCodec = matchRules(videoCodecRules, base, updateTitleEnd)
+	info.AudioCodec = matchRules(audioCodecRules, base, updateTitleEnd)
+	info.AudioQuality = matchRules(audioChannelsRules, base, nil)
+
+	if titleEnd < len(base) {
+		if match := releaseGroupRegexp.FindStringSubmatch(base[titleEnd:]); match != nil && !isTechnicalToken(match[1]) {
+			info.ReleaseGroup = match[1]
+		}
+	}
+
+	info.Title = cleanTitle(base[:titleEnd])
+
+	if info.Season != 0 || info.Episode != 0 {
+		info.Type = "episode"
 	} else {
-		cmd = exec.Command("vidocq", name)
+		info.Type = "movie"
 	}
 
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
+	return info
+}
+
+// Maximum number of episodes in a multi-episode release. Larger ranges are other numbers following the episode number (years, resolutions, ...)
+const MAX_MULTI_EPISODE_RANGE = 50
+
+// parseLastEpisode returns the last episode of multi-episode releases, from the part of the name following the first episode number ("E06", "-07", "E06E07", ...).
+// 0 is returned if release contains a single episode
+func parseLastEpisode(s string, firstEpisode int) int {
+	lastEpisode := firstEpisode
+	for {
+		loc := nextEpisodeRegexp.FindStringSubmatchIndex(s)
+		if loc == nil {
+			break
+		}
 
-	err := cmd.Run()
-	if err != nil {
-		log.Debug("[Vidocq] Could not get execute vidocq: ", err)
-		return MediaInfo{}, errors.Wrap(err, "cannot execute vidocq")
+		number, _ := strconv.Atoi(s[loc[2]:loc[3]])
+		if number <= lastEpisode || number-firstEpisode > MAX_MULTI_EPISODE_RANGE {
+			break
+		}
+		lastEpisode = number
+		s = s[loc[3]:]
 	}
 
-	var mediaInfo MediaInfo
-	parseErr := json.Unmarshal(stdout.Bytes(), &mediaInfo)
-	if parseErr != nil {
-		log.Error("[Vidocq] Could not parse request result:", parseErr)
-		return MediaInfo{}, errors.Wrap(err, "cannot parse vidocq request result")
+	if lastEpisode == firstEpisode {
+		return 0
+	}
+	return lastEpisode
+}
+
+func matchRules(rules []tokenRule, s string, onMatch func(index int)) string {
+	for _, rule := range rules {
+		if loc := rule.Pattern.FindStringIndex(s); loc != nil {
+			if onMatch != nil {
+				onMatch(loc[0])
+			}
+			return rule.Value
+		}
 	}
 
-	return mediaInfo, nil
+	return ""
+}
+
+// isSeparator returns true if character at given index is a separator or if index is out of string bounds
+func isSeparator(s string, index int) bool {
+	if index < 0 || index >= len(s) {
+		return true
+	}
+
+	return strings.ContainsRune(" ._-[]()+,", rune(s[index]))
+}
+
+// isTechnicalToken returns true if given string is a known technical tag, to avoid taking "WEB-DL" or "DTS-HD" suffixes as release groups
+func isTechnicalToken(s string) bool {
+	for _, rules := range [][]tokenRule{qualityRules, releaseTypeRules, videoCodecRules, audioCodecRules} {
+		if matchRules(rules, s, nil) != "" {
+			return true
+		}
+	}
+
+	switch strings.ToLower(s) {
+	case "dl", "rip", "hd", "ma":
+		return true
+	}
+
+	return false
+}
+
+func cleanTitle(title string) string {
+	title = trailingTagRegexp.ReplaceAllString(title, "")
+	title = separatorsRegexp.ReplaceAllString(title, " ")
+	title = strings.Trim(title, " -([")
+	title = spacesRegexp.ReplaceAllString(title, " ")
+
+	return strings.TrimSpace(title)
 }
@@ -0,0 +1,175 @@
+package vidocq
+
+import (
+	"testing"
+
+	. "github.com/macarrie/flemzerd/objects"
+)
+
+func TestParse(t *testing.T) {
+	testMatrix := []struct {
+		Name     string
+		Expected MediaInfo
+	}{
+		{
+			"The.Expanse.S02E05.720p.HDTV.x264-KILLERS[ettv]",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, Quality: "720p", ReleaseType: "hdtv", VideoCodec: "h264", ReleaseGroup: "KILLERS", Type: "episode"},
+		},
+		{
+			"The.Expanse.S02E05.720p.WEB-DL.DD5.1.H264-RARBG.mkv",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, Quality: "720p", ReleaseType: "webdl", VideoCodec: "h264", AudioCodec: "ac3", AudioQuality: "5.1", ReleaseGroup: "RARBG", Container: "mkv", Type: "episode"},
+		},
+		{
+			"Game of Thrones S08E03 1080p WEB H264-MEMENTO",
+			MediaInfo{Title: "Game of Thrones", Season: 8, Episode: 3, Quality: "1080p", ReleaseType: "webdl", VideoCodec: "h264", ReleaseGroup: "MEMENTO", Type: "episode"},
+		},
+		{
+			"Westworld.S03E01.2160p.AMZN.WEB-DL.DDP5.1.HEVC-NTb",
+			MediaInfo{Title: "Westworld", Season: 3, Episode: 1, Quality: "2160p", ReleaseType: "webdl", VideoCodec: "h265", AudioCodec: "eac3", AudioQuality: "5.1", ReleaseGroup: "NTb", Type: "episode"},
+		},
+		{
+			"/library/shows/doctor_who/season_11/doctor.who.2005.11x03.rosa.720p.hdtv.x264-mtb.mkv",
+			MediaInfo{Title: "doctor who", Season: 11, Episode: 3, Year: 2005, Quality: "720p", ReleaseType: "hdtv", VideoCodec: "h264", ReleaseGroup: "mtb", Container: "mkv", Type: "episode"},
+		},
+		{
+			"The.Expanse.S02E05E06.720p.HDTV.x264-KILLERS",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, LastEpisode: 6, Quality: "720p", ReleaseType: "hdtv", VideoCodec: "h264", ReleaseGroup: "KILLERS", Type: "episode"},
+		},
+		{
+			"The.Expanse.S02E05-E06.720p.HDTV.x264-KILLERS",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, LastEpisode: 6, Quality: "720p", ReleaseType: "hdtv", VideoCodec: "h264", ReleaseGroup: "KILLERS", Type: "episode"},
+		},
+		{
+			"The Expanse S02E05-07 1080p WEB H264-MEMENTO",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, LastEpisode: 7, Quality: "1080p", ReleaseType: "webdl", VideoCodec: "h264", ReleaseGroup: "MEMENTO", Type: "episode"},
+		},
+		{
+			"Show.S01E01-2019.720p",
+			MediaInfo{Title: "Show", Season: 1, Episode: 1, Year: 2019, Quality: "720p", Type: "episode"},
+		},
+		{
+			"The.Expanse.S02E05-720p-KILLERS",
+			MediaInfo{Title: "The Expanse", Season: 2, Episode: 5, Quality: "720p", ReleaseGroup: "KILLERS", Type: "episode"},
+		},
+		{
+			"Breaking Bad Season 5 Episode 14 Ozymandias",
+			MediaInfo{Title: "Breaking Bad", Season: 5, Episode: 14, Type: "episode"},
+		},
+		{
+			"Mr.Robot.S04.COMPLETE.1080p.WEBRip.x265-RARBG",
+			MediaInfo{Title: "Mr Robot", Season: 4, Quality: "1080p", ReleaseType: "webdl", VideoCodec: "h265", ReleaseGroup: "RARBG", Type: "episode"},
+		},
+		{
+			"[HorribleSubs] One Punch Man S2 - 01 [720p].mkv",
+			MediaInfo{Title: "One Punch Man", Season: 2, Episode: 1, Quality: "720p", ReleaseGroup: "HorribleSubs", Container: "mkv", Type: "episode"},
+		},
+		{
+			"[Erai-raws] Boruto - Naruto Next Generations - 152 [1080p].mkv",
+			MediaInfo{Title: "Boruto - Naruto Next Generations", Episode: 152, Quality: "1080p", ReleaseGroup: "Erai-raws", Container: "mkv", Type: "episode"},
+		},
+		{
+			"Blade.Runner.2049.2017.1080p.BluRay.x264.DTS-HD.MA.7.1-FGT",
+			MediaInfo{Title: "Blade Runner 2049", Year: 2017, Quality: "1080p", ReleaseType: "blurayrip", VideoCodec: "h264", AudioCodec: "dts-hd", AudioQuality: "7.1", ReleaseGroup: "FGT", Type: "movie"},
+		},
+		{
+			"2012.2009.720p.BluRay.x264.AAC-ETRG.mp4",
+			MediaInfo{Title: "2012", Year: 2009, Quality: "720p", ReleaseType: "blurayrip", VideoCodec: "h264", AudioCodec: "aac", ReleaseGroup: "ETRG", Container: "mp4", Type: "movie"},
+		},
+		{
+			"Avengers Endgame (2019) [WEBRip] [1080p] [YTS.LT]",
+			MediaInfo{Title: "Avengers Endgame", Year: 2019, Quality: "1080p", ReleaseType: "webdl", Type: "movie"},
+		},
+		{
+			"Joker.2019.HDCAM.x264.AC3-ETRG",
+			MediaInfo{Title: "Joker", Year: 2019, ReleaseType: "cam", VideoCodec: "h264", AudioCodec: "ac3", ReleaseGroup: "ETRG", Type: "movie"},
+		},
+		{
+			"Us.2019.720p.HDTS.XviD.MP3-STUTTERSHIT",
+			MediaInfo{Title: "Us", Year: 2019, Quality: "720p", ReleaseType: "telesync", VideoCodec: "xvid", AudioCodec: "mp3", ReleaseGroup: "STUTTERSHIT", Type: "movie"},
+		},
+		{
+			"Parasite.2019.DVDScr.XVID.AC3.HQ.Hive-CM8",
+			MediaInfo{Title: "Parasite", Year: 2019, ReleaseType: "screener", VideoCodec: "xvid", AudioCodec: "ac3", ReleaseGroup: "CM8", Type: "movie"},
+		},
+		{
+			"Alien.1979.Directors.Cut.DVDRip.XviD-AMiABLE.avi",
+			MediaInfo{Title: "Alien", Year: 1979, ReleaseType: "dvdrip", VideoCodec: "xvid", ReleaseGroup: "AMiABLE", Container: "avi", Type: "movie"},
+		},
+		{
+			"The.Irishman.2019.2160p.NF.WEB-DL.Atmos.DDP5.1.HDR.HEVC-SiGMA",
+			MediaInfo{Title: "The Irishman", Year: 2019, Quality: "2160p", ReleaseType: "webdl", VideoCodec: "h265", AudioCodec: "atmos", AudioQuality: "5.1", ReleaseGroup: "SiGMA", Type: "movie"},
+		},
+		{
+			"Torrent4.s01e01.cam",
+			MediaInfo{Title: "Torrent4", Season: 1, Episode: 1, ReleaseType: "cam", Type: "episode"},
+		},
+		{
+			"Torrent6.2018.screener",
+			MediaInfo{Title: "Torrent6", Year: 2018, ReleaseType: "screener", Type: "movie"},
+		},
+		{
+			"Torrent7.480p.screener",
+			MediaInfo{Title: "Torrent7", Quality: "480p", ReleaseType: "screener", Type: "movie"},
+		},
+		{
+			"Big Buck Bunny",
+			MediaInfo{Title: "Big Buck Bunny", Type: "movie"},
+		},
+	}
+
+	for _, test := range testMatrix {
+		t.Run(test.Name, func(t *testing.T) {
+			info := Parse(test.Name)
+			test.Expected.Raw = test.Name
+			if info != test.Expected {
+				t.Errorf("Parsed info does not match.\nExpected: %+v\nGot:      %+v", test.Expected, info)
+			}
+		})
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	if _, err := GetInfo(""); err == nil {
+		t.Error("Expected to have an error when parsing empty release name")
+	}
+
+	if _, err := GetInfo("..."); err == nil {
+		t.Error("Expected to have an error when no info can be found in release name")
+	}
+
+	info, err := GetInfo("Big.Buck.Bunny.720p", EPISODE)
+	if err != nil {
+		t.Errorf("Expected no error when parsing valid release name, got %s instead", err.Error())
+	}
+	if info.Type != "episode" {
+		t.Errorf("Expected media type to be forced to 'episode', got '%s' instead", info.Type)
+	}
+
+	info, _ = GetInfo("Show.S01E01.720p", MOVIE)
+	if info.Type != "movie" {
+		t.Errorf("Expected media type to be forced to 'movie', got '%s' instead", info.Type)
+	}
+}
+
+func TestIsSample(t *testing.T) {
+	testData := map[string]bool{
+		"release/movie.sample.mkv":                     true,
+		"release/Sample/movie.mkv":                     true,
+		"release/sample-movie.mkv":                     true,
+		"release/movie.mkv":                            false,
+		"Free.Samples.2012.720p/Free.Samples.2012.mkv": false,
+		"Samples/Season 1/Show.S01E01.mkv":             false,
+	}
+
+	for path, expected := range testData {
+		if result := IsSample(path); result != expected {
+			t.Errorf("Expected IsSample('%s') to be %t, got %t instead", path, expected, result)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Parse("The.Expanse.S02E05.720p.WEB-DL.DD5.1.H264-RARBG.mkv")
+	}
+}